@@ -0,0 +1,327 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+// Package config loads a struct from layered sources. Later layers override earlier ones:
+//
+//  1. struct defaults: the value passed to New and `flag:"default:…"` tags of zero fields
+//  2. config files: json, yaml or toml by extension, a top-level include key merges other files first
+//  3. environment variables: PREFIX_SERVER_PORT for Server.Port, or `flag:"env:…"`
+//  4. command-line flags: `flag:"name:…;short:…;usage:…"`, see flag.AddFlag
+//
+// Field names in files and environment variables come from the tag set by WithTag, json by default.
+package config
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	flagx "github.com/hopeio/gox/flag"
+	watchx "github.com/hopeio/gox/os/fs/watch"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/pflag"
+)
+
+type options struct {
+	files      []string
+	envPrefix  string
+	args       []string
+	tag        string
+	errHandler func(error)
+}
+
+type Option func(*options)
+
+// WithFiles sets the config files, later files override earlier ones.
+func WithFiles(files ...string) Option {
+	return func(o *options) {
+		o.files = append(o.files, files...)
+	}
+}
+
+// WithEnvPrefix enables environment variables named PREFIX_KEY_SUBKEY.
+// Without a prefix only fields with `flag:"env:…"` read the environment.
+func WithEnvPrefix(prefix string) Option {
+	return func(o *options) {
+		o.envPrefix = prefix
+	}
+}
+
+// WithArgs sets the command-line arguments without the program name, os.Args[1:] by default.
+// Pass an empty slice to disable flags.
+func WithArgs(args []string) Option {
+	return func(o *options) {
+		if args == nil {
+			args = []string{}
+		}
+		o.args = args
+	}
+}
+
+// WithTag sets the tag naming the fields in files and environment variables.
+func WithTag(tag string) Option {
+	return func(o *options) {
+		o.tag = tag
+	}
+}
+
+// WithErrHandler sets the handler of reload errors, the previous config is kept on errors.
+func WithErrHandler(errHandler func(error)) Option {
+	return func(o *options) {
+		o.errHandler = errHandler
+	}
+}
+
+// Loader loads T from the configured sources and keeps the latest value.
+type Loader[T any] struct {
+	options
+	defaults  *T
+	value     atomic.Pointer[T]
+	sources   atomic.Pointer[Sources]
+	mu        sync.Mutex // 串行化 Reload 与回调
+	files     []string
+	callbacks []func(old, new *T, changed []string)
+	watch     *watchx.Watch
+}
+
+// New creates a new instance, defaults may be nil.
+func New[T any](defaults *T, opts ...Option) *Loader[T] {
+	l := &Loader[T]{defaults: defaults}
+	for _, opt := range opts {
+		opt(&l.options)
+	}
+	if l.tag == "" {
+		l.tag = "json"
+	}
+	if l.args == nil {
+		l.args = os.Args[1:]
+	}
+	return l
+}
+
+// Load loads the config from every source.
+func (l *Loader[T]) Load() (*T, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	v, sources, files, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	l.store(v, sources, files)
+	return v, l.watchFiles()
+}
+
+// Get returns the latest loaded config, nil before Load. The value must not be modified.
+func (l *Loader[T]) Get() *T {
+	return l.value.Load()
+}
+
+// Sources returns the source of every field of the latest loaded config. The map must not be modified.
+func (l *Loader[T]) Sources() Sources {
+	if s := l.sources.Load(); s != nil {
+		return *s
+	}
+	return nil
+}
+
+// OnChange registers a callback called after a reload changed any field.
+// changed lists the paths of the changed fields.
+func (l *Loader[T]) OnChange(callback func(old, new *T, changed []string)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.callbacks = append(l.callbacks, callback)
+}
+
+// Reload loads the config again, keeping the previous one on errors.
+func (l *Loader[T]) Reload() error {
+	l.mu.Lock()
+	v, sources, files, err := l.load()
+	if err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	old := l.value.Load()
+	l.store(v, sources, files)
+	if err = l.watchFiles(); err != nil || old == nil {
+		l.mu.Unlock()
+		return err
+	}
+	changed := diff(l.tag, old, v)
+	callbacks := slices.Clone(l.callbacks)
+	// 回调在锁外执行, 回调中可以再调用 Reload, OnChange 等
+	l.mu.Unlock()
+	if len(changed) > 0 {
+		for _, callback := range callbacks {
+			callback(old, v, changed)
+		}
+	}
+	return nil
+}
+
+func (l *Loader[T]) store(v *T, sources Sources, files []string) {
+	l.value.Store(v)
+	l.sources.Store(&sources)
+	l.files = files
+}
+
+// load builds a new value from every layer.
+func (l *Loader[T]) load() (*T, Sources, []string, error) {
+	v := new(T)
+	rv := reflect.ValueOf(v).Elem()
+	if rv.Kind() != reflect.Struct {
+		return nil, nil, nil, errors.New("config: T must be a struct")
+	}
+	if l.defaults != nil {
+		rv.Set(clone(reflect.ValueOf(l.defaults).Elem()))
+	}
+	sources := Sources{}
+
+	// defaults
+	_, err := walk(rv, l.tag, "", nil, func(f *field) (bool, error) {
+		sources[f.path] = Source{Kind: SourceDefault}
+		settings, err := tagSettings(f)
+		if err != nil || settings == nil || settings.Default == "" || !f.value.IsZero() {
+			return false, err
+		}
+		return true, setString(f, settings.Default)
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// files
+	var files []string
+	if len(l.options.files) > 0 {
+		layer, err := readFiles(l.options.files)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		files = layer.files
+		if err = l.decode(layer.data, v); err != nil {
+			return nil, nil, nil, err
+		}
+		_, err = walk(rv, l.tag, "", nil, func(f *field) (bool, error) {
+			if file, ok := layer.source(f.key()); ok {
+				sources[f.path] = Source{Kind: SourceFile, Name: file}
+			}
+			return false, nil
+		})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	// environment variables
+	_, err = walk(rv, l.tag, "", nil, func(f *field) (bool, error) {
+		settings, err := tagSettings(f)
+		if err != nil {
+			return false, err
+		}
+		var name string
+		if settings != nil && settings.Env != "" {
+			name = strings.ToUpper(settings.Env)
+		} else if l.envPrefix != "" {
+			name = f.env(l.envPrefix)
+		} else {
+			return false, nil
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return false, nil
+		}
+		sources[f.path] = Source{Kind: SourceEnv, Name: name}
+		return true, setString(f, value)
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// command-line flags
+	if len(l.args) > 0 {
+		if err = l.parseFlags(rv, sources); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return v, sources, files, nil
+}
+
+// decode decodes the merged file content into v, fields missing in files keep their values.
+func (l *Loader[T]) decode(data map[string]any, v *T) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.TextUnmarshallerHookFunc(),
+		),
+		WeaklyTypedInput: true,
+		Squash:           true,
+		TagName:          l.tag,
+		Result:           v,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(data)
+}
+
+// parseFlags binds and parses the flags, the flag tags are the ones read by flag.AddFlag.
+func (l *Loader[T]) parseFlags(rv reflect.Value, sources Sources) error {
+	paths := map[string]string{}
+	_, err := walk(rv, l.tag, "", nil, func(f *field) (bool, error) {
+		settings, err := tagSettings(f)
+		if err == nil && settings != nil && settings.Name != "" {
+			paths[settings.Name] = f.path
+		}
+		return false, err
+	})
+	if err != nil {
+		return err
+	}
+
+	commandLine := pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
+	commandLine.ParseErrorsAllowlist.UnknownFlags = true
+	if err = flagx.AddFlag(commandLine, rv.Addr().Interface()); err != nil {
+		return err
+	}
+	if err = commandLine.Parse(l.args); err != nil {
+		return err
+	}
+	commandLine.Visit(func(f *pflag.Flag) {
+		if path, ok := paths[f.Name]; ok {
+			sources[path] = Source{Kind: SourceFlag, Name: f.Name}
+		}
+	})
+	return nil
+}
+
+// tagSettings parses the flag tag of the field, nil if absent.
+func tagSettings(f *field) (*flagx.TagSettings, error) {
+	tag := f.sf.Tag.Get("flag")
+	if tag == "" {
+		return nil, nil
+	}
+	return flagx.ParseTag(tag)
+}
+
+// diff returns the paths of the fields that differ.
+func diff[T any](tag string, old, new *T) []string {
+	values := map[string]any{}
+	walk(reflect.ValueOf(old).Elem(), tag, "", nil, func(f *field) (bool, error) {
+		values[f.path] = f.value.Interface()
+		return false, nil
+	})
+	var changed []string
+	walk(reflect.ValueOf(new).Elem(), tag, "", nil, func(f *field) (bool, error) {
+		if !reflect.DeepEqual(values[f.path], f.value.Interface()) {
+			changed = append(changed, f.path)
+		}
+		return false, nil
+	})
+	return changed
+}
@@ -0,0 +1,256 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ */
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type testBase struct {
+	Name string `json:"name"`
+}
+
+type testServer struct {
+	Host    string        `json:"host"`
+	Port    int           `json:"port" flag:"name:port;usage:listen port"`
+	Timeout time.Duration `json:"timeout"`
+}
+
+type testTLS struct {
+	Cert string `json:"cert"`
+}
+
+type testConfig struct {
+	testBase
+	Debug   bool              `json:"debug" flag:"name:debug;env:TEST_CONFIG_DEBUG"`
+	Level   string            `json:"level" flag:"default:info"`
+	Server  testServer        `json:"server"`
+	TLS     *testTLS          `json:"tls"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels"`
+	Started time.Time         `json:"started"`
+	Ignored string            `json:"-"`
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "base.yaml", `
+name: base
+server:
+  host: localhost
+  port: 80
+  timeout: 5s
+labels:
+  a: "1"
+`)
+	writeFile(t, dir, "tls.json", `{"tls": {"cert": "c.pem"}}`)
+	main := writeFile(t, dir, "main.toml", `
+include = ["base.yaml", "tls.json"]
+tags = ["x", "y"]
+started = 2024-01-02T03:04:05Z
+
+[server]
+port = 8080
+
+[labels]
+b = "2"
+`)
+	t.Setenv("APP_SERVER_HOST", "0.0.0.0")
+	t.Setenv("TEST_CONFIG_DEBUG", "true")
+
+	defaults := &testConfig{Server: testServer{Timeout: time.Second}, Labels: map[string]string{"d": "0"}}
+	loader := New(defaults,
+		WithFiles(main),
+		WithEnvPrefix("app"),
+		WithArgs([]string{"--port=9090", "--unknown=1"}),
+	)
+	got, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &testConfig{
+		testBase: testBase{Name: "base"},
+		Debug:    true,
+		Level:    "info",
+		Server:   testServer{Host: "0.0.0.0", Port: 9090, Timeout: 5 * time.Second},
+		TLS:      &testTLS{Cert: "c.pem"},
+		Tags:     []string{"x", "y"},
+		Labels:   map[string]string{"a": "1", "b": "2", "d": "0"},
+		Started:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if len(defaults.Labels) != 1 {
+		t.Errorf("defaults modified: %v", defaults.Labels)
+	}
+
+	base, tls := filepath.Join(dir, "base.yaml"), filepath.Join(dir, "tls.json")
+	wantSources := map[string]string{
+		"Name":           "file:" + base,
+		"Debug":          "env:TEST_CONFIG_DEBUG",
+		"Level":          "default",
+		"Server.Host":    "env:APP_SERVER_HOST",
+		"Server.Port":    "flag:port",
+		"Server.Timeout": "file:" + base,
+		"TLS.Cert":       "file:" + tls,
+		"Tags":           "file:" + main,
+		"Labels":         "file:" + main,
+		"Started":        "file:" + main,
+	}
+	sources := loader.Sources()
+	for path, want := range wantSources {
+		if got := sources[path].String(); got != want {
+			t.Errorf("source of %s: got %s, want %s", path, got, want)
+		}
+	}
+	if _, ok := sources["Ignored"]; ok {
+		t.Error("ignored field has a source")
+	}
+}
+
+func TestIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.json", `{"include": "b.json"}`)
+	writeFile(t, dir, "b.json", `{"include": ["a.json"]}`)
+	_, err := New[testConfig](nil, WithFiles(a), WithArgs(nil)).Load()
+	if err == nil {
+		t.Fatal("expected include cycle error")
+	}
+}
+
+func TestReloadOnChange(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "c.json", `{"level": "debug", "server": {"port": 1}}`)
+	loader := New[testConfig](nil, WithFiles(path), WithArgs(nil))
+	if _, err := loader.Load(); err != nil {
+		t.Fatal(err)
+	}
+	var changed []string
+	loader.OnChange(func(old, new *testConfig, c []string) {
+		if old.Server.Port != 1 || new.Server.Port != 2 {
+			t.Errorf("got ports %d -> %d", old.Server.Port, new.Server.Port)
+		}
+		changed = c
+	})
+
+	writeFile(t, dir, "c.json", `{"level": "debug", "server": {"port": 2}, "tags": ["a"]}`)
+	if err := loader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(changed)
+	if want := []string{"Server.Port", "Tags"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("got changed %v, want %v", changed, want)
+	}
+	if loader.Get().Server.Port != 2 {
+		t.Errorf("got port %d", loader.Get().Server.Port)
+	}
+
+	writeFile(t, dir, "c.json", `{"server": `)
+	if err := loader.Reload(); err == nil {
+		t.Fatal("expected error for invalid file")
+	}
+	if loader.Get().Server.Port != 2 {
+		t.Error("previous config should be kept on errors")
+	}
+}
+
+func TestReloadInCallback(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "c.json", `{"level": "a"}`)
+	loader := New[testConfig](nil, WithFiles(path), WithArgs(nil))
+	if _, err := loader.Load(); err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	loader.OnChange(func(old, new *testConfig, changed []string) {
+		calls++
+		// 回调持锁时会死锁
+		loader.OnChange(func(old, new *testConfig, changed []string) {})
+		if err := loader.Reload(); err != nil {
+			t.Error(err)
+		}
+	})
+	writeFile(t, dir, "c.json", `{"level": "b"}`)
+	done := make(chan error, 1)
+	go func() { done <- loader.Reload() }()
+	select {
+	case err := <-done:
+		if err != nil || calls != 1 || loader.Get().Level != "b" {
+			t.Errorf("got %v after %d calls", err, calls)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reload in the callback deadlocked")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "c.yaml", "level: a\n")
+	loader := New[testConfig](nil, WithFiles(path), WithArgs(nil))
+	if _, err := loader.Load(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan string, 1)
+	loader.OnChange(func(old, new *testConfig, changed []string) {
+		done <- new.Level
+	})
+	if err := loader.Watch(); err != nil {
+		t.Fatal(err)
+	}
+	defer loader.Close()
+
+	writeFile(t, dir, "c.yaml", "level: b\n")
+	select {
+	case level := <-done:
+		if level != "b" {
+			t.Errorf("got level %s", level)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after the file changed")
+	}
+}
+
+func TestWatchClose(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "c.yaml", "level: a\n")
+	loader := New[testConfig](nil, WithFiles(path), WithArgs(nil))
+	if _, err := loader.Load(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := false
+	loader.OnChange(func(old, new *testConfig, changed []string) {
+		reloaded = true
+	})
+	if err := loader.Watch(); err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 与 Close 竞争的事件不应解引用已释放的监听器
+	writeFile(t, dir, "c.yaml", "level: b\n")
+	loader.onEvent(fsnotify.Event{Name: path, Op: fsnotify.Rename})
+	if reloaded {
+		t.Error("events after Close should be dropped")
+	}
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package config
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+
+	"github.com/hopeio/gox/kvstruct"
+)
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// field is a leaf field of the config struct.
+type field struct {
+	// path is the dotted Go field path, embedded structs are squashed
+	path string
+	// keys are the names used by config files and environment variables
+	keys  []string
+	value reflect.Value
+	sf    *reflect.StructField
+}
+
+// key returns the lowercased dotted config key.
+func (f *field) key() string {
+	return strings.ToLower(strings.Join(f.keys, "."))
+}
+
+// env returns the environment variable name under prefix.
+func (f *field) env(prefix string) string {
+	name := strings.ToUpper(strings.Join(f.keys, "_"))
+	name = strings.NewReplacer("-", "_", ".", "_").Replace(name)
+	if prefix == "" {
+		return name
+	}
+	return strings.ToUpper(prefix) + "_" + name
+}
+
+// walk visits the leaf fields of the struct v, visit reports whether it set the field.
+// Nil struct pointers are only allocated when a field under them is set.
+func walk(v reflect.Value, tag, path string, keys []string, visit func(*field) (bool, error)) (bool, error) {
+	var set bool
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() && !(sf.Anonymous && sf.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fv := v.Field(i)
+		ft := sf.Type
+		isPtr := ft.Kind() == reflect.Pointer
+		if isPtr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct || isLeaf(ft) {
+			fieldSet, err := visit(&field{path: join(path, sf.Name), keys: append(keys[:len(keys):len(keys)], name), value: fv, sf: &sf})
+			if err != nil {
+				return set, err
+			}
+			set = set || fieldSet
+			continue
+		}
+
+		subPath, subKeys := join(path, sf.Name), append(keys[:len(keys):len(keys)], name)
+		if sf.Anonymous && !isPtr {
+			// 与 mapstructure 的 Squash 一致,嵌入结构体的字段提升到上层
+			subPath, subKeys = path, keys
+		}
+		if isPtr && fv.IsNil() {
+			tmp := reflect.New(ft)
+			fieldSet, err := walk(tmp.Elem(), tag, subPath, subKeys, visit)
+			if err != nil {
+				return set, err
+			}
+			if fieldSet {
+				fv.Set(tmp)
+				set = true
+			}
+			continue
+		}
+		if isPtr {
+			fv = fv.Elem()
+		}
+		fieldSet, err := walk(fv, tag, subPath, subKeys, visit)
+		if err != nil {
+			return set, err
+		}
+		set = set || fieldSet
+	}
+	return set, nil
+}
+
+// isLeaf reports whether a struct type is set as a single value, like time.Time.
+func isLeaf(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// join joins a field path.
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// setString parses s into the field value, allocating pointers.
+func setString(f *field, s string) error {
+	v := f.value
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		if err := kvstruct.ParseStringSetReflectValue(elem.Elem(), s, f.sf); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	return kvstruct.ParseStringSetReflectValue(v, s, f.sf)
+}
+
+// clone returns a deep copy of v so layers never write into shared maps, slices or pointers.
+func clone(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(clone(v.Elem()))
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), clone(iter.Value()))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			c.Index(i).Set(clone(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := range v.Len() {
+			c.Index(i).Set(clone(v.Index(i)))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				c.Field(i).Set(clone(v.Field(i)))
+			}
+		}
+		return c
+	}
+	return v
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hopeio/gox/encoding"
+	"gopkg.in/yaml.v3"
+)
+
+// IncludeKey is the top-level key listing the files a config file includes.
+// Included files are merged first, so the including file overrides them.
+const IncludeKey = "include"
+
+// fileLayer is the merged content of config files.
+type fileLayer struct {
+	data map[string]any
+	// origin maps lowercased dotted keys to the file that set them
+	origin map[string]string
+	// files in merge order
+	files []string
+}
+
+// readFiles reads and merges the files in order, later files override earlier ones.
+func readFiles(paths []string) (*fileLayer, error) {
+	layer := &fileLayer{data: map[string]any{}, origin: map[string]string{}}
+	for _, path := range paths {
+		if err := layer.read(filepath.Clean(path), map[string]bool{}); err != nil {
+			return nil, err
+		}
+	}
+	return layer, nil
+}
+
+// read merges a file and its includes into the layer.
+func (l *fileLayer) read(path string, reading map[string]bool) error {
+	if reading[path] {
+		return fmt.Errorf("config: include cycle at %s", path)
+	}
+	reading[path] = true
+	defer delete(reading, path)
+
+	data, err := unmarshalFile(path)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range data {
+		if !strings.EqualFold(key, IncludeKey) {
+			continue
+		}
+		delete(data, key)
+		includes, err := includePaths(value)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", path, err)
+		}
+		for _, include := range includes {
+			if !filepath.IsAbs(include) {
+				include = filepath.Join(filepath.Dir(path), include)
+			}
+			if err = l.read(filepath.Clean(include), reading); err != nil {
+				return err
+			}
+		}
+	}
+
+	// files 按合并顺序记录,包含的文件在前
+	l.files = append(l.files, path)
+	merge(l.data, data)
+	flatten(data, "", func(key string) {
+		l.origin[key] = path
+	})
+	return nil
+}
+
+// unmarshalFile decodes a file by its extension.
+func unmarshalFile(path string) (map[string]any, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	data := map[string]any{}
+	switch encoding.Format(strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))) {
+	case encoding.Json:
+		err = json.Unmarshal(content, &data)
+	case encoding.Yaml, encoding.Yml:
+		err = yaml.Unmarshal(content, &data)
+	case encoding.Toml:
+		err = toml.Unmarshal(content, &data)
+	default:
+		return nil, fmt.Errorf("config: unsupported file format %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// includePaths accepts a string or a list of strings.
+func includePaths(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []any:
+		paths := make([]string, 0, len(v))
+		for _, p := range v {
+			s, ok := p.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s value %v", IncludeKey, p)
+			}
+			paths = append(paths, s)
+		}
+		return paths, nil
+	case []string:
+		return v, nil
+	}
+	return nil, fmt.Errorf("invalid %s value %v", IncludeKey, value)
+}
+
+// merge deep merges src into dst, keys are matched case-insensitively like the struct decoding.
+func merge(dst, src map[string]any) {
+	for key, value := range src {
+		existing := key
+		for k := range dst {
+			if strings.EqualFold(k, key) {
+				existing = k
+				break
+			}
+		}
+		if sm, ok := value.(map[string]any); ok {
+			if dm, ok := dst[existing].(map[string]any); ok {
+				merge(dm, sm)
+				continue
+			}
+			dm := map[string]any{}
+			merge(dm, sm)
+			value = dm
+		}
+		if existing != key {
+			delete(dst, existing)
+		}
+		dst[key] = value
+	}
+}
+
+// flatten calls fn with the lowercased dotted path of every leaf.
+func flatten(data map[string]any, prefix string, fn func(key string)) {
+	for key, value := range data {
+		key = prefix + strings.ToLower(key)
+		if m, ok := value.(map[string]any); ok {
+			flatten(m, key+".", fn)
+			continue
+		}
+		fn(key)
+	}
+}
+
+// source returns the file that set the field with the dotted key, or any of its children.
+func (l *fileLayer) source(key string) (string, bool) {
+	if file, ok := l.origin[key]; ok {
+		return file, true
+	}
+	var file string
+	for k, f := range l.origin {
+		if strings.HasPrefix(k, key+".") {
+			// 多个文件都设置了子键时取最后合并的文件
+			if file == "" || slices.Index(l.files, f) > slices.Index(l.files, file) {
+				file = f
+			}
+		}
+	}
+	return file, file != ""
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package config
+
+type SourceKind uint8
+
+const (
+	SourceDefault SourceKind = iota
+	SourceFile
+	SourceEnv
+	SourceFlag
+)
+
+// String returns the string representation.
+func (k SourceKind) String() string {
+	switch k {
+	case SourceFile:
+		return "file"
+	case SourceEnv:
+		return "env"
+	case SourceFlag:
+		return "flag"
+	}
+	return "default"
+}
+
+// Source tells where the value of a field came from.
+type Source struct {
+	Kind SourceKind
+	// Name is the file path, the environment variable or the flag name, empty for defaults
+	Name string
+}
+
+// String returns the string representation.
+func (s Source) String() string {
+	if s.Name == "" {
+		return s.Kind.String()
+	}
+	return s.Kind.String() + ":" + s.Name
+}
+
+// Sources maps field paths like "Server.Port" to the source of their value.
+type Sources map[string]Source
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package config
+
+import (
+	"github.com/fsnotify/fsnotify"
+	watchx "github.com/hopeio/gox/os/fs/watch"
+)
+
+// Watch reloads the config when any loaded file, includes too, changes.
+// Callbacks registered by OnChange are called after each reload that changed a field.
+func (l *Loader[T]) Watch(opts ...watchx.Option) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.watch != nil {
+		return nil
+	}
+	if l.errHandler != nil {
+		opts = append([]watchx.Option{watchx.WithErrHandler(l.errHandler)}, opts...)
+	}
+	watch, err := watchx.New(opts...)
+	if err != nil {
+		return err
+	}
+	l.watch = watch
+	return l.watchFiles()
+}
+
+// watchFiles adds the loaded files to the watcher, new includes are picked up after reloads.
+func (l *Loader[T]) watchFiles() error {
+	if l.watch == nil {
+		return nil
+	}
+	for _, file := range l.files {
+		if err := l.watch.Add(file, l.onEvent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onEvent reloads the config on file events.
+func (l *Loader[T]) onEvent(event fsnotify.Event) {
+	l.mu.Lock()
+	// Close 之后到达的事件直接丢弃
+	if l.watch == nil {
+		l.mu.Unlock()
+		return
+	}
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		// 编辑器常以重命名替换文件,原文件的监听随之失效,需要重新添加
+		if err := l.watch.Watcher.Add(event.Name); err != nil {
+			l.mu.Unlock()
+			l.handleErr(err)
+			return
+		}
+	}
+	l.mu.Unlock()
+	if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+		return
+	}
+	l.handleErr(l.Reload())
+}
+
+func (l *Loader[T]) handleErr(err error) {
+	if err != nil && l.errHandler != nil {
+		l.errHandler(err)
+	}
+}
+
+// Close stops watching files.
+func (l *Loader[T]) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.watch == nil {
+		return nil
+	}
+	err := l.watch.Close()
+	l.watch = nil
+	return err
+}
@@ -19,6 +19,7 @@ package consistenthash
 
 import (
 	"hash/crc32"
+	"slices"
 	"sort"
 	"strconv"
 )
@@ -80,3 +81,23 @@ func (m *Map) Get(key string) string {
 
 	return m.hashMap[m.keys[idx]]
 }
+
+// GetN gets up to n distinct items walking the hash clockwise from the provided key,
+// the items after the first are the fallbacks when the first is unavailable.
+func (m *Map) GetN(key string, n int) []string {
+	if m.IsEmpty() || n <= 0 {
+		return nil
+	}
+
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool { return m.keys[i] >= hash })
+
+	var items []string
+	for i := 0; i < len(m.keys) && len(items) < n; i++ {
+		item := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if !slices.Contains(items, item) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -108,3 +108,26 @@ func benchmarkGet(b *testing.B, shards int) {
 		hash.Get(buckets[i&(shards-1)])
 	}
 }
+
+func TestGetN(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, err := strconv.Atoi(string(key))
+		if err != nil {
+			panic(err)
+		}
+		return uint32(i)
+	})
+
+	// 2, 4, 6, 12, 14, 16, 22, 24, 26
+	hash.Add("6", "4", "2")
+
+	if got := fmt.Sprint(hash.GetN("23", 3)); got != "[4 6 2]" {
+		t.Errorf("GetN(23, 3) = %s", got)
+	}
+	if got := fmt.Sprint(hash.GetN("27", 5)); got != "[2 4 6]" {
+		t.Errorf("GetN(27, 5) = %s", got)
+	}
+	if got := hash.GetN("27", 1); got[0] != hash.Get("27") {
+		t.Errorf("GetN(27, 1) = %v", got)
+	}
+}
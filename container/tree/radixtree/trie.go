@@ -445,6 +445,12 @@ walk: // Outer loop for walking the tree
 	}
 }
 
+// FindCaseInsensitivePath makes a case-insensitive lookup of the given path, it can optionally
+// also fix trailing slashes. It returns the case-corrected path and whether the lookup was successful.
+func (n *Node[T]) FindCaseInsensitivePath(path string, fixTrailingSlash bool) (fixedPath string, found bool) {
+	return n.findCaseInsensitivePath(path, "", fixTrailingSlash)
+}
+
 // Makes a case-insensitive lookup of the given path and tries to find a handler.
 // It can optionally also fix trailing slashes.
 // It returns the case-corrected path and a bool indicating whether the lookup
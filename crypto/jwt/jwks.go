@@ -0,0 +1,299 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+)
+
+// JWK is a JSON Web Key, RFC 7517, of the kty RSA, EC, OKP (Ed25519) or oct.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	K   string `json:"k,omitempty"`
+}
+
+// NewJWK returns the JWK of a public key, or of a []byte secret. Private keys are reduced to their public key.
+func NewJWK(key any, kid, alg string) (*JWK, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		key = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		key = &k.PublicKey
+	case ed25519.PrivateKey:
+		key = k.Public()
+	}
+	jwk := &JWK{Kid: kid, Alg: alg}
+	enc := base64.RawURLEncoding.EncodeToString
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty, jwk.N, jwk.E = "RSA", enc(k.N.Bytes()), enc(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		// 非压缩点 0x04||X||Y
+		point, err := k.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		size := (len(point) - 1) / 2
+		jwk.Kty, jwk.Crv, jwk.X, jwk.Y = "EC", k.Curve.Params().Name, enc(point[1:1+size]), enc(point[1+size:])
+	case ed25519.PublicKey:
+		jwk.Kty, jwk.Crv, jwk.X = "OKP", "Ed25519", enc(k)
+	case []byte:
+		jwk.Kty, jwk.K = "oct", enc(k)
+	default:
+		return nil, fmt.Errorf("jwt: unsupported key type %T", key)
+	}
+	return jwk, nil
+}
+
+// Key returns the key for KeyFunc.
+func (k *JWK) Key() (any, error) {
+	dec := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := dec(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := dec(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("jwt: invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("jwt: unsupported curve %s", k.Crv)
+		}
+		x, err := dec(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := dec(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		// 同时校验点在曲线上
+		return ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("jwt: unsupported curve %s", k.Crv)
+		}
+		x, err := dec(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("jwt: invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		return dec(k.K)
+	}
+	return nil, fmt.Errorf("jwt: unsupported key type %s", k.Kty)
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// Lookup returns the key of the token header: the key with the kid, or the only key when the header has no kid.
+func (s *JWKS) Lookup(header *Header) (any, error) {
+	var found *JWK
+	for _, k := range s.Keys {
+		if (header.Kid == "" && len(s.Keys) == 1) || (header.Kid != "" && k.Kid == header.Kid) {
+			found = k
+			break
+		}
+	}
+	if found == nil || (found.Use != "" && found.Use != "sig") {
+		return nil, fmt.Errorf("%w: kid %q", ErrKeyNotFound, header.Kid)
+	}
+	if found.Alg != "" && found.Alg != header.Alg {
+		return nil, fmt.Errorf("%w: key %q is for %s", ErrAlgorithm, found.Kid, found.Alg)
+	}
+	return found.Key()
+}
+
+// KeyFunc returns a KeyFunc looking up the keys of the set.
+func (s *JWKS) KeyFunc() KeyFunc {
+	return func(_ context.Context, header *Header) (any, error) {
+		return s.Lookup(header)
+	}
+}
+
+// RemoteJWKS fetches and caches the key set of URL. The set is refetched once RefreshInterval elapsed, or when a
+// token refers to an unknown kid, so rotated keys are picked up. Use KeyFunc as the KeyFunc of Parse.
+// Fetches run without holding the lock, a stale set keeps being used while it is refetched.
+type RemoteJWKS struct {
+	URL    string
+	Client *http.Client
+	// Timeout of a fetch, 0 means DefaultJWKSTimeout
+	Timeout time.Duration
+	// RefreshInterval 缓存时长
+	RefreshInterval time.Duration
+	// MinRefreshInterval 未知 kid 触发刷新的最小间隔, 防止伪造的 kid 压垮 JWKS 服务
+	MinRefreshInterval time.Duration
+	mu                 sync.Mutex
+	set                *JWKS
+	fetched            time.Time
+	attempted          time.Time
+	// refreshing is closed when the running fetch is done
+	refreshing chan struct{}
+	refreshErr error
+}
+
+// DefaultJWKSTimeout bounds fetching a key set.
+const DefaultJWKSTimeout = 10 * time.Second
+
+// NewRemoteJWKS creates and returns a new instance.
+func NewRemoteJWKS(url string) *RemoteJWKS {
+	return &RemoteJWKS{URL: url, Client: http.DefaultClient, Timeout: DefaultJWKSTimeout, RefreshInterval: time.Hour, MinRefreshInterval: time.Minute}
+}
+
+// KeyFunc looks up the key of header, refetching the set when it is stale or does not have the key.
+func (r *RemoteJWKS) KeyFunc(ctx context.Context, header *Header) (any, error) {
+	r.mu.Lock()
+	set := r.set
+	if set != nil && time.Since(r.fetched) >= r.RefreshInterval && r.refreshing == nil {
+		r.startRefresh(ctx)
+	}
+	r.mu.Unlock()
+	if set == nil {
+		var err error
+		if set, err = r.await(ctx, true); set == nil {
+			return nil, err
+		}
+	}
+	key, err := set.Lookup(header)
+	if errors.Is(err, ErrKeyNotFound) {
+		fresh, refreshErr := r.await(ctx, false)
+		if refreshErr != nil {
+			return nil, refreshErr
+		}
+		if fresh != set {
+			key, err = fresh.Lookup(header)
+		}
+	}
+	return key, err
+}
+
+// Refresh refetches the set.
+func (r *RemoteJWKS) Refresh(ctx context.Context) error {
+	_, err := r.await(ctx, true)
+	return err
+}
+
+// await waits for the running fetch and returns the set. A fetch is started when none runs and force is set or
+// MinRefreshInterval elapsed since the last one, otherwise the cached set is returned.
+func (r *RemoteJWKS) await(ctx context.Context, force bool) (*JWKS, error) {
+	r.mu.Lock()
+	if r.refreshing == nil {
+		if !force && time.Since(r.attempted) < r.MinRefreshInterval {
+			set := r.set
+			r.mu.Unlock()
+			return set, nil
+		}
+		r.startRefresh(ctx)
+	}
+	refreshing := r.refreshing
+	r.mu.Unlock()
+	select {
+	case <-refreshing:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.set, r.refreshErr
+}
+
+// startRefresh fetches the set in a goroutine, the cached set is kept on failure. r.mu must be held.
+func (r *RemoteJWKS) startRefresh(ctx context.Context) {
+	done := make(chan struct{})
+	r.refreshing = done
+	r.attempted = time.Now()
+	go func() {
+		set, err := r.fetch(context.WithoutCancel(ctx))
+		r.mu.Lock()
+		r.refreshing = nil
+		r.refreshErr = err
+		if err == nil {
+			r.set, r.fetched = set, time.Now()
+		}
+		r.mu.Unlock()
+		close(done)
+	}()
+}
+
+// fetch requests the set.
+func (r *RemoteJWKS) fetch(ctx context.Context) (*JWKS, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultJWKSTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/jwk-set+json, application/json")
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwt: fetch %s: %s", r.URL, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	var set JWKS
+	if err = jsonx.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwt: decode %s: %w", r.URL, err)
+	}
+	return &set, nil
+}
@@ -0,0 +1,407 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+)
+
+// Signing algorithms, RFC 7518 3.1 and RFC 8037.
+const (
+	HS256 = "HS256"
+	HS384 = "HS384"
+	HS512 = "HS512"
+	RS256 = "RS256"
+	RS384 = "RS384"
+	RS512 = "RS512"
+	PS256 = "PS256"
+	PS384 = "PS384"
+	PS512 = "PS512"
+	ES256 = "ES256"
+	ES384 = "ES384"
+	ES512 = "ES512"
+	EdDSA = "EdDSA"
+)
+
+var (
+	ErrMalformed   = errors.New("jwt: malformed token")
+	ErrAlgorithm   = errors.New("jwt: unexpected signing algorithm")
+	ErrKeyType     = errors.New("jwt: key does not match the signing algorithm")
+	ErrKeyNotFound = errors.New("jwt: key not found")
+	ErrSignature   = errors.New("jwt: invalid signature")
+	ErrExpired     = errors.New("jwt: token is expired")
+	ErrNotValidYet = errors.New("jwt: token is not valid yet")
+	ErrIssuer      = errors.New("jwt: invalid issuer")
+	ErrAudience    = errors.New("jwt: invalid audience")
+)
+
+// Header is the JOSE header of a token.
+type Header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// NumericDate is the seconds since the epoch, fractional values are truncated.
+type NumericDate int64
+
+// NewNumericDate returns the NumericDate of t.
+func NewNumericDate(t time.Time) NumericDate {
+	return NumericDate(t.Unix())
+}
+
+// Time returns the time of the date.
+func (d NumericDate) Time() time.Time {
+	return time.Unix(int64(d), 0)
+}
+
+// UnmarshalJSON decodes the value from JSON.
+func (d *NumericDate) UnmarshalJSON(data []byte) error {
+	f, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("jwt: invalid numeric date %s", data)
+	}
+	*d = NumericDate(f)
+	return nil
+}
+
+// Audience is a single string or an array of strings.
+type Audience []string
+
+// MarshalJSON encodes a single audience as a string.
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return jsonx.Marshal(a[0])
+	}
+	return jsonx.Marshal([]string(a))
+}
+
+// UnmarshalJSON decodes the value from JSON.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := jsonx.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*a = Audience{s}
+		return nil
+	}
+	return jsonx.Unmarshal(data, (*[]string)(a))
+}
+
+// RegisteredClaims are the claims of RFC 7519 4.1, custom claims embed it.
+type RegisteredClaims struct {
+	Issuer    string      `json:"iss,omitempty"`
+	Subject   string      `json:"sub,omitempty"`
+	Audience  Audience    `json:"aud,omitempty"`
+	ExpiresAt NumericDate `json:"exp,omitempty"`
+	NotBefore NumericDate `json:"nbf,omitempty"`
+	IssuedAt  NumericDate `json:"iat,omitempty"`
+	ID        string      `json:"jti,omitempty"`
+}
+
+// Options of Parse.
+type Options struct {
+	// Algorithms 允许的签名算法, 为空时只校验算法与密钥类型匹配
+	Algorithms []string
+	// Issuers 为空时不校验 iss
+	Issuers []string
+	// Audience 为空时不校验 aud
+	Audience string
+	// Leeway tolerates the clock skew of exp and nbf
+	Leeway time.Duration
+	// RequireExpiration rejects tokens without exp
+	RequireExpiration bool
+	// Now nil 时为 time.Now
+	Now func() time.Time
+}
+
+// Validate checks exp, nbf, iss and aud by options.
+func (c *RegisteredClaims) Validate(options *Options) error {
+	if options == nil {
+		options = &Options{}
+	}
+	now := time.Now()
+	if options.Now != nil {
+		now = options.Now()
+	}
+	if c.ExpiresAt != 0 {
+		if !now.Before(c.ExpiresAt.Time().Add(options.Leeway)) {
+			return ErrExpired
+		}
+	} else if options.RequireExpiration {
+		return fmt.Errorf("%w: missing exp", ErrExpired)
+	}
+	if c.NotBefore != 0 && now.Add(options.Leeway).Before(c.NotBefore.Time()) {
+		return ErrNotValidYet
+	}
+	if len(options.Issuers) > 0 && !slices.Contains(options.Issuers, c.Issuer) {
+		return ErrIssuer
+	}
+	if options.Audience != "" && !slices.Contains(c.Audience, options.Audience) {
+		return ErrAudience
+	}
+	return nil
+}
+
+// KeyFunc returns the verification key of a token: []byte for HS, *rsa.PublicKey for RS and PS,
+// *ecdsa.PublicKey for ES and ed25519.PublicKey for EdDSA. Private keys are accepted as well.
+type KeyFunc func(ctx context.Context, header *Header) (any, error)
+
+// StaticKey returns a KeyFunc always returning key.
+func StaticKey(key any) KeyFunc {
+	return func(context.Context, *Header) (any, error) {
+		return key, nil
+	}
+}
+
+// Sign encodes claims as a token signed by key, which is []byte for HS, *rsa.PrivateKey for RS and PS,
+// *ecdsa.PrivateKey for ES and ed25519.PrivateKey for EdDSA. kid is omitted when empty.
+func Sign(claims any, alg, kid string, key any) (string, error) {
+	header, err := jsonx.Marshal(&Header{Alg: alg, Typ: "JWT", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := jsonx.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := sign(alg, key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Parse verifies token with the key returned by keyFunc, decodes the payload into claims and validates
+// the registered claims by options. claims may be nil, it usually embeds RegisteredClaims.
+func Parse(ctx context.Context, token string, claims any, keyFunc KeyFunc, options *Options) (*Header, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	var header Header
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg == "" || strings.EqualFold(header.Alg, "none") ||
+		(len(options.Algorithms) > 0 && !slices.Contains(options.Algorithms, header.Alg)) {
+		return &header, fmt.Errorf("%w: %s", ErrAlgorithm, header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return &header, ErrMalformed
+	}
+	key, err := keyFunc(ctx, &header)
+	if err != nil {
+		return &header, err
+	}
+	if err = verify(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return &header, err
+	}
+	var registered RegisteredClaims
+	if err = decodeSegment(parts[1], &registered); err != nil {
+		return &header, err
+	}
+	if claims != nil {
+		if err = decodeSegment(parts[1], claims); err != nil {
+			return &header, err
+		}
+	}
+	return &header, registered.Validate(options)
+}
+
+// decodeSegment decodes a base64url JSON segment into v.
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return ErrMalformed
+	}
+	if err = jsonx.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return nil
+}
+
+// hashOf returns the hash of the algorithm.
+func hashOf(alg string) (crypto.Hash, error) {
+	if len(alg) != 5 {
+		return 0, fmt.Errorf("%w: %s", ErrAlgorithm, alg)
+	}
+	switch alg[2:] {
+	case "256":
+		return crypto.SHA256, nil
+	case "384":
+		return crypto.SHA384, nil
+	case "512":
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("%w: %s", ErrAlgorithm, alg)
+}
+
+// curveOf returns the curve ES algorithms require.
+func curveOf(alg string) elliptic.Curve {
+	switch alg {
+	case ES256:
+		return elliptic.P256()
+	case ES384:
+		return elliptic.P384()
+	case ES512:
+		return elliptic.P521()
+	}
+	return nil
+}
+
+// sign returns the signature of input.
+func sign(alg string, key any, input []byte) ([]byte, error) {
+	if alg == EdDSA {
+		k, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, ErrKeyType
+		}
+		return ed25519.Sign(k, input), nil
+	}
+	hash, err := hashOf(alg)
+	if err != nil {
+		return nil, err
+	}
+	switch alg[:2] {
+	case "HS":
+		k, ok := key.([]byte)
+		if !ok {
+			return nil, ErrKeyType
+		}
+		mac := hmac.New(hash.New, k)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	}
+	h := hash.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+	switch alg[:2] {
+	case "RS", "PS":
+		k, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, ErrKeyType
+		}
+		if alg[0] == 'P' {
+			return rsa.SignPSS(rand.Reader, k, hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+	case "ES":
+		k, ok := key.(*ecdsa.PrivateKey)
+		if !ok || k.Curve != curveOf(alg) {
+			return nil, ErrKeyType
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			return nil, err
+		}
+		// 定长 r||s, RFC 7518 3.4
+		size := (k.Curve.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+		return sig, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrAlgorithm, alg)
+}
+
+// verify checks the signature of input.
+func verify(alg string, key any, input, sig []byte) error {
+	// 私钥也可用于验证
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		key = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		key = &k.PublicKey
+	case ed25519.PrivateKey:
+		key = k.Public()
+	}
+	if alg == EdDSA {
+		k, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return ErrKeyType
+		}
+		if !ed25519.Verify(k, input, sig) {
+			return ErrSignature
+		}
+		return nil
+	}
+	hash, err := hashOf(alg)
+	if err != nil {
+		return err
+	}
+	if alg[:2] == "HS" {
+		k, ok := key.([]byte)
+		if !ok {
+			return ErrKeyType
+		}
+		mac := hmac.New(hash.New, k)
+		mac.Write(input)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return ErrSignature
+		}
+		return nil
+	}
+	h := hash.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+	switch alg[:2] {
+	case "RS", "PS":
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrKeyType
+		}
+		if alg[0] == 'P' {
+			err = rsa.VerifyPSS(k, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			err = rsa.VerifyPKCS1v15(k, hash, digest, sig)
+		}
+		if err != nil {
+			return ErrSignature
+		}
+		return nil
+	case "ES":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok || k.Curve != curveOf(alg) {
+			return ErrKeyType
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return ErrSignature
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return ErrSignature
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrAlgorithm, alg)
+}
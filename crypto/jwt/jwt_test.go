@@ -0,0 +1,164 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package jwt_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hopeio/gox/crypto/jwt"
+	"github.com/hopeio/gox/crypto/jwt/jwttest"
+	jsonx "github.com/hopeio/gox/encoding/json"
+)
+
+type userClaims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role"`
+}
+
+func TestSignParse(t *testing.T) {
+	algs := []string{jwt.HS256, jwt.HS512, jwt.RS256, jwt.PS384, jwt.ES256, jwt.ES384, jwt.ES512, jwt.EdDSA}
+	for _, alg := range algs {
+		t.Run(alg, func(t *testing.T) {
+			issuer := jwttest.NewIssuer(t, alg)
+			token := issuer.Mint(&userClaims{RegisteredClaims: issuer.Claims("bob", time.Minute, "api"), Role: "admin"})
+			var claims userClaims
+			options := &jwt.Options{Algorithms: []string{alg}, Issuers: []string{"jwttest"}, Audience: "api"}
+			header, err := jwt.Parse(t.Context(), token, &claims, issuer.JWKS().KeyFunc(), options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.Kid != issuer.Kid() || claims.Subject != "bob" || claims.Role != "admin" {
+				t.Errorf("got %+v %+v", header, claims)
+			}
+
+			parts := strings.Split(token, ".")
+			tampered := parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2]))
+			if _, err = jwt.Parse(t.Context(), tampered, nil, issuer.JWKS().KeyFunc(), options); !errors.Is(err, jwt.ErrSignature) {
+				t.Errorf("tampered: %v", err)
+			}
+		})
+	}
+}
+
+func TestParse_Claims(t *testing.T) {
+	issuer := jwttest.NewIssuer(t, jwt.ES256)
+	keys := issuer.JWKS().KeyFunc()
+	now := time.Now()
+	parse := func(claims jwt.RegisteredClaims, options *jwt.Options) error {
+		_, err := jwt.Parse(t.Context(), issuer.Mint(claims), nil, keys, options)
+		return err
+	}
+	base := issuer.Claims("bob", time.Minute, "api")
+
+	expired := base
+	expired.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Second))
+	if err := parse(expired, nil); !errors.Is(err, jwt.ErrExpired) {
+		t.Errorf("expired: %v", err)
+	}
+	if err := parse(expired, &jwt.Options{Leeway: time.Minute}); err != nil {
+		t.Errorf("leeway: %v", err)
+	}
+	future := base
+	future.NotBefore = jwt.NewNumericDate(now.Add(time.Hour))
+	if err := parse(future, nil); !errors.Is(err, jwt.ErrNotValidYet) {
+		t.Errorf("nbf: %v", err)
+	}
+	if err := parse(base, &jwt.Options{Issuers: []string{"other"}}); !errors.Is(err, jwt.ErrIssuer) {
+		t.Errorf("iss: %v", err)
+	}
+	if err := parse(base, &jwt.Options{Audience: "web"}); !errors.Is(err, jwt.ErrAudience) {
+		t.Errorf("aud: %v", err)
+	}
+	noExp := base
+	noExp.ExpiresAt = 0
+	if err := parse(noExp, &jwt.Options{RequireExpiration: true}); !errors.Is(err, jwt.ErrExpired) {
+		t.Errorf("require exp: %v", err)
+	}
+	if err := parse(base, &jwt.Options{Algorithms: []string{jwt.RS256}}); !errors.Is(err, jwt.ErrAlgorithm) {
+		t.Errorf("algorithms: %v", err)
+	}
+
+	// HS 令牌不能用公钥 JWK 的字节验证
+	hs, _ := jwt.Sign(base, jwt.HS256, issuer.Kid(), []byte("secret"))
+	if _, err := jwt.Parse(t.Context(), hs, nil, keys, nil); err == nil {
+		t.Error("algorithm confusion accepted")
+	}
+	if _, err := jwt.Parse(t.Context(), "e30.e30.", nil, keys, nil); !errors.Is(err, jwt.ErrAlgorithm) {
+		t.Errorf("alg none: %v", err)
+	}
+}
+
+func TestRemoteJWKS(t *testing.T) {
+	issuer := jwttest.NewIssuer(t, jwt.RS256)
+	jwks := jwt.NewRemoteJWKS(issuer.URL())
+	jwks.MinRefreshInterval = 0
+	claims := issuer.Claims("bob", time.Minute)
+	if _, err := jwt.Parse(t.Context(), issuer.Mint(claims), nil, jwks.KeyFunc, nil); err != nil {
+		t.Fatal(err)
+	}
+	// 轮换后未知 kid 触发重新拉取
+	issuer.Rotate()
+	if _, err := jwt.Parse(t.Context(), issuer.Mint(claims), nil, jwks.KeyFunc, nil); err != nil {
+		t.Fatal(err)
+	}
+	key, _ := jwttest.GenerateKey(jwt.RS256)
+	token, _ := jwt.Sign(claims, jwt.RS256, "unknown", key)
+	if _, err := jwt.Parse(t.Context(), token, nil, jwks.KeyFunc, nil); !errors.Is(err, jwt.ErrKeyNotFound) {
+		t.Errorf("unknown kid: %v", err)
+	}
+}
+
+func TestRemoteJWKS_SlowEndpoint(t *testing.T) {
+	issuer := jwttest.NewIssuer(t, jwt.ES256)
+	var slow atomic.Bool
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow.Load() {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		data, _ := jsonx.Marshal(issuer.JWKS())
+		w.Write(data)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	jwks := jwt.NewRemoteJWKS(srv.URL)
+	jwks.Timeout = 200 * time.Millisecond
+	token := issuer.Mint(issuer.Claims("bob", time.Minute))
+	if _, err := jwt.Parse(t.Context(), token, nil, jwks.KeyFunc, nil); err != nil {
+		t.Fatal(err)
+	}
+	// 过期的集合在后台刷新, 慢的 JWKS 服务不阻塞已知 kid 的验证
+	slow.Store(true)
+	jwks.RefreshInterval = 0
+	start := time.Now()
+	for range 3 {
+		if _, err := jwt.Parse(t.Context(), token, nil, jwks.KeyFunc, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("known kid waited %s for the refresh", elapsed)
+	}
+
+	cold := jwt.NewRemoteJWKS(srv.URL)
+	cold.Timeout = 50 * time.Millisecond
+	if _, err := jwt.Parse(t.Context(), token, nil, cold.KeyFunc, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("fetch timeout: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+// Package jwttest mints tokens and serves key sets for tests.
+package jwttest
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hopeio/gox/crypto/jwt"
+	jsonx "github.com/hopeio/gox/encoding/json"
+)
+
+// Issuer signs tokens with a generated key and serves the JWKS of its current and previous keys.
+type Issuer struct {
+	Alg string
+	// Name is the iss of the tokens minted by Claims
+	Name string
+	tb   testing.TB
+	mu   sync.Mutex
+	kid  string
+	key  any
+	set  jwt.JWKS
+	// Server serves the JWKS at its root, it is closed by the cleanup of the test
+	Server *httptest.Server
+}
+
+// NewIssuer creates and returns a new instance signing with alg.
+func NewIssuer(tb testing.TB, alg string) *Issuer {
+	tb.Helper()
+	i := &Issuer{Alg: alg, Name: "jwttest", tb: tb}
+	i.Rotate()
+	i.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i.mu.Lock()
+		data, err := jsonx.Marshal(&i.set)
+		i.mu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.Write(data)
+	}))
+	tb.Cleanup(i.Server.Close)
+	return i
+}
+
+// URL returns the JWKS URL.
+func (i *Issuer) URL() string {
+	return i.Server.URL
+}
+
+// Kid returns the kid of the current key.
+func (i *Issuer) Kid() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.kid
+}
+
+// JWKS returns a copy of the served key set.
+func (i *Issuer) JWKS() *jwt.JWKS {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return &jwt.JWKS{Keys: append([]*jwt.JWK(nil), i.set.Keys...)}
+}
+
+// Rotate generates a new current key, the previous keys stay in the JWKS.
+func (i *Issuer) Rotate() {
+	i.tb.Helper()
+	key, err := GenerateKey(i.Alg)
+	if err != nil {
+		i.tb.Fatal(err)
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	kid := "key-" + strconv.Itoa(len(i.set.Keys)+1)
+	jwk, err := jwt.NewJWK(key, kid, i.Alg)
+	if err != nil {
+		i.tb.Fatal(err)
+	}
+	jwk.Use = "sig"
+	i.kid, i.key = kid, key
+	i.set.Keys = append(i.set.Keys, jwk)
+}
+
+// Mint signs claims with the current key.
+func (i *Issuer) Mint(claims any) string {
+	i.tb.Helper()
+	i.mu.Lock()
+	kid, key := i.kid, i.key
+	i.mu.Unlock()
+	token, err := jwt.Sign(claims, i.Alg, kid, key)
+	if err != nil {
+		i.tb.Fatal(err)
+	}
+	return token
+}
+
+// Claims returns the registered claims of subject issued now by the issuer, valid for ttl.
+func (i *Issuer) Claims(subject string, ttl time.Duration, audience ...string) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Issuer:    i.Name,
+		Subject:   subject,
+		Audience:  audience,
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+}
+
+// GenerateKey returns a new signing key of alg.
+func GenerateKey(alg string) (any, error) {
+	switch alg {
+	case jwt.HS256, jwt.HS384, jwt.HS512:
+		key := make([]byte, 64)
+		rand.Read(key)
+		return key, nil
+	case jwt.RS256, jwt.RS384, jwt.RS512, jwt.PS256, jwt.PS384, jwt.PS512:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.ES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwt.ES384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case jwt.ES512:
+		return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case jwt.EdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, jwt.ErrAlgorithm
+}
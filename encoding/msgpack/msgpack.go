@@ -8,6 +8,7 @@ package msgpack
 
 import (
 	"bytes"
+	"io"
 
 	"github.com/ugorji/go/codec"
 )
@@ -17,6 +18,21 @@ var handler = codec.MsgpackHandle{}
 // Marshal encodes the value.
 func Marshal(v any) ([]byte, error) {
 	r := bytes.NewBuffer(nil)
-	decoder := codec.NewEncoder(r, &handler)
-	return r.Bytes(), decoder.Encode(v)
+	err := codec.NewEncoder(r, &handler).Encode(v)
+	return r.Bytes(), err
+}
+
+// Unmarshal decodes data into v.
+func Unmarshal(data []byte, v any) error {
+	return codec.NewDecoderBytes(data, &handler).Decode(v)
+}
+
+// NewEncoder returns an encoder writing to w.
+func NewEncoder(w io.Writer) *codec.Encoder {
+	return codec.NewEncoder(w, &handler)
+}
+
+// NewDecoder returns a decoder reading from r.
+func NewDecoder(r io.Reader) *codec.Decoder {
+	return codec.NewDecoder(r, &handler)
 }
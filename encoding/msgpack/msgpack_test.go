@@ -19,8 +19,7 @@ func TestMarshal(t *testing.T) {
 	if err != nil {
 		t.Errorf("Marshal() error: %v", err)
 	}
-	// Note: current Marshal implementation returns bytes before encoding,
-	// so we verify using codec directly
+	// compare with codec directly
 	handler := codec.MsgpackHandle{}
 	buf := bytes.NewBuffer(nil)
 	enc := codec.NewEncoder(buf, &handler)
@@ -30,6 +29,25 @@ func TestMarshal(t *testing.T) {
 	if buf.Len() == 0 {
 		t.Error("encoded buffer should not be empty")
 	}
+	b, _ := Marshal([]int{1, 2})
+	if !bytes.Equal(b, []byte{0x92, 0x01, 0x02}) {
+		t.Errorf("Marshal() = %x", b)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	type item struct {
+		Name string
+		N    int
+	}
+	b, err := Marshal(item{Name: "a", N: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got item
+	if err = Unmarshal(b, &got); err != nil || got != (item{Name: "a", N: 1}) {
+		t.Errorf("Unmarshal() = %+v, %v", got, err)
+	}
 }
 
 func TestMarshal_String(t *testing.T) {
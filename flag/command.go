@@ -0,0 +1,303 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package flag
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/hopeio/gox/kvstruct"
+	"github.com/hopeio/gox/structtag"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	cmdTagName = "cmd"
+	argTagName = "arg"
+)
+
+// Runner is implemented by command structs, Run is called after flags and arguments are bound.
+type Runner interface {
+	Run(cmd *cobra.Command, args []string) error
+}
+
+// commandTagSettings of a subcommand field, e.g. `cmd:"name:serve;usage:start the server;aliases:s,srv"`.
+type commandTagSettings struct {
+	Name    string `meta:"name"`
+	Usage   string `meta:"usage"`
+	Long    string `meta:"long"`
+	Aliases string `meta:"aliases"`
+	Hidden  bool   `meta:"hidden"`
+}
+
+// argTagSettings of a positional argument field, e.g. `arg:"name:file;usage:input file;required"`.
+type argTagSettings struct {
+	Name     string `meta:"name"`
+	Usage    string `meta:"usage"`
+	Enum     string `meta:"enum"`
+	Required bool   `meta:"required"`
+}
+
+type positional struct {
+	argTagSettings
+	enum     []string
+	variadic bool
+	value    reflect.Value
+	field    reflect.StructField
+}
+
+// isCommandOrArg reports whether the field is a subcommand or a positional argument rather than flags.
+func isCommandOrArg(field *reflect.StructField) bool {
+	if _, ok := field.Tag.Lookup(cmdTagName); ok {
+		return true
+	}
+	_, ok := field.Tag.Lookup(argTagName)
+	return ok
+}
+
+/*
+NewCommand builds a cobra command from the struct v points to.
+
+	type Serve struct {
+		Port int    `flag:"name:port;short:p;usage:listen port;required"`
+		Mode string `flag:"name:mode;enum:dev,prod;usage:run mode"`
+		Dir  string `arg:"name:dir;usage:directory to serve;required"`
+	}
+
+	type CLI struct {
+		Verbose bool  `flag:"name:verbose;short:v;persistent"`
+		Serve   Serve `cmd:"name:serve;usage:start the server"`
+	}
+
+Fields with a cmd tag become subcommands, fields with an arg tag are positional arguments in field order,
+a slice argument must be the last one and takes the rest. Other fields are flags as in AddFlag;
+required flags are marked required and enum values are checked and used for shell completion.
+Commands whose struct implements Runner run it, the others print help.
+*/
+func NewCommand(use string, v any) (*cobra.Command, error) {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Pointer || ptr.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("flag: NewCommand requires a pointer to struct")
+	}
+	cmd := &cobra.Command{Use: use}
+	if err := buildCommand(cmd, ptr); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// buildCommand adds the flags, arguments and subcommands of the struct ptr points to.
+func buildCommand(cmd *cobra.Command, ptr reflect.Value) error {
+	err := addFlags(cmd.Flags(), cmd.PersistentFlags(), ptr.Elem(), func(flag *pflag.Flag, settings *TagSettings) error {
+		if settings.Required {
+			var err error
+			if settings.Persistent {
+				err = cmd.MarkPersistentFlagRequired(flag.Name)
+			} else {
+				err = cmd.MarkFlagRequired(flag.Name)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if enum := settings.enum(); enum != nil {
+			return cmd.RegisterFlagCompletionFunc(flag.Name, cobra.FixedCompletions(enum, cobra.ShellCompDirectiveNoFileComp))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	v := ptr.Elem()
+	t := v.Type()
+	var args []*positional
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if tag, ok := field.Tag.Lookup(cmdTagName); ok {
+			sub, err := subcommand(&field, tag, v.Field(i))
+			if err != nil {
+				return fmt.Errorf("flag: field %s: %w", field.Name, err)
+			}
+			cmd.AddCommand(sub)
+			continue
+		}
+		if tag, ok := field.Tag.Lookup(argTagName); ok {
+			arg, err := newPositional(&field, tag, v.Field(i))
+			if err != nil {
+				return fmt.Errorf("flag: field %s: %w", field.Name, err)
+			}
+			args = append(args, arg)
+		}
+	}
+	if err = setArgs(cmd, args); err != nil {
+		return err
+	}
+
+	if runner, ok := ptr.Interface().(Runner); ok {
+		cmd.RunE = func(cmd *cobra.Command, values []string) error {
+			if err := bindArgs(args, values); err != nil {
+				return err
+			}
+			return runner.Run(cmd, values)
+		}
+	}
+	return nil
+}
+
+// subcommand builds the command of a struct field.
+func subcommand(field *reflect.StructField, tag string, fv reflect.Value) (*cobra.Command, error) {
+	var settings commandTagSettings
+	if tag != "" {
+		if err := structtag.ParseSettingTagIntoStruct(tag, ";", ":", &settings); err != nil {
+			return nil, err
+		}
+	}
+	var ptr reflect.Value
+	switch {
+	case fv.Kind() == reflect.Struct:
+		ptr = fv.Addr()
+	case fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Struct:
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		ptr = fv
+	default:
+		return nil, errors.New("command field must be a struct or a pointer to struct")
+	}
+	if settings.Name == "" {
+		settings.Name = strings.ToLower(field.Name)
+	}
+	cmd := &cobra.Command{
+		Use:    settings.Name,
+		Short:  settings.Usage,
+		Long:   settings.Long,
+		Hidden: settings.Hidden,
+	}
+	if settings.Aliases != "" {
+		cmd.Aliases = strings.Split(settings.Aliases, ",")
+	}
+	return cmd, buildCommand(cmd, ptr)
+}
+
+// newPositional parses the arg tag of a field.
+func newPositional(field *reflect.StructField, tag string, fv reflect.Value) (*positional, error) {
+	arg := &positional{value: fv, field: *field}
+	if tag != "" {
+		if err := structtag.ParseSettingTagIntoStruct(tag, ";", ":", &arg.argTagSettings); err != nil {
+			return nil, err
+		}
+	}
+	if arg.Name == "" {
+		arg.Name = strings.ToLower(field.Name)
+	}
+	if arg.Enum != "" {
+		arg.enum = strings.Split(arg.Enum, ",")
+	}
+	arg.variadic = fv.Kind() == reflect.Slice
+	return arg, nil
+}
+
+// setArgs sets the usage line, the argument validation and completion of cmd.
+func setArgs(cmd *cobra.Command, args []*positional) error {
+	if len(args) == 0 {
+		return nil
+	}
+	var min int
+	var usage strings.Builder
+	for i, arg := range args {
+		if arg.variadic && i != len(args)-1 {
+			return fmt.Errorf("flag: argument %s: only the last argument can be a slice", arg.Name)
+		}
+		if arg.Required {
+			if min != i {
+				return fmt.Errorf("flag: argument %s: required arguments must come before optional ones", arg.Name)
+			}
+			min++
+		}
+		switch {
+		case arg.variadic && arg.Required:
+			cmd.Use += " <" + arg.Name + ">..."
+		case arg.variadic:
+			cmd.Use += " [" + arg.Name + "...]"
+		case arg.Required:
+			cmd.Use += " <" + arg.Name + ">"
+		default:
+			cmd.Use += " [" + arg.Name + "]"
+		}
+		if arg.Usage != "" {
+			fmt.Fprintf(&usage, "\n  %-12s %s", arg.Name, arg.Usage)
+		}
+	}
+	if usage.Len() > 0 {
+		long := cmd.Long
+		if long == "" {
+			long = cmd.Short
+		}
+		cmd.Long = strings.TrimSpace(long + "\n\nArguments:" + usage.String())
+	}
+
+	last := args[len(args)-1]
+	cmd.Args = func(cmd *cobra.Command, values []string) error {
+		if len(values) < min {
+			return fmt.Errorf("requires at least %d arg(s), only received %d", min, len(values))
+		}
+		if !last.variadic && len(values) > len(args) {
+			return fmt.Errorf("accepts at most %d arg(s), received %d", len(args), len(values))
+		}
+		for i, value := range values {
+			if arg := argAt(args, i); arg.enum != nil && !slices.Contains(arg.enum, value) {
+				return fmt.Errorf("invalid argument %q for %s, must be one of %s", value, arg.Name, strings.Join(arg.enum, ", "))
+			}
+		}
+		return nil
+	}
+	cmd.ValidArgsFunction = func(cmd *cobra.Command, values []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		if !last.variadic && len(values) >= len(args) {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		if arg := argAt(args, len(values)); arg.enum != nil {
+			return arg.enum, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	return nil
+}
+
+// argAt returns the argument at position i, the last variadic one takes the rest.
+func argAt(args []*positional, i int) *positional {
+	if i >= len(args) {
+		return args[len(args)-1]
+	}
+	return args[i]
+}
+
+// bindArgs sets the argument fields.
+func bindArgs(args []*positional, values []string) error {
+	for i, arg := range args {
+		if i >= len(values) {
+			break
+		}
+		var err error
+		if arg.variadic {
+			err = kvstruct.ParseStringsSetReflectValue(arg.value, values[i:], &arg.field)
+		} else {
+			err = kvstruct.ParseStringSetReflectValue(arg.value, values[i], &arg.field)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid argument %q for %s: %w", values[i], arg.Name, err)
+		}
+	}
+	return nil
+}
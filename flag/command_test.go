@@ -0,0 +1,102 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ */
+
+package flag
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+type testServe struct {
+	Port  int      `flag:"name:port;short:p;usage:listen port;required"`
+	Mode  string   `flag:"name:mode;enum:dev,prod;usage:run mode"`
+	Dir   string   `arg:"name:dir;usage:directory to serve;required"`
+	Files []string `arg:"name:file;enum:a,b"`
+	ran   bool
+}
+
+// Run runs the command.
+func (s *testServe) Run(cmd *cobra.Command, args []string) error {
+	s.ran = true
+	return nil
+}
+
+type testCLI struct {
+	Verbose bool       `flag:"name:verbose;short:v;persistent"`
+	Serve   testServe  `cmd:"name:serve;usage:start the server;aliases:s"`
+	Tool    *testServe `cmd:"hidden"`
+}
+
+func execute(t *testing.T, cli *testCLI, args ...string) (string, error) {
+	t.Helper()
+	cmd, err := NewCommand("cli", cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(args)
+	err = cmd.Execute()
+	return out.String(), err
+}
+
+func TestNewCommand(t *testing.T) {
+	var cli testCLI
+	if _, err := execute(t, &cli, "-v", "s", "--port=80", "--mode", "prod", "www", "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	want := testServe{Port: 80, Mode: "prod", Dir: "www", Files: []string{"a", "b"}, ran: true}
+	if !cli.Verbose || !reflect.DeepEqual(cli.Serve, want) {
+		t.Errorf("got %+v", cli)
+	}
+	if cli.Tool == nil || cli.Tool.ran {
+		t.Errorf("tool command should be allocated and not run")
+	}
+}
+
+func TestNewCommandErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"serve", "www"}, `required flag(s) "port" not set`},
+		{[]string{"serve", "-p", "1"}, "requires at least 1 arg(s)"},
+		{[]string{"serve", "-p", "1", "--mode", "test", "www"}, "must be one of dev, prod"},
+		{[]string{"serve", "-p", "1", "www", "c"}, `invalid argument "c" for file`},
+	}
+	for _, tt := range tests {
+		var cli testCLI
+		_, err := execute(t, &cli, tt.args...)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: got error %v, want %q", tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestNewCommandHelp(t *testing.T) {
+	var cli testCLI
+	out, err := execute(t, &cli, "serve", "--help")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"serve <dir> [file...]", "directory to serve", "listen port", "--verbose"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("help misses %q:\n%s", want, out)
+		}
+	}
+
+	cmd, _ := NewCommand("cli", &testCLI{})
+	serve, _, _ := cmd.Find([]string{"serve"})
+	completions, _ := serve.ValidArgsFunction(serve, []string{"www"}, "")
+	if !reflect.DeepEqual(completions, []cobra.Completion{"a", "b"}) {
+		t.Errorf("got completions %v", completions)
+	}
+}
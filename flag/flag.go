@@ -2,8 +2,10 @@ package flag
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/hopeio/gox/kvstruct"
@@ -15,12 +17,35 @@ import (
 
 const flagTagName = "flag"
 
-type flagTagSettings struct {
+// TagSettings holds the settings of a flag tag, e.g. `flag:"name:port;short:p;env:PORT;default:80;usage:listen port"`.
+type TagSettings struct {
 	Name    string `meta:"name"`
 	Short   string `meta:"short"`
 	Env     string `meta:"env" comment:"read from environment variable"`
 	Default string `meta:"default"`
 	Usage   string `meta:"usage"`
+	// Enum lists the allowed values separated by ",", also used for shell completion
+	Enum string `meta:"enum"`
+	// Required and Persistent are used by commands built with NewCommand
+	Required   bool `meta:"required"`
+	Persistent bool `meta:"persistent"`
+}
+
+// ParseTag parses the settings of a flag tag.
+func ParseTag(tag string) (*TagSettings, error) {
+	var settings TagSettings
+	if err := structtag.ParseSettingTagIntoStruct(tag, ";", ":", &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// enum splits the Enum setting.
+func (s *TagSettings) enum() []string {
+	if s.Enum == "" {
+		return nil
+	}
+	return strings.Split(s.Enum, ",")
 }
 
 type anyValue reflect.Value
@@ -40,6 +65,20 @@ func (a anyValue) Set(v string) error {
 	return kvstruct.ParseStringSetReflectValue(reflect.Value(a), v, nil)
 }
 
+// enumValue only accepts the listed values.
+type enumValue struct {
+	pflag.Value
+	enum []string
+}
+
+// Set updates or inserts a value.
+func (e *enumValue) Set(v string) error {
+	if !slices.Contains(e.enum, v) {
+		return fmt.Errorf("must be one of %s", strings.Join(e.enum, ", "))
+	}
+	return e.Value.Set(v)
+}
+
 // Bind performs the operation.
 func Bind(args []string, v any) error {
 	commandLine := pflag.NewFlagSet(args[0], pflag.ContinueOnError)
@@ -62,10 +101,15 @@ func AddFlag(commandLine *pflag.FlagSet, v any) error {
 
 // AddFlagByReflectValue updates or inserts a value.
 func AddFlagByReflectValue(commandLine *pflag.FlagSet, fcValue reflect.Value) error {
+	return addFlags(commandLine, commandLine, fcValue, nil)
+}
+
+// addFlags adds the flags of fcValue, persistent ones go to persistentFlags; added is called for each new flag.
+func addFlags(commandLine, persistentFlags *pflag.FlagSet, fcValue reflect.Value, added func(*pflag.Flag, *TagSettings) error) error {
 	fcTyp := fcValue.Type()
 	for i := range fcTyp.NumField() {
 		fieldType := fcTyp.Field(i)
-		if !fieldType.IsExported() {
+		if !fieldType.IsExported() || isCommandOrArg(&fieldType) {
 			continue
 		}
 		flagTag := fieldType.Tag.Get(flagTagName)
@@ -79,8 +123,7 @@ func AddFlagByReflectValue(commandLine *pflag.FlagSet, fcValue reflect.Value) er
 			}
 		}
 		if flagTag != "" {
-			var flagTagSettings flagTagSettings
-			err := structtag.ParseSettingTagIntoStruct(flagTag, ";", ":", &flagTagSettings)
+			flagTagSettings, err := ParseTag(flagTag)
 			if err != nil {
 				return err
 			}
@@ -95,13 +138,26 @@ func AddFlagByReflectValue(commandLine *pflag.FlagSet, fcValue reflect.Value) er
 			}
 			if flagTagSettings.Name != "" {
 				// Set from flags
-				flag := commandLine.VarPF(anyValue(fieldValue), flagTagSettings.Name, flagTagSettings.Short, flagTagSettings.Usage)
+				var value pflag.Value = anyValue(fieldValue)
+				if flagTagSettings.Enum != "" {
+					value = &enumValue{Value: value, enum: flagTagSettings.enum()}
+				}
+				flagSet := commandLine
+				if flagTagSettings.Persistent {
+					flagSet = persistentFlags
+				}
+				flag := flagSet.VarPF(value, flagTagSettings.Name, flagTagSettings.Short, flagTagSettings.Usage)
 				if kind == reflect.Bool {
 					flag.NoOptDefVal = "true"
 				}
+				if added != nil {
+					if err = added(flag, flagTagSettings); err != nil {
+						return err
+					}
+				}
 			}
 		} else if kind == reflect.Struct {
-			err := AddFlagByReflectValue(commandLine, fieldValue)
+			err := addFlags(commandLine, persistentFlags, fieldValue, added)
 			if err != nil {
 				return err
 			}
@@ -3,6 +3,7 @@ module github.com/hopeio/gox
 go 1.27
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/agiledragon/gomonkey/v2 v2.14.2
 	github.com/andybalholm/brotli v1.2.2
 	github.com/boombuler/barcode v1.1.0
@@ -44,6 +45,7 @@ require (
 	golang.org/x/time v0.15.0
 	golang.org/x/tools v0.48.0
 	google.golang.org/protobuf v1.36.11
+	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/gen v0.3.28
 	gorm.io/gorm v1.31.2
 	modernc.org/cc/v3 v3.41.0
@@ -92,7 +94,6 @@ require (
 	golang.org/x/mod v0.38.0 // indirect
 	golang.org/x/sync v0.22.0 // indirect
 	golang.org/x/term v0.45.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	gorm.io/datatypes v1.2.7 // indirect
 	gorm.io/driver/mysql v1.6.0 // indirect
 	gorm.io/driver/postgres v1.6.2 // indirect
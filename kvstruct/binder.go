@@ -0,0 +1,94 @@
+package kvstruct
+
+import (
+	"encoding"
+	"strings"
+
+	"go.uber.org/multierr"
+)
+
+// KVDecoder is implemented by types with a generated binder,
+// DecodeKV follows the rules of Decoder.Decode without reflection.
+//
+//	//go:generate go run github.com/hopeio/gox/tools/kvgen --type=Query --tag=json
+type KVDecoder interface {
+	DecodeKV(src map[string][]string) error
+}
+
+// KVEncoder is implemented by types with a generated binder,
+// EncodeKV follows the rules of Encoder.Encode without reflection.
+type KVEncoder interface {
+	EncodeKV(dst map[string][]string) error
+}
+
+// KVMapper is implemented by types with a generated binder,
+// MappingKV follows the rules of Mapping without reflection.
+type KVMapper interface {
+	MappingKV(getter ValuesGetter) error
+}
+
+// KVTagger reports the tag a binder was generated for,
+// binders are skipped when it differs from the tag of the Decoder, Encoder or Mapping.
+type KVTagger interface {
+	KVTag() string
+}
+
+// matchKVTag reports whether the binder of v was generated for tag.
+func matchKVTag(v any, tag string) bool {
+	if t, ok := v.(KVTagger); ok {
+		return t.KVTag() == tag
+	}
+	return true
+}
+
+// dropUnknownKeyErrors removes UnknownKeyError from errs.
+func dropUnknownKeyErrors(errs error) error {
+	if errs == nil {
+		return nil
+	}
+	var kept error
+	for _, err := range multierr.Errors(errs) {
+		if _, ok := err.(UnknownKeyError); !ok {
+			kept = multierr.Append(kept, err)
+		}
+	}
+	return kept
+}
+
+// HasValue reports whether src holds a non-empty value for one of paths or a path nested under them,
+// it is used by generated binders to check required fields the same way as Decoder.
+func HasValue(src map[string][]string, scalar bool, paths ...string) bool {
+	for _, path := range paths {
+		if v, ok := src[path]; ok && !isEmptyValues(scalar, v) {
+			return true
+		}
+		for key, v := range src {
+			if !isEmptyValues(scalar, v) && strings.HasPrefix(key, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isEmptyValues returns true if value is empty, only the first value of scalar fields is checked.
+func isEmptyValues(scalar bool, value []string) bool {
+	if len(value) == 0 {
+		return true
+	}
+	return scalar && len(value[0]) == 0
+}
+
+// LastValue returns the last value, Decoder uses the last value for single-value fields.
+func LastValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// TextString returns the text of m, marshal errors are ignored as Encoder does.
+func TextString(m encoding.TextMarshaler) string {
+	text, _ := m.MarshalText()
+	return string(text)
+}
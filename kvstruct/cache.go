@@ -96,6 +96,33 @@ func (c *cache) parsePath(p string, t reflect.Type) ([]pathPart, error) {
 					t = t.Elem()
 				}
 			}
+		} else if field.isMap {
+			// i+1 must be the map key.
+			i++
+			if i+1 > len(keys) {
+				return nil, invalidPath
+			}
+			parts = append(parts, pathPart{
+				path:   path,
+				field:  field,
+				index:  -1,
+				mapKey: keys[i],
+			})
+			path = make([]string, 0)
+
+			t = indirectType(indirectType(field.typ).Elem())
+			if t.Kind() != reflect.Struct {
+				// Values which are not structs end the path.
+				if i+1 != len(keys) {
+					return nil, invalidPath
+				}
+				parts = append(parts, pathPart{
+					path:  path,
+					field: field,
+					index: -1,
+				})
+				return parts, nil
+			}
 		} else if field.typ.Kind() == reflect.Ptr {
 			t = field.typ.Elem()
 		} else {
@@ -181,7 +208,15 @@ func (c *cache) createField(field reflect.StructField, parentAlias string) *fiel
 			ft = ft.Elem()
 		}
 	}
-	if isStruct = ft.Kind() == reflect.Struct; !isStruct {
+	isMap := ft.Kind() == reflect.Map && !isSlice
+	if isMap {
+		if ft.Key().Kind() != reflect.String {
+			// Type is not supported.
+			return nil
+		}
+		ft = indirectType(ft.Elem())
+	}
+	if isStruct = ft.Kind() == reflect.Struct; !isStruct && !isMap {
 		if c.converter(ft) == nil && GetStringConverter(ft) == nil {
 			// Type is not supported.
 			return nil
@@ -195,8 +230,9 @@ func (c *cache) createField(field reflect.StructField, parentAlias string) *fiel
 		canonicalAlias:   canonicalAlias,
 		unmarshalerInfo:  m,
 		isSliceOfStructs: isSlice && isStruct,
+		isMap:            isMap,
 		isAnonymous:      field.Anonymous,
-		isRequired:       options.Required,
+		isRequired:       options != nil && options.Required,
 	}
 }
 
@@ -247,6 +283,9 @@ type fieldInfo struct {
 	unmarshalerInfo unmarshaler
 	// isSliceOfStructs indicates if the field type is a slice of structs.
 	isSliceOfStructs bool
+	// isMap indicates if the field type is a map with string keys,
+	// the path segment following the field is the map key.
+	isMap bool
 	// isAnonymous indicates whether the field is embedded in the struct.
 	isAnonymous bool
 	isRequired  bool
@@ -261,9 +300,10 @@ func (f *fieldInfo) paths(prefix string) []string {
 }
 
 type pathPart struct {
-	field *fieldInfo
-	path  []string // path to the field: walks structs using field names.
-	index int      // struct index in slices of structs.
+	field  *fieldInfo
+	path   []string // path to the field: walks structs using field names.
+	index  int      // struct index in slices of structs.
+	mapKey string   // key in maps, valid when field.isMap.
 }
 
 // ----------------------------------------------------------------------------
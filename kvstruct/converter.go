@@ -18,7 +18,11 @@ func (c StringConverterE) IgnoreError() StringConverter {
 		return nil
 	}
 	return func(value string) any {
-		r, _ := c(value)
+		r, err := c(value)
+		if err != nil {
+			// nil 表示转换失败,Decoder 据此返回 ConversionError
+			return nil
+		}
 		return r
 	}
 }
@@ -83,7 +87,7 @@ func GetStringSliceConverterByKind(kind reflect.Kind) func(value string) (any, e
 	if kind == reflect.String {
 		return stringConvertString
 	}
-	if kind > reflect.Uint64 {
+	if int(kind) >= len(stringConverterSliceArrays) {
 		return nil
 	}
 	return stringConverterSliceArrays[kind]
@@ -99,7 +103,7 @@ func GetStringConverterEByKind(kind reflect.Kind) StringConverterE {
 	if kind == reflect.String {
 		return stringConvertString
 	}
-	if kind > reflect.Uint64 {
+	if int(kind) >= len(stringConverterArrays) {
 		return nil
 	}
 	return stringConverterArrays[kind]
@@ -25,6 +25,7 @@ type Decoder struct {
 	cache             *cache
 	zeroEmpty         bool
 	ignoreUnknownKeys bool
+	validator         Validator
 }
 
 // SetAliasTag changes the Key used to locate custom field aliases.
@@ -57,6 +58,12 @@ func (d *Decoder) IgnoreUnknownKeys(i bool) {
 	d.ignoreUnknownKeys = i
 }
 
+// SetValidator sets the Validator run at the end of Decode.
+// A nil Validator disables validation, which is the default.
+func (d *Decoder) SetValidator(v Validator) {
+	d.validator = v
+}
+
 // RegisterConverter registers a converter function for a custom type.
 func (d *Decoder) RegisterConverter(value interface{}, converterFunc StringConverter) {
 	d.cache.registerConverter(value, converterFunc)
@@ -71,6 +78,13 @@ func (d *Decoder) RegisterConverter(value interface{}, converterFunc StringConve
 //
 // See the package documentation for a full explanation of the mechanics.
 func (d *Decoder) Decode(dst interface{}, src map[string][]string) error {
+	if b, ok := dst.(KVDecoder); ok && d.canBind(dst) {
+		errs := b.DecodeKV(src)
+		if d.ignoreUnknownKeys {
+			errs = dropUnknownKeyErrors(errs)
+		}
+		return d.validate(dst, errs)
+	}
 	v := reflect.ValueOf(dst)
 	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
 		return errors.New("schema: interface must be a pointer to struct")
@@ -88,9 +102,22 @@ func (d *Decoder) Decode(dst interface{}, src map[string][]string) error {
 		}
 	}
 	errs = multierr.Append(errs, d.checkRequired(t, src))
+	return d.validate(dst, errs)
+}
+
+// validate runs the Validator when decoding succeeded.
+func (d *Decoder) validate(dst any, errs error) error {
+	if errs == nil && d.validator != nil {
+		return d.validator.Validate(dst)
+	}
 	return errs
 }
 
+// canBind reports whether a generated DecodeKV matches the settings of the Decoder.
+func (d *Decoder) canBind(dst any) bool {
+	return !d.zeroEmpty && len(d.cache.regconv) == 0 && matchKVTag(dst, d.cache.tag)
+}
+
 // checkRequired checks whether required fields are empty
 //
 // check type t recursively if t has struct fields.
@@ -168,17 +195,14 @@ func isEmptyFields(fields []fieldWithPrefix, src map[string][]string) bool {
 
 // isEmpty returns true if value is empty for specific type
 func isEmpty(t reflect.Type, value []string) bool {
-	if len(value) == 0 {
-		return true
-	}
 	switch t.Kind() {
 	case reflect.Bool, reflect.String,
 		reflect.Float32, reflect.Float64,
 		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return len(value[0]) == 0
+		return isEmptyValues(true, value)
 	}
-	return false
+	return isEmptyValues(false, value)
 }
 
 // decode fills a struct field using a parsed path.
@@ -219,6 +243,23 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 		v = v.Elem()
 	}
 
+	// Map value. Decode into a copy, then store it back.
+	if len(parts) > 1 && parts[0].field.isMap && parts[0].index < 0 {
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		key := reflect.ValueOf(parts[0].mapKey).Convert(t.Key())
+		elem := reflect.New(t.Elem()).Elem()
+		if old := v.MapIndex(key); old.IsValid() {
+			elem.Set(old)
+		}
+		if err := d.decode(elem, path, parts[1:], values); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+	}
+
 	// Slice of structs. Let's go recursive.
 	if len(parts) > 1 {
 		idx := parts[0].index
@@ -328,11 +369,8 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 		value := reflect.Append(reflect.MakeSlice(t, 0, 0), items...)
 		v.Set(value)
 	} else {
-		val := ""
 		// Use the last value provided if any values were provided
-		if len(values) > 0 {
-			val = values[len(values)-1]
-		}
+		val := LastValue(values)
 
 		if conv != nil {
 			if value := reflect.ValueOf(conv(val)); value.IsValid() {
@@ -22,6 +22,12 @@ func IgnoreUnknownKeys(i bool) {
 	defaultDecoder.ignoreUnknownKeys = i
 }
 
+// SetValidator sets the Validator run at the end of Decode and Mapping.
+func SetValidator(v Validator) {
+	defaultDecoder.validator = v
+	mappingValidator = v
+}
+
 // RegisterConverter registers a converter function for a custom type.
 func RegisterConverter(value interface{}, converterFunc StringConverter) {
 	defaultDecoder.cache.registerConverter(value, converterFunc)
@@ -1,6 +1,7 @@
 package kvstruct
 
 import (
+	"encoding"
 	"errors"
 	"fmt"
 
@@ -12,6 +13,8 @@ import (
 
 type encoderFunc func(reflect.Value) string
 
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
 // Encoder encodes values from a struct into url.Values.
 type Encoder struct {
 	cache  *cache
@@ -27,9 +30,12 @@ func NewEncoder(tag string) *Encoder {
 //
 // Intended for use with url.Values.
 func (e *Encoder) Encode(src interface{}, dst map[string][]string) error {
+	if b, ok := src.(KVEncoder); ok && len(e.regenc) == 0 && matchKVTag(src, e.cache.tag) {
+		return b.EncodeKV(dst)
+	}
 	v := reflect.ValueOf(src)
 
-	return e.encode(v, dst)
+	return e.encode(v, "", dst)
 }
 
 // RegisterEncoder registers a converter for encoding a custom type.
@@ -43,11 +49,6 @@ func (e *Encoder) SetAliasTag(tag string) {
 	e.cache.tag = tag
 }
 
-// isValidStructPointer test if input value is a valid struct pointer.
-func isValidStructPointer(v reflect.Value) bool {
-	return v.Type().Kind() == reflect.Ptr && v.Elem().IsValid() && v.Elem().Type().Kind() == reflect.Struct
-}
-
 // isZero reports whether the condition holds.
 func isZero(v reflect.Value) bool {
 	switch v.Kind() {
@@ -79,8 +80,8 @@ func isZero(v reflect.Value) bool {
 	return v.Interface() == z.Interface()
 }
 
-// encode performs the operation.
-func (e *Encoder) encode(v reflect.Value, dst map[string][]string) error {
+// encode encodes the fields of a struct, prefix is the dotted path of the struct itself.
+func (e *Encoder) encode(v reflect.Value, prefix string, dst map[string][]string) error {
 	if v.Kind() == reflect.Ptr {
 		v = v.Elem()
 	}
@@ -92,56 +93,120 @@ func (e *Encoder) encode(v reflect.Value, dst map[string][]string) error {
 	var errs error
 
 	for i := 0; i < v.NumField(); i++ {
-		name, opts := fieldAlias(t.Field(i), e.cache.tag)
-		if name == "-" {
+		sf := t.Field(i)
+		if !sf.IsExported() && !sf.Anonymous {
 			continue
 		}
-
-		// Encode struct pointer types if the field is a valid pointer and a struct.
-		if isValidStructPointer(v.Field(i)) {
-			errs = multierr.Append(errs, e.encode(v.Field(i).Elem(), dst))
+		if !sf.Anonymous && !v.Field(i).CanInterface() {
+			// promoted from an unexported embedded struct, the decoder can't set it either
 			continue
 		}
-
-		encFunc := typeEncoder(v.Field(i).Type(), e.regenc)
-
-		// Encode non-slice types and custom implementations immediately.
-		if encFunc != nil {
-			value := encFunc(v.Field(i))
-			if opts.Omitempty && isZero(v.Field(i)) {
+		name, opts := fieldAlias(sf, e.cache.tag)
+		if name == "-" {
+			continue
+		}
+		omitempty := opts != nil && opts.Omitempty
+
+		// Fields of embedded structs are promoted, the same as the decoder resolves them.
+		if fv := v.Field(i); sf.Anonymous && !e.isScalar(indirectType(sf.Type)) {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				errs = multierr.Append(errs, e.encode(fv, prefix, dst))
 				continue
 			}
-
-			dst[name] = append(dst[name], value)
-			continue
 		}
 
-		if v.Field(i).Type().Kind() == reflect.Struct {
-			errs = multierr.Append(errs, e.encode(v.Field(i), dst))
-			continue
-		}
+		errs = multierr.Append(errs, e.encodeValue(v.Field(i), prefix+name, omitempty, dst))
+	}
 
-		if v.Field(i).Type().Kind() == reflect.Slice {
-			encFunc = typeEncoder(v.Field(i).Type().Elem(), e.regenc)
-		}
+	return errs
+}
 
-		if encFunc == nil {
-			errs = multierr.Append(errs, fmt.Errorf("schema: encoder not found for %v", v.Field(i)))
-			continue
+// encodeValue encodes a value of any depth at the dotted path key.
+//
+// Nested structs use "key.field", slices of structs use "key.index.field"
+// and maps use "key.mapKey", matching the paths parsed by the Decoder.
+func (e *Encoder) encodeValue(v reflect.Value, key string, omitempty bool, dst map[string][]string) error {
+	t := v.Type()
+	if e.isScalar(t) {
+		if omitempty && isZero(v) {
+			return nil
 		}
-
-		// Encode a slice.
-		if v.Field(i).Len() == 0 && opts.Omitempty {
-			continue
+		if t.Kind() == reflect.Ptr && v.IsNil() && !e.hasEncoder(t) {
+			// nil pointers are left out so that decoding keeps them nil
+			return nil
 		}
+		dst[key] = append(dst[key], typeEncoder(t, e.regenc)(v))
+		return nil
+	}
 
-		dst[name] = []string{}
-		for j := 0; j < v.Field(i).Len(); j++ {
-			dst[name] = append(dst[name], encFunc(v.Field(i).Index(j)))
+	switch t.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
 		}
+		return e.encodeValue(v.Elem(), key, omitempty, dst)
+	case reflect.Struct:
+		return e.encode(v, key+".", dst)
+	case reflect.Slice, reflect.Array:
+		if omitempty && v.Len() == 0 {
+			return nil
+		}
+		if encFunc := typeEncoder(t.Elem(), e.regenc); encFunc != nil {
+			dst[key] = []string{}
+			for j := 0; j < v.Len(); j++ {
+				dst[key] = append(dst[key], encFunc(v.Index(j)))
+			}
+			return nil
+		}
+		var errs error
+		for j := 0; j < v.Len(); j++ {
+			errs = multierr.Append(errs, e.encodeValue(v.Index(j), key+"."+strconv.Itoa(j), false, dst))
+		}
+		return errs
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return fmt.Errorf("schema: map key must be a string for %v", key)
+		}
+		if omitempty && v.Len() == 0 {
+			return nil
+		}
+		var errs error
+		iter := v.MapRange()
+		for iter.Next() {
+			errs = multierr.Append(errs, e.encodeValue(iter.Value(), key+"."+iter.Key().String(), false, dst))
+		}
+		return errs
 	}
+	return fmt.Errorf("schema: encoder not found for %v", key)
+}
 
-	return errs
+// isScalar reports whether values of t are encoded as a single string.
+func (e *Encoder) isScalar(t reflect.Type) bool {
+	if e.hasEncoder(t) {
+		return true
+	}
+	if t.Kind() == reflect.Ptr && e.hasEncoder(t.Elem()) {
+		return true
+	}
+	switch indirectType(t).Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+		return false
+	}
+	return typeEncoder(t, e.regenc) != nil
+}
+
+// hasEncoder reports whether t has a registered or TextMarshaler encoder.
+func (e *Encoder) hasEncoder(t reflect.Type) bool {
+	if _, ok := e.regenc[t]; ok {
+		return true
+	}
+	return t.Implements(textMarshalerType)
 }
 
 // typeEncoder returns the result.
@@ -149,6 +214,9 @@ func typeEncoder(t reflect.Type, reg map[reflect.Type]encoderFunc) encoderFunc {
 	if f, ok := reg[t]; ok {
 		return f
 	}
+	if t.Implements(textMarshalerType) {
+		return encodeText
+	}
 
 	switch t.Kind() {
 	case reflect.Bool:
@@ -163,6 +231,9 @@ func typeEncoder(t reflect.Type, reg map[reflect.Type]encoderFunc) encoderFunc {
 		return encodeFloat64
 	case reflect.Ptr:
 		f := typeEncoder(t.Elem(), reg)
+		if f == nil {
+			return nil
+		}
 		return func(v reflect.Value) string {
 			if v.IsNil() {
 				return "null"
@@ -176,6 +247,14 @@ func typeEncoder(t reflect.Type, reg map[reflect.Type]encoderFunc) encoderFunc {
 	}
 }
 
+// encodeText returns the result.
+func encodeText(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return "null"
+	}
+	return TextString(v.Interface().(encoding.TextMarshaler))
+}
+
 // encodeBool returns the result.
 func encodeBool(v reflect.Value) string {
 	return strconv.FormatBool(v.Bool())
@@ -193,7 +272,7 @@ func encodeUint(v reflect.Value) string {
 
 // encodeFloat returns the result.
 func encodeFloat(v reflect.Value, bits int) string {
-	return strconv.FormatFloat(v.Float(), 'f', 6, bits)
+	return strconv.FormatFloat(v.Float(), 'f', -1, bits)
 }
 
 // encodeFloat32 returns the result.
@@ -0,0 +1,152 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ */
+
+package kvstruct
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type encItem struct {
+	Name  string  `json:"name"`
+	Price float64 `json:"price,omitempty"`
+}
+
+type encBase struct {
+	ID int `json:"id"`
+}
+
+type encRoot struct {
+	encBase
+	Title   string             `json:"title"`
+	Skip    string             `json:"-"`
+	Note    string             `json:"note,omitempty"`
+	Count   *int               `json:"count"`
+	Tags    []string           `json:"tags"`
+	Item    encItem            `json:"item"`
+	ItemPtr *encItem           `json:"itemPtr"`
+	Items   []encItem          `json:"items"`
+	Ptrs    []*encItem         `json:"ptrs"`
+	Attrs   map[string]string  `json:"attrs"`
+	ByName  map[string]encItem `json:"byName"`
+	At      time.Time          `json:"at"`
+	Color   encColor           `json:"color"`
+	Colors  []encColor         `json:"colors"`
+}
+
+type encColor int
+
+func TestEncodeNestedPaths(t *testing.T) {
+	count := 3
+	src := encRoot{
+		encBase: encBase{ID: 7},
+		Title:   "t",
+		Skip:    "x",
+		Count:   &count,
+		Tags:    []string{"a", "b"},
+		Item:    encItem{Name: "i", Price: 1.5},
+		ItemPtr: &encItem{Name: "p"},
+		Items:   []encItem{{Name: "x"}, {Name: "y", Price: 2}},
+		Ptrs:    []*encItem{{Name: "z"}},
+		Attrs:   map[string]string{"k": "v"},
+		ByName:  map[string]encItem{"one": {Name: "o"}},
+		At:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Color:   2,
+		Colors:  []encColor{1, 2},
+	}
+	enc := NewEncoder("json")
+	enc.RegisterEncoder(encColor(0), func(v reflect.Value) string {
+		return []string{"red", "green", "blue"}[v.Int()]
+	})
+	dst := map[string][]string{}
+	if err := enc.Encode(&src, dst); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"id":              {"7"},
+		"title":           {"t"},
+		"count":           {"3"},
+		"tags":            {"a", "b"},
+		"item.name":       {"i"},
+		"item.price":      {"1.5"},
+		"itemPtr.name":    {"p"},
+		"items.0.name":    {"x"},
+		"items.1.name":    {"y"},
+		"items.1.price":   {"2"},
+		"ptrs.0.name":     {"z"},
+		"attrs.k":         {"v"},
+		"byName.one.name": {"o"},
+		"at":              {"2024-01-02T03:04:05Z"},
+		"color":           {"blue"},
+		"colors":          {"green", "blue"},
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Fatalf("Encode() = %v, want %v", dst, want)
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	count := 3
+	src := encRoot{
+		encBase: encBase{ID: 7},
+		Title:   "t",
+		Count:   &count,
+		Tags:    []string{"a", "b"},
+		Item:    encItem{Name: "i", Price: 0.1},
+		ItemPtr: &encItem{Name: "p"},
+		Items:   []encItem{{Name: "x"}, {Name: "y", Price: 2}},
+		Ptrs:    []*encItem{{Name: "z"}},
+		Attrs:   map[string]string{"k": "v", "k2": "v2"},
+		ByName:  map[string]encItem{"one": {Name: "o", Price: 3.25}},
+		At:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Color:   2,
+		Colors:  []encColor{1, 2},
+	}
+	dst := map[string][]string{}
+	if err := NewEncoder("json").Encode(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	var got encRoot
+	if err := NewDecoder("json").Decode(&got, dst); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, src) {
+		t.Fatalf("round trip = %+v, want %+v", got, src)
+	}
+}
+
+func TestEncodeOmitemptyNested(t *testing.T) {
+	src := struct {
+		Items []encItem          `json:"items,omitempty"`
+		Map   map[string]encItem `json:"map,omitempty"`
+		Inner encItem            `json:"inner"`
+	}{
+		Map: map[string]encItem{"a": {Name: "n"}},
+	}
+	dst := map[string][]string{}
+	if err := NewEncoder("json").Encode(&src, dst); err != nil {
+		t.Fatal(err)
+	}
+	for k := range dst {
+		if strings.HasSuffix(k, "price") || k == "items" {
+			t.Fatalf("omitempty field %q encoded: %v", k, dst)
+		}
+	}
+	if !reflect.DeepEqual(dst["map.a.name"], []string{"n"}) || !reflect.DeepEqual(dst["inner.name"], []string{""}) {
+		t.Fatalf("Encode() = %v", dst)
+	}
+}
+
+func TestEncodeUnsupportedMapKey(t *testing.T) {
+	src := struct {
+		M map[int]string `json:"m"`
+	}{M: map[int]string{1: "a"}}
+	if err := NewEncoder("json").Encode(src, map[string][]string{}); err == nil {
+		t.Fatal("want error for non-string map key")
+	}
+}
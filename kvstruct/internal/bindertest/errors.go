@@ -0,0 +1,10 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ */
+
+package bindertest
+
+import "errors"
+
+var errInvalidLevel = errors.New("invalid level")
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ */
+
+// Package bindertest holds fixtures comparing generated kvstruct binders with the reflective path.
+package bindertest
+
+import "time"
+
+//go:generate go run github.com/hopeio/gox/tools/kvgen --type=Query,Flat --tag=json
+
+type Level int
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (l *Level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	case "":
+		*l = 0
+	default:
+		return errInvalidLevel
+	}
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l Level) MarshalText() ([]byte, error) {
+	switch l {
+	case 1:
+		return []byte("low"), nil
+	case 2:
+		return []byte("high"), nil
+	}
+	return nil, nil
+}
+
+type Page struct {
+	No   int `json:"no,default=1"`
+	Size int `json:"size"`
+}
+
+type Filter struct {
+	Field string   `json:"field"`
+	Value *float64 `json:"value"`
+}
+
+type Query struct {
+	Page
+	Keyword  string        `json:"keyword,required"`
+	IDs      []int64       `json:"ids"`
+	Names    []string      `json:"names,omitempty"`
+	Active   bool          `json:"active"`
+	Limit    *uint16       `json:"limit"`
+	Ratio    float32       `json:"ratio"`
+	Level    Level         `json:"level"`
+	Since    time.Time     `json:"since"`
+	Timeout  time.Duration `json:"timeout"`
+	Filter   Filter        `json:"filter"`
+	Optional *Filter       `json:"optional"`
+	Ignored  string        `json:"-"`
+	internal int
+}
+
+type Flat struct {
+	A string  `json:"a"`
+	B int8    `json:"b,omitempty"`
+	C []uint  `json:"c"`
+	D *string `json:"d"`
+}
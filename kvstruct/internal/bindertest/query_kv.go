@@ -0,0 +1,639 @@
+// Code generated by kvgen. DO NOT EDIT.
+
+//go:build !kvgen
+
+package bindertest
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hopeio/gox/kvstruct"
+	stringsx "github.com/hopeio/gox/strings"
+	"go.uber.org/multierr"
+)
+
+// KVTag implements kvstruct.KVTagger.
+func (Query) KVTag() string {
+	return "json"
+}
+
+// DecodeKV implements kvstruct.KVDecoder.
+func (x *Query) DecodeKV(src map[string][]string) error {
+	var errs error
+	for key, values := range src {
+		switch strings.ToLower(key) {
+		case "page":
+			if kvstruct.LastValue(values) != "" {
+				errs = multierr.Append(errs, fmt.Errorf("schema: converter not found for %v", reflect.TypeFor[Page]()))
+			}
+		case "page.no":
+			if val := kvstruct.LastValue(values); val != "" {
+				if v, err := stringsx.Int(val); err == nil {
+					x.Page.No = int(v)
+				} else {
+					errs = multierr.Append(errs, kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[int](), Index: -1})
+				}
+			}
+		case "page.size":
+			if val := kvstruct.LastValue(values); val != "" {
+				if v, err := stringsx.Int(val); err == nil {
+					x.Page.Size = int(v)
+				} else {
+					errs = multierr.Append(errs, kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[int](), Index: -1})
+				}
+			}
+		case "keyword":
+			if val := kvstruct.LastValue(values); val != "" {
+				x.Keyword = string(val)
+			}
+		case "ids":
+			items := make([]int64, 0, len(values))
+			var err error
+			for i, value := range values {
+				if value == "" {
+					continue
+				}
+				if v, e := strconv.ParseInt(value, 10, 64); e == nil {
+					items = append(items, int64(v))
+					continue
+				}
+				if !strings.Contains(value, ",") {
+					err = kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[int64](), Index: i}
+					break
+				}
+				for _, value := range strings.Split(value, ",") {
+					if value == "" {
+						continue
+					}
+					v, e := strconv.ParseInt(value, 10, 64)
+					if e != nil {
+						err = kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[int64](), Index: i}
+						break
+					}
+					items = append(items, int64(v))
+				}
+				if err != nil {
+					break
+				}
+			}
+			if err != nil {
+				errs = multierr.Append(errs, err)
+			} else {
+				x.IDs = items
+			}
+		case "names":
+			items := make([]string, 0, len(values))
+			var err error
+			for _, value := range values {
+				if value == "" {
+					continue
+				}
+				items = append(items, string(value))
+			}
+			if err != nil {
+				errs = multierr.Append(errs, err)
+			} else {
+				x.Names = items
+			}
+		case "active":
+			if val := kvstruct.LastValue(values); val != "" {
+				if v, err := strconv.ParseBool(val); err == nil {
+					x.Active = bool(v)
+				} else {
+					errs = multierr.Append(errs, kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[bool](), Index: -1})
+				}
+			}
+		case "limit":
+			if x.Limit == nil {
+				x.Limit = new(uint16)
+			}
+			if val := kvstruct.LastValue(values); val != "" {
+				if v, err := stringsx.Uint16(val); err == nil {
+					*x.Limit = uint16(v)
+				} else {
+					errs = multierr.Append(errs, kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[uint16](), Index: -1})
+				}
+			}
+		case "ratio":
+			if val := kvstruct.LastValue(values); val != "" {
+				if v, err := stringsx.Float32(val); err == nil {
+					x.Ratio = float32(v)
+				} else {
+					errs = multierr.Append(errs, kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[float32](), Index: -1})
+				}
+			}
+		case "level":
+			var v Level
+			if err := v.UnmarshalText([]byte(kvstruct.LastValue(values))); err != nil {
+				errs = multierr.Append(errs, kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[Level](), Index: -1, Err: err})
+			} else {
+				x.Level = v
+			}
+		case "since":
+			var v time.Time
+			if err := v.UnmarshalText([]byte(kvstruct.LastValue(values))); err != nil {
+				errs = multierr.Append(errs, kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[time.Time](), Index: -1, Err: err})
+			} else {
+				x.Since = v
+			}
+		case "timeout":
+			if val := kvstruct.LastValue(values); val != "" {
+				if v, err := strconv.ParseInt(val, 10, 64); err == nil {
+					x.Timeout = time.Duration(v)
+				} else {
+					errs = multierr.Append(errs, kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[time.Duration](), Index: -1})
+				}
+			}
+		case "filter":
+			if kvstruct.LastValue(values) != "" {
+				errs = multierr.Append(errs, fmt.Errorf("schema: converter not found for %v", reflect.TypeFor[Filter]()))
+			}
+		case "filter.field":
+			if val := kvstruct.LastValue(values); val != "" {
+				x.Filter.Field = string(val)
+			}
+		case "filter.value":
+			if x.Filter.Value == nil {
+				x.Filter.Value = new(float64)
+			}
+			if val := kvstruct.LastValue(values); val != "" {
+				if v, err := strconv.ParseFloat(val, 64); err == nil {
+					*x.Filter.Value = float64(v)
+				} else {
+					errs = multierr.Append(errs, kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[float64](), Index: -1})
+				}
+			}
+		case "optional":
+			if x.Optional == nil {
+				x.Optional = new(Filter)
+			}
+			if kvstruct.LastValue(values) != "" {
+				errs = multierr.Append(errs, fmt.Errorf("schema: converter not found for %v", reflect.TypeFor[Filter]()))
+			}
+		case "optional.field":
+			if x.Optional == nil {
+				x.Optional = new(Filter)
+			}
+			if val := kvstruct.LastValue(values); val != "" {
+				x.Optional.Field = string(val)
+			}
+		case "optional.value":
+			if x.Optional == nil {
+				x.Optional = new(Filter)
+			}
+			if x.Optional.Value == nil {
+				x.Optional.Value = new(float64)
+			}
+			if val := kvstruct.LastValue(values); val != "" {
+				if v, err := strconv.ParseFloat(val, 64); err == nil {
+					*x.Optional.Value = float64(v)
+				} else {
+					errs = multierr.Append(errs, kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[float64](), Index: -1})
+				}
+			}
+		case "internal":
+		case "no":
+			if val := kvstruct.LastValue(values); val != "" {
+				if v, err := stringsx.Int(val); err == nil {
+					x.Page.No = int(v)
+				} else {
+					errs = multierr.Append(errs, kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[int](), Index: -1})
+				}
+			}
+		case "size":
+			if val := kvstruct.LastValue(values); val != "" {
+				if v, err := stringsx.Int(val); err == nil {
+					x.Page.Size = int(v)
+				} else {
+					errs = multierr.Append(errs, kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[int](), Index: -1})
+				}
+			}
+		default:
+			errs = multierr.Append(errs, kvstruct.UnknownKeyError{Key: key})
+		}
+	}
+	if !kvstruct.HasValue(src, true, "keyword") {
+		errs = multierr.Append(errs, kvstruct.EmptyFieldError{Key: "keyword"})
+	}
+	return errs
+}
+
+// EncodeKV implements kvstruct.KVEncoder.
+func (x Query) EncodeKV(dst map[string][]string) error {
+	dst["no"] = append(dst["no"], strconv.FormatInt(int64(x.Page.No), 10))
+	dst["size"] = append(dst["size"], strconv.FormatInt(int64(x.Page.Size), 10))
+	dst["keyword"] = append(dst["keyword"], string(x.Keyword))
+	dst["ids"] = []string{}
+	for _, e := range x.IDs {
+		dst["ids"] = append(dst["ids"], strconv.FormatInt(int64(e), 10))
+	}
+	if len(x.Names) != 0 {
+		dst["names"] = []string{}
+		for _, e := range x.Names {
+			dst["names"] = append(dst["names"], string(e))
+		}
+	}
+	dst["active"] = append(dst["active"], strconv.FormatBool(bool(x.Active)))
+	if x.Limit != nil {
+		dst["limit"] = append(dst["limit"], strconv.FormatUint(uint64((*x.Limit)), 10))
+	}
+	dst["ratio"] = append(dst["ratio"], strconv.FormatFloat(float64(x.Ratio), 'f', -1, 32))
+	dst["level"] = append(dst["level"], kvstruct.TextString(x.Level))
+	dst["since"] = append(dst["since"], kvstruct.TextString(x.Since))
+	dst["timeout"] = append(dst["timeout"], strconv.FormatInt(int64(x.Timeout), 10))
+	dst["filter.field"] = append(dst["filter.field"], string(x.Filter.Field))
+	if x.Filter.Value != nil {
+		dst["filter.value"] = append(dst["filter.value"], strconv.FormatFloat(float64((*x.Filter.Value)), 'f', -1, 64))
+	}
+	if x.Optional != nil {
+		dst["optional.field"] = append(dst["optional.field"], string(x.Optional.Field))
+		if x.Optional.Value != nil {
+			dst["optional.value"] = append(dst["optional.value"], strconv.FormatFloat(float64((*x.Optional.Value)), 'f', -1, 64))
+		}
+	}
+	return nil
+}
+
+// MappingKV implements kvstruct.KVMapper.
+func (x *Query) MappingKV(getter kvstruct.ValuesGetter) error {
+	{
+		vals, _ := getter.Get("no")
+		if len(vals) == 0 {
+			vals = strings.Split("1", ",")
+		}
+		if len(vals) > 0 {
+			val := vals[0]
+			if val != "" {
+				v, err := strconv.ParseInt(val, 10, 0)
+				if err != nil {
+					return err
+				}
+				x.Page.No = int(v)
+			}
+		}
+	}
+	{
+		vals, _ := getter.Get("size")
+		if len(vals) > 0 {
+			val := vals[0]
+			if val != "" {
+				v, err := strconv.ParseInt(val, 10, 0)
+				if err != nil {
+					return err
+				}
+				x.Page.Size = int(v)
+			}
+		}
+	}
+	{
+		vals, _ := getter.Get("keyword")
+		if len(vals) > 0 {
+			val := vals[0]
+			if val != "" {
+				x.Keyword = string(val)
+			}
+		}
+	}
+	{
+		vals, _ := getter.Get("ids")
+		if len(vals) > 0 {
+			s := make([]int64, len(vals))
+			for i, val := range vals {
+				if val != "" {
+					v, err := strconv.ParseInt(val, 10, 64)
+					if err != nil {
+						return err
+					}
+					s[i] = int64(v)
+				}
+			}
+			x.IDs = s
+		}
+	}
+	{
+		vals, _ := getter.Get("names")
+		if len(vals) > 0 {
+			s := make([]string, len(vals))
+			for i, val := range vals {
+				if val != "" {
+					s[i] = string(val)
+				}
+			}
+			x.Names = s
+		}
+	}
+	{
+		vals, _ := getter.Get("active")
+		if len(vals) > 0 {
+			val := vals[0]
+			if val != "" {
+				v, err := strconv.ParseBool(val)
+				if err != nil {
+					return err
+				}
+				x.Active = bool(v)
+			}
+		}
+	}
+	{
+		p1 := x.Limit
+		isNew := p1 == nil
+		if isNew {
+			p1 = new(uint16)
+		}
+		var set1 bool
+		{
+			vals, _ := getter.Get("limit")
+			if len(vals) > 0 {
+				val := vals[0]
+				if val != "" {
+					v, err := strconv.ParseUint(val, 10, 16)
+					if err != nil {
+						return err
+					}
+					(*p1) = uint16(v)
+				}
+				set1 = true
+			}
+		}
+		if isNew && set1 {
+			x.Limit = p1
+		}
+	}
+	{
+		vals, _ := getter.Get("ratio")
+		if len(vals) > 0 {
+			val := vals[0]
+			if val != "" {
+				v, err := strconv.ParseFloat(val, 32)
+				if err != nil {
+					return err
+				}
+				x.Ratio = float32(v)
+			}
+		}
+	}
+	{
+		vals, _ := getter.Get("level")
+		if len(vals) > 0 {
+			val := vals[0]
+			if val != "" {
+				if err := x.Level.UnmarshalText([]byte(val)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	{
+		vals, _ := getter.Get("timeout")
+		if len(vals) > 0 {
+			val := vals[0]
+			if val != "" {
+				v, err := time.ParseDuration(val)
+				if err != nil {
+					return err
+				}
+				x.Timeout = time.Duration(v)
+			}
+		}
+	}
+	{
+		vals, _ := getter.Get("field")
+		if len(vals) > 0 {
+			val := vals[0]
+			if val != "" {
+				x.Filter.Field = string(val)
+			}
+		}
+	}
+	{
+		p2 := x.Filter.Value
+		isNew := p2 == nil
+		if isNew {
+			p2 = new(float64)
+		}
+		var set2 bool
+		{
+			vals, _ := getter.Get("value")
+			if len(vals) > 0 {
+				val := vals[0]
+				if val != "" {
+					v, err := strconv.ParseFloat(val, 64)
+					if err != nil {
+						return err
+					}
+					(*p2) = float64(v)
+				}
+				set2 = true
+			}
+		}
+		if isNew && set2 {
+			x.Filter.Value = p2
+		}
+	}
+	{
+		p3 := x.Optional
+		isNew := p3 == nil
+		if isNew {
+			p3 = new(Filter)
+		}
+		var set3 bool
+		{
+			vals, _ := getter.Get("field")
+			if len(vals) > 0 {
+				val := vals[0]
+				if val != "" {
+					(*p3).Field = string(val)
+				}
+				set3 = true
+			}
+		}
+		{
+			p4 := (*p3).Value
+			isNew := p4 == nil
+			if isNew {
+				p4 = new(float64)
+			}
+			var set4 bool
+			{
+				vals, _ := getter.Get("value")
+				if len(vals) > 0 {
+					val := vals[0]
+					if val != "" {
+						v, err := strconv.ParseFloat(val, 64)
+						if err != nil {
+							return err
+						}
+						(*p4) = float64(v)
+					}
+					set4 = true
+				}
+			}
+			if isNew && set4 {
+				(*p3).Value = p4
+			}
+			if set4 {
+				set3 = true
+			}
+		}
+		if isNew && set3 {
+			x.Optional = p3
+		}
+	}
+	return nil
+}
+
+// KVTag implements kvstruct.KVTagger.
+func (Flat) KVTag() string {
+	return "json"
+}
+
+// DecodeKV implements kvstruct.KVDecoder.
+func (x *Flat) DecodeKV(src map[string][]string) error {
+	var errs error
+	for key, values := range src {
+		switch strings.ToLower(key) {
+		case "a":
+			if val := kvstruct.LastValue(values); val != "" {
+				x.A = string(val)
+			}
+		case "b":
+			if val := kvstruct.LastValue(values); val != "" {
+				if v, err := stringsx.Int8(val); err == nil {
+					x.B = int8(v)
+				} else {
+					errs = multierr.Append(errs, kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[int8](), Index: -1})
+				}
+			}
+		case "c":
+			items := make([]uint, 0, len(values))
+			var err error
+			for i, value := range values {
+				if value == "" {
+					continue
+				}
+				if v, e := stringsx.Uint(value); e == nil {
+					items = append(items, uint(v))
+					continue
+				}
+				if !strings.Contains(value, ",") {
+					err = kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[uint](), Index: i}
+					break
+				}
+				for _, value := range strings.Split(value, ",") {
+					if value == "" {
+						continue
+					}
+					v, e := stringsx.Uint(value)
+					if e != nil {
+						err = kvstruct.ConversionError{Key: key, Type: reflect.TypeFor[uint](), Index: i}
+						break
+					}
+					items = append(items, uint(v))
+				}
+				if err != nil {
+					break
+				}
+			}
+			if err != nil {
+				errs = multierr.Append(errs, err)
+			} else {
+				x.C = items
+			}
+		case "d":
+			if x.D == nil {
+				x.D = new(string)
+			}
+			if val := kvstruct.LastValue(values); val != "" {
+				*x.D = string(val)
+			}
+		default:
+			errs = multierr.Append(errs, kvstruct.UnknownKeyError{Key: key})
+		}
+	}
+	return errs
+}
+
+// EncodeKV implements kvstruct.KVEncoder.
+func (x Flat) EncodeKV(dst map[string][]string) error {
+	dst["a"] = append(dst["a"], string(x.A))
+	if !(x.B == 0) {
+		dst["b"] = append(dst["b"], strconv.FormatInt(int64(x.B), 10))
+	}
+	dst["c"] = []string{}
+	for _, e := range x.C {
+		dst["c"] = append(dst["c"], strconv.FormatUint(uint64(e), 10))
+	}
+	if x.D != nil {
+		dst["d"] = append(dst["d"], string((*x.D)))
+	}
+	return nil
+}
+
+// MappingKV implements kvstruct.KVMapper.
+func (x *Flat) MappingKV(getter kvstruct.ValuesGetter) error {
+	{
+		vals, _ := getter.Get("a")
+		if len(vals) > 0 {
+			val := vals[0]
+			if val != "" {
+				x.A = string(val)
+			}
+		}
+	}
+	{
+		vals, _ := getter.Get("b")
+		if len(vals) > 0 {
+			val := vals[0]
+			if val != "" {
+				v, err := strconv.ParseInt(val, 10, 8)
+				if err != nil {
+					return err
+				}
+				x.B = int8(v)
+			}
+		}
+	}
+	{
+		vals, _ := getter.Get("c")
+		if len(vals) > 0 {
+			s := make([]uint, len(vals))
+			for i, val := range vals {
+				if val != "" {
+					v, err := strconv.ParseUint(val, 10, 0)
+					if err != nil {
+						return err
+					}
+					s[i] = uint(v)
+				}
+			}
+			x.C = s
+		}
+	}
+	{
+		p1 := x.D
+		isNew := p1 == nil
+		if isNew {
+			p1 = new(string)
+		}
+		var set1 bool
+		{
+			vals, _ := getter.Get("d")
+			if len(vals) > 0 {
+				val := vals[0]
+				if val != "" {
+					(*p1) = string(val)
+				}
+				set1 = true
+			}
+		}
+		if isNew && set1 {
+			x.D = p1
+		}
+	}
+	return nil
+}
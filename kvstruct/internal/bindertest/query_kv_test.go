@@ -0,0 +1,159 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ */
+
+package bindertest
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/hopeio/gox/kvstruct"
+	"go.uber.org/multierr"
+)
+
+// plainQuery and plainFlat drop the generated methods, so kvstruct takes the reflective path.
+type plainQuery Query
+
+type plainFlat Flat
+
+var (
+	_ kvstruct.KVDecoder = (*Query)(nil)
+	_ kvstruct.KVEncoder = Query{}
+	_ kvstruct.KVMapper  = (*Query)(nil)
+	_ kvstruct.KVTagger  = Query{}
+)
+
+func errorStrings(err error) []string {
+	var ss []string
+	for _, e := range multierr.Errors(err) {
+		ss = append(ss, e.Error())
+	}
+	sort.Strings(ss)
+	return ss
+}
+
+var decodeCases = []map[string][]string{
+	{},
+	{"keyword": {"go"}},
+	{"Keyword": {"a", "b"}, "no": {"2"}, "page.size": {"20"}},
+	{"keyword": {"go"}, "ids": {"1,2", "3"}, "names": {"x", "", "y"}, "active": {"true"}},
+	{"keyword": {"go"}, "ids": {"1", "x"}, "active": {"yes"}, "ratio": {"nan?"}},
+	{"keyword": {"go"}, "limit": {""}, "ratio": {"0.5"}, "timeout": {"1000"}},
+	{"keyword": {"go"}, "level": {"high"}, "since": {"2024-01-02T03:04:05Z"}},
+	{"keyword": {"go"}, "level": {"bad"}, "since": {"yesterday"}},
+	{"keyword": {"go"}, "filter.field": {"f"}, "filter.value": {"1.25"}},
+	{"keyword": {"go"}, "optional.field": {"f"}, "optional.value": {"x"}},
+	{"keyword": {"go"}, "filter": {"f"}, "unknown": {"1"}, "ignored": {"1"}},
+	{"keyword": {""}, "internal": {"1"}},
+}
+
+func TestDecodeKV(t *testing.T) {
+	for _, ignoreUnknown := range []bool{false, true} {
+		d := kvstruct.NewDecoder("json")
+		d.IgnoreUnknownKeys(ignoreUnknown)
+		for i, src := range decodeCases {
+			var got Query
+			var want plainQuery
+			gotErr := d.Decode(&got, src)
+			wantErr := d.Decode(&want, src)
+			if !reflect.DeepEqual(got, Query(want)) {
+				t.Errorf("case %d: got %+v, want %+v", i, got, want)
+			}
+			if g, w := errorStrings(gotErr), errorStrings(wantErr); !reflect.DeepEqual(g, w) {
+				t.Errorf("case %d: got errors %q, want %q", i, g, w)
+			}
+		}
+	}
+}
+
+func TestDecodeKVFlat(t *testing.T) {
+	d := kvstruct.NewDecoder("json")
+	for i, src := range []map[string][]string{
+		{"a": {"x"}, "b": {"-3"}, "c": {"1,2"}, "d": {""}},
+		{"b": {"300"}, "c": {"-1"}},
+	} {
+		var got Flat
+		var want plainFlat
+		gotErr := d.Decode(&got, src)
+		wantErr := d.Decode(&want, src)
+		if !reflect.DeepEqual(got, Flat(want)) {
+			t.Errorf("case %d: got %+v, want %+v", i, got, want)
+		}
+		if g, w := errorStrings(gotErr), errorStrings(wantErr); !reflect.DeepEqual(g, w) {
+			t.Errorf("case %d: got errors %q, want %q", i, g, w)
+		}
+	}
+}
+
+func TestEncodeKV(t *testing.T) {
+	limit, value, s := uint16(9), 1.5, "d"
+	e := kvstruct.NewEncoder("json")
+	for i, q := range []Query{
+		{},
+		{
+			Page:     Page{No: 2, Size: 10},
+			Keyword:  "go",
+			IDs:      []int64{1, 2},
+			Names:    []string{"x"},
+			Active:   true,
+			Limit:    &limit,
+			Ratio:    0.25,
+			Level:    2,
+			Since:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Timeout:  time.Second,
+			Filter:   Filter{Field: "f", Value: &value},
+			Optional: &Filter{Field: "o"},
+			Ignored:  "i",
+		},
+	} {
+		got, want := map[string][]string{}, map[string][]string{}
+		if err := e.Encode(q, got); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Encode(plainQuery(q), want); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("case %d: got %v, want %v", i, got, want)
+		}
+	}
+
+	got, want := map[string][]string{}, map[string][]string{}
+	f := Flat{A: "a", C: []uint{1}, D: &s}
+	if err := e.Encode(f, got); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(plainFlat(f), want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMappingKV(t *testing.T) {
+	for i, src := range []kvstruct.KVsSource{
+		{},
+		{"keyword": {"go", "x"}, "size": {"5"}, "ids": {"1", "2"}, "active": {"true"}},
+		{"limit": {""}, "ratio": {"0.5"}, "level": {"low"}, "timeout": {"1m"}},
+		{"field": {"f"}, "value": {"2.5"}},
+		{"value": {""}},
+		{"ids": {"1", "x"}},
+		{"level": {"bad"}},
+	} {
+		var got Query
+		var want plainQuery
+		gotErr := kvstruct.Mapping(&got, src, "json")
+		wantErr := kvstruct.Mapping(&want, src, "json")
+		if !reflect.DeepEqual(got, Query(want)) {
+			t.Errorf("case %d: got %+v, want %+v", i, got, want)
+		}
+		if (gotErr == nil) != (wantErr == nil) {
+			t.Errorf("case %d: got error %v, want %v", i, gotErr, wantErr)
+		}
+	}
+}
@@ -25,14 +25,44 @@ func (receiver Setters) TrySet(value reflect.Value, field *reflect.StructField,
 	return
 }
 
+// Validator validates a struct after it has been decoded.
+type Validator interface {
+	Validate(v any) error
+}
+
+var mappingValidator Validator
+
 // Mapping performs the operation.
 func Mapping(ptr any, setter Setter, tag string) error {
-	_, err := mapping(reflect.ValueOf(ptr), nil, setter, tag)
+	err := MappingUnvalidated(ptr, setter, tag)
+	if err == nil && mappingValidator != nil {
+		return mappingValidator.Validate(ptr)
+	}
+	return err
+}
+
+// MappingUnvalidated is Mapping without the Validator set by SetValidator,
+// for binding several sources into ptr and validating once afterwards.
+func MappingUnvalidated(ptr any, setter Setter, tag string) error {
+	if b, ok := ptr.(KVMapper); ok && matchKVTag(ptr, tag) {
+		if getter, ok := setter.(ValuesGetter); ok {
+			return b.MappingKV(getter)
+		}
+	}
+	_, err := mapping(reflect.ValueOf(ptr), nil, setter, tag, false)
 	return err
 }
 
-// mapping performs the operation.
-func mapping(value reflect.Value, field *reflect.StructField, setter Setter, tag string) (bool, error) {
+// MappingTagged binds only the fields carrying tag, unlike Mapping fields without it are not bound by
+// the field name. Defaults of the tag only fill zero fields, values set before, e.g. decoded from the body,
+// are kept when the key is missing. The Validator set by SetValidator is not run.
+func MappingTagged(ptr any, setter Setter, tag string) error {
+	_, err := mapping(reflect.ValueOf(ptr), nil, setter, tag, true)
+	return err
+}
+
+// mapping performs the operation, tagged skips the fields without tag.
+func mapping(value reflect.Value, field *reflect.StructField, setter Setter, tag string, tagged bool) (bool, error) {
 	var tagValue string
 	if field != nil {
 		tagValue = field.Tag.Get(tag)
@@ -50,7 +80,7 @@ func mapping(value reflect.Value, field *reflect.StructField, setter Setter, tag
 			isNew = true
 			vPtr = reflect.New(value.Type().Elem())
 		}
-		isSet, err := mapping(vPtr.Elem(), field, setter, tag)
+		isSet, err := mapping(vPtr.Elem(), field, setter, tag, tagged)
 		if err != nil {
 			return false, err
 		}
@@ -69,7 +99,7 @@ func mapping(value reflect.Value, field *reflect.StructField, setter Setter, tag
 			if sf.PkgPath != "" && !sf.Anonymous { // unexported
 				continue
 			}
-			ok, err := mapping(value.Field(i), &sf, setter, tag)
+			ok, err := mapping(value.Field(i), &sf, setter, tag, tagged)
 			if err != nil {
 				return false, err
 			}
@@ -78,7 +108,10 @@ func mapping(value reflect.Value, field *reflect.StructField, setter Setter, tag
 		return isSet, nil
 	}
 
-	if field != nil && !field.Anonymous {
+	if field != nil && !field.Anonymous && (!tagged || tagValue != "") {
+		if tagged && !value.IsZero() {
+			tagValue = withoutDefault(tagValue)
+		}
 		ok, err := tryToSetValue(value, field, setter, tagValue)
 		if err != nil {
 			return false, err
@@ -91,6 +124,21 @@ func mapping(value reflect.Value, field *reflect.StructField, setter Setter, tag
 	return false, nil
 }
 
+// withoutDefault removes the default option from the tag value.
+func withoutDefault(tagValue string) string {
+	alias, opts, ok := strings.Cut(tagValue, ",")
+	if !ok {
+		return tagValue
+	}
+	kept := []string{alias}
+	for opt := range strings.SplitSeq(opts, ",") {
+		if !strings.HasPrefix(opt, "default=") {
+			kept = append(kept, opt)
+		}
+	}
+	return strings.Join(kept, ",")
+}
+
 type Options struct {
 	Default   string
 	Required  bool
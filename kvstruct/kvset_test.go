@@ -125,3 +125,57 @@ func TestMapping(t *testing.T) {
 		t.Fatalf("got %#v", c)
 	}
 }
+
+type rejectAll struct{}
+
+func (rejectAll) Validate(any) error { return errUnknownField }
+
+func TestMappingTagged(t *testing.T) {
+	type nested struct {
+		Z int `query:"z"`
+		W int
+	}
+	type req struct {
+		Page    int `query:"page"`
+		IsAdmin bool
+		Nested  *nested
+	}
+	var r req
+	src := KVsSource{"page": {"2"}, "IsAdmin": {"true"}, "z": {"3"}, "W": {"4"}}
+	if err := MappingTagged(&r, src, "query"); err != nil {
+		t.Fatal(err)
+	}
+	if r.Page != 2 || r.IsAdmin || r.Nested == nil || r.Nested.Z != 3 || r.Nested.W != 0 {
+		t.Fatalf("got %+v %+v", r, r.Nested)
+	}
+
+	// 校验器只在 Mapping 中执行
+	SetValidator(rejectAll{})
+	defer SetValidator(nil)
+	if err := MappingTagged(&r, src, "query"); err != nil {
+		t.Errorf("tagged %v", err)
+	}
+	if err := MappingUnvalidated(&r, src, "query"); err != nil || !r.IsAdmin {
+		t.Errorf("unvalidated %v %+v", err, r)
+	}
+	if err := Mapping(&r, src, "query"); err == nil {
+		t.Error("Mapping should run the validator")
+	}
+}
+
+func TestMappingTagged_Default(t *testing.T) {
+	type req struct {
+		Page int `query:"page,default=1"`
+		Size int `query:"size,default=10"`
+	}
+	r := req{Page: 3}
+	if err := MappingTagged(&r, KVsSource{}, "query"); err != nil {
+		t.Fatal(err)
+	}
+	if r.Page != 3 || r.Size != 10 {
+		t.Errorf("got %+v", r)
+	}
+	if err := MappingTagged(&r, KVsSource{"page": {"5"}}, "query"); err != nil || r.Page != 5 {
+		t.Errorf("got %v %+v", err, r)
+	}
+}
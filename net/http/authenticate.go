@@ -0,0 +1,220 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/hopeio/gox/crypto/jwt"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request does not carry its credentials,
+	// the next Authenticator is tried.
+	ErrNoCredentials = errors.New("no credentials")
+	ErrInvalidAPIKey = errors.New("invalid api key")
+)
+
+// Authenticator returns the claims of the credentials of a request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (any, error)
+}
+
+// AuthenticatorFunc is an Authenticator function.
+type AuthenticatorFunc func(r *http.Request) (any, error)
+
+// Authenticate implements Authenticator.
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (any, error) {
+	return f(r)
+}
+
+type claimsKey struct{}
+
+// ContextWithClaims returns a copy of ctx carrying claims.
+func ContextWithClaims(ctx context.Context, claims any) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims put by Authenticate, T is the type returned by the Authenticator,
+// e.g. *MyClaims for JWTAuthenticator[MyClaims].
+func ClaimsFromContext[T any](ctx context.Context) (T, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(T)
+	return claims, ok
+}
+
+type AuthenticateOptions struct {
+	// Authenticators 依次尝试, 返回 ErrNoCredentials 时尝试下一个
+	Authenticators []Authenticator
+	// Optional 为 true 时没有凭证的请求也放行, 凭证无效仍返回 401
+	Optional bool
+	// Realm of the WWW-Authenticate challenge
+	Realm              string
+	ExcludedPaths      ExcludedPaths
+	ExcludedPathsRegex ExcludedPathsRegex
+}
+
+// Authenticate returns a middleware putting the claims of the first Authenticator accepting the request into
+// the request context, requests without credentials or with credentials failing with a jwt error,
+// ErrInvalidAPIKey, ErrInvalidSession or ErrSessionExpired are rejected with 401. Errors implementing
+// StatusCoder, e.g. a Problem of 503 when the key store is down, keep their status, other errors are 500.
+//
+//	httpx.Authenticate(&httpx.AuthenticateOptions{Authenticators: []httpx.Authenticator{
+//		&httpx.JWTAuthenticator[MyClaims]{Keys: jwks.KeyFunc},
+//		&httpx.APIKeyAuthenticator[Client]{Lookup: lookupClient},
+//	}})
+func Authenticate(options *AuthenticateOptions) Middleware {
+	challenge := "Bearer"
+	if options.Realm != "" {
+		challenge += ` realm="` + options.Realm + `"`
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if options.ExcludedPaths.Contains(r.URL.Path) || options.ExcludedPathsRegex.Contains(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			err := ErrNoCredentials
+			for _, authenticator := range options.Authenticators {
+				var claims any
+				claims, err = authenticator.Authenticate(r)
+				if err == nil {
+					next.ServeHTTP(w, r.WithContext(ContextWithClaims(r.Context(), claims)))
+					return
+				}
+				if !errors.Is(err, ErrNoCredentials) {
+					break
+				}
+			}
+			if errors.Is(err, ErrNoCredentials) {
+				if options.Optional {
+					next.ServeHTTP(w, r)
+					return
+				}
+				w.Header().Set(HeaderWWWAuthenticate, challenge)
+				WriteProblem(w, r, NewProblem(http.StatusUnauthorized, err.Error()))
+				return
+			}
+			var sc StatusCoder
+			if errors.As(err, &sc) || !invalidCredentials(err) {
+				// 其它错误如查询失败为 500, 由 WriteProblem 记录且不返回给客户端
+				WriteProblem(w, r, err)
+				return
+			}
+			w.Header().Set(HeaderWWWAuthenticate, challenge+`, error="invalid_token"`)
+			WriteProblem(w, r, NewProblem(http.StatusUnauthorized, err.Error()))
+		})
+	}
+}
+
+// credentialErrors are the errors rejecting the credentials of a request.
+var credentialErrors = []error{
+	ErrInvalidAPIKey, ErrInvalidSession, ErrSessionExpired,
+	jwt.ErrMalformed, jwt.ErrAlgorithm, jwt.ErrKeyType, jwt.ErrKeyNotFound, jwt.ErrSignature,
+	jwt.ErrExpired, jwt.ErrNotValidYet, jwt.ErrIssuer, jwt.ErrAudience,
+}
+
+// invalidCredentials reports whether err rejects the credentials rather than being a failure of the server.
+func invalidCredentials(err error) bool {
+	for _, target := range credentialErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// BearerToken returns the token of the Authorization: Bearer header.
+func BearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get(HeaderAuthorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// JWTAuthenticator verifies the bearer token and returns its claims as *T, T usually embeds jwt.RegisteredClaims.
+type JWTAuthenticator[T any] struct {
+	// Keys is jwt.StaticKey, (*jwt.JWKS).KeyFunc or (*jwt.RemoteJWKS).KeyFunc
+	Keys    jwt.KeyFunc
+	Options *jwt.Options
+	// Token nil 时为 BearerToken
+	Token func(r *http.Request) string
+}
+
+// Authenticate implements Authenticator.
+func (a *JWTAuthenticator[T]) Authenticate(r *http.Request) (any, error) {
+	token := ""
+	if a.Token != nil {
+		token = a.Token(r)
+	} else {
+		token = BearerToken(r)
+	}
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+	claims := new(T)
+	if _, err := jwt.Parse(r.Context(), token, claims, a.Keys, a.Options); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// HashAPIKey returns the hex SHA-256 of key, API keys are stored and looked up by the hash.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyAuthenticator looks up the API key of the header by its HashAPIKey and returns the found *T.
+type APIKeyAuthenticator[T any] struct {
+	// Header 为空时为 X-API-Key
+	Header string
+	// Lookup returns the owner of the hashed key, nil when there is none
+	Lookup func(ctx context.Context, hash string) (*T, error)
+}
+
+// Authenticate implements Authenticator.
+func (a *APIKeyAuthenticator[T]) Authenticate(r *http.Request) (any, error) {
+	header := a.Header
+	if header == "" {
+		header = HeaderXAPIKey
+	}
+	key := r.Header.Get(header)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	v, err := a.Lookup(r.Context(), HashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	return v, nil
+}
+
+// SessionAuthenticator loads the session of the cookie and returns it as *T.
+type SessionAuthenticator[T any] struct {
+	Cookie *SessionCookie
+}
+
+// Authenticate implements Authenticator.
+func (a *SessionAuthenticator[T]) Authenticate(r *http.Request) (any, error) {
+	session := new(T)
+	if err := a.Cookie.Load(r, session); err != nil {
+		if errors.Is(err, http.ErrNoCookie) {
+			return nil, ErrNoCredentials
+		}
+		return nil, err
+	}
+	return session, nil
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hopeio/gox/crypto/jwt"
+	"github.com/hopeio/gox/crypto/jwt/jwttest"
+)
+
+type testClaims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role"`
+}
+
+type testClient struct {
+	Name string
+}
+
+type testSession struct {
+	UserID int `json:"uid"`
+}
+
+func TestAuthenticate(t *testing.T) {
+	issuer := jwttest.NewIssuer(t, jwt.ES256)
+	sessions := NewSessionCookie("sid", []byte("new-key"), []byte("old-key"))
+	keys := map[string]*testClient{HashAPIKey("k1"): {Name: "ci"}}
+	handler := Authenticate(&AuthenticateOptions{
+		Authenticators: []Authenticator{
+			&JWTAuthenticator[testClaims]{Keys: jwt.NewRemoteJWKS(issuer.URL()).KeyFunc, Options: &jwt.Options{Audience: "api"}},
+			&APIKeyAuthenticator[testClient]{Lookup: func(ctx context.Context, hash string) (*testClient, error) {
+				if hash == HashAPIKey("down") {
+					return nil, NewProblem(http.StatusServiceUnavailable, "")
+				}
+				if hash == HashAPIKey("broken") {
+					return nil, errors.New("db: password=hunter2 refused")
+				}
+				return keys[hash], nil
+			}},
+			&SessionAuthenticator[testSession]{Cookie: sessions},
+		},
+		Realm:         "api",
+		ExcludedPaths: ExcludedPaths{"/public"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims, ok := ClaimsFromContext[*testClaims](r.Context()); ok {
+			w.Write([]byte("jwt " + claims.Subject + " " + claims.Role))
+		} else if client, ok := ClaimsFromContext[*testClient](r.Context()); ok {
+			w.Write([]byte("key " + client.Name))
+		} else if session, ok := ClaimsFromContext[*testSession](r.Context()); ok {
+			w.Write([]byte("session " + strconv.Itoa(session.UserID)))
+		}
+	}))
+	do := func(path string, set func(r *http.Request)) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if set != nil {
+			set(r)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+	bearer := func(token string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set(HeaderAuthorization, "Bearer "+token) }
+	}
+
+	token := issuer.Mint(&testClaims{RegisteredClaims: issuer.Claims("bob", time.Minute, "api"), Role: "admin"})
+	if rec := do("/", bearer(token)); rec.Body.String() != "jwt bob admin" {
+		t.Errorf("jwt %d %s", rec.Code, rec.Body)
+	}
+	expired := issuer.Claims("bob", -time.Minute, "api")
+	rec := do("/", bearer(issuer.Mint(expired)))
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Header().Get(HeaderWWWAuthenticate), `error="invalid_token"`) {
+		t.Errorf("expired %d %v", rec.Code, rec.Header())
+	}
+	if rec = do("/", bearer(issuer.Mint(issuer.Claims("bob", time.Minute, "web")))); rec.Code != http.StatusUnauthorized {
+		t.Errorf("audience %d", rec.Code)
+	}
+
+	if rec = do("/", func(r *http.Request) { r.Header.Set(HeaderXAPIKey, "k1") }); rec.Body.String() != "key ci" {
+		t.Errorf("api key %d %s", rec.Code, rec.Body)
+	}
+	if rec = do("/", func(r *http.Request) { r.Header.Set(HeaderXAPIKey, "k2") }); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unknown api key %d", rec.Code)
+	}
+	if rec = do("/", func(r *http.Request) { r.Header.Set(HeaderXAPIKey, "down") }); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("lookup failure %d", rec.Code)
+	}
+	// 查询失败不是凭证无效, 内部信息不能返回给客户端
+	rec = do("/", func(r *http.Request) { r.Header.Set(HeaderXAPIKey, "broken") })
+	if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "hunter2") || rec.Header().Get(HeaderWWWAuthenticate) != "" {
+		t.Errorf("lookup error %d %s", rec.Code, rec.Body)
+	}
+
+	// 旧密钥签名的会话仍然有效
+	old := &SessionCookie{Name: "sid", Keys: [][]byte{[]byte("old-key")}}
+	value, _ := old.Encode(&testSession{UserID: 7}, time.Now().Add(time.Minute))
+	if rec = do("/", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "sid", Value: value}) }); rec.Body.String() != "session 7" {
+		t.Errorf("session %d %s", rec.Code, rec.Body)
+	}
+	if rec = do("/", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "sid", Value: "e30" + value[3:]}) }); rec.Code != http.StatusUnauthorized {
+		t.Errorf("tampered session %d", rec.Code)
+	}
+
+	rec = do("/", nil)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get(HeaderWWWAuthenticate) != `Bearer realm="api"` {
+		t.Errorf("anonymous %d %v", rec.Code, rec.Header())
+	}
+	if rec = do("/public/a", nil); rec.Code != http.StatusOK {
+		t.Errorf("excluded %d", rec.Code)
+	}
+}
+
+func TestSessionCookie(t *testing.T) {
+	sessions := NewSessionCookie("sid", []byte("key"))
+	rec := httptest.NewRecorder()
+	if err := sessions.Save(rec, &testSession{UserID: 3}); err != nil {
+		t.Fatal(err)
+	}
+	cookie := rec.Result().Cookies()[0]
+	if !cookie.HttpOnly || !cookie.Secure || cookie.MaxAge != 86400 {
+		t.Errorf("cookie %+v", cookie)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookie)
+	var session testSession
+	if err := sessions.Load(r, &session); err != nil || session.UserID != 3 {
+		t.Errorf("load %v %+v", err, session)
+	}
+
+	value, _ := sessions.Encode(&session, time.Now().Add(-time.Second))
+	if err := sessions.Decode(value, &session); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("expired %v", err)
+	}
+	other := NewSessionCookie("other", []byte("key"))
+	if err := other.Decode(cookie.Value, &session); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("moved cookie %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+	"github.com/hopeio/gox/encoding/msgpack"
+	"github.com/hopeio/gox/kvstruct"
+	"google.golang.org/protobuf/proto"
+)
+
+// Binding tags, the value is the key and options as kvstruct.ParseTag, e.g. `query:"page,default=1"`.
+const (
+	TagPath   = "path"
+	TagQuery  = "query"
+	TagHeader = "header"
+	TagCookie = "cookie"
+	TagForm   = "form"
+)
+
+const ContentTypeXMsgPack = "application/x-msgpack"
+
+var (
+	// DefaultMaxMemory of multipart forms, the rest is stored in temporary files.
+	DefaultMaxMemory int64 = 32 << 20
+
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+	fileHeaderType      = reflect.TypeFor[*multipart.FileHeader]()
+	fileHeaderSliceType = reflect.TypeFor[[]*multipart.FileHeader]()
+)
+
+// BindError is returned by Bind when the request can not be decoded.
+type BindError struct {
+	Source string
+	Err    error
+}
+
+// Error returns the error message.
+func (e *BindError) Error() string {
+	return "bind " + e.Source + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// Bind decodes the body and then the path, query, header and cookie values into ptr, the latter win
+// so a body can not override e.g. the resource ID of the route.
+//
+// Path, query, header and cookie values are only bound to fields with the path, query, header and
+// cookie tags, so clients can not set body fields through them. Form fields are bound by the form tag,
+// fields without it by the field name as kvstruct.Mapping does. Bind does not validate, Handle validates
+// the bound request once with Validator. The body is decoded by Content-Type: JSON,
+// msgpack, protobuf when ptr is a proto.Message, url encoded or multipart forms. Form files are
+// bound to *multipart.FileHeader and []*multipart.FileHeader fields.
+func Bind(r *http.Request, ptr any) error {
+	if err := BindBody(r, ptr); err != nil {
+		return err
+	}
+	path := kvstruct.GetFunc(func(key string) (string, bool) {
+		v := r.PathValue(key)
+		return v, v != ""
+	})
+	if err := kvstruct.MappingTagged(ptr, path, TagPath); err != nil {
+		return &BindError{Source: TagPath, Err: err}
+	}
+	if err := kvstruct.MappingTagged(ptr, kvstruct.KVsSource(r.URL.Query()), TagQuery); err != nil {
+		return &BindError{Source: TagQuery, Err: err}
+	}
+	header := kvstruct.ValuesGetFunc(func(key string) ([]string, bool) {
+		v := r.Header.Values(key)
+		return v, len(v) > 0
+	})
+	if err := kvstruct.MappingTagged(ptr, header, TagHeader); err != nil {
+		return &BindError{Source: TagHeader, Err: err}
+	}
+	cookie := kvstruct.GetFunc(func(key string) (string, bool) {
+		c, err := r.Cookie(key)
+		if err != nil {
+			return "", false
+		}
+		return c.Value, true
+	})
+	if err := kvstruct.MappingTagged(ptr, cookie, TagCookie); err != nil {
+		return &BindError{Source: TagCookie, Err: err}
+	}
+	return nil
+}
+
+// BindBody decodes the body into ptr by Content-Type, an empty body is ignored.
+func BindBody(r *http.Request, ptr any) error {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil
+	}
+	contentType := r.Header.Get(HeaderContentType)
+	if contentType == "" {
+		contentType = ContentTypeJson
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return &BindError{Source: "body", Err: fmt.Errorf("%w: %s", ErrUnsupportedMediaType, contentType)}
+	}
+	switch {
+	case mediaType == ContentTypeJson || strings.HasSuffix(mediaType, "+json"):
+		if err = jsonx.NewDecoder(r.Body).Decode(ptr); err == io.EOF {
+			err = nil
+		}
+	case mediaType == ContentTypeMsgPack || mediaType == ContentTypeXMsgPack:
+		if err = msgpack.NewDecoder(r.Body).Decode(ptr); err == io.EOF {
+			err = nil
+		}
+	case mediaType == ContentTypeProtobuf || mediaType == ContentTypeXProtobuf:
+		msg, ok := ptr.(proto.Message)
+		if !ok {
+			return &BindError{Source: "body", Err: fmt.Errorf("%w: %T is not a proto.Message", ErrUnsupportedMediaType, ptr)}
+		}
+		var data []byte
+		if data, err = io.ReadAll(r.Body); err == nil {
+			err = proto.Unmarshal(data, msg)
+		}
+	case mediaType == ContentTypeForm:
+		if err = r.ParseForm(); err == nil {
+			err = kvstruct.MappingUnvalidated(ptr, kvstruct.KVsSource(r.PostForm), TagForm)
+		}
+	case mediaType == ContentTypeMultipart:
+		if err = r.ParseMultipartForm(DefaultMaxMemory); err == nil {
+			if err = kvstruct.MappingUnvalidated(ptr, kvstruct.KVsSource(r.MultipartForm.Value), TagForm); err == nil {
+				bindFiles(reflect.ValueOf(ptr), r.MultipartForm.File)
+			}
+		}
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
+	}
+	if err != nil {
+		return &BindError{Source: "body", Err: err}
+	}
+	return nil
+}
+
+// bindFiles sets the file header fields of the struct v points to.
+func bindFiles(v reflect.Value, files map[string][]*multipart.FileHeader) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		fv := v.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			bindFiles(fv.Addr(), files)
+			continue
+		}
+		if !sf.IsExported() || (sf.Type != fileHeaderType && sf.Type != fileHeaderSliceType) {
+			continue
+		}
+		name, _ := kvstruct.ParseTag(sf.Tag.Get(TagForm))
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		headers := files[name]
+		if len(headers) == 0 {
+			continue
+		}
+		if sf.Type == fileHeaderType {
+			fv.Set(reflect.ValueOf(headers[0]))
+		} else {
+			fv.Set(reflect.ValueOf(headers))
+		}
+	}
+}
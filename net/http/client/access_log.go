@@ -32,6 +32,8 @@ type AccessLogParam struct {
 	Response          *http.Response
 	ReqBody, RespBody []byte
 	Duration          time.Duration
+	// Cache is the status of the response cache, empty if the cache was bypassed
+	Cache CacheStatus
 }
 type AccessLog func(param *AccessLogParam, err error)
 
@@ -61,6 +63,10 @@ func DefaultLogger(param *AccessLogParam, err error) {
 	if param.Response != nil {
 		statusField = zap.Int("status", param.Response.StatusCode)
 	}
+	cacheField := zap.Skip()
+	if param.Cache != CacheBypass {
+		cacheField = zap.String("cache", string(param.Cache))
+	}
 
 	log.NoCallerLogger().Logger.Info("http request", zap.String("url", param.Url),
 		zap.String("method", param.Method),
@@ -68,6 +74,7 @@ func DefaultLogger(param *AccessLogParam, err error) {
 		zap.Duration("duration", param.Duration),
 		respField,
 		statusField,
+		cacheField,
 		zap.Error(err),
 	)
 }
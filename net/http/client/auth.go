@@ -0,0 +1,263 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	httpx "github.com/hopeio/gox/net/http"
+)
+
+// Authenticator authorizes a request right before every attempt is sent.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc is a function Authenticator.
+type AuthenticatorFunc func(req *http.Request) error
+
+// Authenticate implements Authenticator.
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// invalidator is implemented by authenticators with cached credentials, e.g. OAuth2.
+type invalidator interface {
+	// Invalidate drops the cached credentials after the server rejected them
+	Invalidate()
+}
+
+// Auth sets the authenticator, it signs every attempt after the middlewares, the cache and the hedger,
+// so signatures cover the final request. A 401 response makes an authenticator with cached credentials
+// drop them and the request is sent once more.
+func (d *Client) Auth(auth Authenticator) *Client {
+	d.auth = auth
+	return d
+}
+
+// wrapAuth signs the request with the authenticator.
+func wrapAuth(auth Authenticator, next RoundTripFunc) RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		// 签名会修改请求,不影响调用方和对冲的其他请求
+		signed := req.Clone(req.Context())
+		if err := auth.Authenticate(signed); err != nil {
+			return nil, err
+		}
+		resp, err := next(signed)
+		inv, ok := auth.(invalidator)
+		if err != nil || !ok || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			return resp, nil
+		}
+		inv.Invalidate()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		retry := req.Clone(req.Context())
+		if req.GetBody != nil {
+			if retry.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		if err = auth.Authenticate(retry); err != nil {
+			return nil, err
+		}
+		return next(retry)
+	}
+}
+
+// requestBody returns the body of the request and makes it replayable.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return data, nil
+}
+
+// BearerAuth sets "Authorization: Bearer" with the token.
+type BearerAuth struct {
+	Token func() (string, error)
+}
+
+// Authenticate implements Authenticator.
+func (a *BearerAuth) Authenticate(req *http.Request) error {
+	token, err := a.Token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set(httpx.HeaderAuthorization, "Bearer "+token)
+	return nil
+}
+
+// BearerToken authenticates with a static token.
+func BearerToken(token string) *BearerAuth {
+	return &BearerAuth{Token: func() (string, error) { return token, nil }}
+}
+
+// BearerTokenFromEnv authenticates with the token in the environment variable, read on every request.
+func BearerTokenFromEnv(name string) *BearerAuth {
+	return &BearerAuth{Token: func() (string, error) {
+		token := strings.TrimSpace(os.Getenv(name))
+		if token == "" {
+			return "", errors.New("bearer token env " + name + " is empty")
+		}
+		return token, nil
+	}}
+}
+
+// BearerTokenFromFile authenticates with the token in the file, read again when the file changes,
+// e.g. a rotated Kubernetes service account token.
+func BearerTokenFromFile(path string) *BearerAuth {
+	var mu sync.Mutex
+	var token string
+	var modTime time.Time
+	return &BearerAuth{Token: func() (string, error) {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if token != "" && stat.ModTime().Equal(modTime) {
+			return token, nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if token = strings.TrimSpace(string(data)); token == "" {
+			return "", errors.New("bearer token file " + path + " is empty")
+		}
+		modTime = stat.ModTime()
+		return token, nil
+	}}
+}
+
+const (
+	HeaderHMACTimestamp = "X-Timestamp"
+	hmacAlgorithm       = "HMAC-SHA256"
+)
+
+/*
+HMACAuth signs requests with a shared secret. By default the string to sign is
+
+	method \n request uri \n unix timestamp \n hex sha256 of the body [\n lower(header):value]...
+
+and the request gets X-Timestamp and
+
+	Authorization: HMAC-SHA256 KeyId=<KeyID>,SignedHeaders=<a;b>,Signature=<base64>
+
+StringToSign and Apply adapt it to other schemes, e.g. webhook robots signing only the timestamp.
+*/
+type HMACAuth struct {
+	KeyID  string
+	Secret []byte
+	// Hash default sha256.New
+	Hash func() hash.Hash
+	// SignedHeaders are added to the default string to sign
+	SignedHeaders []string
+	// StringToSign replaces the default string to sign
+	StringToSign func(req *http.Request, timestamp time.Time, body []byte) (string, error)
+	// Apply replaces the default of setting X-Timestamp and Authorization
+	Apply func(req *http.Request, timestamp time.Time, signature []byte)
+	// Now default time.Now
+	Now func() time.Time
+}
+
+// Authenticate implements Authenticator.
+func (a *HMACAuth) Authenticate(req *http.Request) error {
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	timestamp := now()
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+	stringToSign := a.StringToSign
+	if stringToSign == nil {
+		stringToSign = a.defaultStringToSign
+	}
+	toSign, err := stringToSign(req, timestamp, body)
+	if err != nil {
+		return err
+	}
+	newHash := a.Hash
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	mac := hmac.New(newHash, a.Secret)
+	mac.Write([]byte(toSign))
+	signature := mac.Sum(nil)
+	if a.Apply != nil {
+		a.Apply(req, timestamp, signature)
+		return nil
+	}
+	req.Header.Set(HeaderHMACTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	signedHeaders := make([]string, len(a.SignedHeaders))
+	for i, h := range a.SignedHeaders {
+		signedHeaders[i] = strings.ToLower(h)
+	}
+	req.Header.Set(httpx.HeaderAuthorization, hmacAlgorithm+" KeyId="+a.KeyID+",SignedHeaders="+strings.Join(signedHeaders, ";")+
+		",Signature="+base64.StdEncoding.EncodeToString(signature))
+	return nil
+}
+
+// defaultStringToSign returns the documented string to sign.
+func (a *HMACAuth) defaultStringToSign(req *http.Request, timestamp time.Time, body []byte) (string, error) {
+	bodyHash := sha256.Sum256(body)
+	var b strings.Builder
+	b.WriteString(req.Method + "\n" + req.URL.RequestURI() + "\n" + strconv.FormatInt(timestamp.Unix(), 10) + "\n" + hex.EncodeToString(bodyHash[:]))
+	for _, h := range a.SignedHeaders {
+		b.WriteString("\n" + strings.ToLower(h) + ":" + strings.Join(headerValues(req, h), ","))
+	}
+	return b.String(), nil
+}
+
+// headerValues returns the values of a header, including Host which net/http keeps out of the header map.
+func headerValues(req *http.Request, name string) []string {
+	if strings.EqualFold(name, httpx.HeaderHost) {
+		if req.Host != "" {
+			return []string{req.Host}
+		}
+		return []string{req.URL.Host}
+	}
+	return slices.Clone(req.Header.Values(name))
+}
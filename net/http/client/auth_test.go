@@ -0,0 +1,230 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	httpx "github.com/hopeio/gox/net/http"
+)
+
+func TestBearerTokenFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("t1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(httpx.HeaderAuthorization))
+	}))
+	defer server.Close()
+
+	c := newTestClient().Auth(BearerTokenFromFile(path))
+	if _, err := c.GetRaw(server.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("t2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if _, err := c.GetRaw(server.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Bearer t1", "Bearer t2"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	t.Setenv("TEST_BEARER_TOKEN", "")
+	if _, err := BearerTokenFromEnv("TEST_BEARER_TOKEN").Token(); err == nil {
+		t.Error("empty env token should fail")
+	}
+}
+
+func TestHMACAuth(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1700000000, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodyHash := sha256.Sum256(body)
+		toSign := r.Method + "\n" + r.URL.RequestURI() + "\n" + r.Header.Get(HeaderHMACTimestamp) + "\n" +
+			hex.EncodeToString(bodyHash[:]) + "\nx-app:" + r.Header.Get("X-App")
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(toSign))
+		want := "HMAC-SHA256 KeyId=k1,SignedHeaders=x-app,Signature=" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		if r.Header.Get(httpx.HeaderAuthorization) != want {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	c := newTestClient().Auth(&HMACAuth{KeyID: "k1", Secret: secret, SignedHeaders: []string{"X-App"}, Now: func() time.Time { return now }})
+	c.Header(http.Header{"X-App": []string{"demo"}})
+	raw, err := c.PostRequest(server.URL + "/p?a=1").ContentType(ContentTypeText).DoRaw(strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "hello" {
+		t.Errorf("body %q", raw)
+	}
+}
+
+// oauth2Server issues tok1, tok2... and accepts only the token set in valid.
+type oauth2Server struct {
+	*httptest.Server
+	issued        atomic.Int32
+	expiresIn     int
+	mu            sync.Mutex
+	refreshTokens []string
+	valid         atomic.Value
+}
+
+func newOAuth2Server(expiresIn int) *oauth2Server {
+	s := &oauth2Server{expiresIn: expiresIn}
+	s.valid.Store("")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if id, secret, _ := r.BasicAuth(); id != "id" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		s.mu.Lock()
+		s.refreshTokens = append(s.refreshTokens, r.PostForm.Get("refresh_token"))
+		s.mu.Unlock()
+		n := s.issued.Add(1)
+		w.Header().Set(httpx.HeaderContentType, httpx.ContentTypeJson)
+		fmt.Fprintf(w, `{"access_token":"tok%d","token_type":"bearer","expires_in":%d,"refresh_token":"r%d"}`, n, s.expiresIn, n+1)
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		if valid := s.valid.Load().(string); valid != "" && r.Header.Get(httpx.HeaderAuthorization) != "Bearer "+valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(r.Header.Get(httpx.HeaderAuthorization)))
+	})
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func TestOAuth2Caching(t *testing.T) {
+	server := newOAuth2Server(3600)
+	defer server.Close()
+	c := newTestClient().Auth(NewOAuth2(OAuth2Config{TokenURL: server.URL + "/token", ClientID: "id", ClientSecret: "secret"}))
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			raw, err := c.GetRaw(server.URL+"/api", nil)
+			if err != nil || string(raw) != "Bearer tok1" {
+				t.Errorf("got %q, %v", raw, err)
+			}
+		})
+	}
+	wg.Wait()
+	if n := server.issued.Load(); n != 1 {
+		t.Errorf("issued %d tokens, want 1", n)
+	}
+}
+
+func TestOAuth2Invalidate(t *testing.T) {
+	server := newOAuth2Server(3600)
+	defer server.Close()
+	server.valid.Store("tok2")
+	c := newTestClient().Auth(NewOAuth2(OAuth2Config{TokenURL: server.URL + "/token", ClientID: "id", ClientSecret: "secret"}))
+	raw, err := c.GetRaw(server.URL+"/api", nil)
+	if err != nil || string(raw) != "Bearer tok2" {
+		t.Fatalf("got %q, %v", raw, err)
+	}
+
+	_, err = NewOAuth2(OAuth2Config{TokenURL: server.URL + "/token", ClientID: "id", ClientSecret: "bad"}).Token(context.Background())
+	if oauthErr, ok := err.(*OAuth2Error); !ok || oauthErr.Code != "invalid_client" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestOAuth2Refresh(t *testing.T) {
+	server := newOAuth2Server(30)
+	defer server.Close()
+	o := NewOAuth2(OAuth2Config{TokenURL: server.URL + "/token", ClientID: "id", ClientSecret: "secret", RefreshToken: "r1"})
+	ctx := context.Background()
+	token, err := o.Token(ctx)
+	if err != nil || token.AccessToken != "tok1" {
+		t.Fatalf("got %v, %v", token, err)
+	}
+	// 在 RefreshBefore 内,返回旧 token 并后台刷新
+	token, err = o.Token(ctx)
+	if err != nil || token.AccessToken != "tok1" {
+		t.Fatalf("got %v, %v", token, err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for server.issued.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	o.mu.Lock()
+	for o.refreshing != nil {
+		o.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		o.mu.Lock()
+	}
+	o.mu.Unlock()
+	o.Invalidate()
+	if token, err = o.Token(ctx); err != nil {
+		t.Fatal(err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if want := []string{"r1", "r2", "r3"}; fmt.Sprint(server.refreshTokens) != fmt.Sprint(want) {
+		t.Errorf("refresh tokens %v, want %v", server.refreshTokens, want)
+	}
+}
+
+func TestSigV4Auth(t *testing.T) {
+	auth := &SigV4Auth{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "service",
+		Now:             func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) },
+	}
+	// aws-sig-v4-test-suite
+	for _, tc := range []struct {
+		url, signature string
+	}{
+		{"https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, tc.url, nil)
+		if err := auth.Authenticate(req); err != nil {
+			t.Fatal(err)
+		}
+		want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=" + tc.signature
+		if got := req.Header.Get(httpx.HeaderAuthorization); got != want {
+			t.Errorf("%s:\ngot  %s\nwant %s", tc.url, got, want)
+		}
+		if req.Header.Get(HeaderAmzDate) != "20150830T123600Z" {
+			t.Errorf("x-amz-date %s", req.Header.Get(HeaderAmzDate))
+		}
+	}
+}
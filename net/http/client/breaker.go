@@ -0,0 +1,252 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type BreakerState uint8
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+// String returns the string representation.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+const breakerBuckets = 10
+
+type BreakerConfig struct {
+	// Window is the sliding window of the failure rate, default 10s
+	Window time.Duration
+	// MinRequests is the number of requests in the window before the breaker may open, default 10
+	MinRequests int
+	// FailureRate opens the breaker when reached, default 0.5
+	FailureRate float64
+	// CoolDown is how long the breaker stays open before probing, default 30s
+	CoolDown time.Duration
+	// HalfOpenRequests is the number of probes in half-open state, all must succeed to close, default 1
+	HalfOpenRequests int
+	// IsFailure classifies results, default err != nil or status >= 500
+	IsFailure func(resp *http.Response, err error) bool
+	// OnStateChange is called on every state transition
+	OnStateChange func(host string, from, to BreakerState)
+}
+
+// BreakerStats is the state of a host, for metrics.
+type BreakerStats struct {
+	State    BreakerState
+	Requests int
+	Failures int
+	// OpenedAt is the time of the last transition to open
+	OpenedAt time.Time
+}
+
+// Breaker is a per-host circuit breaker.
+type Breaker struct {
+	config BreakerConfig
+	mu     sync.Mutex
+	hosts  map[string]*hostBreaker
+}
+
+type breakerBucket struct {
+	start              time.Time
+	requests, failures int
+}
+
+type hostBreaker struct {
+	state    BreakerState
+	buckets  [breakerBuckets]breakerBucket
+	openedAt time.Time
+	// probes in flight and succeeded in half-open state
+	probes, probed int
+}
+
+// NewBreaker creates a new instance.
+func NewBreaker(config BreakerConfig) *Breaker {
+	if config.Window <= 0 {
+		config.Window = 10 * time.Second
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = 10
+	}
+	if config.FailureRate <= 0 {
+		config.FailureRate = 0.5
+	}
+	if config.CoolDown <= 0 {
+		config.CoolDown = 30 * time.Second
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = 1
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = func(resp *http.Response, err error) bool {
+			return err != nil || resp.StatusCode >= http.StatusInternalServerError
+		}
+	}
+	return &Breaker{config: config, hosts: make(map[string]*hostBreaker)}
+}
+
+// Middleware returns the breaker as a middleware, requests to open hosts fail with ErrCircuitOpen.
+func (b *Breaker) Middleware() Middleware {
+	return b.wrap
+}
+
+// wrap performs the operation.
+func (b *Breaker) wrap(next RoundTripFunc) RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		host := req.URL.Host
+		if !b.allow(host) {
+			return nil, ErrCircuitOpen
+		}
+		resp, err := next(req)
+		b.record(host, b.config.IsFailure(resp, err))
+		return resp, err
+	}
+}
+
+// State returns the state of a host.
+func (b *Breaker) State(host string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if h, ok := b.hosts[host]; ok {
+		b.refresh(host, h, time.Now())
+		return h.state
+	}
+	return BreakerClosed
+}
+
+// Stats returns the stats of every host seen.
+func (b *Breaker) Stats() map[string]BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	stats := make(map[string]BreakerStats, len(b.hosts))
+	for host, h := range b.hosts {
+		b.refresh(host, h, now)
+		requests, failures := h.count(now, b.config.Window)
+		stats[host] = BreakerStats{State: h.state, Requests: requests, Failures: failures, OpenedAt: h.openedAt}
+	}
+	return stats
+}
+
+// allow reports whether a request to host may be sent.
+func (b *Breaker) allow(host string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h, ok := b.hosts[host]
+	if !ok {
+		h = &hostBreaker{}
+		b.hosts[host] = h
+	}
+	b.refresh(host, h, time.Now())
+	switch h.state {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		if h.probes+h.probed >= b.config.HalfOpenRequests {
+			return false
+		}
+		h.probes++
+	}
+	return true
+}
+
+// record records the result of a request.
+func (b *Breaker) record(host string, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h := b.hosts[host]
+	now := time.Now()
+	switch h.state {
+	case BreakerHalfOpen:
+		h.probes--
+		if failed {
+			b.setState(host, h, BreakerOpen, now)
+			return
+		}
+		h.probed++
+		if h.probed >= b.config.HalfOpenRequests {
+			b.setState(host, h, BreakerClosed, now)
+		}
+		return
+	case BreakerOpen:
+		// 打开前已发出的请求,结果不再计入
+		return
+	}
+
+	bucket := h.bucket(now, b.config.Window)
+	bucket.requests++
+	if failed {
+		bucket.failures++
+	}
+	requests, failures := h.count(now, b.config.Window)
+	if requests >= b.config.MinRequests && float64(failures)/float64(requests) >= b.config.FailureRate {
+		b.setState(host, h, BreakerOpen, now)
+	}
+}
+
+// refresh moves an open breaker to half-open after the cool-down.
+func (b *Breaker) refresh(host string, h *hostBreaker, now time.Time) {
+	if h.state == BreakerOpen && now.Sub(h.openedAt) >= b.config.CoolDown {
+		b.setState(host, h, BreakerHalfOpen, now)
+	}
+}
+
+// setState performs the transition.
+func (b *Breaker) setState(host string, h *hostBreaker, state BreakerState, now time.Time) {
+	from := h.state
+	h.state = state
+	h.probes, h.probed = 0, 0
+	switch state {
+	case BreakerOpen:
+		h.openedAt = now
+	case BreakerClosed:
+		h.buckets = [breakerBuckets]breakerBucket{}
+	}
+	if b.config.OnStateChange != nil && from != state {
+		b.config.OnStateChange(host, from, state)
+	}
+}
+
+// bucket returns the bucket of now, resetting stale ones.
+func (h *hostBreaker) bucket(now time.Time, window time.Duration) *breakerBucket {
+	size := max(window/breakerBuckets, 1)
+	start := now.Truncate(size)
+	bucket := &h.buckets[(start.UnixNano()/int64(size))%breakerBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = breakerBucket{start: start}
+	}
+	return bucket
+}
+
+// count sums the buckets inside the window.
+func (h *hostBreaker) count(now time.Time, window time.Duration) (requests, failures int) {
+	for i := range h.buckets {
+		if now.Sub(h.buckets[i].start) < window {
+			requests += h.buckets[i].requests
+			failures += h.buckets[i].failures
+		}
+	}
+	return
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreakerOpenHalfOpenClose(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	host := mustHost(t, srv.URL)
+
+	var transitions []string
+	c := newTestClient().RetryTimesWithInterval(5, 0).CircuitBreaker(BreakerConfig{
+		MinRequests: 3,
+		CoolDown:    50 * time.Millisecond,
+		OnStateChange: func(h string, from, to BreakerState) {
+			transitions = append(transitions, from.String()+">"+to.String())
+		},
+	})
+	for range 3 {
+		if _, err := c.GetRawX(srv.URL); err == nil {
+			t.Fatal("expected status error")
+		}
+	}
+	if state := c.Breaker().State(host); state != BreakerOpen {
+		t.Fatalf("got state %s, want open", state)
+	}
+
+	before := hits.Load()
+	_, err := c.GetRawX(srv.URL)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got %v, want ErrCircuitOpen", err)
+	}
+	if hits.Load() != before {
+		t.Error("request sent while open")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if state := c.Breaker().State(host); state != BreakerHalfOpen {
+		t.Fatalf("got state %s, want half-open", state)
+	}
+	fail.Store(false)
+	raw, err := c.GetRawX(srv.URL)
+	if err != nil || string(raw) != "ok" {
+		t.Fatalf("probe: %q %v", raw, err)
+	}
+	stats := c.Breaker().Stats()[host]
+	if stats.State != BreakerClosed || stats.Requests != 0 {
+		t.Errorf("got stats %+v", stats)
+	}
+	want := []string{"closed>open", "open>half-open", "half-open>closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("got transitions %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("got transitions %v, want %v", transitions, want)
+		}
+	}
+}
+
+func TestBreakerHalfOpenFailure(t *testing.T) {
+	b := NewBreaker(BreakerConfig{MinRequests: 1, CoolDown: time.Millisecond})
+	b.allow("h")
+	b.record("h", true)
+	time.Sleep(2 * time.Millisecond)
+	if !b.allow("h") {
+		t.Fatal("probe not allowed")
+	}
+	if b.allow("h") {
+		t.Error("second probe allowed")
+	}
+	b.record("h", true)
+	if state := b.State("h"); state != BreakerOpen {
+		t.Errorf("got state %s, want open", state)
+	}
+}
+
+func TestBreakerFailureRate(t *testing.T) {
+	b := NewBreaker(BreakerConfig{MinRequests: 4, FailureRate: 0.5})
+	for _, failed := range []bool{false, false, false, true, false, true} {
+		b.allow("h")
+		b.record("h", failed)
+	}
+	if state := b.State("h"); state != BreakerClosed {
+		t.Fatalf("got state %s at 2/6", state)
+	}
+	b.allow("h")
+	b.record("h", true)
+	b.allow("h")
+	b.record("h", true)
+	if state := b.State("h"); state != BreakerOpen {
+		t.Errorf("got state %s at 4/8", state)
+	}
+}
+
+func mustHost(t *testing.T, rawURL string) string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
@@ -0,0 +1,437 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	iox "github.com/hopeio/gox/io"
+	httpx "github.com/hopeio/gox/net/http"
+)
+
+// HeaderCacheStatus is set on responses that went through ResponseCache.
+const HeaderCacheStatus = "X-Cache-Status"
+
+type CacheStatus string
+
+const (
+	CacheBypass      CacheStatus = ""
+	CacheHit         CacheStatus = "HIT"
+	CacheMiss        CacheStatus = "MISS"
+	CacheRevalidated CacheStatus = "REVALIDATED"
+	// CacheStale is a stale response served because the origin failed, see stale-if-error
+	CacheStale CacheStatus = "STALE"
+)
+
+// responseCacheStatus returns the cache status of resp.
+func responseCacheStatus(resp *http.Response) CacheStatus {
+	if resp == nil {
+		return CacheBypass
+	}
+	return CacheStatus(resp.Header.Get(HeaderCacheStatus))
+}
+
+// DefaultMaxCacheBodySize is the largest response body ResponseCache stores.
+var DefaultMaxCacheBodySize int64 = 1 << 20
+
+// statuses cacheable by default, RFC 9110 15.1
+var heuristicStatuses = []int{200, 203, 204, 300, 301, 308, 404, 405, 410, 414, 501}
+
+/*
+ResponseCache is a private HTTP cache as of RFC 9111.
+
+GET responses are stored when they carry explicit freshness (max-age, Expires) or a validator (ETag, Last-Modified)
+and neither side sent no-store. Fresh responses are served without a request, stale ones are revalidated with
+If-None-Match / If-Modified-Since, responses varying on request headers are matched by Vary and
+stale-if-error serves a stale response when the origin fails or returns 5xx. Unsafe methods invalidate the URL.
+Requests with Range or their own conditional headers bypass the cache. Bodies larger than MaxBodySize or of
+unknown length, e.g. downloads, stream through uncached.
+*/
+type ResponseCache struct {
+	storage     CacheStorage
+	maxBodySize int64
+}
+
+// NewResponseCache creates a new instance.
+func NewResponseCache(storage CacheStorage) *ResponseCache {
+	return &ResponseCache{storage: storage}
+}
+
+// MaxBodySize sets the largest response body to store, default DefaultMaxCacheBodySize.
+func (c *ResponseCache) MaxBodySize(size int64) *ResponseCache {
+	c.maxBodySize = size
+	return c
+}
+
+// bodyLimit returns the largest response body to store.
+func (c *ResponseCache) bodyLimit() int64 {
+	if c.maxBodySize > 0 {
+		return c.maxBodySize
+	}
+	return DefaultMaxCacheBodySize
+}
+
+// Middleware returns the cache as a middleware.
+func (c *ResponseCache) Middleware() Middleware {
+	return c.wrap
+}
+
+// cacheEntry is the stored form of a response.
+type cacheEntry struct {
+	StatusCode   int         `json:"status_code"`
+	Status       string      `json:"status"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+	RequestTime  time.Time   `json:"request_time"`
+	ResponseTime time.Time   `json:"response_time"`
+	// Vary holds the request header values named by the Vary response header
+	Vary map[string]string `json:"vary,omitempty"`
+}
+
+// wrap performs the operation.
+func (c *ResponseCache) wrap(next RoundTripFunc) RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodGet || req.Header.Get(httpx.HeaderRange) != "" ||
+			req.Header.Get(httpx.HeaderIfNoneMatch) != "" || req.Header.Get(httpx.HeaderIfModifiedSince) != "" {
+			resp, err := next(req)
+			if err == nil && !isSafeMethod(req.Method) && resp.StatusCode < http.StatusBadRequest {
+				c.invalidate(req, resp)
+			}
+			return resp, err
+		}
+		reqCC := parseCacheControl(req.Header)
+		if reqCC.has(httpx.CacheControlNoStore) {
+			return next(req)
+		}
+		if len(reqCC) == 0 && strings.EqualFold(req.Header.Get(httpx.HeaderPragma), httpx.CacheControlNoCache) {
+			reqCC[httpx.CacheControlNoCache] = ""
+		}
+
+		key := cacheKey(req.URL)
+		entry := c.load(key, req)
+		if entry != nil && entry.servable(reqCC, time.Now()) {
+			return entry.response(req, CacheHit), nil
+		}
+		if reqCC.has(httpx.CacheControlOnlyIfCached) {
+			return &http.Response{
+				Status:     "504 Gateway Timeout",
+				StatusCode: http.StatusGatewayTimeout,
+				Proto:      "HTTP/1.1", ProtoMajor: 1, ProtoMinor: 1,
+				Header:  http.Header{HeaderCacheStatus: {string(CacheMiss)}},
+				Body:    http.NoBody,
+				Request: req,
+			}, nil
+		}
+
+		outReq := req
+		if entry != nil {
+			etag, lastModified := entry.Header.Get(httpx.HeaderETag), entry.Header.Get(httpx.HeaderLastModified)
+			if etag != "" || lastModified != "" {
+				outReq = req.Clone(req.Context())
+				if etag != "" {
+					outReq.Header.Set(httpx.HeaderIfNoneMatch, etag)
+				}
+				if lastModified != "" {
+					outReq.Header.Set(httpx.HeaderIfModifiedSince, lastModified)
+				}
+			}
+		}
+
+		reqTime := time.Now()
+		resp, err := next(outReq)
+		respTime := time.Now()
+		if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+			if entry != nil && entry.staleIfError(reqCC, respTime) {
+				closeResponse(resp)
+				return entry.response(req, CacheStale), nil
+			}
+			return resp, err
+		}
+		if resp.StatusCode == http.StatusNotModified && entry != nil {
+			closeResponse(resp)
+			entry.update(resp.Header, reqTime, respTime)
+			c.save(key, entry)
+			return entry.response(req, CacheRevalidated), nil
+		}
+		limit := c.bodyLimit()
+		if !storable(resp) || resp.ContentLength < 0 || resp.ContentLength > limit {
+			resp.Header.Set(HeaderCacheStatus, string(CacheMiss))
+			return resp, nil
+		}
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if int64(len(body)) > limit {
+			// Content-Length 与实际长度不符, 已读的部分接回响应体, 不缓存
+			resp.Body = iox.WrapReader(io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body.Close)
+			resp.Header.Set(HeaderCacheStatus, string(CacheMiss))
+			return resp, nil
+		}
+		resp.Body.Close()
+		entry = &cacheEntry{
+			StatusCode:   resp.StatusCode,
+			Status:       resp.Status,
+			Header:       resp.Header.Clone(),
+			Body:         body,
+			RequestTime:  reqTime,
+			ResponseTime: respTime,
+		}
+		for _, name := range varyHeaders(resp.Header) {
+			if entry.Vary == nil {
+				entry.Vary = make(map[string]string)
+			}
+			entry.Vary[name] = strings.Join(req.Header.Values(name), ", ")
+		}
+		c.save(key, entry)
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		resp.Header.Set(HeaderCacheStatus, string(CacheMiss))
+		return resp, nil
+	}
+}
+
+// load returns the entry of key matching the Vary headers of req.
+func (c *ResponseCache) load(key string, req *http.Request) *cacheEntry {
+	data, ok := c.storage.Get(key)
+	if !ok {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil
+	}
+	for name, value := range entry.Vary {
+		if strings.Join(req.Header.Values(name), ", ") != value {
+			return nil
+		}
+	}
+	return &entry
+}
+
+// save stores the entry, the cache is best effort so errors are dropped.
+func (c *ResponseCache) save(key string, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	_ = c.storage.Set(key, data)
+}
+
+// invalidate removes the request URL and the same-origin Location and Content-Location of resp, RFC 9111 4.4.
+func (c *ResponseCache) invalidate(req *http.Request, resp *http.Response) {
+	_ = c.storage.Delete(cacheKey(req.URL))
+	for _, name := range []string{httpx.HeaderLocation, httpx.HeaderContentLocation} {
+		location := resp.Header.Get(name)
+		if location == "" {
+			continue
+		}
+		u, err := req.URL.Parse(location)
+		if err == nil && u.Host == req.URL.Host {
+			_ = c.storage.Delete(cacheKey(u))
+		}
+	}
+}
+
+// servable reports whether the entry may be used without contacting the origin.
+func (e *cacheEntry) servable(reqCC cacheControl, now time.Time) bool {
+	respCC := parseCacheControl(e.Header)
+	if respCC.has(httpx.CacheControlNoCache) || reqCC.has(httpx.CacheControlNoCache) {
+		return false
+	}
+	freshness, age := e.freshness(), e.age(now)
+	if d, ok := reqCC.seconds(httpx.CacheControlMaxAge); ok {
+		freshness = min(freshness, d)
+	}
+	if d, ok := reqCC.seconds(httpx.CacheControlMinFresh); ok {
+		age += d
+	}
+	if age < freshness {
+		return true
+	}
+	if respCC.has(httpx.CacheControlMustRevalidate) || !reqCC.has(httpx.CacheControlMaxStale) {
+		return false
+	}
+	if reqCC[httpx.CacheControlMaxStale] == "" {
+		return true
+	}
+	d, ok := reqCC.seconds(httpx.CacheControlMaxStale)
+	return ok && age-freshness <= d
+}
+
+// staleIfError reports whether the entry may be served when the origin failed, RFC 5861 4.
+func (e *cacheEntry) staleIfError(reqCC cacheControl, now time.Time) bool {
+	respCC := parseCacheControl(e.Header)
+	if respCC.has(httpx.CacheControlMustRevalidate) {
+		return false
+	}
+	staleness := e.age(now) - e.freshness()
+	for _, cc := range []cacheControl{reqCC, respCC} {
+		if d, ok := cc.seconds(httpx.CacheControlStaleIfError); ok && staleness <= d {
+			return true
+		}
+	}
+	return false
+}
+
+// freshness returns the freshness lifetime, RFC 9111 4.2.1.
+func (e *cacheEntry) freshness() time.Duration {
+	if d, ok := parseCacheControl(e.Header).seconds(httpx.CacheControlMaxAge); ok {
+		return d
+	}
+	date := e.date()
+	if expires := e.Header.Get(httpx.HeaderExpires); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return t.Sub(date)
+	}
+	if lastModified, err := http.ParseTime(e.Header.Get(httpx.HeaderLastModified)); err == nil && slices.Contains(heuristicStatuses, e.StatusCode) {
+		// 启发式新鲜度,取 Last-Modified 至今的 10%
+		return date.Sub(lastModified) / 10
+	}
+	return 0
+}
+
+// age returns the current age, RFC 9111 4.2.3.
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	apparent := max(0, e.ResponseTime.Sub(e.date()))
+	var ageValue time.Duration
+	if seconds, err := strconv.ParseInt(e.Header.Get(httpx.HeaderAge), 10, 64); err == nil {
+		ageValue = time.Duration(seconds) * time.Second
+	}
+	corrected := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	return max(apparent, corrected) + now.Sub(e.ResponseTime)
+}
+
+// date returns the Date header, the response time if missing.
+func (e *cacheEntry) date() time.Time {
+	t, err := http.ParseTime(e.Header.Get(httpx.HeaderDate))
+	if err != nil {
+		return e.ResponseTime
+	}
+	return t
+}
+
+// update applies the headers of a 304 response, RFC 9111 3.2.
+func (e *cacheEntry) update(header http.Header, reqTime, respTime time.Time) {
+	for name, values := range header {
+		switch name {
+		case httpx.HeaderContentLength, httpx.HeaderContentEncoding, httpx.HeaderTransferEncoding, HeaderCacheStatus:
+			continue
+		}
+		e.Header[name] = values
+	}
+	e.RequestTime, e.ResponseTime = reqTime, respTime
+}
+
+// response builds the response served from the entry.
+func (e *cacheEntry) response(req *http.Request, status CacheStatus) *http.Response {
+	header := e.Header.Clone()
+	header.Set(httpx.HeaderAge, strconv.FormatInt(int64(e.age(time.Now())/time.Second), 10))
+	header.Set(HeaderCacheStatus, string(status))
+	return &http.Response{
+		Status:        e.Status,
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// storable reports whether resp may be stored, RFC 9111 3.
+func storable(resp *http.Response) bool {
+	respCC := parseCacheControl(resp.Header)
+	if respCC.has(httpx.CacheControlNoStore) || slices.Contains(varyHeaders(resp.Header), "*") {
+		return false
+	}
+	if _, ok := respCC.seconds(httpx.CacheControlMaxAge); ok || resp.Header.Get(httpx.HeaderExpires) != "" {
+		return true
+	}
+	return slices.Contains(heuristicStatuses, resp.StatusCode) &&
+		(resp.Header.Get(httpx.HeaderETag) != "" || resp.Header.Get(httpx.HeaderLastModified) != "")
+}
+
+// varyHeaders returns the canonical header names of Vary.
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, line := range header.Values(httpx.HeaderVary) {
+		for name := range strings.SplitSeq(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// isSafeMethod reports whether the method is safe, RFC 9110 9.2.1.
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// cacheKey returns the storage key of u.
+func cacheKey(u *url.URL) string {
+	key := *u
+	key.Fragment = ""
+	key.RawFragment = ""
+	return key.String()
+}
+
+// cacheControl maps the lowercase directives to their unquoted values.
+type cacheControl map[string]string
+
+// parseCacheControl parses the Cache-Control headers.
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, line := range header.Values(httpx.HeaderCacheControl) {
+		for part := range strings.SplitSeq(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				cc[name] = strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+	}
+	return cc
+}
+
+// has reports whether the directive is present.
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// seconds returns the delta-seconds value of the directive.
+func (cc cacheControl) seconds(directive string) (time.Duration, bool) {
+	value, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
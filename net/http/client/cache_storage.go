@@ -0,0 +1,115 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/hopeio/gox/container/cache"
+)
+
+// CacheStorage stores serialized responses of ResponseCache.
+type CacheStorage interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte) error
+	Delete(key string) error
+}
+
+// MemoryCacheStorage stores responses in a container/cache Cache.
+type MemoryCacheStorage struct {
+	cache *cache.Cache
+}
+
+// NewMemoryCacheStorage creates a new instance, nil c means a LRU cache of 1024 responses.
+func NewMemoryCacheStorage(c *cache.Cache) *MemoryCacheStorage {
+	if c == nil {
+		c = cache.New(1024).LRU()
+	}
+	return &MemoryCacheStorage{cache: c}
+}
+
+// Get returns the value.
+func (s *MemoryCacheStorage) Get(key string) ([]byte, bool) {
+	v, err := s.cache.Get(key)
+	if err != nil {
+		return nil, false
+	}
+	data, ok := v.([]byte)
+	return data, ok
+}
+
+// Set updates or inserts a value.
+func (s *MemoryCacheStorage) Set(key string, value []byte) error {
+	return s.cache.Set(key, value, cache.DefaultExpiration)
+}
+
+// Delete removes a value.
+func (s *MemoryCacheStorage) Delete(key string) error {
+	s.cache.Remove(key)
+	return nil
+}
+
+// DiskCacheStorage stores every response in a file of a directory, named by the sha256 of the key.
+type DiskCacheStorage struct {
+	dir string
+}
+
+// NewDiskCacheStorage creates a new instance, the directory is created if missing.
+func NewDiskCacheStorage(dir string) (*DiskCacheStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DiskCacheStorage{dir: dir}, nil
+}
+
+// path returns the file of key.
+func (s *DiskCacheStorage) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get returns the value.
+func (s *DiskCacheStorage) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Set updates or inserts a value, the file is replaced atomically.
+func (s *DiskCacheStorage) Set(key string, value []byte) error {
+	f, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(value)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Delete removes a value.
+func (s *DiskCacheStorage) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,260 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func cacheStatusLogger(statuses *[]CacheStatus) AccessLog {
+	return func(param *AccessLogParam, err error) {
+		*statuses = append(*statuses, param.Cache)
+	}
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("v1"))
+	}))
+	defer srv.Close()
+
+	var statuses []CacheStatus
+	c := New().LogLevel(LogLevelInfo).Logger(cacheStatusLogger(&statuses)).Cache(NewMemoryCacheStorage(nil))
+	for range 3 {
+		raw, err := c.GetRawX(srv.URL)
+		if err != nil || string(raw) != "v1" {
+			t.Fatalf("got %q %v", raw, err)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("got %d origin hits, want 1", hits.Load())
+	}
+	want := []CacheStatus{CacheMiss, CacheHit, CacheHit}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Fatalf("got statuses %v, want %v", statuses, want)
+		}
+	}
+
+	// no-cache 请求强制回源
+	if _, err := c.Request(http.MethodGet, srv.URL).AddHeader("Cache-Control", "no-cache").DoRaw(nil); err != nil {
+		t.Fatal(err)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("got %d origin hits after no-cache, want 2", hits.Load())
+	}
+}
+
+func TestCacheRevalidate(t *testing.T) {
+	var hits, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body"))
+	}))
+	defer srv.Close()
+
+	var statuses []CacheStatus
+	c := New().LogLevel(LogLevelInfo).Logger(cacheStatusLogger(&statuses)).Cache(NewMemoryCacheStorage(nil))
+	for range 2 {
+		raw, err := c.GetRawX(srv.URL)
+		if err != nil || string(raw) != "body" {
+			t.Fatalf("got %q %v", raw, err)
+		}
+	}
+	if hits.Load() != 2 || notModified.Load() != 1 {
+		t.Errorf("got %d hits, %d not modified", hits.Load(), notModified.Load())
+	}
+	if statuses[1] != CacheRevalidated {
+		t.Errorf("got statuses %v", statuses)
+	}
+}
+
+func TestCacheVary(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(r.Header.Get("Accept-Language")))
+	}))
+	defer srv.Close()
+
+	c := newTestClient().Cache(NewMemoryCacheStorage(nil))
+	get := func(lang string) string {
+		raw, err := c.Request(http.MethodGet, srv.URL).AddHeader("Accept-Language", lang).DoRaw(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(raw)
+	}
+	if get("en") != "en" || get("en") != "en" || get("zh") != "zh" {
+		t.Fatal("wrong variant")
+	}
+	if hits.Load() != 2 {
+		t.Errorf("got %d origin hits, want 2", hits.Load())
+	}
+}
+
+func TestCacheStaleIfError(t *testing.T) {
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=0, stale-if-error=60")
+		w.Write([]byte("old"))
+	}))
+	defer srv.Close()
+
+	var statuses []CacheStatus
+	c := New().LogLevel(LogLevelInfo).Logger(cacheStatusLogger(&statuses)).Cache(NewMemoryCacheStorage(nil))
+	if _, err := c.GetRawX(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	fail.Store(true)
+	raw, err := c.GetRawX(srv.URL)
+	if err != nil || string(raw) != "old" {
+		t.Fatalf("got %q %v", raw, err)
+	}
+	if statuses[1] != CacheStale {
+		t.Errorf("got statuses %v", statuses)
+	}
+}
+
+func TestCacheInvalidateAndDisk(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			hits.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	storage, err := NewDiskCacheStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newTestClient().Cache(storage)
+	for range 2 {
+		if _, err := c.GetRawX(srv.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("got %d origin hits, want 1", hits.Load())
+	}
+	// 新的 client 共享磁盘缓存
+	if _, err := newTestClient().Cache(storage).GetRawX(srv.URL); err != nil || hits.Load() != 1 {
+		t.Fatalf("disk cache not shared: %v, %d hits", err, hits.Load())
+	}
+
+	if _, err := c.Request(http.MethodPost, srv.URL).DoRaw("x"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetRawX(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("got %d origin hits after POST, want 2", hits.Load())
+	}
+}
+
+func TestCacheFreshness(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	e := &cacheEntry{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Date":          {now.Add(-10 * time.Second).UTC().Format(http.TimeFormat)},
+			"Last-Modified": {now.Add(-100 * time.Hour).UTC().Format(http.TimeFormat)},
+			"Age":           {"5"},
+		},
+		RequestTime:  now.Add(-time.Second),
+		ResponseTime: now,
+	}
+	if f := e.freshness(); f < 9*time.Hour || f > 11*time.Hour {
+		t.Errorf("got heuristic freshness %s, want about 10h", f)
+	}
+	if age := e.age(now); age != 10*time.Second {
+		t.Errorf("got age %s, want 10s", age)
+	}
+	e.Header.Set("Cache-Control", "max-age=5")
+	if e.servable(cacheControl{}, now) {
+		t.Error("stale entry served")
+	}
+	if !e.servable(parseCacheControl(http.Header{"Cache-Control": {"max-stale=10"}}), now) {
+		t.Error("max-stale not honored")
+	}
+}
+
+func TestCacheMaxBodySize(t *testing.T) {
+	var hits atomic.Int32
+	big := bytes.Repeat([]byte("x"), 2048)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		switch r.URL.Path {
+		case "/chunked":
+			// Flush 之后按 chunked 发送, 长度未知
+			w.Write([]byte("part1"))
+			w.(http.Flusher).Flush()
+			w.Write([]byte("part2"))
+		case "/big":
+			w.Write(big)
+		default:
+			w.Write([]byte("small"))
+		}
+	}))
+	defer srv.Close()
+
+	next := http.DefaultTransport.RoundTrip
+	get := NewResponseCache(NewMemoryCacheStorage(nil)).MaxBodySize(1024).Middleware()(next)
+	for _, tt := range []struct {
+		path string
+		want []byte
+		hits int32
+	}{
+		{"/small", []byte("small"), 1},
+		{"/big", big, 2},
+		{"/chunked", []byte("part1part2"), 2},
+	} {
+		hits.Store(0)
+		for range 2 {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+tt.path, nil)
+			resp, err := get(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil || !bytes.Equal(body, tt.want) {
+				t.Fatalf("%s: got %d bytes %v", tt.path, len(body), err)
+			}
+		}
+		if hits.Load() != tt.hits {
+			t.Errorf("%s: got %d origin hits, want %d", tt.path, hits.Load(), tt.hits)
+		}
+	}
+}
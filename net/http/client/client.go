@@ -11,6 +11,8 @@ import (
 	"net"
 	"net/http"
 	stdurl "net/url"
+	"reflect"
+	"slices"
 	"time"
 
 	httpx "github.com/hopeio/gox/net/http"
@@ -70,6 +72,8 @@ type Client struct {
 	newHttpClient bool
 	// request
 	httpRequestOptions []HttpRequestOption
+	middlewares        []Middleware
+	auth               Authenticator
 	header             http.Header //shared request headers
 	reqBodyMarshal     func(v any) ([]byte, error)
 
@@ -77,6 +81,8 @@ type Client struct {
 	responseHandler   func(response *http.Response) (retry bool, reader io.ReadCloser, err error)
 	respBodyHandler   func(data []byte) ([]byte, error)
 	respBodyUnMarshal func(data []byte, v any) error
+	errorModel        reflect.Type
+	successStatuses   []int
 
 	// logger
 	logger   AccessLog
@@ -86,6 +92,12 @@ type Client struct {
 	retryTimes    int
 	retryInterval time.Duration
 	retryHandler  func(*http.Request)
+	retryStatuses []int
+	// maxRetryAfter 超过该时长的 Retry-After 不再重试
+	maxRetryAfter time.Duration
+	breaker       *Breaker
+	hedger        *Hedger
+	cache         *ResponseCache
 }
 
 // New creates a new instance.
@@ -223,6 +235,36 @@ func (d *Client) RetryHandler(handle func(r *http.Request)) *Client {
 	return d
 }
 
+// CircuitBreaker sets a per-host circuit breaker, requests to an open host fail with ErrCircuitOpen without retrying.
+// Clones share the breaker.
+func (d *Client) CircuitBreaker(config BreakerConfig) *Client {
+	d.breaker = NewBreaker(config)
+	return d
+}
+
+// Breaker returns the circuit breaker for metrics, nil if not set.
+func (d *Client) Breaker() *Breaker {
+	return d.breaker
+}
+
+// Hedge sends extra attempts of idempotent requests slower than the configured delay and takes the first success.
+// Clones share the hedger.
+func (d *Client) Hedge(config HedgeConfig) *Client {
+	d.hedger = NewHedger(config)
+	return d
+}
+
+// Hedger returns the hedger for metrics, nil if not set.
+func (d *Client) Hedger() *Hedger {
+	return d.hedger
+}
+
+// Cache caches GET responses in storage as of RFC 9111, see ResponseCache. Clones share the cache.
+func (d *Client) Cache(storage CacheStorage) *Client {
+	d.cache = NewResponseCache(storage)
+	return d
+}
+
 // ensureOwnHttpClient performs the operation.
 func (d *Client) ensureOwnHttpClient() {
 	if !d.newHttpClient {
@@ -277,6 +319,12 @@ func (d *Client) Clone() *Client {
 		c.httpRequestOptions = make([]HttpRequestOption, len(d.httpRequestOptions))
 		copy(c.httpRequestOptions, d.httpRequestOptions)
 	}
+	if d.middlewares != nil {
+		c.middlewares = make([]Middleware, len(d.middlewares))
+		copy(c.middlewares, d.middlewares)
+	}
+	c.successStatuses = slices.Clone(d.successStatuses)
+	c.retryStatuses = slices.Clone(d.retryStatuses)
 	return &c
 }
 
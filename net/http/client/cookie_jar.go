@@ -0,0 +1,512 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+	"github.com/hopeio/gox/log"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+type CookieFormat int
+
+const (
+	// CookieFormatNetscape is the cookies.txt format of curl and wget
+	CookieFormatNetscape CookieFormat = iota
+	CookieFormatJSON
+)
+
+// netscapeHttpOnlyPrefix marks HttpOnly cookies in the Netscape format.
+const netscapeHttpOnlyPrefix = "#HttpOnly_"
+
+type CookieJarOptions struct {
+	// PublicSuffixList rejects cookies for public suffixes like co.uk, default publicsuffix.List
+	PublicSuffixList cookiejar.PublicSuffixList
+	// Filename loads the jar and saves it after every change, the format is JSON for .json files
+	// and Netscape otherwise. Session cookies are saved as well, so a login survives a restart.
+	Filename string
+}
+
+// CookieJar is an RFC 6265 cookie jar that can be saved and loaded.
+type CookieJar struct {
+	psList   cookiejar.PublicSuffixList
+	filename string
+
+	mu sync.Mutex
+	// entries by eTLD+1 and cookie id
+	entries map[string]map[string]*cookieEntry
+	seq     uint64
+	saveMu  sync.Mutex
+}
+
+// cookieEntry is a stored cookie.
+type cookieEntry struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Domain   string `json:"domain"`
+	Path     string `json:"path"`
+	SameSite string `json:"sameSite,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+	HttpOnly bool   `json:"httpOnly,omitempty"`
+	// HostOnly cookies are sent to Domain only, not to its subdomains
+	HostOnly bool `json:"hostOnly,omitempty"`
+	// Expires is zero for session cookies
+	Expires  time.Time `json:"expires,omitzero"`
+	Creation time.Time `json:"creation"`
+
+	seq uint64
+}
+
+// id identifies the cookie in its eTLD+1.
+func (e *cookieEntry) id() string {
+	return e.Domain + ";" + e.Path + ";" + e.Name
+}
+
+// expired reports whether the persistent cookie expired.
+func (e *cookieEntry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !e.Expires.After(now)
+}
+
+// NewCookieJar returns a jar, loading Filename if it exists.
+func NewCookieJar(options *CookieJarOptions) (*CookieJar, error) {
+	if options == nil {
+		options = &CookieJarOptions{}
+	}
+	jar := &CookieJar{psList: options.PublicSuffixList, filename: options.Filename, entries: make(map[string]map[string]*cookieEntry)}
+	if jar.psList == nil {
+		jar.psList = publicsuffix.List
+	}
+	if jar.filename != "" {
+		f, err := os.Open(jar.filename)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return jar, nil
+			}
+			return nil, err
+		}
+		defer f.Close()
+		if err = jar.Import(f, fileCookieFormat(jar.filename)); err != nil {
+			return nil, err
+		}
+	}
+	return jar, nil
+}
+
+// fileCookieFormat returns the format of the file by its extension.
+func fileCookieFormat(filename string) CookieFormat {
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		return CookieFormatJSON
+	}
+	return CookieFormatNetscape
+}
+
+// CookieJar sets the cookie jar of the http client, e.g. a *CookieJar.
+func (d *Client) CookieJar(jar http.CookieJar) *Client {
+	return d.SetHttpClient(func(client *http.Client) {
+		client.Jar = jar
+	})
+}
+
+// SetCookies implements http.CookieJar.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return
+	}
+	key := jarKey(host, j.psList)
+	defPath := defaultPath(u.Path)
+	now := time.Now()
+
+	j.mu.Lock()
+	changed := false
+	submap := j.entries[key]
+	for _, c := range cookies {
+		e, remove, err := j.newEntry(c, now, defPath, host)
+		if err != nil {
+			continue
+		}
+		id := e.id()
+		if remove {
+			if _, ok := submap[id]; ok {
+				delete(submap, id)
+				changed = true
+			}
+			continue
+		}
+		if submap == nil {
+			submap = make(map[string]*cookieEntry)
+			j.entries[key] = submap
+		}
+		if old, ok := submap[id]; ok {
+			e.Creation, e.seq = old.Creation, old.seq
+		} else {
+			j.seq++
+			e.seq = j.seq
+		}
+		submap[id] = e
+		changed = true
+	}
+	if len(submap) == 0 {
+		delete(j.entries, key)
+	}
+	j.mu.Unlock()
+
+	if changed && j.filename != "" {
+		if err = j.Save(); err != nil {
+			log.Warn("save cookies:", err)
+		}
+	}
+}
+
+// newEntry validates the cookie set by host, remove is true if the cookie deletes a stored one. RFC 6265 5.3.
+func (j *CookieJar) newEntry(c *http.Cookie, now time.Time, defPath, host string) (e *cookieEntry, remove bool, err error) {
+	e = &cookieEntry{Name: c.Name, Value: c.Value, Secure: c.Secure, HttpOnly: c.HttpOnly, Creation: now}
+	if c.Path == "" || c.Path[0] != '/' {
+		e.Path = defPath
+	} else {
+		e.Path = c.Path
+	}
+	if e.Domain, e.HostOnly, err = j.domainAndType(host, c.Domain); err != nil {
+		return nil, false, err
+	}
+	switch c.SameSite {
+	case http.SameSiteStrictMode:
+		e.SameSite = "Strict"
+	case http.SameSiteLaxMode:
+		e.SameSite = "Lax"
+	case http.SameSiteNoneMode:
+		e.SameSite = "None"
+	}
+	if c.MaxAge < 0 {
+		return e, true, nil
+	} else if c.MaxAge > 0 {
+		e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+	} else if !c.Expires.IsZero() {
+		if !c.Expires.After(now) {
+			return e, true, nil
+		}
+		e.Expires = c.Expires
+	}
+	return e, false, nil
+}
+
+// domainAndType returns the cookie domain and whether it is host-only.
+func (j *CookieJar) domainAndType(host, domain string) (string, bool, error) {
+	if domain == "" {
+		return host, true, nil
+	}
+	if net.ParseIP(host) != nil {
+		// IP 地址只能设置 host-only cookie
+		if host != domain {
+			return "", false, errors.New("cookie: domain on an IP host")
+		}
+		return host, true, nil
+	}
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	if domain == "" || strings.HasSuffix(domain, ".") {
+		return "", false, errors.New("cookie: malformed domain")
+	}
+	if ps := j.psList.PublicSuffix(domain); ps != "" && !hasDotSuffix(domain, ps) {
+		// 公共后缀只允许作为 host-only cookie
+		if host == domain {
+			return host, true, nil
+		}
+		return "", false, errors.New("cookie: domain is a public suffix")
+	}
+	if host != domain && !hasDotSuffix(host, domain) {
+		return "", false, errors.New("cookie: domain does not match host")
+	}
+	return domain, false, nil
+}
+
+// Cookies implements http.CookieJar.
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return nil
+	}
+	key := jarKey(host, j.psList)
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	now := time.Now()
+
+	j.mu.Lock()
+	var selected []*cookieEntry
+	for id, e := range j.entries[key] {
+		if e.expired(now) {
+			delete(j.entries[key], id)
+			continue
+		}
+		if e.Secure && u.Scheme != "https" {
+			continue
+		}
+		if e.HostOnly && host != e.Domain || !e.HostOnly && host != e.Domain && !hasDotSuffix(host, e.Domain) {
+			continue
+		}
+		if !pathMatch(e.Path, path) {
+			continue
+		}
+		selected = append(selected, e)
+	}
+	j.mu.Unlock()
+
+	// 路径长的优先,其次创建早的优先
+	slices.SortFunc(selected, func(a, b *cookieEntry) int {
+		if len(a.Path) != len(b.Path) {
+			return len(b.Path) - len(a.Path)
+		}
+		if c := a.Creation.Compare(b.Creation); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.seq, b.seq)
+	})
+	cookies := make([]*http.Cookie, len(selected))
+	for i, e := range selected {
+		cookies[i] = &http.Cookie{Name: e.Name, Value: e.Value}
+	}
+	return cookies
+}
+
+// Clear removes all cookies.
+func (j *CookieJar) Clear() {
+	j.mu.Lock()
+	j.entries = make(map[string]map[string]*cookieEntry)
+	j.mu.Unlock()
+	if j.filename != "" {
+		if err := j.Save(); err != nil {
+			log.Warn("save cookies:", err)
+		}
+	}
+}
+
+// all returns the unexpired cookies in creation order.
+func (j *CookieJar) all() []*cookieEntry {
+	now := time.Now()
+	j.mu.Lock()
+	var all []*cookieEntry
+	for _, submap := range j.entries {
+		for _, e := range submap {
+			if !e.expired(now) {
+				all = append(all, e)
+			}
+		}
+	}
+	j.mu.Unlock()
+	slices.SortFunc(all, func(a, b *cookieEntry) int { return cmp.Compare(a.seq, b.seq) })
+	return all
+}
+
+// Save writes the jar to Filename atomically.
+func (j *CookieJar) Save() error {
+	if j.filename == "" {
+		return errors.New("cookie jar has no filename")
+	}
+	j.saveMu.Lock()
+	defer j.saveMu.Unlock()
+	var buf bytes.Buffer
+	if err := j.Export(&buf, fileCookieFormat(j.filename)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.filename), 0755); err != nil {
+		return err
+	}
+	tmp := j.filename + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.filename)
+}
+
+// Export writes the unexpired cookies in the format.
+func (j *CookieJar) Export(w io.Writer, format CookieFormat) error {
+	all := j.all()
+	if format == CookieFormatJSON {
+		if all == nil {
+			all = []*cookieEntry{}
+		}
+		data, err := jsonx.Marshal(all)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# Netscape HTTP Cookie File\n\n")
+	for _, e := range all {
+		domain, subdomains := e.Domain, "FALSE"
+		if !e.HostOnly {
+			domain, subdomains = "."+domain, "TRUE"
+		}
+		if e.HttpOnly {
+			domain = netscapeHttpOnlyPrefix + domain
+		}
+		var expires int64
+		if !e.Expires.IsZero() {
+			expires = e.Expires.Unix()
+		}
+		secure := "FALSE"
+		if e.Secure {
+			secure = "TRUE"
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, subdomains, e.Path, secure, expires, e.Name, e.Value)
+	}
+	return bw.Flush()
+}
+
+// Import adds the cookies read in the format, expired ones are skipped.
+func (j *CookieJar) Import(r io.Reader, format CookieFormat) error {
+	var entries []*cookieEntry
+	if format == CookieFormatJSON {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			if err = jsonx.Unmarshal(data, &entries); err != nil {
+				return err
+			}
+		}
+	} else {
+		scanner := bufio.NewScanner(r)
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			e := &cookieEntry{}
+			if strings.HasPrefix(text, netscapeHttpOnlyPrefix) {
+				e.HttpOnly = true
+				text = text[len(netscapeHttpOnlyPrefix):]
+			} else if text == "" || text[0] == '#' {
+				continue
+			}
+			fields := strings.Split(text, "\t")
+			if len(fields) == 6 {
+				// 空值的 cookie
+				fields = append(fields, "")
+			}
+			if len(fields) != 7 {
+				return fmt.Errorf("cookie file line %d: want 7 fields, got %d", line, len(fields))
+			}
+			expires, err := strconv.ParseInt(fields[4], 10, 64)
+			if err != nil {
+				return fmt.Errorf("cookie file line %d: %w", line, err)
+			}
+			e.HostOnly = !strings.EqualFold(fields[1], "TRUE")
+			e.Domain = strings.ToLower(strings.TrimPrefix(fields[0], "."))
+			e.Path = fields[2]
+			e.Secure = strings.EqualFold(fields[3], "TRUE")
+			if expires > 0 {
+				e.Expires = time.Unix(expires, 0)
+			}
+			e.Name, e.Value = fields[5], fields[6]
+			entries = append(entries, e)
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, e := range entries {
+		if e.Domain == "" || e.expired(now) {
+			continue
+		}
+		if e.Path == "" {
+			e.Path = "/"
+		}
+		if e.Creation.IsZero() {
+			e.Creation = now
+		}
+		j.seq++
+		e.seq = j.seq
+		key := jarKey(e.Domain, j.psList)
+		if j.entries[key] == nil {
+			j.entries[key] = make(map[string]*cookieEntry)
+		}
+		j.entries[key][e.id()] = e
+	}
+	return nil
+}
+
+// canonicalHost strips the port and converts the host to lower case ASCII.
+func canonicalHost(host string) (string, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSuffix(host, "]"), "["), ".")
+	return idna.Lookup.ToASCII(strings.ToLower(host))
+}
+
+// jarKey returns the eTLD+1 of host, the key cookies of host are stored under.
+func jarKey(host string, psl cookiejar.PublicSuffixList) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	suffix := psl.PublicSuffix(host)
+	if suffix == host {
+		return host
+	}
+	i := len(host) - len(suffix)
+	if i <= 0 || host[i-1] != '.' {
+		return host
+	}
+	return host[strings.LastIndex(host[:i-1], ".")+1:]
+}
+
+// hasDotSuffix reports whether s ends with "."+suffix.
+func hasDotSuffix(s, suffix string) bool {
+	return len(s) > len(suffix) && s[len(s)-len(suffix)-1] == '.' && s[len(s)-len(suffix):] == suffix
+}
+
+// defaultPath returns the default cookie path of the request path, RFC 6265 5.1.4.
+func defaultPath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+// pathMatch reports whether the request path matches the cookie path, RFC 6265 5.1.4.
+func pathMatch(cookiePath, path string) bool {
+	if path == cookiePath {
+		return true
+	}
+	return strings.HasPrefix(path, cookiePath) && (cookiePath[len(cookiePath)-1] == '/' || path[len(cookiePath)] == '/')
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func cookieString(cookies []*http.Cookie) string {
+	var parts []string
+	for _, c := range cookies {
+		parts = append(parts, c.Name+"="+c.Value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func TestCookieJarRules(t *testing.T) {
+	jar, err := NewCookieJar(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("https://www.example.co.uk/a/b")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "suffix", Value: "1", Domain: "co.uk"},
+		{Name: "domain", Value: "2", Domain: ".example.co.uk"},
+		{Name: "host", Value: "3"},
+		{Name: "path", Value: "4", Path: "/a/b"},
+		{Name: "secure", Value: "5", Secure: true},
+		{Name: "other", Value: "6", Domain: "other.co.uk"},
+	})
+	for _, tc := range []struct {
+		url, want string
+	}{
+		{"https://www.example.co.uk/a/b/c", "path=4; domain=2; host=3; secure=5"},
+		{"http://www.example.co.uk/a", "domain=2; host=3"},
+		{"https://api.example.co.uk/a", "domain=2"},
+		{"https://www.example.co.uk/", ""},
+		{"https://example.co.uk/a/bc", "domain=2"},
+		{"https://other.co.uk/", ""},
+	} {
+		u, _ := url.Parse(tc.url)
+		if got := cookieString(jar.Cookies(u)); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.url, got, tc.want)
+		}
+	}
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "host", MaxAge: -1}, {Name: "domain", Value: "7", Domain: "example.co.uk"}})
+	if got := cookieString(jar.Cookies(u)); got != "path=4; domain=7; secure=5" {
+		t.Errorf("after delete got %q", got)
+	}
+}
+
+func TestCookieJarPersistence(t *testing.T) {
+	dir := t.TempDir()
+	u, _ := url.Parse("http://example.com/")
+	for _, name := range []string{"cookies.txt", "cookies.json"} {
+		filename := filepath.Join(dir, name)
+		jar, err := NewCookieJar(&CookieJarOptions{Filename: filename})
+		if err != nil {
+			t.Fatal(err)
+		}
+		jar.SetCookies(u, []*http.Cookie{
+			{Name: "session", Value: "s", HttpOnly: true},
+			{Name: "persistent", Value: "p", Domain: "example.com", MaxAge: 3600},
+			{Name: "empty", Value: ""},
+		})
+
+		loaded, err := NewCookieJar(&CookieJarOptions{Filename: filename})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, _ := url.Parse("http://www.example.com/")
+		if got := cookieString(loaded.Cookies(u)); got != "session=s; persistent=p; empty=" {
+			t.Errorf("%s: got %q", name, got)
+		}
+		if got := cookieString(loaded.Cookies(sub)); got != "persistent=p" {
+			t.Errorf("%s subdomain: got %q", name, got)
+		}
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "cookies.txt"))
+	if !strings.Contains(string(data), "#HttpOnly_example.com\tFALSE\t/\tFALSE\t0\tsession\ts\n") ||
+		!strings.Contains(string(data), ".example.com\tTRUE\t/\tFALSE\t") {
+		t.Errorf("unexpected netscape file:\n%s", data)
+	}
+}
+
+func TestSession(t *testing.T) {
+	var current atomic.Int64
+	var logins atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		logins.Add(1)
+		sid := current.Add(1)
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: strconv.FormatInt(sid, 10)})
+	})
+	mux.HandleFunc("/data", func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("sid")
+		if err != nil || c.Value != strconv.FormatInt(current.Load(), 10) || len(r.Cookies()) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	jar, _ := NewCookieJar(nil)
+	c := newTestClient().BaseUrl(srv.URL).CookieJar(jar)
+	c.Session(SessionConfig{Login: func(ctx context.Context) error {
+		return c.Request(http.MethodPost, "/login").Context(ctx).Do(nil, nil)
+	}})
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			var resp struct{ Ok bool }
+			if err := c.Get("/data", nil, &resp); err != nil || !resp.Ok {
+				t.Errorf("got %v %v", resp, err)
+			}
+		})
+	}
+	wg.Wait()
+	// 会话过期后重新登录
+	current.Add(1)
+	var resp struct{ Ok bool }
+	if err := c.Get("/data", nil, &resp); err != nil || !resp.Ok {
+		t.Fatalf("got %v %v", resp, err)
+	}
+	if n := logins.Load(); n != 2 {
+		t.Errorf("got %d logins, want 2", n)
+	}
+}
@@ -43,6 +43,11 @@ type DownloadReq struct {
 	header     http.Header  //request-level headers
 	mode       DownloadMode // mode: 0 force overwrite, 1 download if missing, 2 resume
 	rangeSize  int64
+	// segmented download
+	mirrors  []string
+	checksum ChecksumAlgorithm
+	sum      string
+	progress func(Progress)
 }
 
 // NewDownloadReq creates and returns a new instance.
@@ -110,16 +115,21 @@ func (dReq *DownloadReq) OverwriteMode() *DownloadReq {
 
 // GetResponse returns the value.
 func (dReq *DownloadReq) GetResponse(options ...func(*http.Request)) (*http.Response, error) {
+	return dReq.response(dReq.ctx, dReq.Url, dReq.header, options...)
+}
+
+// response requests url with header, retrying transport errors.
+func (dReq *DownloadReq) response(ctx context.Context, url string, header http.Header, options ...func(*http.Request)) (*http.Response, error) {
 	d := dReq.downloader
-	req, err := http.NewRequestWithContext(dReq.ctx, http.MethodGet, dReq.Url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	// If Accept-Encoding is set manually, net/http will not auto-decompress gzip; omit Accept-Encoding/Range to let it set gzip
 	//req.Header.Set("Accept-Encoding", "gzip, deflate")
-	if dReq.header != nil {
-		req.Header = dReq.header
+	if header != nil {
+		req.Header = header
 	}
 	if _, ok := req.Header[httpx.HeaderAcceptLanguage]; !ok {
 		req.Header.Set(httpx.HeaderAcceptLanguage, "zh-CN,zh;q=0.9;charset=utf-8")
@@ -146,16 +156,16 @@ func (dReq *DownloadReq) GetResponse(options ...func(*http.Request)) (*http.Resp
 	for i := 0; i < times; i++ {
 		if i > 0 {
 			select {
-			case <-dReq.ctx.Done():
-				return nil, dReq.ctx.Err()
+			case <-ctx.Done():
+				return nil, ctx.Err()
 			case <-time.After(d.retryInterval):
 			}
 		}
-		resp, err = d.httpClient.Do(req)
+		resp, err = d.do(req)
 		if err != nil {
 			log.Warn(err, "url:", req.URL.Path)
 			var dnsErr *net.DNSError
-			if errors.As(err, &dnsErr) {
+			if errors.As(err, &dnsErr) || errors.Is(err, ErrCircuitOpen) {
 				return nil, err
 			}
 			continue
@@ -357,14 +367,6 @@ func (dReq *DownloadReq) continuationDownload(filepath string) error {
 const defaultRange = "bytes=0-"
 const defaultSize = 30 * 1024 * 1024
 
-// ConcurrencyDownload performs the operation.
-func (dReq *DownloadReq) ConcurrencyDownload(filepath string, concurrencyNum int) error {
-	if dReq.mode&DModeOverwrite == 0 && fs.Exist(filepath) {
-		return nil
-	}
-	panic("not implemented")
-}
-
 // GetReader returns the value.
 func GetReader(url string) (io.ReadCloser, error) {
 	return GetReaderWithHttpRequestOptions(url)
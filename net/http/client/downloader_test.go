@@ -6,7 +6,22 @@
 
 package client
 
-import "testing"
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	httpx "github.com/hopeio/gox/net/http"
+)
 
 func TestFetch(t *testing.T) {
 	_, err := GetReader("")
@@ -14,3 +29,162 @@ func TestFetch(t *testing.T) {
 		t.Log(err)
 	}
 }
+
+type fileServer struct {
+	content []byte
+	etag    atomic.Value
+	served  atomic.Int64
+	// failAfter makes range requests fail once this many bytes were served, 0 means never
+	failAfter int64
+	noRange   bool
+}
+
+func (s *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.failAfter > 0 && s.served.Load() >= s.failAfter {
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+		return
+	}
+	if s.noRange {
+		r.Header.Del(httpx.HeaderRange)
+	}
+	w.Header().Set(httpx.HeaderETag, s.etag.Load().(string))
+	counter := &countingWriter{ResponseWriter: w, n: &s.served}
+	http.ServeContent(counter, r, "file", time.Time{}, bytes.NewReader(s.content))
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n *atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n.Add(int64(len(p)))
+	return w.ResponseWriter.Write(p)
+}
+
+func newFileServer(t *testing.T, size int) (*fileServer, *httptest.Server) {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(rand.IntN(256))
+	}
+	fs := &fileServer{content: content}
+	fs.etag.Store(`"v1"`)
+	srv := httptest.NewServer(fs)
+	t.Cleanup(srv.Close)
+	return fs, srv
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func testDownloader() *Downloader {
+	return NewDownloader().RetryTimesWithInterval(2, time.Millisecond)
+}
+
+func TestConcurrencyDownloadMirrors(t *testing.T) {
+	primary, srv := newFileServer(t, 100_000)
+	mirror := httptest.NewServer(primary)
+	defer mirror.Close()
+	dst := filepath.Join(t.TempDir(), "file")
+
+	var last Progress
+	err := testDownloader().DownloadReq(srv.URL).Mirrors(mirror.URL).ChunkSize(7000).
+		Checksum(ChecksumSHA256, sha256Hex(primary.content)).
+		Progress(func(p Progress) { last = p }).
+		ConcurrencyDownload(dst, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(dst)
+	if !bytes.Equal(got, primary.content) {
+		t.Fatal("content mismatch")
+	}
+	if last.Total != 100_000 || last.Downloaded != 100_000 || last.Proportion() != 1 {
+		t.Errorf("got progress %+v", last)
+	}
+	if _, err = os.Stat(dst + DownloadKey + ManifestKey); !os.IsNotExist(err) {
+		t.Errorf("manifest not removed: %v", err)
+	}
+}
+
+func TestConcurrencyDownloadResume(t *testing.T) {
+	fs, srv := newFileServer(t, 50_000)
+	fs.failAfter = 20_000
+	dst := filepath.Join(t.TempDir(), "file")
+
+	req := testDownloader().DownloadReq(srv.URL).ChunkSize(4000)
+	if err := req.ConcurrencyDownload(dst, 2); err == nil {
+		t.Fatal("want error from the failing server")
+	}
+	m := loadManifest(dst + DownloadKey + ManifestKey)
+	if m == nil || m.downloaded() == 0 {
+		t.Fatal("manifest not saved")
+	}
+	resumed := m.downloaded()
+
+	fs.failAfter = 0
+	fs.served.Store(0)
+	if err := req.ConcurrencyDownload(dst, 2); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(dst)
+	if !bytes.Equal(got, fs.content) {
+		t.Fatal("content mismatch")
+	}
+	// 探测请求返回 1 字节
+	if served := fs.served.Load(); served != 50_000-resumed+1 {
+		t.Errorf("served %d bytes, want %d", served, 50_000-resumed+1)
+	}
+}
+
+func TestConcurrencyDownloadRemoteChanged(t *testing.T) {
+	fs, srv := newFileServer(t, 30_000)
+	fs.failAfter = 10_000
+	dst := filepath.Join(t.TempDir(), "file")
+	req := testDownloader().DownloadReq(srv.URL).ChunkSize(3000)
+	req.ConcurrencyDownload(dst, 1)
+
+	// 文件变化后重新下载全部内容
+	fs.failAfter = 0
+	fs.served.Store(0)
+	fs.content = bytes.Repeat([]byte("b"), 30_000)
+	fs.etag.Store(`"v2"`)
+	if err := req.ConcurrencyDownload(dst, 3); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(dst)
+	if !bytes.Equal(got, fs.content) {
+		t.Fatal("content mismatch")
+	}
+	if served := fs.served.Load(); served != 30_000+1 {
+		t.Errorf("served %d bytes", served)
+	}
+}
+
+func TestConcurrencyDownloadChecksumAndNoRange(t *testing.T) {
+	fs, srv := newFileServer(t, 10_000)
+	dir := t.TempDir()
+
+	err := testDownloader().DownloadReq(srv.URL).Checksum(ChecksumMD5, "00").ConcurrencyDownload(filepath.Join(dir, "bad"), 2)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("got %v, want ErrChecksumMismatch", err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "bad"+DownloadKey)); !os.IsNotExist(err) {
+		t.Errorf("temporary file not removed: %v", err)
+	}
+
+	fs.noRange = true
+	var last Progress
+	dst := filepath.Join(dir, "file")
+	err = testDownloader().DownloadReq(srv.URL).Checksum(ChecksumSHA256, sha256Hex(fs.content)).
+		Progress(func(p Progress) { last = p }).ConcurrencyDownload(dst, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(dst)
+	if !bytes.Equal(got, fs.content) || last.Downloaded != 10_000 {
+		t.Fatalf("content mismatch, progress %+v", last)
+	}
+}
@@ -0,0 +1,430 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+	"github.com/hopeio/gox/log"
+	httpx "github.com/hopeio/gox/net/http"
+)
+
+const harRedacted = "REDACTED"
+
+// DefaultHARRedactHeaders are the headers masked in recordings by default.
+var DefaultHARRedactHeaders = []string{httpx.HeaderAuthorization, httpx.HeaderProxyAuthorization, httpx.HeaderCookie, httpx.HeaderSetCookie}
+
+// HARRecorder is a transport recording the traffic as HAR entries, e.g. to replay it in tests with HARReplayer.
+type HARRecorder struct {
+	// Transport sends the requests, default http.DefaultTransport
+	Transport http.RoundTripper
+	// RedactHeaders are masked in the recording, nil means DefaultHARRedactHeaders
+	RedactHeaders []string
+	// Redact edits every entry before it is kept, e.g. to mask tokens in bodies
+	Redact func(entry *httpx.HAREntry)
+
+	mu  sync.Mutex
+	har *httpx.HAR
+}
+
+// NewHARRecorder creates and returns a new instance.
+func NewHARRecorder(transport http.RoundTripper) *HARRecorder {
+	return &HARRecorder{Transport: transport, har: httpx.NewHAR("gox")}
+}
+
+// RecordHAR sends the requests of the client through the recorder.
+func (d *Client) RecordHAR(recorder *HARRecorder) *Client {
+	return d.SetHttpClient(func(c *http.Client) {
+		if recorder.Transport == nil {
+			recorder.Transport = c.Transport
+		}
+		c.Transport = recorder
+	})
+}
+
+// harTrace collects the timings of a request with httptrace.
+type harTrace struct {
+	start, dnsStart, dnsDone, connectStart, connectDone, tlsStart, tlsDone, gotConn, wroteRequest, firstByte time.Time
+	remoteAddr                                                                                               string
+}
+
+func (t *harTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { t.dnsStart = time.Now() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.dnsDone = time.Now() },
+		ConnectStart:         func(string, string) { t.connectStart = time.Now() },
+		ConnectDone:          func(string, string, error) { t.connectDone = time.Now() },
+		TLSHandshakeStart:    func() { t.tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.tlsDone = time.Now() },
+		GotConn:              t.gotConnInfo,
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.wroteRequest = time.Now() },
+		GotFirstResponseByte: func() { t.firstByte = time.Now() },
+	}
+}
+
+func (t *harTrace) gotConnInfo(info httptrace.GotConnInfo) {
+	t.gotConn = time.Now()
+	if info.Conn != nil {
+		t.remoteAddr = info.Conn.RemoteAddr().String()
+	}
+}
+
+// harMillis returns the milliseconds between start and end, -1 if either is unknown.
+func harMillis(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return -1
+	}
+	return float64(end.Sub(start).Microseconds()) / 1000
+}
+
+// timings returns the HAR timings, end is when the body was read.
+func (t *harTrace) timings(end time.Time) httpx.HARTimings {
+	timings := httpx.HARTimings{
+		Blocked: -1,
+		DNS:     harMillis(t.dnsStart, t.dnsDone),
+		Connect: harMillis(t.connectStart, t.connectDone),
+		SSL:     harMillis(t.tlsStart, t.tlsDone),
+		Send:    max(harMillis(t.gotConn, t.wroteRequest), 0),
+		Wait:    max(harMillis(t.wroteRequest, t.firstByte), 0),
+		Receive: max(harMillis(t.firstByte, end), 0),
+	}
+	// HAR 的 connect 包含 ssl
+	if timings.Connect >= 0 && timings.SSL >= 0 {
+		timings.Connect += timings.SSL
+	}
+	if dialStart := t.dnsStart; !dialStart.IsZero() || !t.connectStart.IsZero() {
+		if dialStart.IsZero() {
+			dialStart = t.connectStart
+		}
+		timings.Blocked = harMillis(t.start, dialStart)
+	} else if !t.gotConn.IsZero() {
+		timings.Blocked = harMillis(t.start, t.gotConn)
+	}
+	return timings
+}
+
+// RoundTrip implements http.RoundTripper, the entry is kept when the response body is read or closed.
+func (r *HARRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = data
+	}
+	trace := &harTrace{start: time.Now()}
+	sent := req.Clone(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+	if reqBody != nil {
+		sent.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(sent)
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+	resp.Body = &harBody{ReadCloser: resp.Body, done: func(body []byte) {
+		end := time.Now()
+		entry := httpx.HAREntry{
+			StartedDateTime: trace.start,
+			Time:            harMillis(trace.start, end),
+			Request:         httpx.NewHARRequest(req, reqBody),
+			Response:        httpx.NewHARResponse(resp.Proto, resp.StatusCode, resp.Header, body),
+			Timings:         trace.timings(end),
+			ServerIPAddress: trace.remoteAddr,
+		}
+		if host, _, ok := strings.Cut(entry.ServerIPAddress, "]"); ok {
+			entry.ServerIPAddress = strings.TrimPrefix(host, "[")
+		} else if i := strings.LastIndexByte(entry.ServerIPAddress, ':'); i >= 0 {
+			entry.ServerIPAddress = entry.ServerIPAddress[:i]
+		}
+		r.add(&entry)
+	}}
+	return resp, nil
+}
+
+// add redacts and keeps the entry.
+func (r *HARRecorder) add(entry *httpx.HAREntry) {
+	redactHeaders := r.RedactHeaders
+	if redactHeaders == nil {
+		redactHeaders = DefaultHARRedactHeaders
+	}
+	for _, name := range redactHeaders {
+		redactHARHeaders(entry.Request.Headers, name)
+		redactHARHeaders(entry.Response.Headers, name)
+		if strings.EqualFold(name, httpx.HeaderCookie) {
+			for i := range entry.Request.Cookies {
+				entry.Request.Cookies[i].Value = harRedacted
+			}
+		}
+		if strings.EqualFold(name, httpx.HeaderSetCookie) {
+			for i := range entry.Response.Cookies {
+				entry.Response.Cookies[i].Value = harRedacted
+			}
+		}
+	}
+	if r.Redact != nil {
+		r.Redact(entry)
+	}
+	r.mu.Lock()
+	if r.har == nil {
+		r.har = httpx.NewHAR("gox")
+	}
+	r.har.Log.Entries = append(r.har.Log.Entries, *entry)
+	r.mu.Unlock()
+}
+
+func redactHARHeaders(headers []httpx.HARNameValue, name string) {
+	for i := range headers {
+		if strings.EqualFold(headers[i].Name, name) {
+			headers[i].Value = harRedacted
+		}
+	}
+}
+
+// HAR returns a copy of the recorded archive.
+func (r *HARRecorder) HAR() *httpx.HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.har == nil {
+		return httpx.NewHAR("gox")
+	}
+	har := *r.har
+	har.Log.Entries = slices.Clone(r.har.Log.Entries)
+	return &har
+}
+
+// Save writes the recorded archive to the file.
+func (r *HARRecorder) Save(filename string) error {
+	return r.HAR().WriteFile(filename)
+}
+
+// harBody buffers the response body and reports it once on EOF or Close.
+type harBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	once sync.Once
+	done func(body []byte)
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.once.Do(func() { b.done(b.buf.Bytes()) })
+	}
+	return n, err
+}
+
+func (b *harBody) Close() error {
+	b.once.Do(func() { b.done(b.buf.Bytes()) })
+	return b.ReadCloser.Close()
+}
+
+// HARMatcher reports whether a recorded entry answers the request, body is the request body.
+type HARMatcher func(req *http.Request, body []byte, entry *httpx.HAREntry) bool
+
+// MatchMethod matches the request method.
+func MatchMethod(req *http.Request, _ []byte, entry *httpx.HAREntry) bool {
+	return req.Method == entry.Request.Method
+}
+
+// MatchURL matches the url, the order of query parameters is ignored.
+func MatchURL(req *http.Request, _ []byte, entry *httpx.HAREntry) bool {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == req.URL.Scheme && u.Host == req.URL.Host && u.Path == req.URL.Path &&
+		reflect.DeepEqual(u.Query(), req.URL.Query())
+}
+
+// MatchPath matches the url without the query.
+func MatchPath(req *http.Request, _ []byte, entry *httpx.HAREntry) bool {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == req.URL.Scheme && u.Host == req.URL.Host && u.Path == req.URL.Path
+}
+
+// MatchBody matches the request body, JSON bodies are compared by value.
+func MatchBody(_ *http.Request, body []byte, entry *httpx.HAREntry) bool {
+	recorded, err := entry.Request.Body()
+	if err != nil {
+		return false
+	}
+	if bytes.Equal(body, recorded) {
+		return true
+	}
+	var v1, v2 any
+	if jsonx.Unmarshal(body, &v1) != nil || jsonx.Unmarshal(recorded, &v2) != nil {
+		return false
+	}
+	return reflect.DeepEqual(v1, v2)
+}
+
+// MatchHeaders returns a matcher of the header values.
+func MatchHeaders(names ...string) HARMatcher {
+	return func(req *http.Request, _ []byte, entry *httpx.HAREntry) bool {
+		header := httpx.HTTPHeader(entry.Request.Headers)
+		for _, name := range names {
+			if !slices.Equal(req.Header.Values(name), header.Values(name)) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// DefaultHARMatchers match method, url and body.
+var DefaultHARMatchers = []HARMatcher{MatchMethod, MatchURL, MatchBody}
+
+// ErrHARNoMatch is returned for requests without a recorded entry.
+var ErrHARNoMatch = errors.New("har: no recorded entry matches the request")
+
+// HARReplayer is a transport answering requests with recorded entries, it never touches the network.
+// Entries are used in order, when all matching entries were used the last one is repeated.
+type HARReplayer struct {
+	// Matchers all must match, nil means DefaultHARMatchers
+	Matchers []HARMatcher
+
+	mu        sync.Mutex
+	entries   []httpx.HAREntry
+	used      []bool
+	unmatched []string
+}
+
+// NewHARReplayer creates and returns a new instance.
+func NewHARReplayer(har *httpx.HAR) *HARReplayer {
+	return &HARReplayer{entries: har.Log.Entries, used: make([]bool, len(har.Log.Entries))}
+}
+
+// LoadHARReplayer replays the archive file.
+func LoadHARReplayer(filename string) (*HARReplayer, error) {
+	har, err := httpx.ReadHARFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewHARReplayer(har), nil
+}
+
+// ReplayHAR answers the requests of the client with the replayer.
+func (d *Client) ReplayHAR(replayer *HARReplayer) *Client {
+	return d.SetHttpClient(func(c *http.Client) {
+		c.Transport = replayer
+	})
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *HARReplayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = data
+	}
+	matchers := r.Matchers
+	if matchers == nil {
+		matchers = DefaultHARMatchers
+	}
+	r.mu.Lock()
+	match, last := -1, -1
+	for i := range r.entries {
+		if !r.matches(matchers, req, body, &r.entries[i]) {
+			continue
+		}
+		last = i
+		if !r.used[i] {
+			match = i
+			break
+		}
+	}
+	if match < 0 {
+		match = last
+	}
+	if match < 0 {
+		r.unmatched = append(r.unmatched, req.Method+" "+req.URL.String())
+		r.mu.Unlock()
+		log.Error("har replay: unmatched request ", req.Method, " ", req.URL.String())
+		return nil, fmt.Errorf("%w: %s %s", ErrHARNoMatch, req.Method, req.URL)
+	}
+	r.used[match] = true
+	entry := &r.entries[match]
+	r.mu.Unlock()
+
+	respBody, err := entry.Response.Body()
+	if err != nil {
+		return nil, err
+	}
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.Response.Status, entry.Response.StatusText),
+		StatusCode:    entry.Response.Status,
+		Proto:         entry.Response.HTTPVersion,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        httpx.HTTPHeader(entry.Response.Headers),
+		Body:          io.NopCloser(bytes.NewReader(respBody)),
+		ContentLength: int64(len(respBody)),
+		Request:       req,
+	}
+	if major, minor, ok := http.ParseHTTPVersion(resp.Proto); ok {
+		resp.ProtoMajor, resp.ProtoMinor = major, minor
+	}
+	resp.Header.Del(httpx.HeaderContentLength)
+	return resp, nil
+}
+
+func (r *HARReplayer) matches(matchers []HARMatcher, req *http.Request, body []byte, entry *httpx.HAREntry) bool {
+	for _, match := range matchers {
+		if !match(req, body, entry) {
+			return false
+		}
+	}
+	return true
+}
+
+// Unmatched returns the requests that had no recorded entry.
+func (r *HARReplayer) Unmatched() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.unmatched)
+}
+
+// Unused returns the recorded entries never replayed.
+func (r *HARReplayer) Unused() []httpx.HAREntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []httpx.HAREntry
+	for i, used := range r.used {
+		if !used {
+			unused = append(unused, r.entries[i])
+		}
+	}
+	return unused
+}
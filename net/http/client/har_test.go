@@ -0,0 +1,100 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	httpx "github.com/hopeio/gox/net/http"
+)
+
+func TestHARRecordReplay(t *testing.T) {
+	binary := []byte{0xff, 0xd8, 0x00, 0x01}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set(httpx.HeaderContentType, httpx.ContentTypeJson)
+			w.Header().Set(httpx.HeaderSetCookie, "sid=secret")
+			w.Write([]byte(`{"echo":` + string(body) + `,"q":"` + r.URL.Query().Get("q") + `"}`))
+		case "/bin":
+			w.Header().Set(httpx.HeaderContentType, "image/jpeg")
+			w.Write(binary)
+		}
+	}))
+
+	recorder := NewHARRecorder(nil)
+	recorder.Redact = func(entry *httpx.HAREntry) {
+		entry.Comment = "recorded"
+	}
+	c := newTestClient().RecordHAR(recorder).Header(http.Header{httpx.HeaderAuthorization: []string{"Bearer secret"}})
+	var resp map[string]any
+	if err := c.Post(server.URL+"/json?q=1&r=2", map[string]int{"a": 1, "b": 2}, &resp); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := c.GetRaw(server.URL+"/bin", nil)
+	if err != nil || !bytes.Equal(raw, binary) {
+		t.Fatalf("got %v, %v", raw, err)
+	}
+	server.Close()
+
+	filename := filepath.Join(t.TempDir(), "traffic.har")
+	if err = recorder.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	har, err := httpx.ReadHARFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(har.Log.Entries) != 2 || har.Log.Version != httpx.HARVersion {
+		t.Fatalf("got %d entries", len(har.Log.Entries))
+	}
+	for _, entry := range har.Log.Entries {
+		if entry.Comment != "recorded" {
+			t.Error("redact hook not called")
+		}
+		for _, h := range append(entry.Request.Headers, entry.Response.Headers...) {
+			if strings.Contains(h.Value, "secret") {
+				t.Errorf("header %s not redacted", h.Name)
+			}
+		}
+	}
+	if entry := har.Log.Entries[1]; entry.Response.Content.Encoding != "base64" || entry.Response.Content.Size != int64(len(binary)) {
+		t.Errorf("binary content %+v", entry.Response.Content)
+	}
+
+	replayer, err := LoadHARReplayer(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = newTestClient().ReplayHAR(replayer)
+	// query 顺序和 JSON 字段顺序不影响匹配
+	resp = nil
+	err = c.PostRequest(server.URL+"/json?r=2&q=1").ContentType(ContentTypeJson).Do(strings.NewReader(`{"b":2,"a":1}`), &resp)
+	if err != nil || resp["q"] != "1" {
+		t.Fatalf("got %v, %v", resp, err)
+	}
+	if raw, err = c.GetRaw(server.URL+"/bin", nil); err != nil || !bytes.Equal(raw, binary) {
+		t.Fatalf("got %v, %v", raw, err)
+	}
+	if err = c.Post(server.URL+"/json?q=1&r=2", map[string]int{"a": 2}, &resp); !errors.Is(err, ErrHARNoMatch) {
+		t.Fatalf("unmatched body: %v", err)
+	}
+	if unmatched := replayer.Unmatched(); len(unmatched) != 1 || !strings.HasPrefix(unmatched[0], "POST ") {
+		t.Errorf("unmatched %v", unmatched)
+	}
+	if len(replayer.Unused()) != 0 {
+		t.Error("all entries should be used")
+	}
+}
@@ -0,0 +1,243 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const hedgeSamples = 128
+
+type HedgeConfig struct {
+	// Delay before sending a hedged attempt, 0 means the Percentile of recent latencies of the host
+	Delay time.Duration
+	// Percentile of latency used as delay, default 0.95
+	Percentile float64
+	// MinSamples is the number of latencies needed before hedging by percentile, default 20
+	MinSamples int
+	// MaxHedges is the number of extra attempts, default 1
+	MaxHedges int
+	// Methods that may be hedged, default idempotent methods GET, HEAD and OPTIONS
+	Methods []string
+}
+
+// HedgeStats for metrics.
+type HedgeStats struct {
+	// Requests seen, Hedged requests that sent at least one extra attempt, Wins of extra attempts
+	Requests, Hedged, Wins int64
+}
+
+// Hedger sends extra attempts of slow requests and takes the first success.
+type Hedger struct {
+	config                 HedgeConfig
+	mu                     sync.Mutex
+	latencies              map[string]*latencyRing
+	requests, hedged, wins atomic.Int64
+}
+
+type latencyRing struct {
+	samples [hedgeSamples]time.Duration
+	n       int
+}
+
+type hedgeResult struct {
+	resp *http.Response
+	err  error
+	id   int
+}
+
+// NewHedger creates a new instance.
+func NewHedger(config HedgeConfig) *Hedger {
+	if config.Percentile <= 0 || config.Percentile > 1 {
+		config.Percentile = 0.95
+	}
+	if config.MinSamples <= 0 {
+		config.MinSamples = 20
+	}
+	if config.MaxHedges <= 0 {
+		config.MaxHedges = 1
+	}
+	if config.Methods == nil {
+		config.Methods = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+	}
+	return &Hedger{config: config, latencies: make(map[string]*latencyRing)}
+}
+
+// Middleware returns the hedger as a middleware.
+func (h *Hedger) Middleware() Middleware {
+	return h.wrap
+}
+
+// Stats returns the counters.
+func (h *Hedger) Stats() HedgeStats {
+	return HedgeStats{Requests: h.requests.Load(), Hedged: h.hedged.Load(), Wins: h.wins.Load()}
+}
+
+// Delay returns the current hedging delay of host, 0 if it does not hedge yet.
+func (h *Hedger) Delay(host string) time.Duration {
+	if h.config.Delay > 0 {
+		return h.config.Delay
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ring, ok := h.latencies[host]
+	if !ok || ring.n < h.config.MinSamples {
+		return 0
+	}
+	samples := slices.Clone(ring.samples[:min(ring.n, hedgeSamples)])
+	slices.Sort(samples)
+	return samples[int(float64(len(samples)-1)*h.config.Percentile)]
+}
+
+// observe records a latency of host.
+func (h *Hedger) observe(host string, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ring, ok := h.latencies[host]
+	if !ok {
+		ring = &latencyRing{}
+		h.latencies[host] = ring
+	}
+	ring.samples[ring.n%hedgeSamples] = d
+	ring.n++
+}
+
+// wrap performs the operation.
+func (h *Hedger) wrap(next RoundTripFunc) RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		h.requests.Add(1)
+		host := req.URL.Host
+		delay := h.Delay(host)
+		// 请求体不可重放的请求不对冲
+		if delay <= 0 || !slices.Contains(h.config.Methods, req.Method) || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+			start := time.Now()
+			resp, err := next(req)
+			if err == nil {
+				h.observe(host, time.Since(start))
+			}
+			return resp, err
+		}
+
+		results := make(chan hedgeResult, h.config.MaxHedges+1)
+		var cancels []context.CancelFunc
+		launch := func() error {
+			ctx, cancel := context.WithCancel(req.Context())
+			attempt := req.Clone(ctx)
+			if len(cancels) > 0 && req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					cancel()
+					return err
+				}
+				attempt.Body = body
+			}
+			id := len(cancels)
+			cancels = append(cancels, cancel)
+			go func() {
+				start := time.Now()
+				resp, err := next(attempt)
+				if err == nil {
+					h.observe(host, time.Since(start))
+				}
+				results <- hedgeResult{resp: resp, err: err, id: id}
+			}()
+			return nil
+		}
+		// settle cancels the other attempts and hands the context of res over to its body
+		settle := func(res hedgeResult, pending int) (*http.Response, error) {
+			for i, cancel := range cancels {
+				if i != res.id {
+					cancel()
+				}
+			}
+			if pending > 0 {
+				go drainHedges(results, pending)
+			}
+			if res.err != nil {
+				cancels[res.id]()
+				return nil, res.err
+			}
+			res.resp.Body = &cancelBody{ReadCloser: res.resp.Body, cancel: cancels[res.id]}
+			return res.resp, nil
+		}
+
+		_ = launch()
+		pending := 1
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		var last *hedgeResult
+		for {
+			select {
+			case res := <-results:
+				pending--
+				if res.err == nil && res.resp.StatusCode < http.StatusInternalServerError {
+					if res.id > 0 {
+						h.wins.Add(1)
+					}
+					if last != nil {
+						closeResponse(last.resp)
+					}
+					return settle(res, pending)
+				}
+				if last != nil {
+					closeResponse(last.resp)
+				}
+				last = &res
+				if pending == 0 {
+					if len(cancels) > h.config.MaxHedges {
+						return settle(res, 0)
+					}
+					// 已失败,不再等待,立即对冲
+					timer.Reset(0)
+				}
+			case <-timer.C:
+				if len(cancels) > h.config.MaxHedges {
+					continue
+				}
+				if len(cancels) == 1 {
+					h.hedged.Add(1)
+				}
+				if err := launch(); err != nil {
+					if pending == 0 {
+						return settle(*last, 0)
+					}
+					// 无法重放请求体,等待已发出的请求
+					continue
+				}
+				pending++
+				timer.Reset(delay)
+			}
+		}
+	}
+}
+
+// drainHedges closes the responses of the losing attempts.
+func drainHedges(results <-chan hedgeResult, pending int) {
+	for range pending {
+		res := <-results
+		closeResponse(res.resp)
+	}
+}
+
+// cancelBody cancels the context of the attempt when closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close releases the resources.
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
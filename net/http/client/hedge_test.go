@@ -0,0 +1,105 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgeFirstSuccess(t *testing.T) {
+	var calls atomic.Int32
+	canceled := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+				canceled <- struct{}{}
+			case <-time.After(2 * time.Second):
+			}
+			w.Write([]byte("slow"))
+			return
+		}
+		w.Write([]byte("fast"))
+	}))
+	defer srv.Close()
+
+	c := newTestClient().Hedge(HedgeConfig{Delay: 20 * time.Millisecond})
+	start := time.Now()
+	raw, err := c.GetRawX(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "fast" {
+		t.Errorf("got %q, want fast", raw)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("waited for the slow attempt")
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("slow attempt not canceled")
+	}
+	stats := c.Hedger().Stats()
+	if stats.Requests != 1 || stats.Hedged != 1 || stats.Wins != 1 {
+		t.Errorf("got stats %+v", stats)
+	}
+}
+
+func TestHedgeNotIdempotent(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	c := newTestClient().Hedge(HedgeConfig{Delay: 5 * time.Millisecond})
+	if _, err := c.Request(http.MethodPost, srv.URL).DoRaw("body"); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("got %d calls, want 1", calls.Load())
+	}
+}
+
+func TestHedgePercentileDelay(t *testing.T) {
+	h := NewHedger(HedgeConfig{MinSamples: 10, Percentile: 0.9})
+	for i := range 9 {
+		h.observe("h", time.Duration(i+1)*time.Millisecond)
+	}
+	if d := h.Delay("h"); d != 0 {
+		t.Fatalf("got delay %s before MinSamples", d)
+	}
+	h.observe("h", 10*time.Millisecond)
+	if d := h.Delay("h"); d != 9*time.Millisecond {
+		t.Errorf("got delay %s, want 9ms", d)
+	}
+}
+
+func TestHedgeFailedAttemptHedgesAtOnce(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	c := newTestClient().Hedge(HedgeConfig{Delay: time.Hour})
+	raw, err := c.GetRawX(srv.URL)
+	if err != nil || string(raw) != "ok" {
+		t.Fatalf("got %q %v", raw, err)
+	}
+}
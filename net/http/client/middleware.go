@@ -0,0 +1,57 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"net/http"
+)
+
+// RoundTripFunc sends a request and returns its response.
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps the next RoundTripFunc, it may change the request, the response or skip next.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// Use appends middlewares, the first one is the outermost.
+// Every attempt of Request.Do, DownloadReq and UploadReq goes through them after the
+// request options are applied.
+func (d *Client) Use(middlewares ...Middleware) *Client {
+	d.middlewares = append(d.middlewares, middlewares...)
+	return d
+}
+
+// do sends the request through the middlewares, the cache, the circuit breaker and the hedger.
+func (d *Client) do(req *http.Request) (*http.Response, error) {
+	if len(d.middlewares) == 0 && d.cache == nil && d.breaker == nil && d.hedger == nil && d.auth == nil {
+		return d.httpClient.Do(req)
+	}
+	next := RoundTripFunc(d.httpClient.Do)
+	// 签名在最内层,每个对冲请求单独签名
+	if d.auth != nil {
+		next = wrapAuth(d.auth, next)
+	}
+	// 熔断在对冲外层,一组对冲请求只计一次结果
+	if d.hedger != nil {
+		next = d.hedger.wrap(next)
+	}
+	if d.breaker != nil {
+		next = d.breaker.wrap(next)
+	}
+	// 熔断或失败时缓存可以返回 stale-if-error 的响应
+	if d.cache != nil {
+		next = d.cache.wrap(next)
+	}
+	for i := len(d.middlewares) - 1; i >= 0; i-- {
+		next = d.middlewares[i](next)
+	}
+	return next(req)
+}
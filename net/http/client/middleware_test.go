@@ -0,0 +1,131 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func headerMiddleware(k, v string, trace *[]string) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			*trace = append(*trace, "before "+v)
+			req.Header.Set(k, req.Header.Get(k)+v)
+			resp, err := next(req)
+			*trace = append(*trace, "after "+v)
+			return resp, err
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Trace")))
+	}))
+	defer srv.Close()
+
+	var trace []string
+	c := newTestClient().Use(headerMiddleware("X-Trace", "a", &trace), headerMiddleware("X-Trace", "b", &trace))
+	raw, err := c.GetRawX(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "ab" {
+		t.Errorf("got %q, want ab", raw)
+	}
+	want := []string{"before a", "before b", "after b", "after a"}
+	if strings.Join(trace, ",") != strings.Join(want, ",") {
+		t.Errorf("got trace %v, want %v", trace, want)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	c := newTestClient().Use(func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(`{"name":"cached"}`)),
+				Request:    req,
+			}, nil
+		}
+	})
+	var resp struct {
+		Name string `json:"name"`
+	}
+	if err := c.Get("http://127.0.0.1:0/never", nil, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Name != "cached" {
+		t.Errorf("got %q", resp.Name)
+	}
+}
+
+func TestMiddlewareEachAttempt(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	var attempts int
+	c := newTestClient().RetryTimesWithInterval(2, 0).Use(func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts < 3 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return next(req)
+		}
+	})
+	raw, err := c.GetRawX(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "ok" || attempts != 3 {
+		t.Errorf("got %q after %d attempts", raw, attempts)
+	}
+}
+
+func TestMiddlewareDownloadUpload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.Copy(w, r.Body)
+	}))
+	defer srv.Close()
+
+	auth := func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("Authorization", "Bearer t")
+			return next(req)
+		}
+	}
+
+	reader, err := NewDownloader().Use(auth).DownloadReq(srv.URL).GetReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader.Close()
+
+	err = NewUploader().Use(auth).UploadReq(srv.URL).UploadMultipart(map[string]string{"k": "v"},
+		NewMultipart("file", "a.txt", nil, bytes.NewReader([]byte("data"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = NewUploader().UploadReq(srv.URL).UploadMultipart(map[string]string{"k": "v"})
+	if err == nil {
+		t.Error("expected unauthorized without the middleware")
+	}
+}
@@ -0,0 +1,239 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+	"github.com/hopeio/gox/log"
+	httpx "github.com/hopeio/gox/net/http"
+)
+
+// OAuth2Token is a token response, RFC 6749 5.1.
+type OAuth2Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	// Expiry is computed from ExpiresIn, zero means the token does not expire
+	Expiry time.Time `json:"-"`
+}
+
+// OAuth2Error is an error response, RFC 6749 5.2.
+type OAuth2Error struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// Error implements error.
+func (e *OAuth2Error) Error() string {
+	if e.Description != "" {
+		return "oauth2: " + e.Code + ": " + e.Description
+	}
+	return fmt.Sprintf("oauth2: %s, status %d", e.Code, e.StatusCode)
+}
+
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// RefreshToken uses the refresh_token grant instead of client_credentials, rotated refresh tokens are kept
+	RefreshToken string
+	// CredentialsInBody sends the client credentials as form parameters instead of basic auth
+	CredentialsInBody bool
+	// EndpointParams are added to token requests
+	EndpointParams url.Values
+	// RefreshBefore refreshes in the background when the token expires within it, default 1 minute
+	RefreshBefore time.Duration
+	// OnToken is called with every new token, e.g. to persist the refresh token
+	OnToken func(token *OAuth2Token)
+	// HttpClient sends token requests, default http.DefaultClient
+	HttpClient *http.Client
+}
+
+// OAuth2 authenticates with tokens of the client credentials or refresh token grant, RFC 6749 4.4 and 6.
+// Tokens are cached and refreshed before they expire.
+type OAuth2 struct {
+	config OAuth2Config
+
+	mu           sync.Mutex
+	token        *OAuth2Token
+	refreshToken string
+	// refreshing is closed when the running refresh is done
+	refreshing chan struct{}
+	refreshErr error
+}
+
+// NewOAuth2 creates and returns a new instance.
+func NewOAuth2(config OAuth2Config) *OAuth2 {
+	if config.RefreshBefore <= 0 {
+		config.RefreshBefore = time.Minute
+	}
+	if config.HttpClient == nil {
+		config.HttpClient = http.DefaultClient
+	}
+	return &OAuth2{config: config, refreshToken: config.RefreshToken}
+}
+
+// Authenticate implements Authenticator.
+func (o *OAuth2) Authenticate(req *http.Request) error {
+	token, err := o.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	req.Header.Set(httpx.HeaderAuthorization, tokenType+" "+token.AccessToken)
+	return nil
+}
+
+// Invalidate drops the cached token.
+func (o *OAuth2) Invalidate() {
+	o.mu.Lock()
+	o.token = nil
+	o.mu.Unlock()
+}
+
+// Token returns the cached token, fetching a new one if it expired. A token about to expire is returned
+// while a new one is fetched in the background.
+func (o *OAuth2) Token(ctx context.Context) (*OAuth2Token, error) {
+	o.mu.Lock()
+	token := o.token
+	now := time.Now()
+	if token != nil && (token.Expiry.IsZero() || now.Before(token.Expiry)) {
+		if !token.Expiry.IsZero() && now.Add(o.config.RefreshBefore).After(token.Expiry) {
+			// 提前刷新,期间继续使用旧 token
+			if o.refreshing == nil {
+				o.startRefresh(context.WithoutCancel(ctx))
+			}
+		}
+		o.mu.Unlock()
+		return token, nil
+	}
+	if o.refreshing == nil {
+		o.startRefresh(context.WithoutCancel(ctx))
+	}
+	refreshing := o.refreshing
+	o.mu.Unlock()
+
+	select {
+	case <-refreshing:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.token == nil {
+		return nil, o.refreshErr
+	}
+	return o.token, nil
+}
+
+// startRefresh fetches a token in a goroutine, o.mu must be held.
+func (o *OAuth2) startRefresh(ctx context.Context) {
+	done := make(chan struct{})
+	o.refreshing = done
+	refreshToken := o.refreshToken
+	go func() {
+		token, err := o.fetch(ctx, refreshToken)
+		o.mu.Lock()
+		o.refreshing = nil
+		o.refreshErr = err
+		if err == nil {
+			o.token = token
+			if token.RefreshToken != "" {
+				o.refreshToken = token.RefreshToken
+			}
+		} else {
+			log.Warn("oauth2 token:", err)
+			if o.token != nil && !o.token.Expiry.IsZero() && !time.Now().Before(o.token.Expiry) {
+				o.token = nil
+			}
+		}
+		o.mu.Unlock()
+		close(done)
+		if err == nil && o.config.OnToken != nil {
+			o.config.OnToken(token)
+		}
+	}()
+}
+
+// fetch requests a token from the token endpoint.
+func (o *OAuth2) fetch(ctx context.Context, refreshToken string) (*OAuth2Token, error) {
+	c := &o.config
+	form := url.Values{}
+	for k, vs := range c.EndpointParams {
+		form[k] = vs
+	}
+	if refreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	if c.CredentialsInBody {
+		form.Set("client_id", c.ClientID)
+		if c.ClientSecret != "" {
+			form.Set("client_secret", c.ClientSecret)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(httpx.HeaderContentType, httpx.ContentTypeForm)
+	req.Header.Set(httpx.HeaderAccept, httpx.ContentTypeJson)
+	if !c.CredentialsInBody {
+		// RFC 6749 2.3.1 要求先 form 编码
+		req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	}
+	requestTime := time.Now()
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		oauthErr := &OAuth2Error{StatusCode: resp.StatusCode}
+		if jsonx.Unmarshal(data, oauthErr) != nil || oauthErr.Code == "" {
+			oauthErr.Code = resp.Status
+		}
+		return nil, oauthErr
+	}
+	token := &OAuth2Token{}
+	if err = jsonx.Unmarshal(data, token); err != nil {
+		return nil, fmt.Errorf("oauth2: decode token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("oauth2: response has no access_token")
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = requestTime.Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
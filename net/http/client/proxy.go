@@ -0,0 +1,650 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/netip"
+	stdurl "net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	httpx "github.com/hopeio/gox/net/http"
+	"github.com/hopeio/gox/net/http/safedial"
+)
+
+// Proxy schemes supported by ProxyDialer.
+const (
+	ProxySchemeHTTP    = "http"
+	ProxySchemeHTTPS   = "https"
+	ProxySchemeSOCKS5  = "socks5"
+	ProxySchemeSOCKS5H = "socks5h" // 由代理解析域名
+)
+
+var ErrProxyScheme = errors.New("unsupported proxy scheme")
+
+// ProxyReplyError is returned when a proxy is reachable but refuses to connect to the target.
+type ProxyReplyError struct {
+	Proxy  string
+	Target string
+	Reply  string
+}
+
+// Error returns the error message.
+func (e *ProxyReplyError) Error() string {
+	return fmt.Sprintf("proxy %s: connect %s: %s", e.Proxy, e.Target, e.Reply)
+}
+
+// ProxyDialer dials the target through a chain of proxies, each hop is tunneled through the previous one.
+//
+// socks5 resolves the target locally, socks5h sends the host name to the proxy. http and https
+// proxies are used with CONNECT. The user info of a proxy url is sent as credentials.
+type ProxyDialer struct {
+	Chain []*stdurl.URL
+	// Forward dials the first proxy, default net.Dialer with the client timeout.
+	Forward func(ctx context.Context, network, addr string) (net.Conn, error)
+	// Policy 不为空时目标地址在本地解析并逐个校验,通过代理也保持 SSRF 防护,代价是不再使用代理端 DNS。
+	Policy *safedial.Policy
+	// TLSConfig is used for https proxies.
+	TLSConfig *tls.Config
+}
+
+// NewProxyDialer creates and returns a new instance.
+func NewProxyDialer(proxyUrls ...string) (*ProxyDialer, error) {
+	if len(proxyUrls) == 0 {
+		return nil, errors.New("proxy: empty chain")
+	}
+	chain := make([]*stdurl.URL, 0, len(proxyUrls))
+	for _, raw := range proxyUrls {
+		u, err := stdurl.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: parse %q: %w", raw, err)
+		}
+		switch u.Scheme {
+		case ProxySchemeHTTP, ProxySchemeHTTPS, ProxySchemeSOCKS5, ProxySchemeSOCKS5H:
+		default:
+			return nil, fmt.Errorf("proxy %s: %w", u.Redacted(), ErrProxyScheme)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("proxy %s: no host", u.Redacted())
+		}
+		chain = append(chain, u)
+	}
+	return &ProxyDialer{Chain: chain}, nil
+}
+
+// DialContext connects to addr through the proxy chain.
+func (d *ProxyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if network != "tcp" && network != "tcp4" && network != "tcp6" {
+		return nil, fmt.Errorf("proxy: network %s not supported", network)
+	}
+	if len(d.Chain) == 0 {
+		return nil, errors.New("proxy: empty chain")
+	}
+	target, err := d.resolveTarget(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	forward := d.Forward
+	if forward == nil {
+		forward = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+	}
+	conn, err := forward(ctx, "tcp", proxyAddr(d.Chain[0]))
+	if err != nil {
+		return nil, fmt.Errorf("proxy %s: %w", d.Chain[0].Redacted(), err)
+	}
+	// 握手阶段跟随 ctx 的截止时间与取消
+	raw := conn
+	if deadline, ok := ctx.Deadline(); ok {
+		raw.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { raw.SetDeadline(time.Unix(1, 0)) })
+	for i, proxy := range d.Chain {
+		next := target
+		if i+1 < len(d.Chain) {
+			next = proxyAddr(d.Chain[i+1])
+		}
+		if conn, err = d.handshake(ctx, conn, proxy, next); err != nil {
+			break
+		}
+	}
+	if !stop() && err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	raw.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// resolveTarget returns the address sent to the last proxy.
+func (d *ProxyDialer) resolveTarget(ctx context.Context, addr string) (string, error) {
+	last := d.Chain[len(d.Chain)-1]
+	if d.Policy == nil && last.Scheme != ProxySchemeSOCKS5 {
+		return addr, nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	var ips []netip.Addr
+	if ip, err := netip.ParseAddr(host); err == nil {
+		ips = []netip.Addr{ip}
+	} else if ips, err = net.DefaultResolver.LookupNetIP(ctx, "ip", host); err != nil {
+		return "", err
+	}
+	if d.Policy == nil {
+		return net.JoinHostPort(ips[0].Unmap().String(), port), nil
+	}
+	err = &net.AddrError{Err: "no addresses", Addr: host}
+	for _, ip := range ips {
+		if err = d.Policy.IPAllowed(ip); err == nil {
+			return net.JoinHostPort(ip.Unmap().String(), port), nil
+		}
+	}
+	return "", fmt.Errorf("proxy: target %s: %w", host, err)
+}
+
+// handshake asks proxy to connect to target over conn.
+func (d *ProxyDialer) handshake(ctx context.Context, conn net.Conn, proxy *stdurl.URL, target string) (net.Conn, error) {
+	switch proxy.Scheme {
+	case ProxySchemeSOCKS5, ProxySchemeSOCKS5H:
+		return conn, socks5Connect(conn, proxy, target)
+	case ProxySchemeHTTPS:
+		config := d.TLSConfig.Clone()
+		if config == nil {
+			config = &tls.Config{}
+		}
+		if config.ServerName == "" {
+			config.ServerName = proxy.Hostname()
+		}
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return conn, fmt.Errorf("proxy %s: %w", proxy.Redacted(), err)
+		}
+		conn = tlsConn
+	}
+	return httpConnect(conn, proxy, target)
+}
+
+// proxyAddr returns host:port of the proxy with the default port of its scheme.
+func proxyAddr(proxy *stdurl.URL) string {
+	if proxy.Port() != "" {
+		return proxy.Host
+	}
+	port := "1080"
+	switch proxy.Scheme {
+	case ProxySchemeHTTP:
+		port = "80"
+	case ProxySchemeHTTPS:
+		port = "443"
+	}
+	return net.JoinHostPort(proxy.Hostname(), port)
+}
+
+// httpConnect establishes a CONNECT tunnel.
+func httpConnect(conn net.Conn, proxy *stdurl.URL, target string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &stdurl.URL{Opaque: target},
+		Host:   target,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		req.Header.Set(httpx.HeaderProxyAuthorization, "Basic "+base64.StdEncoding.EncodeToString([]byte(proxy.User.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		return conn, fmt.Errorf("proxy %s: %w", proxy.Redacted(), err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return conn, fmt.Errorf("proxy %s: %w", proxy.Redacted(), err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return conn, &ProxyReplyError{Proxy: proxy.Redacted(), Target: target, Reply: resp.Status}
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn keeps the bytes read ahead while parsing the CONNECT response.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Read reads data from the connection.
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+const (
+	socks5Version      = 0x05
+	socks5AuthNone     = 0x00
+	socks5AuthPassword = 0x02
+	socks5CmdConnect   = 0x01
+	socks5AddrIPv4     = 0x01
+	socks5AddrDomain   = 0x03
+	socks5AddrIPv6     = 0x04
+)
+
+var socks5Replies = [...]string{
+	1: "general SOCKS server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// socks5Connect runs the RFC 1928 CONNECT handshake, with RFC 1929 username/password authentication.
+func socks5Connect(conn net.Conn, proxy *stdurl.URL, target string) error {
+	fail := func(err error) error {
+		return fmt.Errorf("proxy %s: %w", proxy.Redacted(), err)
+	}
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("proxy: invalid port %q", portStr)
+	}
+
+	methods := []byte{socks5AuthNone}
+	if proxy.User != nil {
+		methods = append(methods, socks5AuthPassword)
+	}
+	buf := append([]byte{socks5Version, byte(len(methods))}, methods...)
+	if _, err = conn.Write(buf); err != nil {
+		return fail(err)
+	}
+	if _, err = io.ReadFull(conn, buf[:2]); err != nil {
+		return fail(err)
+	}
+	if buf[0] != socks5Version {
+		return fail(fmt.Errorf("unexpected socks version %d", buf[0]))
+	}
+	switch buf[1] {
+	case socks5AuthNone:
+	case socks5AuthPassword:
+		if proxy.User == nil {
+			return fail(errors.New("socks5 password authentication required"))
+		}
+		user := proxy.User.Username()
+		password, _ := proxy.User.Password()
+		if len(user) > 255 || len(password) > 255 {
+			return fail(errors.New("socks5 username or password too long"))
+		}
+		buf = append([]byte{0x01, byte(len(user))}, user...)
+		buf = append(append(buf, byte(len(password))), password...)
+		if _, err = conn.Write(buf); err != nil {
+			return fail(err)
+		}
+		if _, err = io.ReadFull(conn, buf[:2]); err != nil {
+			return fail(err)
+		}
+		if buf[1] != 0 {
+			return fail(errors.New("socks5 authentication failed"))
+		}
+	default:
+		return fail(errors.New("no acceptable socks5 authentication methods"))
+	}
+
+	buf = []byte{socks5Version, socks5CmdConnect, 0}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if ip = ip.Unmap(); ip.Is4() {
+			buf = append(buf, socks5AddrIPv4)
+		} else {
+			buf = append(buf, socks5AddrIPv6)
+		}
+		buf = append(buf, ip.AsSlice()...)
+	} else {
+		if len(host) > 255 {
+			return fail(errors.New("socks5 host name too long"))
+		}
+		buf = append(buf, socks5AddrDomain, byte(len(host)))
+		buf = append(buf, host...)
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(port))
+	if _, err = conn.Write(buf); err != nil {
+		return fail(err)
+	}
+	if _, err = io.ReadFull(conn, buf[:4]); err != nil {
+		return fail(err)
+	}
+	if buf[0] != socks5Version {
+		return fail(fmt.Errorf("unexpected socks version %d", buf[0]))
+	}
+	if rep := buf[1]; rep != 0 {
+		reply := "unknown error " + strconv.Itoa(int(rep))
+		if int(rep) < len(socks5Replies) {
+			reply = socks5Replies[rep]
+		}
+		return &ProxyReplyError{Proxy: proxy.Redacted(), Target: target, Reply: reply}
+	}
+	// 丢弃绑定地址
+	var skip int
+	switch buf[3] {
+	case socks5AddrIPv4:
+		skip = net.IPv4len
+	case socks5AddrIPv6:
+		skip = net.IPv6len
+	case socks5AddrDomain:
+		if _, err = io.ReadFull(conn, buf[:1]); err != nil {
+			return fail(err)
+		}
+		skip = int(buf[0])
+	default:
+		return fail(fmt.Errorf("unknown socks5 address type %d", buf[3]))
+	}
+	if _, err = io.CopyN(io.Discard, conn, int64(skip+2)); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// ProxyStrategy selects a proxy of a ProxyPool.
+type ProxyStrategy int
+
+const (
+	ProxyRoundRobin ProxyStrategy = iota
+	ProxyRandom
+	// ProxyStickyPerHost 同一目标 host 总是使用同一个代理,该代理不可用时才换
+	ProxyStickyPerHost
+)
+
+// ProxyPoolConfig configures a ProxyPool.
+type ProxyPoolConfig struct {
+	Strategy ProxyStrategy
+	// MaxFails consecutive dial failures mark a proxy down for FailTimeout, default 3 and 30s.
+	MaxFails    int
+	FailTimeout time.Duration
+	// HealthCheckInterval enables active checks, zero disables.
+	HealthCheckInterval time.Duration
+	// HealthCheck reports whether a proxy works, default dials HealthCheckAddr through it,
+	// or only the proxy itself when HealthCheckAddr is empty.
+	HealthCheck     func(ctx context.Context, proxy *ProxyDialer) error
+	HealthCheckAddr string
+	// Policy is set on every proxy dialer.
+	Policy    *safedial.Policy
+	TLSConfig *tls.Config
+}
+
+// ProxyPool dials through one of several proxies and skips the unhealthy ones.
+type ProxyPool struct {
+	config  ProxyPoolConfig
+	proxies []*poolProxy
+	next    atomic.Uint64
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+type poolProxy struct {
+	dialer    *ProxyDialer
+	name      string
+	fails     atomic.Int32
+	downUntil atomic.Int64
+}
+
+// available reports whether the proxy is not marked down.
+func (p *poolProxy) available(now time.Time) bool {
+	return p.downUntil.Load() <= now.UnixNano()
+}
+
+// NewProxyPool creates and returns a new instance, each proxy url may be a comma separated chain.
+func NewProxyPool(config ProxyPoolConfig, proxyUrls ...string) (*ProxyPool, error) {
+	if len(proxyUrls) == 0 {
+		return nil, errors.New("proxy pool: no proxies")
+	}
+	if config.MaxFails <= 0 {
+		config.MaxFails = 3
+	}
+	if config.FailTimeout <= 0 {
+		config.FailTimeout = 30 * time.Second
+	}
+	pool := &ProxyPool{config: config}
+	for _, raw := range proxyUrls {
+		dialer, err := NewProxyDialer(splitChain(raw)...)
+		if err != nil {
+			return nil, err
+		}
+		dialer.Policy = config.Policy
+		dialer.TLSConfig = config.TLSConfig
+		pool.proxies = append(pool.proxies, &poolProxy{dialer: dialer, name: chainName(dialer.Chain)})
+	}
+	if config.HealthCheckInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		pool.cancel = cancel
+		pool.wg.Go(func() { pool.healthLoop(ctx) })
+	}
+	return pool, nil
+}
+
+// splitChain splits "a,b" into proxy urls.
+func splitChain(raw string) []string {
+	var chain []string
+	for _, s := range strings.Split(raw, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			chain = append(chain, s)
+		}
+	}
+	return chain
+}
+
+// chainName returns the redacted chain for logs.
+func chainName(chain []*stdurl.URL) string {
+	var name string
+	for i, u := range chain {
+		if i > 0 {
+			name += ","
+		}
+		name += u.Redacted()
+	}
+	return name
+}
+
+// DialContext connects to addr through a healthy proxy, and tries the next one when a proxy fails.
+func (p *ProxyPool) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var errs []error
+	for _, proxy := range p.candidates(addr) {
+		conn, err := proxy.dialer.DialContext(ctx, network, addr)
+		if err == nil {
+			proxy.fails.Store(0)
+			return conn, nil
+		}
+		errs = append(errs, err)
+		var reply *ProxyReplyError
+		// 代理正常但目标不可达,或被 Policy 拦截,换代理也没用
+		if errors.As(err, &reply) || ctx.Err() != nil || isPolicyError(err) {
+			break
+		}
+		p.fail(proxy)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// isPolicyError reports whether err comes from safedial.Policy.
+func isPolicyError(err error) bool {
+	return errors.Is(err, safedial.ErrLoopback) || errors.Is(err, safedial.ErrInternal) || errors.Is(err, safedial.ErrPrivate)
+}
+
+// fail counts a failure of proxy.
+func (p *ProxyPool) fail(proxy *poolProxy) {
+	if int(proxy.fails.Add(1)) >= p.config.MaxFails {
+		proxy.fails.Store(0)
+		proxy.downUntil.Store(time.Now().Add(p.config.FailTimeout).UnixNano())
+	}
+}
+
+// candidates returns the proxies to try in order, available ones first.
+func (p *ProxyPool) candidates(addr string) []*poolProxy {
+	n := len(p.proxies)
+	ordered := make([]*poolProxy, 0, n)
+	switch p.config.Strategy {
+	case ProxyRandom:
+		for _, i := range rand.Perm(n) {
+			ordered = append(ordered, p.proxies[i])
+		}
+	case ProxyStickyPerHost:
+		// rendezvous hashing: 代理增减或下线时只有少数 host 换代理
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		scores := make(map[*poolProxy]uint64, n)
+		for _, proxy := range p.proxies {
+			h := fnv.New64a()
+			io.WriteString(h, host)
+			io.WriteString(h, proxy.name)
+			scores[proxy] = h.Sum64()
+			ordered = append(ordered, proxy)
+		}
+		slices.SortFunc(ordered, func(a, b *poolProxy) int {
+			return cmp.Compare(scores[b], scores[a])
+		})
+	default:
+		start := int(p.next.Add(1)-1) % n
+		for i := range n {
+			ordered = append(ordered, p.proxies[(start+i)%n])
+		}
+	}
+	now := time.Now()
+	healthy := ordered[:0:0]
+	var down []*poolProxy
+	for _, proxy := range ordered {
+		if proxy.available(now) {
+			healthy = append(healthy, proxy)
+		} else {
+			down = append(down, proxy)
+		}
+	}
+	// 全部下线时仍然尝试,不至于完全不可用
+	return append(healthy, down...)
+}
+
+// Healthy returns the proxies not marked down.
+func (p *ProxyPool) Healthy() []string {
+	now := time.Now()
+	var names []string
+	for _, proxy := range p.proxies {
+		if proxy.available(now) {
+			names = append(names, proxy.name)
+		}
+	}
+	return names
+}
+
+// CheckHealth checks every proxy once and marks them up or down.
+func (p *ProxyPool) CheckHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, proxy := range p.proxies {
+		wg.Go(func() {
+			if err := p.check(ctx, proxy.dialer); err != nil {
+				proxy.downUntil.Store(time.Now().Add(p.config.FailTimeout).UnixNano())
+				return
+			}
+			proxy.fails.Store(0)
+			proxy.downUntil.Store(0)
+		})
+	}
+	wg.Wait()
+}
+
+// check runs the health check of one proxy.
+func (p *ProxyPool) check(ctx context.Context, dialer *ProxyDialer) error {
+	if p.config.HealthCheck != nil {
+		return p.config.HealthCheck(ctx, dialer)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var conn net.Conn
+	var err error
+	if p.config.HealthCheckAddr != "" {
+		conn, err = dialer.DialContext(ctx, "tcp", p.config.HealthCheckAddr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", proxyAddr(dialer.Chain[0]))
+	}
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// healthLoop checks the proxies periodically until ctx is done.
+func (p *ProxyPool) healthLoop(ctx context.Context) {
+	ticker := time.NewTicker(p.config.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.CheckHealth(ctx)
+		}
+	}
+}
+
+// Close stops the health checks.
+func (p *ProxyPool) Close() error {
+	if p.cancel != nil {
+		p.cancel()
+		p.wg.Wait()
+	}
+	return nil
+}
+
+// DialContext sets the function dialing every connection and disables the transport proxy.
+func (d *Client) DialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) *Client {
+	d.ensureOwnHttpClient()
+	transport := ensureTransport(d.httpClient)
+	transport.Proxy = nil
+	transport.DialContext = dial
+	return d
+}
+
+// ProxyChain dials through the proxies in order, e.g. ProxyChain("http://a:8080", "socks5h://user:pass@b:1080").
+// An invalid proxy url makes every request fail with its error instead of connecting directly.
+func (d *Client) ProxyChain(proxyUrls ...string) *Client {
+	dialer, err := NewProxyDialer(proxyUrls...)
+	if err != nil {
+		return d.DialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, err
+		})
+	}
+	return d.DialContext(dialer.DialContext)
+}
+
+// ProxyPool dials through the proxies of pool.
+func (d *Client) ProxyPool(pool *ProxyPool) *Client {
+	return d.DialContext(pool.DialContext)
+}
@@ -0,0 +1,288 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	httpx "github.com/hopeio/gox/net/http"
+	"github.com/hopeio/gox/net/http/safedial"
+)
+
+// testSOCKS5 is a minimal SOCKS5 server recording the requested targets.
+type testSOCKS5 struct {
+	net.Listener
+	user, password string
+	mu             sync.Mutex
+	targets        []string
+	conns          atomic.Int32
+}
+
+func newTestSOCKS5(t *testing.T, user, password string) *testSOCKS5 {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testSOCKS5{Listener: l, user: user, password: password}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.conns.Add(1)
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testSOCKS5) url() string {
+	if s.user != "" {
+		return "socks5h://" + s.user + ":" + s.password + "@" + s.Addr().String()
+	}
+	return "socks5h://" + s.Addr().String()
+}
+
+func (s *testSOCKS5) serve(conn net.Conn) {
+	defer conn.Close()
+	buf := make([]byte, 512)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	io.ReadFull(conn, buf[:buf[1]])
+	if s.user != "" {
+		conn.Write([]byte{5, 2})
+		io.ReadFull(conn, buf[:2])
+		user := make([]byte, buf[1])
+		io.ReadFull(conn, user)
+		io.ReadFull(conn, buf[:1])
+		password := make([]byte, buf[0])
+		io.ReadFull(conn, password)
+		if string(user) != s.user || string(password) != s.password {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+	} else {
+		conn.Write([]byte{5, 0})
+	}
+	io.ReadFull(conn, buf[:4])
+	var host string
+	switch buf[3] {
+	case 1:
+		io.ReadFull(conn, buf[:4])
+		host = net.IP(buf[:4]).String()
+	case 3:
+		io.ReadFull(conn, buf[:1])
+		name := make([]byte, buf[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	case 4:
+		io.ReadFull(conn, buf[:16])
+		host = net.IP(buf[:16]).String()
+	}
+	io.ReadFull(conn, buf[:2])
+	target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+	s.mu.Lock()
+	s.targets = append(s.targets, target)
+	s.mu.Unlock()
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+	conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+	go io.Copy(upstream, conn)
+	io.Copy(conn, upstream)
+}
+
+func (s *testSOCKS5) lastTarget() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.targets) == 0 {
+		return ""
+	}
+	return s.targets[len(s.targets)-1]
+}
+
+// newTestConnectProxy starts an HTTP CONNECT proxy requiring basic auth.
+func newTestConnectProxy(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get(httpx.HeaderProxyAuthorization) != "Basic dTpw" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		defer conn.Close()
+		defer upstream.Close()
+		rw.WriteString("HTTP/1.1 200 Connection Established\r\n\r\n")
+		rw.Flush()
+		go io.Copy(upstream, rw.Reader)
+		io.Copy(conn, upstream)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestTarget(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProxyChain(t *testing.T) {
+	target := newTestTarget(t)
+	_, port, _ := net.SplitHostPort(target.Listener.Addr().String())
+	socks := newTestSOCKS5(t, "user", "pass")
+
+	// socks5h 由代理解析域名
+	c := newTestClient().ProxyChain(socks.url())
+	raw, err := c.GetRaw("http://localhost:"+port, nil)
+	if err != nil || string(raw) != "ok" {
+		t.Fatalf("got %s, %v", raw, err)
+	}
+	if got := socks.lastTarget(); got != "localhost:"+port {
+		t.Errorf("socks5h target %s", got)
+	}
+
+	c = newTestClient().ProxyChain("socks5://user:pass@" + socks.Addr().String())
+	if _, err = c.GetRaw("http://localhost:"+port, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := socks.lastTarget(); got != "127.0.0.1:"+port && got != "[::1]:"+port {
+		t.Errorf("socks5 target %s", got)
+	}
+
+	c = newTestClient().ProxyChain("socks5h://user:wrong@" + socks.Addr().String())
+	if _, err = c.GetRaw(target.URL, nil); err == nil {
+		t.Error("wrong password should fail")
+	}
+
+	// http CONNECT -> socks5 -> target
+	connect := newTestConnectProxy(t)
+	c = newTestClient().ProxyChain("http://u:p@"+connect.Listener.Addr().String(), socks.url())
+	before := socks.conns.Load()
+	if raw, err = c.GetRaw(target.URL, nil); err != nil || string(raw) != "ok" {
+		t.Fatalf("got %s, %v", raw, err)
+	}
+	if socks.conns.Load() == before {
+		t.Error("second hop not used")
+	}
+
+	// 代理地址无效时不能退回直连
+	c = newTestClient().ProxyChain("sock5h://" + socks.Addr().String())
+	if _, err = c.GetRaw(target.URL, nil); !errors.Is(err, ErrProxyScheme) {
+		t.Errorf("invalid proxy got %v", err)
+	}
+
+	dialer, _ := NewProxyDialer("http://" + connect.Listener.Addr().String())
+	_, err = dialer.DialContext(t.Context(), "tcp", target.Listener.Addr().String())
+	var reply *ProxyReplyError
+	if !errors.As(err, &reply) || reply.Reply != "407 Proxy Authentication Required" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestProxyDialer_Policy(t *testing.T) {
+	target := newTestTarget(t)
+	socks := newTestSOCKS5(t, "", "")
+	dialer, err := NewProxyDialer(socks.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialer.Policy = &safedial.Policy{}
+	_, port, _ := net.SplitHostPort(target.Listener.Addr().String())
+	c := newTestClient().DialContext(dialer.DialContext)
+	// 代理端解析也不能绕过回环限制
+	if _, err = c.GetRaw("http://localhost:"+port, nil); !errors.Is(err, safedial.ErrLoopback) {
+		t.Fatalf("got %v", err)
+	}
+	if socks.conns.Load() != 0 {
+		t.Error("blocked target must not reach the proxy")
+	}
+}
+
+func TestProxyPool(t *testing.T) {
+	target := newTestTarget(t)
+	a, b := newTestSOCKS5(t, "", ""), newTestSOCKS5(t, "", "")
+	dead := newTestSOCKS5(t, "", "")
+	dead.Close()
+
+	pool, err := NewProxyPool(ProxyPoolConfig{MaxFails: 1, FailTimeout: time.Minute}, a.url(), b.url(), dead.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	c := newTestClient().ProxyPool(pool)
+	c.httpClient.Transport.(*http.Transport).DisableKeepAlives = true
+	for range 6 {
+		if _, err = c.GetRaw(target.URL, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.conns.Load() < 2 || b.conns.Load() < 2 {
+		t.Errorf("round robin: %d, %d", a.conns.Load(), b.conns.Load())
+	}
+	if healthy := pool.Healthy(); len(healthy) != 2 {
+		t.Errorf("healthy %v", healthy)
+	}
+
+	sticky, err := NewProxyPool(ProxyPoolConfig{Strategy: ProxyStickyPerHost}, a.url(), b.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sticky.Close()
+	c = newTestClient().ProxyPool(sticky)
+	c.httpClient.Transport.(*http.Transport).DisableKeepAlives = true
+	beforeA, beforeB := a.conns.Load(), b.conns.Load()
+	for range 4 {
+		if _, err = c.GetRaw(target.URL, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if da, db := a.conns.Load()-beforeA, b.conns.Load()-beforeB; da*db != 0 || da+db != 4 {
+		t.Errorf("sticky: %d, %d", da, db)
+	}
+
+	// 主动健康检查把失效代理标记下线
+	checked, err := NewProxyPool(ProxyPoolConfig{}, a.url(), dead.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checked.CheckHealth(t.Context())
+	if healthy := checked.Healthy(); len(healthy) != 1 || healthy[0] != a.url() {
+		t.Errorf("healthy %v", healthy)
+	}
+}
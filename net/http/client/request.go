@@ -24,7 +24,6 @@ import (
 	httpx "github.com/hopeio/gox/net/http"
 	urlx "github.com/hopeio/gox/net/url"
 	stringsx "github.com/hopeio/gox/strings"
-	"github.com/hopeio/gox/text/encoding/unicode"
 	"github.com/klauspost/compress/zstd"
 )
 
@@ -176,6 +175,7 @@ func (req *Request) Do(param, response any) error {
 				RespBody: respBody,
 				Request:  request,
 				Response: resp,
+				Cache:    responseCacheStatus(resp),
 			}, err)
 		}
 	}(reqTime)
@@ -241,6 +241,7 @@ func (req *Request) Do(param, response any) error {
 	var handlerReader io.ReadCloser
 	var handlerRetry bool
 	var reader io.Reader
+	var wait time.Duration
 	for attempt := 0; attempt < maxAttempts; attempt++ {
 		if attempt > 0 {
 			closeResponse(resp)
@@ -251,7 +252,7 @@ func (req *Request) Do(param, response any) error {
 			select {
 			case <-req.ctx.Done():
 				return req.ctx.Err()
-			case <-time.After(c.retryInterval):
+			case <-time.After(wait):
 			}
 			reqTime = time.Now()
 			if reqBody != nil {
@@ -264,8 +265,13 @@ func (req *Request) Do(param, response any) error {
 		handlerRetry = false
 		handlerReader = nil
 		reader = nil
-		resp, err = c.httpClient.Do(request)
+		wait = c.retryInterval
+		resp, err = c.do(request)
 		if err != nil {
+			// 熔断打开时重试没有意义
+			if errors.Is(err, ErrCircuitOpen) {
+				return err
+			}
 			if c.logLevel > LogLevelSilent {
 				c.logger(&AccessLogParam{
 					Method:   req.Method,
@@ -275,22 +281,43 @@ func (req *Request) Do(param, response any) error {
 					RespBody: respBody,
 					Request:  request,
 					Response: resp,
+					Cache:    responseCacheStatus(resp),
 				}, errors.New(err.Error()+";will retry"))
 			}
 			continue
 		}
 
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			if resp.StatusCode == http.StatusNotFound {
-				resp.Body.Close()
-				return ErrNotFound
-			}
+		if !c.isSuccess(resp.StatusCode) {
 			respBody, err = io.ReadAll(resp.Body)
 			resp.Body.Close()
 			if err != nil {
 				return err
 			}
-			return errors.New("status:" + resp.Status + " " + unicode.ToUtf8(respBody))
+			httpErr := c.newHTTPError(resp, respBody)
+			if attempt == maxAttempts-1 || !c.isRetryStatus(resp.StatusCode) {
+				err = httpErr
+				return err
+			}
+			// 可重试的状态码,等待 Retry-After 与重试间隔中较长者,过长时放弃重试
+			after := retryAfter(resp.Header)
+			if after > c.retryAfterLimit() {
+				err = httpErr
+				return err
+			}
+			wait = max(wait, after)
+			if c.logLevel > LogLevelSilent {
+				c.logger(&AccessLogParam{
+					Method:   req.Method,
+					Url:      req.Url,
+					Duration: time.Since(reqTime),
+					ReqBody:  reqBody,
+					RespBody: respBody,
+					Request:  request,
+					Response: resp,
+					Cache:    responseCacheStatus(resp),
+				}, errors.New(httpErr.Error()+";will retry"))
+			}
+			continue
 		}
 
 		if httpresp, ok := response.(*http.Response); ok {
@@ -373,6 +400,7 @@ func (req *Request) Do(param, response any) error {
 						RespBody: respBody,
 						Request:  request,
 						Response: resp,
+						Cache:    responseCacheStatus(resp),
 					}, err)
 				}
 				continue
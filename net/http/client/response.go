@@ -8,10 +8,147 @@ package client
 
 import (
 	"fmt"
-)
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
 
+	jsonx "github.com/hopeio/gox/encoding/json"
+	httpx "github.com/hopeio/gox/net/http"
+	"github.com/hopeio/gox/text/encoding/unicode"
+)
 
 var (
 	ErrNotFound            = fmt.Errorf("not found")
 	ErrRangeNotSatisfiable = fmt.Errorf("range not satisfiable")
 )
+
+// DefaultRetryStatuses are retried by Request.Do when retry times is set.
+var DefaultRetryStatuses = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// DefaultMaxRetryAfter is the longest Retry-After Request.Do waits for, a longer one ends the retries.
+var DefaultMaxRetryAfter = time.Minute
+
+// HTTPError is returned for responses whose status is not a success.
+// errors.Is(err, ErrNotFound) and errors.Is(err, ErrRangeNotSatisfiable) match 404 and 416,
+// if the decoded Model is an error errors.As reaches it.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+	// Model is the body decoded into a new value of the type registered by Client.ErrorModel, nil if none or undecodable
+	Model any
+}
+
+// Error returns the error message.
+func (e *HTTPError) Error() string {
+	return "status:" + e.Status + " " + unicode.ToUtf8(e.Body)
+}
+
+// Is reports whether the error matches target.
+func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRangeNotSatisfiable:
+		return e.StatusCode == http.StatusRequestedRangeNotSatisfiable
+	}
+	return false
+}
+
+// Unwrap returns the decoded model if it is an error.
+func (e *HTTPError) Unwrap() error {
+	if err, ok := e.Model.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// ErrorModel registers the type of model, error bodies are decoded into a new value of it as HTTPError.Model.
+// Pass a value or a pointer, Model is always a pointer.
+func (d *Client) ErrorModel(model any) *Client {
+	typ := reflect.TypeOf(model)
+	if typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	d.errorModel = typ
+	return d
+}
+
+// SuccessStatus treats the statuses as success besides 2xx, their bodies are decoded into the response.
+func (d *Client) SuccessStatus(statuses ...int) *Client {
+	d.successStatuses = append(d.successStatuses, statuses...)
+	return d
+}
+
+// RetryStatus replaces the statuses retried by Request.Do, default DefaultRetryStatuses, no statuses
+// turns off retrying by status. Retry-After of these responses is respected when longer than the retry interval.
+func (d *Client) RetryStatus(statuses ...int) *Client {
+	// 非 nil 的空切片表示不按状态码重试, nil 才使用默认值
+	d.retryStatuses = append([]int{}, statuses...)
+	return d
+}
+
+// MaxRetryAfter sets the longest Retry-After to wait for, default DefaultMaxRetryAfter.
+// A response asking to wait longer is returned as the HTTPError instead of being retried.
+func (d *Client) MaxRetryAfter(maxWait time.Duration) *Client {
+	d.maxRetryAfter = maxWait
+	return d
+}
+
+// retryAfterLimit returns the longest Retry-After to wait for.
+func (d *Client) retryAfterLimit() time.Duration {
+	if d.maxRetryAfter > 0 {
+		return d.maxRetryAfter
+	}
+	return DefaultMaxRetryAfter
+}
+
+// isSuccess reports whether the status is a success.
+func (d *Client) isSuccess(status int) bool {
+	return status >= 200 && status < 300 || slices.Contains(d.successStatuses, status)
+}
+
+// isRetryStatus reports whether the status may be retried.
+func (d *Client) isRetryStatus(status int) bool {
+	statuses := d.retryStatuses
+	if statuses == nil {
+		statuses = DefaultRetryStatuses
+	}
+	return slices.Contains(statuses, status)
+}
+
+// newHTTPError creates the error of resp whose body has been read.
+func (d *Client) newHTTPError(resp *http.Response, body []byte) *HTTPError {
+	e := &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header, Body: body}
+	if d.errorModel != nil && len(body) > 0 {
+		model := reflect.New(d.errorModel).Interface()
+		var err error
+		if d.respBodyUnMarshal != nil {
+			err = d.respBodyUnMarshal(body, model)
+		} else {
+			err = jsonx.Unmarshal(body, model)
+		}
+		if err == nil {
+			e.Model = model
+		}
+	}
+	return e
+}
+
+// retryAfter returns the delay of the Retry-After header, seconds or a HTTP date, 0 if missing.
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get(httpx.HeaderRetryAfter)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type apiError struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+func (e *apiError) Error() string {
+	return e.Msg
+}
+
+func TestHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "r1")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":1001,"msg":"bad name"}`))
+	}))
+	defer srv.Close()
+
+	var resp any
+	err := newTestClient().ErrorModel(apiError{}).Get(srv.URL, nil, &resp)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("got %T %v, want *HTTPError", err, err)
+	}
+	if httpErr.StatusCode != http.StatusBadRequest || httpErr.Header.Get("X-Request-Id") != "r1" {
+		t.Errorf("got %+v", httpErr)
+	}
+	var model *apiError
+	if !errors.As(err, &model) || model.Code != 1001 || model.Msg != "bad name" {
+		t.Errorf("got model %+v", httpErr.Model)
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Error("400 matched ErrNotFound")
+	}
+}
+
+func TestSuccessStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code":0,"msg":"empty"}`))
+	}))
+	defer srv.Close()
+
+	var resp apiError
+	if err := newTestClient().SuccessStatus(http.StatusNotFound).Get(srv.URL, nil, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Msg != "empty" {
+		t.Errorf("got %+v", resp)
+	}
+}
+
+func TestRetryStatus(t *testing.T) {
+	var count atomic.Int32
+	var last time.Time
+	var gap time.Duration
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		if !last.IsZero() {
+			gap = now.Sub(last)
+		}
+		last = now
+		if count.Add(1) < 2 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`"ok"`))
+	}))
+	defer srv.Close()
+
+	var resp string
+	if err := newTestClient().RetryTimesWithInterval(2, time.Millisecond).Get(srv.URL, nil, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp != "ok" || count.Load() != 2 {
+		t.Errorf("got %q after %d attempts", resp, count.Load())
+	}
+	if gap < time.Second {
+		t.Errorf("retried after %s, want Retry-After 1s", gap)
+	}
+
+	count.Store(0)
+	err := newTestClient().RetryTimesWithInterval(2, time.Millisecond).RetryStatus(http.StatusServiceUnavailable).Get(srv.URL, nil, &resp)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests || count.Load() != 1 {
+		t.Errorf("got %v after %d attempts", err, count.Load())
+	}
+
+	// 不传状态码时不按状态码重试
+	count.Store(0)
+	err = newTestClient().RetryTimesWithInterval(2, time.Millisecond).RetryStatus().Get(srv.URL, nil, &resp)
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests || count.Load() != 1 {
+		t.Errorf("got %v after %d attempts", err, count.Load())
+	}
+
+	statuses := []int{http.StatusServiceUnavailable}
+	c := newTestClient().RetryStatus(statuses...)
+	clone := c.Clone()
+	statuses[0] = http.StatusTooManyRequests
+	clone.retryStatuses[0] = http.StatusBadGateway
+	if !c.isRetryStatus(http.StatusServiceUnavailable) || c.isRetryStatus(http.StatusBadGateway) {
+		t.Errorf("retry statuses shared: %v", c.retryStatuses)
+	}
+}
+
+func TestMaxRetryAfter(t *testing.T) {
+	var count atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count.Add(1)
+		w.Header().Set("Retry-After", "86400")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	var resp string
+	err := newTestClient().RetryTimesWithInterval(3, time.Millisecond).Get(srv.URL, nil, &resp)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable || count.Load() != 1 {
+		t.Errorf("got %v after %d attempts", err, count.Load())
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("waited %s for Retry-After above the limit", elapsed)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d := retryAfter(http.Header{"Retry-After": {"3"}}); d != 3*time.Second {
+		t.Errorf("got %s", d)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if d := retryAfter(http.Header{"Retry-After": {date}}); d < 58*time.Second || d > time.Minute {
+		t.Errorf("got %s", d)
+	}
+	if d := retryAfter(http.Header{"Retry-After": {"soon"}}); d != 0 {
+		t.Errorf("got %s", d)
+	}
+}
@@ -0,0 +1,572 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+	"github.com/hopeio/gox/log"
+	httpx "github.com/hopeio/gox/net/http"
+	"github.com/hopeio/gox/os/fs"
+)
+
+// ManifestKey is the suffix of the sidecar manifest next to the temporary download file.
+const ManifestKey = ".manifest"
+
+// DefaultChunkSize is the segment size of ConcurrencyDownload.
+const DefaultChunkSize = 4 * 1024 * 1024
+
+// ProgressInterval is how often progress is reported and the manifest is saved.
+var ProgressInterval = 500 * time.Millisecond
+
+var (
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrRemoteChanged    = errors.New("remote file changed")
+)
+
+type ChecksumAlgorithm string
+
+const (
+	ChecksumMD5    ChecksumAlgorithm = "md5"
+	ChecksumSHA256 ChecksumAlgorithm = "sha256"
+)
+
+// newHash returns the hash of the algorithm.
+func (a ChecksumAlgorithm) newHash() (hash.Hash, error) {
+	switch a {
+	case ChecksumMD5:
+		return md5.New(), nil
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum algorithm %q", a)
+}
+
+// Progress of a download.
+type Progress struct {
+	// Total size, -1 if unknown
+	Total      int64
+	Downloaded int64
+	// Speed in bytes per second since the last report
+	Speed float64
+}
+
+// Proportion returns the downloaded proportion for terminal.DrawProgressBar, 0 if the size is unknown.
+func (p Progress) Proportion() float32 {
+	if p.Total <= 0 {
+		return 0
+	}
+	return float32(p.Downloaded) / float32(p.Total)
+}
+
+// Mirrors adds urls serving the same file, chunks are spread over all of them.
+func (dReq *DownloadReq) Mirrors(urls ...string) *DownloadReq {
+	dReq.mirrors = append(dReq.mirrors, urls...)
+	return dReq
+}
+
+// Checksum verifies the hex encoded sum of the downloaded file.
+func (dReq *DownloadReq) Checksum(algorithm ChecksumAlgorithm, sum string) *DownloadReq {
+	dReq.checksum = algorithm
+	dReq.sum = sum
+	return dReq
+}
+
+// ChunkSize sets the segment size of ConcurrencyDownload.
+func (dReq *DownloadReq) ChunkSize(size int64) *DownloadReq {
+	dReq.rangeSize = size
+	return dReq
+}
+
+// Progress sets the callback called every ProgressInterval and once at the end, e.g.
+//
+//	req.Progress(func(p client.Progress) { terminal.DrawProgressBar(name, p.Proportion(), 50) })
+func (dReq *DownloadReq) Progress(progress func(Progress)) *DownloadReq {
+	dReq.progress = progress
+	return dReq
+}
+
+type manifestChunk struct {
+	Start int64 `json:"start"`
+	// End is inclusive
+	End     int64 `json:"end"`
+	Written int64 `json:"written"`
+}
+
+// downloadManifest is the persisted state of a segmented download.
+type downloadManifest struct {
+	Url          string           `json:"url"`
+	ETag         string           `json:"etag,omitempty"`
+	LastModified string           `json:"lastModified,omitempty"`
+	Size         int64            `json:"size"`
+	Chunks       []*manifestChunk `json:"chunks"`
+
+	mu   sync.Mutex
+	path string
+}
+
+// remoteFile is the file as described by the probe response.
+type remoteFile struct {
+	url          string
+	etag         string
+	lastModified string
+	size         int64
+	// resp is the full response of a server without range support
+	resp *http.Response
+}
+
+// newManifest splits the remote file into chunks.
+func newManifest(path string, remote *remoteFile, chunkSize int64) *downloadManifest {
+	m := &downloadManifest{Url: remote.url, ETag: remote.etag, LastModified: remote.lastModified, Size: remote.size, path: path}
+	for start := int64(0); start < remote.size; start += chunkSize {
+		m.Chunks = append(m.Chunks, &manifestChunk{Start: start, End: min(start+chunkSize, remote.size) - 1})
+	}
+	return m
+}
+
+// loadManifest returns the saved manifest, nil if there is none or it is unreadable.
+func loadManifest(path string) *downloadManifest {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	m := &downloadManifest{path: path}
+	if err = jsonx.Unmarshal(data, m); err != nil {
+		return nil
+	}
+	return m
+}
+
+// match reports whether the manifest describes the same version of the remote file.
+func (m *downloadManifest) match(remote *remoteFile) bool {
+	if m.Size != remote.size || m.ETag != remote.etag || m.LastModified != remote.lastModified {
+		return false
+	}
+	var next int64
+	for _, c := range m.Chunks {
+		if c.Start != next || c.End < c.Start || c.Written < 0 || c.Written > c.End-c.Start+1 {
+			return false
+		}
+		next = c.End + 1
+	}
+	return next == m.Size
+}
+
+// save writes the manifest atomically.
+func (m *downloadManifest) save() error {
+	m.mu.Lock()
+	data, err := jsonx.Marshal(m)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// offset returns the next offset of the chunk.
+func (m *downloadManifest) offset(c *manifestChunk) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return c.Start + c.Written
+}
+
+// advance records n bytes written to the chunk.
+func (m *downloadManifest) advance(c *manifestChunk, n int64) {
+	m.mu.Lock()
+	c.Written += n
+	m.mu.Unlock()
+}
+
+// downloaded returns the bytes written of all chunks.
+func (m *downloadManifest) downloaded() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for _, c := range m.Chunks {
+		n += c.Written
+	}
+	return n
+}
+
+// urls returns the url followed by the mirrors.
+func (dReq *DownloadReq) urls() []string {
+	return append([]string{dReq.Url}, dReq.mirrors...)
+}
+
+// requestHeader returns a copy of the request headers.
+func (dReq *DownloadReq) requestHeader() http.Header {
+	if dReq.header == nil {
+		return make(http.Header)
+	}
+	return dReq.header.Clone()
+}
+
+// probe finds the size and validators of the file from the first url that responds.
+func (dReq *DownloadReq) probe(ctx context.Context) (*remoteFile, error) {
+	var err error
+	for _, url := range dReq.urls() {
+		header := dReq.requestHeader()
+		// FormatRange 把 end 0 当作到结尾
+		header.Set(httpx.HeaderRange, "bytes=0-0")
+		var resp *http.Response
+		resp, err = dReq.response(ctx, url, header)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+		remote := &remoteFile{url: url, etag: resp.Header.Get(httpx.HeaderETag), lastModified: resp.Header.Get(httpx.HeaderLastModified)}
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+			resp.Body.Close()
+			_, _, remote.size, err = httpx.ParseContentRange(resp.Header.Get(httpx.HeaderContentRange))
+			if err == nil && remote.size < 0 {
+				err = errors.New("unknown size in Content-Range")
+			}
+			if err != nil {
+				continue
+			}
+			return remote, nil
+		case http.StatusOK:
+			remote.size = resp.ContentLength
+			remote.resp = resp
+			return remote, nil
+		case http.StatusRequestedRangeNotSatisfiable:
+			// 空文件
+			if resp.Header.Get(httpx.HeaderContentRange) == "bytes */0" {
+				resp.Body.Close()
+				return remote, nil
+			}
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		err = dReq.downloader.newHTTPError(resp, body)
+		log.Warn(err, "url:", url)
+	}
+	return nil, err
+}
+
+/*
+ConcurrencyDownload downloads the file in chunks over concurrencyNum connections spread over the url and its mirrors.
+The chunk map is persisted to filepath+DownloadKey+ManifestKey, so a download interrupted even by a crash resumes
+where it stopped, unless the ETag, Last-Modified or size of the file changed. Servers without range support are
+downloaded over a single connection. The checksum, if set, is verified before the file is moved into place.
+*/
+func (dReq *DownloadReq) ConcurrencyDownload(filepath string, concurrencyNum int) error {
+	if dReq.mode&DModeOverwrite == 0 && fs.Exist(filepath) {
+		return nil
+	}
+	if concurrencyNum <= 0 {
+		concurrencyNum = 1
+	}
+	ctx, cancel := context.WithCancel(dReq.ctx)
+	defer cancel()
+	tmpPath := filepath + DownloadKey
+	manifestPath := tmpPath + ManifestKey
+
+	remote, err := dReq.probe(ctx)
+	if err != nil {
+		return err
+	}
+	if remote.resp != nil {
+		// 不支持 Range,单连接下载
+		os.Remove(manifestPath)
+		return dReq.streamDownload(filepath, remote)
+	}
+
+	m := loadManifest(manifestPath)
+	if m == nil || !m.match(remote) {
+		if m != nil {
+			log.Infof("%s changed, restart download", remote.url)
+		}
+		chunkSize := dReq.rangeSize
+		if chunkSize <= 0 {
+			chunkSize = DefaultChunkSize
+		}
+		m = newManifest(manifestPath, remote, chunkSize)
+		// 旧的临时文件内容无法确认,重新下载
+		if err = os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	f, err := fs.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	if err = m.save(); err != nil {
+		f.Close()
+		return err
+	}
+
+	var downloaded atomic.Int64
+	downloaded.Store(m.downloaded())
+	stopProgress := dReq.reportProgress(m.Size, &downloaded, func() {
+		// 先落盘数据再保存进度,manifest 记录的进度不会超过文件内容
+		if f.Sync() == nil {
+			m.save()
+		}
+	})
+
+	chunks := make(chan int)
+	errs := make(chan error, concurrencyNum)
+	urls := dReq.urls()
+	var wg sync.WaitGroup
+	for range min(concurrencyNum, len(m.Chunks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range chunks {
+				if err := dReq.downloadChunk(ctx, f, m, i, urls, &downloaded); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+dispatch:
+	for i, c := range m.Chunks {
+		if c.Written > c.End-c.Start {
+			continue
+		}
+		select {
+		case chunks <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(chunks)
+	wg.Wait()
+	stopProgress()
+	close(errs)
+
+	if err = <-errs; err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		m.save()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = dReq.verify(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		os.Remove(manifestPath)
+		return err
+	}
+	if err = os.Rename(tmpPath, filepath); err != nil {
+		return err
+	}
+	return os.Remove(manifestPath)
+}
+
+// downloadChunk downloads the rest of the chunk, switching mirrors on failure.
+func (dReq *DownloadReq) downloadChunk(ctx context.Context, f *os.File, m *downloadManifest, i int, urls []string, downloaded *atomic.Int64) error {
+	d := dReq.downloader
+	c := m.Chunks[i]
+	times := max(d.retryTimes, 1)
+	var err error
+	for attempt := range times {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(d.retryInterval):
+			}
+		}
+		start := m.offset(c)
+		if start > c.End {
+			return nil
+		}
+		url := urls[(i+attempt)%len(urls)]
+		header := dReq.requestHeader()
+		header.Set(httpx.HeaderRange, httpx.FormatRange(start, c.End))
+		ifRange := url == m.Url && m.ETag != "" && !strings.HasPrefix(m.ETag, "W/")
+		if ifRange {
+			header.Set(httpx.HeaderIfRange, m.ETag)
+		}
+		var resp *http.Response
+		resp, err = dReq.response(ctx, url, header)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK && ifRange {
+				return fmt.Errorf("%w: %s", ErrRemoteChanged, url)
+			}
+			err = d.newHTTPError(resp, body)
+			continue
+		}
+		rangeStart, rangeEnd, total, perr := httpx.ParseContentRange(resp.Header.Get(httpx.HeaderContentRange))
+		if perr != nil || rangeStart != start || rangeEnd > c.End || total != m.Size {
+			resp.Body.Close()
+			err = fmt.Errorf("%w: unexpected Content-Range %q from %s", ErrRemoteChanged, resp.Header.Get(httpx.HeaderContentRange), url)
+			continue
+		}
+		err = writeAt(f, io.LimitReader(resp.Body, rangeEnd-start+1), start, func(n int64) {
+			m.advance(c, n)
+			downloaded.Add(n)
+		})
+		resp.Body.Close()
+		if err == nil && m.offset(c) > c.End {
+			return nil
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		log.Warn(err, "url:", url)
+	}
+	return err
+}
+
+// writeAt copies r to f from offset, calling written after each write.
+func writeAt(f *os.File, r io.Reader, offset int64, written func(n int64)) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, werr := f.WriteAt(buf[:n], offset); werr != nil {
+				return werr
+			}
+			offset += int64(n)
+			written(int64(n))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// streamDownload downloads the body of the probe response of a server without range support.
+func (dReq *DownloadReq) streamDownload(filepath string, remote *remoteFile) error {
+	tmpPath := filepath + DownloadKey
+	f, err := fs.Create(tmpPath)
+	if err != nil {
+		remote.resp.Body.Close()
+		return err
+	}
+	var downloaded atomic.Int64
+	stopProgress := dReq.reportProgress(remote.size, &downloaded, nil)
+	err = writeAt(f, remote.resp.Body, 0, func(n int64) { downloaded.Add(n) })
+	remote.resp.Body.Close()
+	stopProgress()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = dReq.verify(tmpPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, filepath)
+}
+
+// reportProgress calls the progress callback and tick every ProgressInterval until the returned stop is called,
+// which reports once more.
+func (dReq *DownloadReq) reportProgress(total int64, downloaded *atomic.Int64, tick func()) (stop func()) {
+	if dReq.progress == nil && tick == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	last, lastTime := downloaded.Load(), time.Now()
+	report := func() {
+		now, n := time.Now(), downloaded.Load()
+		if dReq.progress != nil {
+			var speed float64
+			if elapsed := now.Sub(lastTime).Seconds(); elapsed > 0 {
+				speed = float64(n-last) / elapsed
+			}
+			dReq.progress(Progress{Total: total, Downloaded: n, Speed: speed})
+		}
+		last, lastTime = n, now
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(ProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				report()
+				return
+			case <-ticker.C:
+				if tick != nil {
+					tick()
+				}
+				report()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// verify checks the checksum of the file if set.
+func (dReq *DownloadReq) verify(filepath string) error {
+	if dReq.checksum == "" {
+		return nil
+	}
+	h, err := dReq.checksum.newHash()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(filepath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = io.Copy(h, f); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, dReq.sum) {
+		return fmt.Errorf("%w: %s %s, want %s", ErrChecksumMismatch, dReq.checksum, sum, dReq.sum)
+	}
+	return nil
+}
+
+// ConcurrencyDownload executes the operation.
+func (d *Downloader) ConcurrencyDownload(filepath string, r *DownloadReq, concurrencyNum int) error {
+	return r.Downloader(d).ConcurrencyDownload(filepath, concurrencyNum)
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+
+	httpx "github.com/hopeio/gox/net/http"
+)
+
+type SessionConfig struct {
+	// Login signs in, usually with requests of the same client so the cookie jar keeps the session.
+	// Requests must use ctx, they bypass the session check.
+	Login func(ctx context.Context) error
+	// Expired reports whether the response means the session expired, default status 401
+	Expired func(resp *http.Response) bool
+}
+
+// Session logs in again when a response shows the session expired and replays the request.
+type Session struct {
+	config SessionConfig
+
+	mu sync.Mutex
+	// generation counts successful logins
+	generation uint64
+}
+
+type sessionLoginKey struct{}
+
+// NewSession creates and returns a new instance.
+func NewSession(config SessionConfig) *Session {
+	if config.Expired == nil {
+		config.Expired = func(resp *http.Response) bool {
+			return resp.StatusCode == http.StatusUnauthorized
+		}
+	}
+	return &Session{config: config}
+}
+
+// Session logs in again with config.Login when a response shows the session expired, then replays the request.
+// It is usually combined with a CookieJar.
+func (d *Client) Session(config SessionConfig) *Client {
+	return d.Use(NewSession(config).Middleware())
+}
+
+// login runs the login function unless another login succeeded since generation.
+func (s *Session) login(ctx context.Context, generation uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 并发请求同时过期时只登录一次
+	if s.generation != generation {
+		return nil
+	}
+	if err := s.config.Login(context.WithValue(ctx, sessionLoginKey{}, true)); err != nil {
+		return err
+	}
+	s.generation++
+	return nil
+}
+
+// Login signs in now, e.g. before the first request.
+func (s *Session) Login(ctx context.Context) error {
+	s.mu.Lock()
+	generation := s.generation
+	s.mu.Unlock()
+	return s.login(ctx, generation)
+}
+
+// Middleware returns the session as a client middleware.
+func (s *Session) Middleware() Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Context().Value(sessionLoginKey{}) != nil {
+				return next(req)
+			}
+			s.mu.Lock()
+			generation := s.generation
+			s.mu.Unlock()
+			// http.Client 会把 cookie jar 的 cookie 加到原请求上,重放时需要去掉
+			cookies, hasCookie := req.Header[httpx.HeaderCookie]
+			cookies = slices.Clone(cookies)
+
+			resp, err := next(req)
+			if err != nil || !s.config.Expired(resp) {
+				return resp, err
+			}
+			// 请求体无法重放时返回原响应
+			if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+				return resp, nil
+			}
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			if err = s.login(req.Context(), generation); err != nil {
+				return nil, fmt.Errorf("session login: %w", err)
+			}
+			replay := req.Clone(req.Context())
+			if hasCookie {
+				replay.Header[httpx.HeaderCookie] = cookies
+			} else {
+				replay.Header.Del(httpx.HeaderCookie)
+			}
+			if req.GetBody != nil {
+				if replay.Body, err = req.GetBody(); err != nil {
+					return nil, err
+				}
+			}
+			return next(replay)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	httpx "github.com/hopeio/gox/net/http"
+)
+
+const (
+	sigV4Algorithm         = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat        = "20060102T150405Z"
+	sigV4UnsignedPayload   = "UNSIGNED-PAYLOAD"
+	HeaderAmzDate          = "X-Amz-Date"
+	HeaderAmzContentSHA    = "X-Amz-Content-Sha256"
+	HeaderAmzSecurityToken = "X-Amz-Security-Token"
+)
+
+// SigV4Auth signs requests with AWS Signature Version 4,
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_sigv-create-signed-request.html.
+type SigV4Auth struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken of temporary credentials
+	SessionToken string
+	Region       string
+	Service      string
+	// UnsignedPayload skips hashing the body, only allowed by some services like S3
+	UnsignedPayload bool
+	// DisableURIPathEscaping signs the path as is instead of escaping it again, required by S3
+	DisableURIPathEscaping bool
+	// Now default time.Now
+	Now func() time.Time
+}
+
+// Authenticate implements Authenticator.
+func (a *SigV4Auth) Authenticate(req *http.Request) error {
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	t := now().UTC()
+	amzDate := t.Format(sigV4TimeFormat)
+	date := amzDate[:8]
+
+	payloadHash := sigV4UnsignedPayload
+	if !a.UnsignedPayload {
+		body, err := requestBody(req)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set(HeaderAmzDate, amzDate)
+	if a.Service == "s3" || a.UnsignedPayload {
+		req.Header.Set(HeaderAmzContentSHA, payloadHash)
+	}
+	if a.SessionToken != "" {
+		req.Header.Set(HeaderAmzSecurityToken, a.SessionToken)
+	}
+
+	canonicalHeaders, signedHeaders := a.canonicalHeaders(req)
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if !a.DisableURIPathEscaping {
+		path = sigV4Escape(path, false)
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		sigV4Query(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + a.Region + "/" + a.Service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := sigV4Algorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+a.SecretAccessKey), date)
+	key = hmacSHA256(key, a.Region)
+	key = hmacSHA256(key, a.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set(httpx.HeaderAuthorization, sigV4Algorithm+" Credential="+a.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return nil
+}
+
+// canonicalHeaders returns the canonical headers ending with a newline and the signed header names.
+// Host, Content-Type, Content-MD5 and the X-Amz- headers are signed.
+func (a *SigV4Auth) canonicalHeaders(req *http.Request) (string, string) {
+	headers := map[string]string{"host": headerValues(req, httpx.HeaderHost)[0]}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower != "content-type" && lower != "content-md5" && !strings.HasPrefix(lower, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[lower] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// sigV4Query returns the canonical query string, sorted by key and value.
+func sigV4Query(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	escaped := make(map[string]string, len(query))
+	for k := range query {
+		ek := sigV4Escape(k, true)
+		keys = append(keys, ek)
+		escaped[ek] = k
+	}
+	slices.Sort(keys)
+	var pairs []string
+	for _, ek := range keys {
+		values := make([]string, len(query[escaped[ek]]))
+		for i, v := range query[escaped[ek]] {
+			values[i] = sigV4Escape(v, true)
+		}
+		slices.Sort(values)
+		for _, v := range values {
+			pairs = append(pairs, ek+"="+v)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// sigV4Escape percent-encodes everything but the unreserved characters of RFC 3986, and '/' unless encodeSlash.
+func sigV4Escape(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			c == '/' && !encodeSlash {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&15])
+	}
+	return b.String()
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,262 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+	httpx "github.com/hopeio/gox/net/http"
+)
+
+const HeaderLastEventID = "Last-Event-ID"
+
+// DefaultSSERetry is the reconnection delay until the server sends a retry field.
+var DefaultSSERetry = 3 * time.Second
+
+// Event is a Server-Sent Event.
+type Event struct {
+	// ID is the last event ID, it persists across events until changed
+	ID    string
+	Event string
+	Data  string
+	// Retry is the reconnection time sent with the event, 0 if not sent
+	Retry time.Duration
+}
+
+// EventReader parses a text/event-stream as of the HTML Living Standard 9.2.6.
+type EventReader struct {
+	scanner *bufio.Scanner
+	lastID  string
+	retry   time.Duration
+}
+
+// NewEventReader creates a new instance.
+func NewEventReader(r io.Reader) *EventReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), 1<<20)
+	scanner.Split(scanSSELines)
+	return &EventReader{scanner: scanner}
+}
+
+// Next returns the next event, io.EOF at the end of the stream. An incomplete last event is discarded.
+func (r *EventReader) Next() (*Event, error) {
+	event := &Event{}
+	var data strings.Builder
+	var hasData bool
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		if line == "" {
+			if !hasData {
+				event.Event, event.Retry = "", 0
+				continue
+			}
+			event.ID = r.lastID
+			event.Data = strings.TrimSuffix(data.String(), "\n")
+			if event.Event == "" {
+				event.Event = "message"
+			}
+			return event, nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				r.lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				event.Retry = time.Duration(ms) * time.Millisecond
+				r.retry = event.Retry
+			}
+		}
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// LastEventID returns the last event ID seen.
+func (r *EventReader) LastEventID() string {
+	return r.lastID
+}
+
+// Retry returns the last reconnection time sent, 0 if none.
+func (r *EventReader) Retry() time.Duration {
+	return r.retry
+}
+
+// scanSSELines splits lines ended by CRLF, LF or CR.
+func scanSSELines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		// CR 在末尾,需要更多数据判断是否为 CRLF
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+/*
+DoSSE subscribes to a Server-Sent Events stream.
+
+When the stream ends or breaks it reconnects after the retry time sent by the server (DefaultSSERetry if none)
+with Last-Event-ID set. It stops after yielding an error when a connection fails, including the retries of the
+client, when the response is not text/event-stream, or when the request context is done.
+A 204 No Content response ends the stream. Breaking the loop closes the connection.
+*/
+func (req *Request) DoSSE(param any) iter.Seq2[*Event, error] {
+	return func(yield func(*Event, error) bool) {
+		ctx := req.context()
+		var lastID string
+		retry := DefaultSSERetry
+		for {
+			r := req.clone()
+			r.AddHeader(httpx.HeaderAccept, httpx.ContentTypeTextEventStream)
+			r.AddHeader(httpx.HeaderCacheControl, httpx.CacheControlNoCache)
+			if lastID != "" {
+				r.AddHeader(HeaderLastEventID, lastID)
+			}
+			var resp *http.Response
+			if err := r.Do(param, &resp); err != nil {
+				yield(nil, err)
+				return
+			}
+			if resp.StatusCode == http.StatusNoContent {
+				resp.Body.Close()
+				return
+			}
+			if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get(httpx.HeaderContentType)); mediaType != httpx.ContentTypeTextEventStream {
+				resp.Body.Close()
+				yield(nil, fmt.Errorf("unexpected content type %q for event stream", mediaType))
+				return
+			}
+
+			reader := NewEventReader(resp.Body)
+			reader.lastID = lastID
+			for {
+				event, err := reader.Next()
+				if err != nil {
+					break
+				}
+				if !yield(event, nil) {
+					resp.Body.Close()
+					return
+				}
+			}
+			resp.Body.Close()
+			lastID = reader.lastID
+			if reader.retry > 0 {
+				retry = reader.retry
+			}
+
+			select {
+			case <-ctx.Done():
+				yield(nil, ctx.Err())
+				return
+			case <-time.After(retry):
+			}
+		}
+	}
+}
+
+/*
+DoJSONLines consumes a newline delimited JSON stream (NDJSON, JSON Lines), every non-blank line is decoded
+into a new T by the client's response unmarshaler. The sequence stops after the first error,
+breaking the loop or canceling the request context closes the connection.
+*/
+func (req *Request) DoJSONLines[T any](param any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		var resp *http.Response
+		if err := req.Do(param, &resp); err != nil {
+			yield(zero, err)
+			return
+		}
+		defer resp.Body.Close()
+		unmarshal := req.client.respBodyUnMarshal
+		if unmarshal == nil {
+			unmarshal = jsonx.Unmarshal
+		}
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				var v T
+				if uerr := unmarshal(line, &v); uerr != nil {
+					yield(zero, fmt.Errorf("json lines: %w", uerr))
+					return
+				}
+				if !yield(v, nil) {
+					return
+				}
+			}
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					if ctxErr := req.context().Err(); ctxErr != nil {
+						err = ctxErr
+					}
+					yield(zero, err)
+				}
+				return
+			}
+		}
+	}
+}
+
+// context returns the context of the request.
+func (req *Request) context() context.Context {
+	if req.ctx == nil {
+		return context.Background()
+	}
+	return req.ctx
+}
+
+// clone returns a copy that Do can change, e.g. appending the query to the url.
+func (req *Request) clone() *Request {
+	r := *req
+	r.header = req.header.Clone()
+	return &r
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEventReader(t *testing.T) {
+	stream := ": comment\r\n" +
+		"retry: 1500\r\n\r\n" +
+		"event: update\ndata: a\ndata:b\nid: 1\n\n" +
+		"data: c\r\r" +
+		"data: incomplete"
+	r := NewEventReader(strings.NewReader(stream))
+	var got []string
+	for {
+		event, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s|%s|%q", event.ID, event.Event, event.Data))
+	}
+	want := []string{`1|update|"a\nb"`, `1|message|"c"`}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if r.Retry() != 1500*time.Millisecond {
+		t.Errorf("got retry %s", r.Retry())
+	}
+}
+
+func TestDoSSEReconnect(t *testing.T) {
+	var conns atomic.Int32
+	var lastIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastIDs = append(lastIDs, r.Header.Get(HeaderLastEventID))
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		switch conns.Add(1) {
+		case 1:
+			fmt.Fprint(w, "retry: 10\n\nid: 1\ndata: one\n\nid: 2\ndata: two\n\n")
+		case 2:
+			fmt.Fprint(w, "id: 3\ndata: three\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	var data []string
+	for event, err := range newTestClient().Request(http.MethodGet, srv.URL).DoSSE(nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, event.ID+":"+event.Data)
+	}
+	if strings.Join(data, ",") != "1:one,2:two,3:three" {
+		t.Errorf("got %v", data)
+	}
+	if strings.Join(lastIDs, ",") != ",2,3" {
+		t.Errorf("got Last-Event-IDs %q", lastIDs)
+	}
+}
+
+func TestDoSSECancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var events int
+	var lastErr error
+	for event, err := range newTestClient().Request(http.MethodGet, srv.URL).Context(ctx).DoSSE(nil) {
+		if err != nil {
+			lastErr = err
+			break
+		}
+		events++
+		if event.Data == "first" {
+			cancel()
+		}
+	}
+	if events != 1 || !errors.Is(lastErr, context.Canceled) {
+		t.Errorf("got %d events, err %v", events, lastErr)
+	}
+}
+
+func TestDoSSEContentType(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	for _, err := range newTestClient().Request(http.MethodGet, srv.URL).DoSSE(nil) {
+		if err == nil {
+			t.Fatal("expected content type error")
+		}
+	}
+}
+
+func TestDoJSONLines(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "{\"n\":1}\n\n{\"n\":2}\r\n{\"n\":3}")
+	}))
+	defer srv.Close()
+
+	type item struct {
+		N int `json:"n"`
+	}
+	var sum int
+	for v, err := range newTestClient().Request(http.MethodGet, srv.URL).DoJSONLines[item](nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum += v.N
+	}
+	if sum != 6 {
+		t.Errorf("got sum %d", sum)
+	}
+
+	var n int
+	var lastErr error
+	srvBad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "{\"n\":1}\nnot json\n{\"n\":3}\n")
+	}))
+	defer srvBad.Close()
+	for _, err := range newTestClient().Request(http.MethodGet, srvBad.URL).DoJSONLines[item](nil) {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		n++
+	}
+	if n != 1 || lastErr == nil {
+		t.Errorf("got %d items, err %v", n, lastErr)
+	}
+}
@@ -0,0 +1,253 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	httpx "github.com/hopeio/gox/net/http"
+	"github.com/hopeio/gox/net/http/tus"
+)
+
+// Resume stores the upload url of UploadTus in store under fingerprint, so an upload interrupted even by a
+// process restart resumes from the offset the server has.
+func (r *UploadReq) Resume(store CacheStorage, fingerprint string) *UploadReq {
+	r.resumeStore = store
+	r.fingerprint = fingerprint
+	return r
+}
+
+// Checksum sends the digest of every chunk of UploadTus with the checksum extension.
+func (r *UploadReq) Checksum(algorithm ChecksumAlgorithm) *UploadReq {
+	r.checksum = algorithm
+	return r
+}
+
+// UploadFileTus uploads the file with UploadTus, the filename is sent as metadata. With Resume and an
+// empty fingerprint, the fingerprint is derived from the path, size and modification time.
+func (r *UploadReq) UploadFileTus(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if r.resumeStore != nil && r.fingerprint == "" {
+		abs, _ := filepath.Abs(path)
+		r.fingerprint = fmt.Sprintf("%s-%d-%d", abs, stat.Size(), stat.ModTime().UnixNano())
+	}
+	return r.UploadTus(f, stat.Size(), map[string]string{"filename": stat.Name()})
+}
+
+/*
+UploadTus uploads size bytes of reader to the tus endpoint Url in chunks of ChunkSize and returns the upload url.
+A failed chunk is retried from the offset reported by the server, up to the uploader retry times in a row.
+*/
+func (r *UploadReq) UploadTus(reader io.ReaderAt, size int64, metadata map[string]string) (string, error) {
+	u := r.uploader
+	chunkSize := r.chunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	storeKey := "tus:" + r.fingerprint
+
+	var location string
+	var offset int64 = -1
+	if r.resumeStore != nil {
+		if data, ok := r.resumeStore.Get(storeKey); ok {
+			location = string(data)
+			o, length, err := r.tusOffset(location)
+			var httpErr *HTTPError
+			switch {
+			case err == nil && length == size:
+				offset = o
+			case err == nil || errors.As(err, &httpErr) && httpErr.StatusCode < 500:
+				// 上传已失效,重新创建
+				r.resumeStore.Delete(storeKey)
+			default:
+				return location, err
+			}
+		}
+	}
+	if offset < 0 {
+		var err error
+		if location, err = r.createTus(size, metadata); err != nil {
+			return "", err
+		}
+		offset = 0
+		if r.resumeStore != nil {
+			if err = r.resumeStore.Set(storeKey, []byte(location)); err != nil {
+				return location, err
+			}
+		}
+	}
+
+	var failures int
+	for offset < size {
+		next, err := r.patchTus(location, io.NewSectionReader(reader, offset, min(chunkSize, size-offset)), offset)
+		if err == nil {
+			offset = next
+			failures = 0
+			continue
+		}
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && !tusRetryable(httpErr.StatusCode) {
+			return location, err
+		}
+		if failures++; failures >= max(u.retryTimes, 1) {
+			return location, err
+		}
+		select {
+		case <-r.ctx.Done():
+			return location, r.ctx.Err()
+		case <-time.After(u.retryInterval):
+		}
+		// 以服务端的偏移量为准
+		if o, _, herr := r.tusOffset(location); herr == nil {
+			offset = o
+		}
+	}
+	if r.resumeStore != nil {
+		r.resumeStore.Delete(storeKey)
+	}
+	return location, nil
+}
+
+// TerminateTus deletes an upload with the termination extension.
+func (r *UploadReq) TerminateTus(location string) error {
+	req, err := r.newTusRequest(http.MethodDelete, location, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.uploader.do(req)
+	if err != nil {
+		return err
+	}
+	return r.tusError(resp, http.StatusNoContent)
+}
+
+// tusRetryable reports whether a failed PATCH may succeed after syncing the offset.
+func tusRetryable(status int) bool {
+	return status == http.StatusConflict || status == http.StatusLocked || status == tus.StatusChecksumMismatch ||
+		status == http.StatusTooManyRequests || status >= 500
+}
+
+// newTusRequest returns a tus request with the request and uploader headers.
+func (r *UploadReq) newTusRequest(method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(r.ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if r.header != nil {
+		req.Header = r.header.Clone()
+	}
+	u := r.uploader
+	httpx.CopyHttpHeader(req.Header, u.header)
+	for _, opt := range u.httpRequestOptions {
+		opt(req)
+	}
+	req.Header.Set(tus.HeaderTusResumable, tus.Version)
+	return req, nil
+}
+
+// tusError closes the response and returns an HTTPError if the status is not expected.
+func (r *UploadReq) tusError(resp *http.Response, expected int) error {
+	defer resp.Body.Close()
+	if resp.StatusCode == expected {
+		return nil
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return r.uploader.newHTTPError(resp, data)
+}
+
+// createTus creates an upload and returns its absolute url.
+func (r *UploadReq) createTus(size int64, metadata map[string]string) (string, error) {
+	req, err := r.newTusRequest(http.MethodPost, r.Url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(tus.HeaderUploadLength, strconv.FormatInt(size, 10))
+	if len(metadata) > 0 {
+		req.Header.Set(tus.HeaderUploadMetadata, tus.EncodeMetadata(metadata))
+	}
+	resp, err := r.uploader.do(req)
+	if err != nil {
+		return "", err
+	}
+	if err = r.tusError(resp, http.StatusCreated); err != nil {
+		return "", err
+	}
+	location, err := req.URL.Parse(resp.Header.Get(httpx.HeaderLocation))
+	if err != nil {
+		return "", err
+	}
+	return location.String(), nil
+}
+
+// tusOffset returns the offset and length of an upload.
+func (r *UploadReq) tusOffset(location string) (offset, length int64, err error) {
+	req, err := r.newTusRequest(http.MethodHead, location, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	resp, err := r.uploader.do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err = r.tusError(resp, http.StatusOK); err != nil {
+		return 0, 0, err
+	}
+	if offset, err = strconv.ParseInt(resp.Header.Get(tus.HeaderUploadOffset), 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid %s: %w", tus.HeaderUploadOffset, err)
+	}
+	length, _ = strconv.ParseInt(resp.Header.Get(tus.HeaderUploadLength), 10, 64)
+	return offset, length, nil
+}
+
+// patchTus sends a chunk at offset and returns the new offset.
+func (r *UploadReq) patchTus(location string, chunk *io.SectionReader, offset int64) (int64, error) {
+	var checksum string
+	if r.checksum != "" {
+		h, err := r.checksum.newHash()
+		if err != nil {
+			return 0, err
+		}
+		if _, err = io.Copy(h, chunk); err != nil {
+			return 0, err
+		}
+		chunk.Seek(0, io.SeekStart)
+		checksum = tus.FormatChecksum(string(r.checksum), h.Sum(nil))
+	}
+	req, err := r.newTusRequest(http.MethodPatch, location, chunk)
+	if err != nil {
+		return 0, err
+	}
+	req.ContentLength = chunk.Size()
+	req.Header.Set(httpx.HeaderContentType, tus.ContentTypeOffsetOctetStream)
+	req.Header.Set(tus.HeaderUploadOffset, strconv.FormatInt(offset, 10))
+	if checksum != "" {
+		req.Header.Set(tus.HeaderUploadChecksum, checksum)
+	}
+	resp, err := r.uploader.do(req)
+	if err != nil {
+		return 0, err
+	}
+	if err = r.tusError(resp, http.StatusNoContent); err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(resp.Header.Get(tus.HeaderUploadOffset), 10, 64)
+}
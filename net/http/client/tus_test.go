@@ -0,0 +1,88 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hopeio/gox/net/http/tus"
+)
+
+func TestUploadTusResume(t *testing.T) {
+	store, err := tus.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var completed atomic.Pointer[tus.Info]
+	handler := tus.NewHandler(store, "/files/")
+	handler.OnComplete = func(info *tus.Info) { completed.Store(info) }
+	var creates, patches atomic.Int32
+	var failAt atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			creates.Add(1)
+		case http.MethodPatch:
+			// 模拟连接中断
+			if n := patches.Add(1); n == failAt.Load() {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	content := make([]byte, 10_000)
+	for i := range content {
+		content[i] = byte(rand.IntN(256))
+	}
+	path := filepath.Join(t.TempDir(), "data.bin")
+	os.WriteFile(path, content, 0644)
+	resume, err := NewDiskCacheStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploader := NewUploader().RetryTimesWithInterval(1, time.Millisecond)
+
+	failAt.Store(3)
+	_, err = uploader.UploadReq(srv.URL+"/files/").ChunkSize(1024).Checksum(ChecksumSHA256).Resume(resume, "").UploadFileTus(path)
+	if err == nil {
+		t.Fatal("want error from the interrupted upload")
+	}
+
+	// 新的请求(如进程重启后)从服务端偏移量继续
+	failAt.Store(0)
+	patches.Store(0)
+	location, err := uploader.UploadReq(srv.URL+"/files/").ChunkSize(1024).Checksum(ChecksumSHA256).Resume(resume, "").UploadFileTus(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creates.Load() != 1 || patches.Load() != 8 {
+		t.Errorf("got %d creates %d patches after resume", creates.Load(), patches.Load())
+	}
+	info := completed.Load()
+	if info == nil || info.Metadata["filename"] != "data.bin" || location != srv.URL+"/files/"+info.ID {
+		t.Fatalf("got %+v at %s", info, location)
+	}
+	got, _ := os.ReadFile(store.Path(info.ID))
+	if !bytes.Equal(got, content) {
+		t.Fatal("content mismatch")
+	}
+
+	if err = uploader.UploadReq(srv.URL).TerminateTus(location); err != nil {
+		t.Fatal(err)
+	}
+}
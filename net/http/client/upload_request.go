@@ -49,6 +49,10 @@ type UploadReq struct {
 	boundary  string
 	mode      UploadMode
 	chunkSize int64
+	// tus
+	resumeStore CacheStorage
+	fingerprint string
+	checksum    ChecksumAlgorithm
 }
 
 type Multipart struct {
@@ -192,14 +196,14 @@ func (r *UploadReq) UploadMultipart(formData map[string]string, files ...*Multip
 		opt(req)
 	}
 	req.Header.Set(httpx.HeaderContentType, w.FormDataContentType())
-	resp, err := d.httpClient.Do(req)
+	resp, err := d.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+	if !d.isSuccess(resp.StatusCode) {
 		data, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("status:%s %s", resp.Status, string(data))
+		return d.newHTTPError(resp, data)
 	}
 	return nil
 }
@@ -226,7 +230,7 @@ func (r *UploadReq) UploadReader(reader io.Reader, name string) error {
 	req.Header.Set(httpx.HeaderContentType, httpx.ContentTypeOctetStream)
 	req.Header.Set(httpx.HeaderContentLength, strconv.FormatInt(r.chunkSize, 10))
 	req.Header.Set(httpx.HeaderContentDisposition, httpx.FormatAttachment(name))
-	resp, err := u.httpClient.Do(req)
+	resp, err := u.do(req)
 	if err != nil {
 		return err
 	}
@@ -256,7 +260,7 @@ func (r *UploadReq) UploadReaderChunked(reader io.ReaderAt, name string, total i
 		req.ContentLength = chunk
 		req.Header.Set(httpx.HeaderContentRange, httpx.FormatContentRange(start, start+chunk-1, total))
 		req.Header.Set(httpx.HeaderContentLength, strconv.FormatInt(chunk, 10))
-		resp, err := u.httpClient.Do(req)
+		resp, err := u.do(req)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,45 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/hopeio/gox/net/http/websocket"
+)
+
+// WebSocket dials a WebSocket connection with the client settings, the base url, shared headers,
+// request options and the http client, so its proxy and TLS configuration apply.
+func (d *Client) WebSocket(ctx context.Context, url string, opts *websocket.DialOptions) (*websocket.Conn, *http.Response, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if d.baseUrl != "" && !strings.Contains(url, "://") {
+		url = d.baseUrl + url
+	}
+	var dialOpts websocket.DialOptions
+	if opts != nil {
+		dialOpts = *opts
+	}
+	if d.header != nil {
+		header := d.header.Clone()
+		for k, vs := range dialOpts.Header {
+			header[k] = vs
+		}
+		dialOpts.Header = header
+	}
+	if len(d.httpRequestOptions) > 0 {
+		requestOptions := make([]func(*http.Request), 0, len(d.httpRequestOptions)+len(dialOpts.RequestOptions))
+		for _, opt := range d.httpRequestOptions {
+			requestOptions = append(requestOptions, opt)
+		}
+		dialOpts.RequestOptions = append(requestOptions, dialOpts.RequestOptions...)
+	}
+	return websocket.Dial(ctx, d.httpClient, url, &dialOpts)
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hopeio/gox/net/http/websocket"
+)
+
+func TestClientWebSocket(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/ws", websocket.Handler(&websocket.Upgrader{}, func(conn *websocket.Conn, r *http.Request) {
+		conn.WriteMessage(websocket.TextMessage, []byte(r.Header.Get("Authorization")+" "+r.Header.Get("X-Trace")))
+	}))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c := newTestClient().BaseUrl(srv.URL).
+		Header(http.Header{"X-Trace": {"shared"}}).
+		HttpRequestOptions(func(req *http.Request) { req.Header.Set("Authorization", "Bearer token") })
+	conn, _, err := c.WebSocket(context.Background(), "/ws", &websocket.DialOptions{Header: http.Header{"X-Trace": {"override"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "Bearer token override" {
+		t.Errorf("got %q", data)
+	}
+}
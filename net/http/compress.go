@@ -0,0 +1,580 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package http
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings, RFC 9110 8.4.1.
+const (
+	EncodingBrotli   = "br"
+	EncodingZstd     = "zstd"
+	EncodingGzip     = "gzip"
+	EncodingDeflate  = "deflate"
+	EncodingIdentity = "identity"
+)
+
+var (
+	// DefaultEncodings are offered in order of server preference.
+	DefaultEncodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+	// DefaultCompressibleContentTypes are media type patterns matched with path.Match.
+	DefaultCompressibleContentTypes = []string{
+		"text/*", "application/json", "application/*+json", "application/javascript", "application/x-javascript",
+		"application/xml", "application/*+xml", "application/wasm", "application/x-ndjson", "image/svg+xml",
+	}
+	DefaultCompressOptions = &CompressOptions{
+		Encodings:          DefaultEncodings,
+		MinSize:            1024,
+		ContentTypes:       DefaultCompressibleContentTypes,
+		ExcludedExtensions: DefaultExcludedExtensions,
+	}
+	// precompressedExtensions are the sibling file extensions of each encoding.
+	precompressedExtensions = map[string]string{
+		EncodingBrotli: ".br",
+		EncodingZstd:   ".zst",
+		EncodingGzip:   ".gz",
+	}
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+)
+
+type CompressOptions struct {
+	// Encodings 按服务端偏好排序, 客户端 q 值相同时靠前的优先; nil 时为 DefaultEncodings
+	Encodings []string
+	// MinSize 响应体小于该字节数时不压缩, 小于等于 0 时总是压缩
+	MinSize int
+	// ContentTypes 允许压缩的媒体类型, nil 时为 DefaultCompressibleContentTypes; "*/*" 压缩任意类型, 包括未知类型
+	ContentTypes       []string
+	ExcludedExtensions ExcludedExtensions
+	ExcludedPaths      ExcludedPaths
+	ExcludedPathsRegex ExcludedPathsRegex
+}
+
+// encoder is implemented by the writers of every encoding.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+type compressHandler struct {
+	*CompressOptions
+	next  http.Handler
+	pools map[string]*sync.Pool
+}
+
+// Compress returns a middleware negotiating the response content coding with Accept-Encoding.
+// level is a gzip level, mapped to the closest level of brotli and zstd.
+func Compress(level int, options *CompressOptions) Middleware {
+	if options == nil {
+		options = DefaultCompressOptions
+	}
+	if options.Encodings == nil || options.ContentTypes == nil {
+		opts := *options
+		if opts.Encodings == nil {
+			opts.Encodings = DefaultEncodings
+		}
+		if opts.ContentTypes == nil {
+			opts.ContentTypes = DefaultCompressibleContentTypes
+		}
+		options = &opts
+	}
+	pools := make(map[string]*sync.Pool, len(options.Encodings))
+	for _, encoding := range options.Encodings {
+		newEncoder := encoderFactory(encoding, level)
+		if newEncoder == nil {
+			panic(fmt.Sprintf("compress: %s: %v", encoding, ErrUnsupportedEncoding))
+		}
+		pools[encoding] = &sync.Pool{New: func() any { return newEncoder() }}
+	}
+	return func(next http.Handler) http.Handler {
+		return &compressHandler{CompressOptions: options, next: next, pools: pools}
+	}
+}
+
+// encoderFactory returns the constructor of the encoder of encoding at a gzip style level.
+func encoderFactory(encoding string, level int) func() encoder {
+	if level < NoCompression || level > BestCompression {
+		level = DefaultCompression
+	}
+	switch encoding {
+	case EncodingGzip:
+		return func() encoder {
+			w, _ := gzip.NewWriterLevel(io.Discard, level)
+			return w
+		}
+	case EncodingDeflate:
+		return func() encoder {
+			w, _ := zlib.NewWriterLevel(io.Discard, level)
+			return w
+		}
+	case EncodingBrotli:
+		// 动态内容用 4 级, 压缩率接近 gzip 9 级且更快
+		brLevel := 4
+		if level != DefaultCompression {
+			brLevel = level + level/4
+		}
+		return func() encoder { return brotli.NewWriterLevel(io.Discard, brLevel) }
+	case EncodingZstd:
+		zstdLevel := zstd.SpeedDefault
+		if level != DefaultCompression {
+			zstdLevel = zstd.EncoderLevelFromZstd(max(level, 1))
+		}
+		return func() encoder {
+			// RFC 8878 要求 HTTP 中窗口不超过 8MB
+			w, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstdLevel), zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(8<<20))
+			return w
+		}
+	}
+	return nil
+}
+
+// ServeHTTP executes the operation.
+func (h *compressHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.shouldCompress(r) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	cw := &compressWriter{
+		ResponseWriter: w,
+		handler:        h,
+		encoding:       NegotiateEncoding(r.Header.Get(HeaderAcceptEncoding), h.Encodings),
+		head:           r.Method == http.MethodHead,
+	}
+	defer cw.close()
+	h.next.ServeHTTP(cw, r)
+}
+
+// shouldCompress reports whether the condition holds.
+func (h *compressHandler) shouldCompress(r *http.Request) bool {
+	if strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") {
+		return false
+	}
+	return !h.ExcludedExtensions.Contains(filepath.Ext(r.URL.Path)) &&
+		!h.ExcludedPaths.Contains(r.URL.Path) && !h.ExcludedPathsRegex.Contains(r.URL.Path)
+}
+
+// compressible reports whether the media type is in the allowlist.
+func (h *compressHandler) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if mediaType == "text/event-stream" {
+		return false
+	}
+	for _, pattern := range h.ContentTypes {
+		if pattern == "*/*" {
+			return true
+		}
+		if ok, _ := path.Match(pattern, mediaType); ok && err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter buffers the body until MinSize is reached, then decides whether to compress.
+type compressWriter struct {
+	http.ResponseWriter
+	handler  *compressHandler
+	encoding string
+	head     bool
+	code     int
+	buf      []byte
+	decided  bool
+	enc      encoder
+}
+
+// WriteHeader performs the operation.
+func (w *compressWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.decided || w.code != 0 {
+		return
+	}
+	w.code = code
+	if code == http.StatusNoContent || code == http.StatusNotModified || w.head {
+		w.decide(false)
+	}
+}
+
+// Write performs the operation.
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		if w.code == 0 {
+			w.code = http.StatusOK
+		}
+		w.buf = append(w.buf, p...)
+		if len(w.buf) >= w.handler.MinSize {
+			if err := w.decide(false); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// WriteString performs the operation.
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends the buffered data, a flushed response is compressed regardless of MinSize.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.code == 0 {
+			w.code = http.StatusOK
+		}
+		if w.decide(true) != nil {
+			return
+		}
+	}
+	if w.enc != nil && w.enc.Flush() != nil {
+		return
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide writes the header, with the compression headers when the response is compressible.
+func (w *compressWriter) decide(flush bool) error {
+	w.decided = true
+	header := w.Header()
+	eligible := w.code >= http.StatusOK && w.code != http.StatusNoContent && w.code != http.StatusNotModified &&
+		w.code != http.StatusPartialContent && header.Get(HeaderContentEncoding) == "" &&
+		(flush || len(w.buf) >= w.handler.MinSize || w.head)
+	if eligible {
+		contentType := header.Get(HeaderContentType)
+		if contentType == "" && len(w.buf) > 0 {
+			contentType = http.DetectContentType(w.buf)
+		}
+		eligible = w.handler.compressible(contentType)
+		if eligible && w.encoding != "" && !w.head {
+			// 压缩后 net/http 无法再嗅探类型
+			header.Set(HeaderContentType, contentType)
+		}
+	}
+	if eligible {
+		// 表示可能因 Accept-Encoding 不同而不同, 即使本次没有压缩
+		AddVary(header, HeaderAcceptEncoding)
+	}
+	if eligible && w.encoding != "" {
+		header.Set(HeaderContentEncoding, w.encoding)
+		header.Del(HeaderContentLength)
+		header.Del(HeaderAcceptRanges)
+		// 压缩表示与原始表示字节不同, 强 ETag 降为弱 ETag
+		if etag := header.Get(HeaderETag); strings.HasPrefix(etag, `"`) {
+			header.Set(HeaderETag, "W/"+etag)
+		}
+		if !w.head {
+			w.enc = w.handler.pools[w.encoding].Get().(encoder)
+			w.enc.Reset(w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close finishes the response after the handler returns.
+func (w *compressWriter) close() {
+	if !w.decided {
+		if w.code == 0 {
+			// handler 未写任何内容, 交给 net/http 默认处理
+			return
+		}
+		w.decide(false)
+	}
+	if w.enc != nil {
+		w.enc.Close()
+		w.enc.Reset(io.Discard)
+		w.handler.pools[w.encoding].Put(w.enc)
+		w.enc = nil
+	}
+}
+
+// AddVary adds value to the Vary header unless it is already listed.
+func AddVary(header http.Header, value string) {
+	for _, v := range header.Values(HeaderVary) {
+		for _, field := range strings.Split(v, ",") {
+			if field = strings.TrimSpace(field); field == "*" || strings.EqualFold(field, value) {
+				return
+			}
+		}
+	}
+	header.Add(HeaderVary, value)
+}
+
+// NegotiateEncoding returns the offer with the highest q-value in acceptEncoding, ties broken by
+// the order of offers, or "" when identity should be used.
+func NegotiateEncoding(acceptEncoding string, offers []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qvalues := make(map[string]float64)
+	wildcard := -1.0
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(item, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for param := range strings.SplitSeq(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && f >= 0 && f <= 1 {
+					q = f
+				} else {
+					q = 0
+				}
+			}
+		}
+		switch coding {
+		case "*":
+			wildcard = q
+		case "x-gzip":
+			coding = EncodingGzip
+			fallthrough
+		default:
+			if old, ok := qvalues[coding]; !ok || q > old {
+				qvalues[coding] = q
+			}
+		}
+	}
+	var best string
+	bestQ := 0.0
+	for _, offer := range offers {
+		q, ok := qvalues[offer]
+		if !ok {
+			q = max(wildcard, 0)
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// PrecompressedFileServer serves files from root like http.FileServer, preferring the sibling
+// compressed by the negotiated encoding, e.g. app.js.br or app.js.gz for app.js.
+func PrecompressedFileServer(root http.FileSystem, encodings ...string) http.Handler {
+	if len(encodings) == 0 {
+		encodings = DefaultEncodings
+	}
+	fileServer := http.FileServer(root)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/") || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+		original, err := root.Open(name)
+		if err != nil {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+		info, err := original.Stat()
+		original.Close()
+		if err != nil || info.IsDir() {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+		var available []string
+		for _, encoding := range encodings {
+			if ext, ok := precompressedExtensions[encoding]; ok {
+				if f, err := root.Open(name + ext); err == nil {
+					f.Close()
+					available = append(available, encoding)
+				}
+			}
+		}
+		if len(available) == 0 {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+		AddVary(w.Header(), HeaderAcceptEncoding)
+		encoding := NegotiateEncoding(r.Header.Get(HeaderAcceptEncoding), available)
+		if encoding == "" {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+		f, err := root.Open(name + precompressedExtensions[encoding])
+		if err != nil {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+		defer f.Close()
+		compressed, err := f.Stat()
+		if err != nil {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+		header := w.Header()
+		if header.Get(HeaderContentType) == "" {
+			contentType := mime.TypeByExtension(path.Ext(name))
+			if contentType == "" {
+				contentType = ContentTypeOctetStream
+			}
+			header.Set(HeaderContentType, contentType)
+		}
+		header.Set(HeaderContentEncoding, encoding)
+		http.ServeContent(w, r, name, compressed.ModTime(), f)
+	})
+}
+
+// DecompressBody returns the request body decoded by every coding of Content-Encoding, in reverse
+// order of application. Closing it closes the original body.
+func DecompressBody(r *http.Request) (io.ReadCloser, error) {
+	encodings := r.Header.Values(HeaderContentEncoding)
+	if len(encodings) == 0 {
+		return r.Body, nil
+	}
+	var codings []string
+	for _, v := range encodings {
+		for _, coding := range strings.Split(v, ",") {
+			if coding = strings.ToLower(strings.TrimSpace(coding)); coding != "" && coding != EncodingIdentity {
+				codings = append(codings, coding)
+			}
+		}
+	}
+	body := &decompressReadCloser{Reader: r.Body, closers: []io.Closer{r.Body}}
+	for i := len(codings) - 1; i >= 0; i-- {
+		if err := body.decode(codings[i]); err != nil {
+			for _, closer := range body.closers[1:] {
+				closer.Close()
+			}
+			return nil, err
+		}
+	}
+	if len(body.closers) == 1 {
+		return r.Body, nil
+	}
+	return body, nil
+}
+
+// DecompressRequest returns a middleware replacing the request body with DecompressBody,
+// maxSize limits the decompressed size when positive.
+func DecompressRequest(maxSize int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(HeaderContentEncoding) == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			body, err := DecompressBody(r)
+			if err != nil {
+				code := http.StatusBadRequest
+				if errors.Is(err, ErrUnsupportedEncoding) {
+					w.Header().Set(HeaderAcceptEncoding, "br, zstd, gzip, deflate")
+					code = http.StatusUnsupportedMediaType
+				}
+				http.Error(w, err.Error(), code)
+				return
+			}
+			if maxSize > 0 {
+				body = http.MaxBytesReader(w, body, maxSize)
+			}
+			r.Body = body
+			r.Header.Del(HeaderContentEncoding)
+			r.Header.Del(HeaderContentLength)
+			r.ContentLength = -1
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type decompressReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// decode wraps the reader with the decoder of coding.
+func (d *decompressReadCloser) decode(coding string) error {
+	switch coding {
+	case EncodingGzip, "x-gzip":
+		gr, err := gzip.NewReader(d.Reader)
+		if err != nil {
+			return fmt.Errorf("failed to create gzip reader %w", err)
+		}
+		d.Reader = gr
+		d.closers = append(d.closers, gr)
+	case EncodingBrotli:
+		d.Reader = brotli.NewReader(d.Reader)
+		d.closers = append(d.closers, io.NopCloser(nil))
+	case EncodingZstd:
+		zr, err := zstd.NewReader(d.Reader, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return fmt.Errorf("failed to create zstd reader %w", err)
+		}
+		d.Reader = zr
+		d.closers = append(d.closers, zr.IOReadCloser())
+	case EncodingDeflate, "x-deflate":
+		// 规范要求 zlib 格式, 但不少客户端发送裸 deflate
+		br := bufio.NewReader(d.Reader)
+		if header, err := br.Peek(2); err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return fmt.Errorf("failed to create zlib reader %w", err)
+			}
+			d.Reader = zr
+			d.closers = append(d.closers, zr)
+		} else {
+			fr := flate.NewReader(br)
+			d.Reader = fr
+			d.closers = append(d.closers, fr)
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedEncoding, coding)
+	}
+	return nil
+}
+
+// Close closes the decoders and the underlying body.
+func (d *decompressReadCloser) Close() error {
+	var err error
+	for i := len(d.closers) - 1; i >= 0; i-- {
+		if cerr := d.closers[i].Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
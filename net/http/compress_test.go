@@ -0,0 +1,249 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	offers := []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+	tests := []struct {
+		accept, want string
+	}{
+		{"", ""},
+		{"gzip, deflate, br, zstd", EncodingBrotli},
+		{"gzip;q=1.0, br;q=0.5", EncodingGzip},
+		{"br;q=0, gzip", EncodingGzip},
+		{"*", EncodingBrotli},
+		{"*;q=0.1, zstd", EncodingZstd},
+		{"x-gzip", EncodingGzip},
+		{"deflate", ""},
+		{"gzip;q=0, *;q=0", ""},
+		{"GZIP ; Q=0.8, BR ; q=0.9", EncodingBrotli},
+	}
+	for _, test := range tests {
+		if got := NegotiateEncoding(test.accept, offers); got != test.want {
+			t.Errorf("NegotiateEncoding(%q) = %q, want %q", test.accept, got, test.want)
+		}
+	}
+}
+
+// decode decodes body by encoding.
+func decode(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	switch encoding {
+	case EncodingBrotli:
+		r = brotli.NewReader(bytes.NewReader(body))
+	case EncodingZstd:
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		r = zr
+	case EncodingGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gr
+	default:
+		return string(body)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("hello compress ", 200)
+	handler := Compress(DefaultCompression, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small":
+			w.Header().Set(HeaderContentType, "text/plain")
+			io.WriteString(w, "small")
+		case "/binary":
+			w.Header().Set(HeaderContentType, ContentTypeOctetStream)
+			io.WriteString(w, large)
+		case "/encoded":
+			w.Header().Set(HeaderContentEncoding, EncodingGzip)
+			io.WriteString(w, large)
+		default:
+			w.Header().Set(HeaderETag, `"v1"`)
+			w.Header().Set(HeaderContentLength, "3000")
+			// 未设置 Content-Type 时按内容嗅探
+			for i := 0; i < len(large); i += 100 {
+				io.WriteString(w, large[i:min(i+100, len(large))])
+			}
+		}
+	}))
+	serve := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set(HeaderAcceptEncoding, accept)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, encoding := range []string{EncodingBrotli, EncodingZstd, EncodingGzip} {
+		rec := serve("/", encoding)
+		if got := rec.Header().Get(HeaderContentEncoding); got != encoding {
+			t.Fatalf("Content-Encoding %q, want %q", got, encoding)
+		}
+		if decode(t, encoding, rec.Body.Bytes()) != large {
+			t.Errorf("%s body mismatch", encoding)
+		}
+		if rec.Header().Get(HeaderVary) != HeaderAcceptEncoding || rec.Header().Get(HeaderETag) != `W/"v1"` ||
+			rec.Header().Get(HeaderContentLength) != "" || !strings.HasPrefix(rec.Header().Get(HeaderContentType), "text/plain") {
+			t.Errorf("%s header %v", encoding, rec.Header())
+		}
+	}
+
+	rec := serve("/", "")
+	if rec.Body.String() != large || rec.Header().Get(HeaderContentEncoding) != "" ||
+		rec.Header().Get(HeaderVary) != HeaderAcceptEncoding || rec.Header().Get(HeaderETag) != `"v1"` {
+		t.Errorf("identity response %v", rec.Header())
+	}
+	for _, path := range []string{"/small", "/binary", "/encoded"} {
+		rec = serve(path, "br")
+		if path != "/encoded" && rec.Header().Get(HeaderContentEncoding) != "" {
+			t.Errorf("%s should not be compressed", path)
+		}
+		if path == "/encoded" && (rec.Header().Get(HeaderContentEncoding) != EncodingGzip || rec.Body.String() != large) {
+			t.Errorf("%s should be passed through", path)
+		}
+	}
+}
+
+func TestNewGzipHandler(t *testing.T) {
+	handler := NewGzipHandler(DefaultCompression, &GzipOptions{
+		ExcludedExtensions: DefaultExcludedExtensions,
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/binary" {
+				w.Header().Set(HeaderContentType, ContentTypeOctetStream)
+			}
+			io.WriteString(w, "small")
+		},
+	})
+	// 与原实现一致, 小响应和不在允许列表中的类型也压缩
+	for _, path := range []string{"/small", "/binary"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(HeaderAcceptEncoding, EncodingGzip)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Header().Get(HeaderContentEncoding) != EncodingGzip || decode(t, EncodingGzip, rec.Body.Bytes()) != "small" {
+			t.Errorf("%s not compressed: %v", path, rec.Header())
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/a.png", nil)
+	req.Header.Set(HeaderAcceptEncoding, EncodingGzip)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Header().Get(HeaderContentEncoding) != "" {
+		t.Error("excluded extension compressed")
+	}
+}
+
+func TestPrecompressedFileServer(t *testing.T) {
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	io.WriteString(gw, "console.log(1)")
+	gw.Close()
+	fsys := fstest.MapFS{
+		"app.js":    {Data: []byte("console.log(1)")},
+		"app.js.gz": {Data: gz.Bytes()},
+		"plain.txt": {Data: []byte("plain")},
+	}
+	handler := PrecompressedFileServer(http.FS(fsys))
+	serve := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(HeaderAcceptEncoding, accept)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("/app.js", "br, gzip")
+	if rec.Header().Get(HeaderContentEncoding) != EncodingGzip || decode(t, EncodingGzip, rec.Body.Bytes()) != "console.log(1)" ||
+		!strings.Contains(rec.Header().Get(HeaderContentType), "javascript") || rec.Header().Get(HeaderVary) != HeaderAcceptEncoding {
+		t.Errorf("precompressed %v", rec.Header())
+	}
+	rec = serve("/app.js", "br")
+	if rec.Header().Get(HeaderContentEncoding) != "" || rec.Body.String() != "console.log(1)" || rec.Header().Get(HeaderVary) == "" {
+		t.Errorf("fallback %v", rec.Header())
+	}
+	if rec = serve("/plain.txt", "gzip"); rec.Body.String() != "plain" {
+		t.Errorf("plain %q", rec.Body.String())
+	}
+}
+
+func TestDecompressBody(t *testing.T) {
+	// 先 deflate(裸) 再 gzip
+	var deflated, gzipped bytes.Buffer
+	fw, _ := flate.NewWriter(&deflated, flate.BestSpeed)
+	io.WriteString(fw, "payload")
+	fw.Close()
+	gw := gzip.NewWriter(&gzipped)
+	gw.Write(deflated.Bytes())
+	gw.Close()
+
+	var got string
+	handler := DecompressRequest(1 << 20)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		got = string(data)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/", &gzipped)
+	req.Header.Set(HeaderContentEncoding, "deflate, gzip")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "payload" {
+		t.Errorf("got %q", got)
+	}
+
+	var zstdBody bytes.Buffer
+	zw, _ := zstd.NewWriter(&zstdBody)
+	io.WriteString(zw, "zstd payload")
+	zw.Close()
+	req = httptest.NewRequest(http.MethodPost, "/", &zstdBody)
+	req.Header.Set(HeaderContentEncoding, EncodingZstd)
+	body, err := GzipBody(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(body); string(data) != "zstd payload" {
+		t.Errorf("got %q", data)
+	}
+	body.Close()
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("x"))
+	req.Header.Set(HeaderContentEncoding, "compress")
+	if _, err = DecompressBody(req); !errors.Is(err, ErrUnsupportedEncoding) {
+		t.Errorf("got %v", err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("status %d", rec.Code)
+	}
+}
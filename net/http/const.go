@@ -12,6 +12,7 @@ const (
 	HeaderTraceID                     = "Tracing-ID"
 	HeaderTraceBin                    = "Tracing-Bin"
 	HeaderAuthorization               = "Authorization"
+	HeaderProxyAuthorization          = "Proxy-Authorization"
 	HeaderCookie                      = "Cookie"
 	HeaderCookieValueToken            = "token"
 	HeaderCookieValueDel              = "del"
@@ -46,6 +47,17 @@ const (
 	HeaderContentRange                = "Content-Range"
 	HeaderAcceptRanges                = "Accept-Ranges"
 	HeaderXForwardedHost              = "X-Forwarded-Host"
+	HeaderETag                        = "ETag"
+	HeaderIfNoneMatch                 = "If-None-Match"
+	HeaderIfModifiedSince             = "If-Modified-Since"
+	HeaderIfRange                     = "If-Range"
+	HeaderVary                        = "Vary"
+	HeaderAge                         = "Age"
+	HeaderExpires                     = "Expires"
+	HeaderContentLocation             = "Content-Location"
+	HeaderRetryAfter                  = "Retry-After"
+	HeaderWWWAuthenticate             = "WWW-Authenticate"
+	HeaderXAPIKey                     = "X-API-Key"
 )
 
 const (
@@ -155,7 +167,14 @@ func FormatAttachment(filename string) string {
 }
 
 const (
-	CacheControlNoCache = "no-cache"
+	CacheControlNoCache        = "no-cache"
+	CacheControlNoStore        = "no-store"
+	CacheControlMaxAge         = "max-age"
+	CacheControlMaxStale       = "max-stale"
+	CacheControlMinFresh       = "min-fresh"
+	CacheControlMustRevalidate = "must-revalidate"
+	CacheControlOnlyIfCached   = "only-if-cached"
+	CacheControlStaleIfError   = "stale-if-error"
 )
 
 const (
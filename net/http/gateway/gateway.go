@@ -0,0 +1,304 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+// Package gateway is a load-balancing reverse proxy routing requests by host and path prefix to pools of upstreams.
+//
+// Upstreams are picked round-robin, by least connections or by consistent hashing. Unavailable upstreams are
+// skipped: the active health check polls every upstream, the passive health check marks an upstream down
+// after consecutive failed requests. Requests failing to connect are retried on another upstream. WebSocket
+// and other upgraded connections are passed through.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	httpx "github.com/hopeio/gox/net/http"
+)
+
+var (
+	ErrNoUpstream  = errors.New("gateway: no upstream available")
+	errMissingHost = errors.New("missing scheme or host")
+)
+
+// HeaderRules rewrite headers, Remove is applied first, then Set and Add.
+type HeaderRules struct {
+	Set    map[string]string
+	Add    map[string]string
+	Remove []string
+}
+
+// Apply rewrites header.
+func (h *HeaderRules) Apply(header http.Header) {
+	if h == nil {
+		return
+	}
+	for _, k := range h.Remove {
+		header.Del(k)
+	}
+	for k, v := range h.Set {
+		header.Set(k, v)
+	}
+	for k, v := range h.Add {
+		header.Add(k, v)
+	}
+}
+
+// Route proxies the matched requests to Pool.
+type Route struct {
+	// Host 为空时匹配任意主机, 比较时忽略端口和大小写
+	Host string
+	// PathPrefix matches whole segments, "/api" matches "/api" and "/api/users" but not "/apis"
+	PathPrefix string
+	// StripPrefix removes PathPrefix from the upstream request path
+	StripPrefix bool
+	// PreserveHost sends the Host of the client instead of the upstream host
+	PreserveHost    bool
+	Pool            *Pool
+	RequestHeaders  *HeaderRules
+	ResponseHeaders *HeaderRules
+}
+
+// Match reports whether the route matches r.
+func (rt *Route) Match(r *http.Request) bool {
+	if rt.Host != "" {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if !strings.EqualFold(host, rt.Host) {
+			return false
+		}
+	}
+	prefix := strings.TrimSuffix(rt.PathPrefix, "/")
+	return prefix == "" || r.URL.Path == prefix || strings.HasPrefix(r.URL.Path, prefix+"/")
+}
+
+// Gateway is an http.Handler proxying requests by the first matched route.
+type Gateway struct {
+	Routes []*Route
+	// Transport nil 时为 http.DefaultTransport
+	Transport http.RoundTripper
+	// Retries 连接失败时换其它上游重试的次数
+	Retries int
+	once    sync.Once
+	proxy   *httputil.ReverseProxy
+}
+
+type stateKey struct{}
+
+// proxyState is the state of a proxied request.
+type proxyState struct {
+	route *Route
+	in    *http.Request
+}
+
+// New creates and returns a new instance.
+func New(routes ...*Route) *Gateway {
+	return &Gateway{Routes: routes, Retries: 2}
+}
+
+// Start runs the active health checks of the pools until ctx is done.
+func (g *Gateway) Start(ctx context.Context) {
+	var pools []*Pool
+	for _, route := range g.Routes {
+		if !slices.Contains(pools, route.Pool) {
+			pools = append(pools, route.Pool)
+			go route.Pool.RunHealthCheck(ctx)
+		}
+	}
+}
+
+// ServeHTTP executes the operation.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var route *Route
+	for _, rt := range g.Routes {
+		if rt.Match(r) {
+			route = rt
+			break
+		}
+	}
+	if route == nil {
+		httpx.WriteProblem(w, r, httpx.NewProblem(http.StatusNotFound, "no route"))
+		return
+	}
+	g.once.Do(g.init)
+	ctx := context.WithValue(r.Context(), stateKey{}, &proxyState{route: route, in: r})
+	g.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// init creates the reverse proxy.
+func (g *Gateway) init() {
+	g.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			route := pr.In.Context().Value(stateKey{}).(*proxyState).route
+			pr.SetXForwarded()
+			if route.StripPrefix {
+				path := strings.TrimPrefix(pr.In.URL.Path, strings.TrimSuffix(route.PathPrefix, "/"))
+				if !strings.HasPrefix(path, "/") {
+					path = "/" + path
+				}
+				pr.Out.URL.Path, pr.Out.URL.RawPath = path, ""
+			}
+			if !route.PreserveHost {
+				pr.Out.Host = ""
+			}
+			route.RequestHeaders.Apply(pr.Out.Header)
+		},
+		Transport: roundTripper{g},
+		ModifyResponse: func(resp *http.Response) error {
+			route := resp.Request.Context().Value(stateKey{}).(*proxyState).route
+			route.ResponseHeaders.Apply(resp.Header)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, context.Canceled) && r.Context().Err() != nil {
+				return
+			}
+			status := http.StatusBadGateway
+			var netErr net.Error
+			if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+				status = http.StatusGatewayTimeout
+			}
+			httpx.WriteProblem(w, r, &upstreamError{status: status, err: err})
+		},
+	}
+}
+
+// roundTripper sends the request to an upstream picked by the pool of the route, retrying on another
+// upstream when connecting fails.
+type roundTripper struct {
+	g *Gateway
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	state := req.Context().Value(stateKey{}).(*proxyState)
+	pool := state.route.Pool
+	transport := t.g.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	var body *retryBody
+	if req.Body != nil && req.Body != http.NoBody {
+		body = &retryBody{ReadCloser: req.Body}
+	}
+	var tried []*Upstream
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		upstream := pool.Pick(state.in, tried)
+		if upstream == nil {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, ErrNoUpstream
+		}
+		tried = append(tried, upstream)
+		out := req.Clone(req.Context())
+		out.URL.Scheme, out.URL.Host = upstream.URL.Scheme, upstream.URL.Host
+		if base := strings.TrimSuffix(upstream.URL.Path, "/"); base != "" {
+			out.URL.Path, out.URL.RawPath = base+req.URL.Path, ""
+		}
+		if out.Host == "" {
+			out.Host = upstream.URL.Host
+		}
+		if body != nil {
+			out.Body = body
+		}
+		upstream.active.Add(1)
+		resp, err := transport.RoundTrip(out)
+		if err != nil {
+			upstream.active.Add(-1)
+			upstream.report(&pool.Passive, false)
+			// 连接失败时请求体未被读取, 可以发往其它上游
+			if isConnectError(err) && attempt < t.g.Retries && (body == nil || !body.read.Load()) && req.Context().Err() == nil {
+				lastErr = err
+				continue
+			}
+			return nil, err
+		}
+		upstream.report(&pool.Passive, !slices.Contains(pool.Passive.FailStatuses, resp.StatusCode))
+		counted := &upstreamBody{ReadCloser: resp.Body, done: func() { upstream.active.Add(-1) }}
+		if w, ok := resp.Body.(io.Writer); ok && resp.StatusCode == http.StatusSwitchingProtocols {
+			resp.Body = upstreamConn{counted, w}
+		} else {
+			resp.Body = counted
+		}
+		return resp, nil
+	}
+}
+
+// upstreamError is a failed proxied request, WriteProblem logs it and hides the message from the client.
+type upstreamError struct {
+	status int
+	err    error
+}
+
+// Error returns the error message.
+func (e *upstreamError) Error() string {
+	return "gateway: " + e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *upstreamError) Unwrap() error {
+	return e.err
+}
+
+// StatusCode returns 502 or 504.
+func (e *upstreamError) StatusCode() int {
+	return e.status
+}
+
+// isConnectError reports whether err happened while connecting to the upstream.
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// retryBody records whether the request body was read, closing is left to the reverse proxy.
+type retryBody struct {
+	io.ReadCloser
+	read atomic.Bool
+}
+
+// Read reads data.
+func (b *retryBody) Read(p []byte) (int, error) {
+	b.read.Store(true)
+	return b.ReadCloser.Read(p)
+}
+
+// Close is a no-op, the transport closes the body of failed attempts.
+func (b *retryBody) Close() error {
+	return nil
+}
+
+// upstreamBody ends the in-flight request of the upstream when closed.
+type upstreamBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+// Close closes the body.
+func (b *upstreamBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// upstreamConn is the body of an upgraded connection, it is written by the reverse proxy.
+type upstreamConn struct {
+	*upstreamBody
+	io.Writer
+}
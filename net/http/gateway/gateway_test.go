@@ -0,0 +1,190 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package gateway
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// upstream returns a server answering with its name, the request path and the X-Gateway header.
+func upstream(t *testing.T, name string) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			if name == "sick" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+		if r.Header.Get("Upgrade") == "websocket" {
+			conn, rw, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+			rw.Flush()
+			line, _ := rw.ReadString('\n')
+			rw.WriteString(name + " " + line)
+			rw.Flush()
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Internal", "1")
+		io.WriteString(w, name+" "+r.URL.Path+" "+r.Header.Get("X-Gateway")+" "+string(body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// deadURL returns the URL of a closed listener.
+func deadURL(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	return "http://" + ln.Addr().String()
+}
+
+func get(h http.Handler, target string, set func(r *http.Request)) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if set != nil {
+		set(r)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestGateway(t *testing.T) {
+	a, b := upstream(t, "a"), upstream(t, "b")
+	pool, err := NewPool("api", RoundRobin, deadURL(t), a.URL, b.URL+"/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Passive.MaxFails = 1
+	g := New(&Route{
+		PathPrefix:      "/api",
+		StripPrefix:     true,
+		Pool:            pool,
+		RequestHeaders:  &HeaderRules{Set: map[string]string{"X-Gateway": "gw"}},
+		ResponseHeaders: &HeaderRules{Remove: []string{"X-Internal"}, Set: map[string]string{"X-Served-By": "gw"}},
+	})
+
+	// 第一次命中不可用的上游, 重试到下一个; 之后被动检查将其标记为不可用
+	seen := map[string]int{}
+	for range 4 {
+		rec := get(g, "/api/users", nil)
+		if rec.Code != http.StatusOK || rec.Header().Get("X-Internal") != "" || rec.Header().Get("X-Served-By") != "gw" {
+			t.Fatalf("%d %v %s", rec.Code, rec.Header(), rec.Body)
+		}
+		seen[rec.Body.String()]++
+	}
+	if seen["a /users gw "] != 2 || seen["b /v1/users gw "] != 2 {
+		t.Errorf("round robin %v", seen)
+	}
+	if pool.Upstreams[0].Available() {
+		t.Error("dead upstream should be marked down")
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/echo", strings.NewReader("body"))
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, r)
+	if !strings.HasSuffix(rec.Body.String(), " body") {
+		t.Errorf("post %s", rec.Body)
+	}
+	if rec = get(g, "/apis", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("no route %d", rec.Code)
+	}
+
+	dead, _ := NewPool("dead", RoundRobin, deadURL(t), deadURL(t))
+	if rec = get(New(&Route{Pool: dead}), "/", nil); rec.Code != http.StatusBadGateway {
+		t.Errorf("all dead %d", rec.Code)
+	}
+}
+
+func TestPool_Pick(t *testing.T) {
+	pool, _ := NewPool("p", ConsistentHash, "http://a", "http://b", "http://c")
+	pool.HashKey = HashByHeader("X-User")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-User", "alice")
+	first := pool.Pick(r, nil)
+	for range 5 {
+		if pool.Pick(r, nil) != first {
+			t.Fatal("consistent hash should stick")
+		}
+	}
+	// 不可用时移到环上的下一个, 恢复后回到原上游
+	first.healthy.Store(false)
+	fallback := pool.Pick(r, nil)
+	if fallback == first || pool.Pick(r, nil) != fallback {
+		t.Errorf("fallback %v", fallback.URL)
+	}
+	first.healthy.Store(true)
+	if pool.Pick(r, nil) != first {
+		t.Error("should return to the first upstream")
+	}
+	if pool.Pick(r, pool.Upstreams) != nil {
+		t.Error("all tried should return nil")
+	}
+
+	least, _ := NewPool("p", LeastConnections, "http://a", "http://b")
+	least.Upstreams[0].active.Add(2)
+	for range 3 {
+		if u := least.Pick(r, nil); u != least.Upstreams[1] {
+			t.Errorf("least connections %v", u.URL)
+		}
+	}
+}
+
+func TestPool_CheckHealth(t *testing.T) {
+	pool, _ := NewPool("p", RoundRobin, upstream(t, "ok").URL, upstream(t, "sick").URL)
+	pool.HealthCheck = &HealthCheck{Path: "/healthz", Fall: 2, Rise: 1}
+	pool.CheckHealth(t.Context())
+	if !pool.Upstreams[1].Healthy() {
+		t.Error("one failure should not reach Fall")
+	}
+	pool.CheckHealth(t.Context())
+	if !pool.Upstreams[0].Healthy() || pool.Upstreams[1].Healthy() {
+		t.Errorf("healthy %v %v", pool.Upstreams[0].Healthy(), pool.Upstreams[1].Healthy())
+	}
+	for range 3 {
+		if u := pool.Pick(httptest.NewRequest(http.MethodGet, "/", nil), nil); u != pool.Upstreams[0] {
+			t.Errorf("picked unhealthy %v", u.URL)
+		}
+	}
+}
+
+func TestGateway_WebSocket(t *testing.T) {
+	pool, _ := NewPool("ws", LeastConnections, upstream(t, "ws").URL)
+	s := httptest.NewServer(New(&Route{Pool: pool}))
+	defer s.Close()
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /chat HTTP/1.1\r\nHost: gw\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("%v %v", resp, err)
+	}
+	if pool.Upstreams[0].Active() != 1 {
+		t.Errorf("active %d", pool.Upstreams[0].Active())
+	}
+	io.WriteString(conn, "ping\n")
+	if line, _ := br.ReadString('\n'); line != "ws ping\n" {
+		t.Errorf("got %q", line)
+	}
+}
@@ -0,0 +1,302 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package gateway
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hopeio/gox/container/consistenthash"
+	httpx "github.com/hopeio/gox/net/http"
+)
+
+type Strategy int
+
+const (
+	RoundRobin Strategy = iota
+	// LeastConnections picks the upstream with the fewest in-flight requests.
+	LeastConnections
+	// ConsistentHash picks the upstream by Pool.HashKey on a container/consistenthash ring, requests of a key
+	// stick to an upstream and move to the next one on the ring while it is unavailable.
+	ConsistentHash
+)
+
+// HashKey returns the key ConsistentHash picks the upstream by, "" falls back to RoundRobin.
+type HashKey func(r *http.Request) string
+
+// HashByHeader hashes by the value of the header.
+func HashByHeader(name string) HashKey {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// HashByCookie hashes by the value of the cookie.
+func HashByCookie(name string) HashKey {
+	return func(r *http.Request) string {
+		if c, err := r.Cookie(name); err == nil {
+			return c.Value
+		}
+		return ""
+	}
+}
+
+// HashByIP hashes by the client IP of RemoteAddr.
+func HashByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// HealthCheck is the active health check of a pool.
+type HealthCheck struct {
+	// Path 请求的路径, 如 /healthz
+	Path     string
+	Interval time.Duration
+	Timeout  time.Duration
+	// Rise consecutive successes mark an unhealthy upstream healthy
+	Rise int
+	// Fall consecutive failures mark a healthy upstream unhealthy
+	Fall int
+	// Status reports whether the status code is healthy, nil accepts 2xx and 3xx
+	Status func(code int) bool
+	Client *http.Client
+}
+
+// PassiveHealthCheck marks an upstream unavailable for FailTimeout after MaxFails consecutive failed
+// requests, a failed request is a transport error or a response with one of FailStatuses.
+type PassiveHealthCheck struct {
+	MaxFails    int
+	FailTimeout time.Duration
+	// FailStatuses 视为失败的响应状态码, 如 502, 503, 504
+	FailStatuses []int
+}
+
+// Upstream is a server of a pool.
+type Upstream struct {
+	URL     *url.URL
+	healthy atomic.Bool
+	active  atomic.Int64
+	mu      sync.Mutex
+	// fails 被动检查连续失败次数, checkFails/checkSuccesses 为主动检查的
+	fails          int
+	downUntil      time.Time
+	checkFails     int
+	checkSuccesses int
+}
+
+// Healthy reports whether the active health check considers the upstream healthy.
+func (u *Upstream) Healthy() bool {
+	return u.healthy.Load()
+}
+
+// Active returns the number of in-flight requests.
+func (u *Upstream) Active() int64 {
+	return u.active.Load()
+}
+
+// Available reports whether the upstream is healthy and not marked down by the passive health check.
+func (u *Upstream) Available() bool {
+	if !u.healthy.Load() {
+		return false
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return !time.Now().Before(u.downUntil)
+}
+
+// report records the result of a proxied request for the passive health check.
+func (u *Upstream) report(passive *PassiveHealthCheck, ok bool) {
+	if passive.MaxFails <= 0 {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if ok {
+		u.fails = 0
+		return
+	}
+	u.fails++
+	if u.fails >= passive.MaxFails {
+		u.fails = 0
+		u.downUntil = time.Now().Add(passive.FailTimeout)
+	}
+}
+
+// check records the result of an active health check.
+func (u *Upstream) check(hc *HealthCheck, ok bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if ok {
+		u.checkFails, u.checkSuccesses = 0, u.checkSuccesses+1
+		if !u.healthy.Load() && u.checkSuccesses >= max(hc.Rise, 1) {
+			u.healthy.Store(true)
+		}
+		return
+	}
+	u.checkSuccesses, u.checkFails = 0, u.checkFails+1
+	if u.healthy.Load() && u.checkFails >= max(hc.Fall, 1) {
+		u.healthy.Store(false)
+	}
+}
+
+// Pool is a group of upstreams serving the same content.
+type Pool struct {
+	Name      string
+	Upstreams []*Upstream
+	Strategy  Strategy
+	// HashKey of ConsistentHash, nil 时为 HashByIP
+	HashKey     HashKey
+	HealthCheck *HealthCheck
+	Passive     PassiveHealthCheck
+	ring        *consistenthash.Map
+	upstreams   map[string]*Upstream
+	next        atomic.Uint64
+}
+
+// NewPool creates and returns a new instance of the upstream base URLs, e.g. http://10.0.0.1:8080/api.
+func NewPool(name string, strategy Strategy, urls ...string) (*Pool, error) {
+	p := &Pool{
+		Name:      name,
+		Strategy:  strategy,
+		Passive:   PassiveHealthCheck{MaxFails: 3, FailTimeout: 30 * time.Second},
+		ring:      consistenthash.New(100, nil),
+		upstreams: make(map[string]*Upstream, len(urls)),
+	}
+	for _, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, &url.Error{Op: "parse", URL: rawURL, Err: errMissingHost}
+		}
+		upstream := &Upstream{URL: u}
+		upstream.healthy.Store(true)
+		p.Upstreams = append(p.Upstreams, upstream)
+		p.upstreams[u.String()] = upstream
+		p.ring.Add(u.String())
+	}
+	return p, nil
+}
+
+// Pick returns an available upstream for r other than the tried ones, the unavailable upstreams are only
+// picked when no upstream is available. nil is returned when every upstream was tried.
+func (p *Pool) Pick(r *http.Request, tried []*Upstream) *Upstream {
+	candidates := make([]*Upstream, 0, len(p.Upstreams))
+	for _, u := range p.Upstreams {
+		if !slices.Contains(tried, u) {
+			candidates = append(candidates, u)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	available := slices.DeleteFunc(slices.Clone(candidates), func(u *Upstream) bool { return !u.Available() })
+	if len(available) == 0 {
+		// 全部不可用时仍然尝试, 避免健康检查误判导致整体不可用
+		available = candidates
+	}
+	switch p.Strategy {
+	case LeastConnections:
+		offset := int(p.next.Add(1))
+		var best *Upstream
+		for i := range available {
+			u := available[(offset+i)%len(available)]
+			if best == nil || u.Active() < best.Active() {
+				best = u
+			}
+		}
+		return best
+	case ConsistentHash:
+		hashKey := p.HashKey
+		if hashKey == nil {
+			hashKey = HashByIP
+		}
+		if key := hashKey(r); key != "" {
+			for _, name := range p.ring.GetN(key, len(p.Upstreams)) {
+				if u := p.upstreams[name]; slices.Contains(available, u) {
+					return u
+				}
+			}
+		}
+	}
+	return available[int(p.next.Add(1)-1)%len(available)]
+}
+
+// RunHealthCheck checks the upstreams every HealthCheck.Interval until ctx is done.
+func (p *Pool) RunHealthCheck(ctx context.Context) {
+	hc := p.HealthCheck
+	if hc == nil || hc.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(hc.Interval)
+	defer ticker.Stop()
+	for {
+		p.CheckHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckHealth checks every upstream once.
+func (p *Pool) CheckHealth(ctx context.Context) {
+	hc := p.HealthCheck
+	if hc == nil {
+		return
+	}
+	client := hc.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var wg sync.WaitGroup
+	for _, u := range p.Upstreams {
+		wg.Go(func() {
+			u.check(hc, probe(ctx, client, hc, u.URL))
+		})
+	}
+	wg.Wait()
+}
+
+// probe requests the health check path of base.
+func probe(ctx context.Context, client *http.Client, hc *HealthCheck, base *url.URL) bool {
+	if hc.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hc.Timeout)
+		defer cancel()
+	}
+	target := *base
+	target.Path = strings.TrimSuffix(base.Path, "/") + "/" + strings.TrimPrefix(hc.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set(httpx.HeaderUserAgent, "gateway-health-check")
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if hc.Status != nil {
+		return hc.Status(resp.StatusCode)
+	}
+	return resp.StatusCode >= 200 && resp.StatusCode < 400
+}
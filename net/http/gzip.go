@@ -8,14 +8,8 @@ package http
 
 import (
 	"compress/gzip"
-	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
-	"path/filepath"
-	"strconv"
-	"strings"
-	"sync"
 )
 
 var (
@@ -41,133 +35,38 @@ type GzipOptions struct {
 	Handler            http.HandlerFunc
 }
 
-type gzipWriter struct {
-	http.ResponseWriter
-	writer *gzip.Writer
-	size   int
-}
-
-// WriteString performs the operation.
-func (g *gzipWriter) WriteString(s string) (int, error) {
-	g.Header().Del("Content-Length")
-	return g.writer.Write([]byte(s))
-}
-
-// Write performs the operation.
-func (g *gzipWriter) Write(data []byte) (int, error) {
-	g.Header().Del("Content-Length")
-	n, err := g.writer.Write(data)
-	if err != nil {
-		return n, err
-	}
-	g.size += n
-	return n, nil
-}
-
-// WriteHeader performs the operation.
-func (g *gzipWriter) WriteHeader(code int) {
-	g.Header().Del("Content-Length")
-	g.ResponseWriter.WriteHeader(code)
-}
-
 type gzipHandler struct {
 	*GzipOptions
-	gzPool *sync.Pool
+	handler http.Handler
 }
 
-// NewGzipHandler creates and returns a new instance.
+// NewGzipHandler creates and returns a new instance, it is Compress with gzip only compressing every response
+// regardless of size and type as before, use Compress with CompressOptions for MinSize and the type allowlist.
 func NewGzipHandler(level int, options *GzipOptions) *gzipHandler {
-	var gzPool sync.Pool
-	gzPool.New = func() interface{} {
-		gz, err := gzip.NewWriterLevel(ioutil.Discard, level)
-		if err != nil {
-			panic(err)
-		}
-		return gz
-	}
 	if options == nil {
 		options = DefaultGzipOptions
 	}
-	handler := &gzipHandler{
-		GzipOptions: options,
-		gzPool:      &gzPool,
-	}
+	handler := &gzipHandler{GzipOptions: options}
+	handler.handler = Compress(level, &CompressOptions{
+		Encodings:          []string{EncodingGzip},
+		ContentTypes:       []string{"*/*"},
+		ExcludedExtensions: options.ExcludedExtensions,
+		ExcludedPaths:      options.ExcludedPaths,
+		ExcludedPathsRegex: options.ExcludedPathsRegex,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler.Handler != nil {
+			handler.Handler(w, r)
+		}
+	}))
 	return handler
 }
 
 // ServeHTTP executes the operation.
 func (g *gzipHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-
-	if !g.shouldCompress(r) {
-		if g.GzipOptions != nil && g.GzipOptions.Handler != nil {
-			g.GzipOptions.Handler(w, r)
-		}
-		return
-	}
-
-	gz := g.gzPool.Get().(*gzip.Writer)
-	defer g.gzPool.Put(gz)
-	defer gz.Reset(ioutil.Discard)
-	gz.Reset(w)
-	header := w.Header()
-	header.Set("Content-Encoding", "gzip")
-	header.Set("Vary", "Accept-Encoding")
-	gw := &gzipWriter{w, gz, 0}
-	if g.GzipOptions != nil && g.GzipOptions.Handler != nil {
-		g.GzipOptions.Handler(gw, r)
-	}
-	gz.Close()
-	header.Set("Content-Length", strconv.Itoa(gw.size))
-
-}
-
-// shouldCompress reports whether the condition holds.
-func (g *gzipHandler) shouldCompress(req *http.Request) bool {
-	if !strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") ||
-		strings.Contains(req.Header.Get("Connection"), "Upgrade") ||
-		strings.Contains(req.Header.Get("Content-Type"), "text/event-stream") {
-
-		return false
-	}
-
-	extension := filepath.Ext(req.URL.Path)
-	if g.ExcludedExtensions.Contains(extension) {
-		return false
-	}
-
-	if g.ExcludedPaths.Contains(req.URL.Path) {
-		return false
-	}
-	if g.ExcludedPathsRegex.Contains(req.URL.Path) {
-		return false
-	}
-
-	return true
+	g.handler.ServeHTTP(w, r)
 }
 
-// GzipBody performs the operation.
+// GzipBody returns the decoded request body, it is DecompressBody and handles every encoding.
 func GzipBody(r *http.Request) (io.ReadCloser, error) {
-	if r.Header.Get("Content-Encoding") == "gzip" {
-		reader, err := gzip.NewReader(r.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create gzip reader %w", err)
-		}
-		// gzip.Reader.Close 不会关闭底层 Body，组合关闭避免调用方泄漏连接
-		return &gzipReadCloser{Reader: reader, body: r.Body}, nil
-	}
-	return r.Body, nil
-}
-
-type gzipReadCloser struct {
-	*gzip.Reader
-	body io.ReadCloser
-}
-
-// Close closes both the gzip reader and the underlying body.
-func (g *gzipReadCloser) Close() error {
-	err := g.Reader.Close()
-	if berr := g.body.Close(); err == nil {
-		err = berr
-	}
-	return err
+	return DecompressBody(r)
 }
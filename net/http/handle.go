@@ -0,0 +1,263 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package http
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+	"github.com/hopeio/gox/encoding/msgpack"
+	"github.com/hopeio/gox/kvstruct"
+	"github.com/hopeio/gox/log"
+	"github.com/hopeio/gox/structtag/validate"
+	"google.golang.org/protobuf/proto"
+)
+
+// Validator validates the request bound by Handle, nil disables validation.
+var Validator kvstruct.Validator = validate.Default()
+
+// StatusCoder is implemented by responses and errors choosing their status code.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Handle returns a handler binding the request into Req with Bind, validating it with Validator,
+// calling fn and rendering the result with Render. A nil result is rendered as 204, errors are
+// rendered with WriteProblem.
+//
+//	mux.Handle("GET /users/{id}", httpx.Handle(func(ctx context.Context, req *GetUserReq) (*User, error) { ... }))
+func Handle[Req, Resp any](fn func(ctx context.Context, req *Req) (*Resp, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := new(Req)
+		if err := Bind(r, req); err != nil {
+			WriteProblem(w, r, err)
+			return
+		}
+		if Validator != nil {
+			if err := Validator.Validate(req); err != nil && !errors.Is(err, validate.ErrInvalidValue) {
+				WriteProblem(w, r, err)
+				return
+			}
+		}
+		resp, err := fn(r.Context(), req)
+		if err != nil {
+			WriteProblem(w, r, err)
+			return
+		}
+		if resp == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		status := http.StatusOK
+		if sc, ok := any(resp).(StatusCoder); ok {
+			status = sc.StatusCode()
+		}
+		Render(w, r, status, resp)
+	})
+}
+
+// Render writes v with the media type negotiated by Accept: JSON, msgpack, or protobuf when v is
+// a proto.Message. JSON is used when nothing acceptable is offered.
+func Render(w http.ResponseWriter, r *http.Request, status int, v any) {
+	offers := []string{ContentTypeJson, ContentTypeMsgPack, ContentTypeXMsgPack}
+	msg, isProto := v.(proto.Message)
+	if isProto {
+		offers = append(offers, ContentTypeProtobuf, ContentTypeXProtobuf)
+	}
+	contentType := NegotiateContentType(r.Header.Get(HeaderAccept), offers)
+	if contentType == "" {
+		contentType = ContentTypeJson
+	}
+	var data []byte
+	var err error
+	switch contentType {
+	case ContentTypeMsgPack, ContentTypeXMsgPack:
+		data, err = msgpack.Marshal(v)
+	case ContentTypeProtobuf, ContentTypeXProtobuf:
+		data, err = proto.Marshal(msg)
+	default:
+		data, err = jsonx.Marshal(v)
+	}
+	if err != nil {
+		log.Error("render: ", err)
+		WriteProblem(w, r, err)
+		return
+	}
+	header := w.Header()
+	header.Set(HeaderContentType, contentType)
+	header.Set(HeaderContentLength, strconv.Itoa(len(data)))
+	AddVary(header, HeaderAccept)
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// NegotiateContentType returns the offer with the highest q-value in accept, ties broken by the
+// order of offers, or "" when no offer is acceptable. An empty accept accepts the first offer.
+func NegotiateContentType(accept string, offers []string) string {
+	if accept == "" {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+	type mediaRange struct {
+		typ, subtype string
+		q            float64
+	}
+	var ranges []mediaRange
+	for _, item := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		typ, subtype, _ := strings.Cut(mediaType, "/")
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+				q = f
+			}
+		}
+		ranges = append(ranges, mediaRange{typ, subtype, q})
+	}
+	var best string
+	bestQ := 0.0
+	for _, offer := range offers {
+		typ, subtype, _ := strings.Cut(offer, "/")
+		// 最具体的范围决定 q 值
+		q, specificity := 0.0, -1
+		for _, mr := range ranges {
+			s := -1
+			switch {
+			case mr.typ == typ && mr.subtype == subtype:
+				s = 2
+			case mr.typ == typ && mr.subtype == "*":
+				s = 1
+			case mr.typ == "*" && mr.subtype == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = mr.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// Problem is an RFC 9457 (was RFC 7807) problem details object, it implements error.
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// InvalidParams lists the fields failing binding or validation.
+	InvalidParams []InvalidParam `json:"invalid-params,omitempty"`
+}
+
+// InvalidParam is an entry of Problem.InvalidParams.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// NewProblem creates and returns a new instance.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+// Error returns the error message.
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Title + ": " + p.Detail
+	}
+	return p.Title
+}
+
+// StatusCode returns the status of the problem.
+func (p *Problem) StatusCode() int {
+	return p.Status
+}
+
+// ProblemOf maps err to a Problem: Problem and StatusCoder errors keep their status, binding errors
+// are 400, 413 or 415, validation errors are 400 with InvalidParams, other errors are 500 without
+// the error message.
+func ProblemOf(err error) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem
+	}
+	var fieldErrs validate.Errors
+	if errors.As(err, &fieldErrs) {
+		problem = NewProblem(http.StatusBadRequest, "validation failed")
+		for _, fe := range fieldErrs {
+			name := fe.Namespace
+			if name == "" {
+				name = fe.Field
+			}
+			problem.InvalidParams = append(problem.InvalidParams, InvalidParam{Name: name, Reason: fe.Error()})
+		}
+		return problem
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return NewProblem(http.StatusRequestEntityTooLarge, err.Error())
+	}
+	if errors.Is(err, ErrUnsupportedMediaType) {
+		return NewProblem(http.StatusUnsupportedMediaType, err.Error())
+	}
+	var bindErr *BindError
+	if errors.As(err, &bindErr) {
+		return NewProblem(http.StatusBadRequest, err.Error())
+	}
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		if status := sc.StatusCode(); status >= 400 && status < 600 {
+			detail := err.Error()
+			if status >= 500 {
+				detail = ""
+			}
+			return NewProblem(status, detail)
+		}
+	}
+	return NewProblem(http.StatusInternalServerError, "")
+}
+
+// WriteProblem writes err as application/problem+json, server errors are logged.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	problem := ProblemOf(err)
+	if problem.Status >= 500 {
+		log.Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+	if problem.Instance == "" || problem.Status == 0 {
+		p := *problem
+		if p.Instance == "" {
+			p.Instance = r.URL.Path
+		}
+		if p.Status == 0 {
+			p.Status = http.StatusInternalServerError
+		}
+		problem = &p
+	}
+	data, merr := jsonx.Marshal(problem)
+	if merr != nil {
+		http.Error(w, problem.Error(), problem.Status)
+		return
+	}
+	header := w.Header()
+	header.Set(HeaderContentType, ContentTypeJsonProblem)
+	header.Set(HeaderContentLength, strconv.Itoa(len(data)))
+	w.WriteHeader(problem.Status)
+	w.Write(data)
+}
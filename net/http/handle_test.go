@@ -0,0 +1,236 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+	"github.com/hopeio/gox/encoding/msgpack"
+	"github.com/hopeio/gox/kvstruct"
+	"github.com/hopeio/gox/structtag/validate"
+)
+
+type updateUserReq struct {
+	ID      int      `path:"id" json:"-"`
+	Page    int      `query:"page,default=1" json:"-"`
+	Tags    []string `query:"tag" json:"-"`
+	Trace   string   `header:"X-Trace" json:"-"`
+	Session string   `cookie:"sid" json:"-"`
+	Name    string   `json:"name" validate:"required"`
+}
+
+type updateUserResp struct {
+	ID      int      `json:"id"`
+	Page    int      `json:"page"`
+	Tags    []string `json:"tags"`
+	Trace   string   `json:"trace"`
+	Session string   `json:"session"`
+	Name    string   `json:"name"`
+}
+
+type teapotError struct{}
+
+func (teapotError) Error() string   { return "short and stout" }
+func (teapotError) StatusCode() int { return http.StatusTeapot }
+
+func TestHandle(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("PUT /users/{id}", Handle(func(ctx context.Context, req *updateUserReq) (*updateUserResp, error) {
+		switch req.Name {
+		case "teapot":
+			return nil, teapotError{}
+		case "boom":
+			return nil, errors.New("database password is hunter2")
+		case "empty":
+			return nil, nil
+		}
+		return &updateUserResp{ID: req.ID, Page: req.Page, Tags: req.Tags, Trace: req.Trace, Session: req.Session, Name: req.Name}, nil
+	}))
+	do := func(body, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPut, "/users/7?tag=a&tag=b", strings.NewReader(body))
+		r.Header.Set(HeaderContentType, ContentTypeJson)
+		r.Header.Set("X-Trace", "t1")
+		r.Header.Set(HeaderAccept, accept)
+		r.AddCookie(&http.Cookie{Name: "sid", Value: "s1"})
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := do(`{"name":"bob"}`, "")
+	var resp updateUserResp
+	if err := jsonx.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, rec.Body)
+	}
+	want := updateUserResp{ID: 7, Page: 1, Tags: []string{"a", "b"}, Trace: "t1", Session: "s1", Name: "bob"}
+	if resp.ID != want.ID || resp.Page != want.Page || strings.Join(resp.Tags, ",") != "a,b" ||
+		resp.Trace != want.Trace || resp.Session != want.Session || resp.Name != want.Name {
+		t.Errorf("got %+v", resp)
+	}
+
+	rec = do(`{"name":"bob"}`, "application/json;q=0.5, application/msgpack")
+	resp = updateUserResp{}
+	if rec.Header().Get(HeaderContentType) != ContentTypeMsgPack || msgpack.Unmarshal(rec.Body.Bytes(), &resp) != nil || resp.Name != "bob" {
+		t.Errorf("msgpack %s %+v", rec.Header().Get(HeaderContentType), resp)
+	}
+
+	problem := func(rec *httptest.ResponseRecorder) Problem {
+		t.Helper()
+		var p Problem
+		if rec.Header().Get(HeaderContentType) != ContentTypeJsonProblem {
+			t.Fatalf("content type %q", rec.Header().Get(HeaderContentType))
+		}
+		if err := jsonx.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	if p := problem(do(`{}`, "")); p.Status != http.StatusBadRequest || len(p.InvalidParams) != 1 || p.InvalidParams[0].Name != "Name" {
+		t.Errorf("validation %+v", p)
+	}
+	if p := problem(do(`{"name":`, "")); p.Status != http.StatusBadRequest || p.Instance != "/users/7" {
+		t.Errorf("bad json %+v", p)
+	}
+	if p := problem(do(`{"name":"teapot"}`, "")); p.Status != http.StatusTeapot || p.Detail != "short and stout" {
+		t.Errorf("status coder %+v", p)
+	}
+	if p := problem(do(`{"name":"boom"}`, "")); p.Status != http.StatusInternalServerError || strings.Contains(p.Detail, "hunter2") {
+		t.Errorf("internal error %+v", p)
+	}
+	if rec = do(`{"name":"empty"}`, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("nil response %d", rec.Code)
+	}
+}
+
+func TestBind_Multipart(t *testing.T) {
+	type upload struct {
+		Title string                  `form:"title"`
+		File  *multipart.FileHeader   `form:"file"`
+		Files []*multipart.FileHeader `form:"files"`
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "doc")
+	fw, _ := mw.CreateFormFile("file", "a.txt")
+	io.WriteString(fw, "aaa")
+	for _, name := range []string{"b.txt", "c.txt"} {
+		fw, _ = mw.CreateFormFile("files", name)
+		io.WriteString(fw, name)
+	}
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/", &body)
+	r.Header.Set(HeaderContentType, mw.FormDataContentType())
+
+	var got upload
+	if err := Bind(r, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "doc" || got.File == nil || got.File.Filename != "a.txt" || len(got.Files) != 2 {
+		t.Errorf("got %+v", got)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("x"))
+	r.Header.Set(HeaderContentType, "text/csv")
+	if err := Bind(r, &got); !errors.Is(err, ErrUnsupportedMediaType) || ProblemOf(err).Status != http.StatusUnsupportedMediaType {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestBind_TaggedOnly(t *testing.T) {
+	type createUserReq struct {
+		Name    string `json:"name"`
+		IsAdmin bool   `json:"is_admin"`
+	}
+	r := httptest.NewRequest(http.MethodPost, "/u?IsAdmin=true&is_admin=true", strings.NewReader(`{"name":"a"}`))
+	r.Header.Set(HeaderContentType, ContentTypeJson)
+	r.Header.Set("IsAdmin", "true")
+	r.AddCookie(&http.Cookie{Name: "IsAdmin", Value: "true"})
+	var got createUserReq
+	if err := Bind(r, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "a" || got.IsAdmin {
+		t.Errorf("untagged field bound from query, header or cookie: %+v", got)
+	}
+}
+
+func TestBind_PathOverBody(t *testing.T) {
+	type updateUserReq struct {
+		ID   int    `path:"id" json:"id"`
+		Page int    `query:"page,default=1" json:"page"`
+		Name string `json:"name"`
+	}
+	var got updateUserReq
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if err := Bind(r, &got); err != nil {
+			t.Error(err)
+		}
+	})
+	r := httptest.NewRequest(http.MethodPut, "/users/1", strings.NewReader(`{"id":999,"page":2,"name":"a"}`))
+	r.Header.Set(HeaderContentType, ContentTypeJson)
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+	if got.ID != 1 || got.Page != 2 || got.Name != "a" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestBind_ValidateOnce(t *testing.T) {
+	type listReq struct {
+		ID   int    `path:"id" json:"-" validate:"required"`
+		Page int    `query:"page" json:"-" validate:"required"`
+		Name string `json:"name" validate:"required"`
+	}
+	// kvstruct 的校验器不能在只绑定了路径参数时执行
+	kvstruct.SetValidator(validate.Default())
+	defer kvstruct.SetValidator(nil)
+	mux := http.NewServeMux()
+	mux.Handle("POST /items/{id}", Handle(func(ctx context.Context, req *listReq) (*listReq, error) {
+		return req, nil
+	}))
+	do := func(target, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		r.Header.Set(HeaderContentType, ContentTypeJson)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, r)
+		return rec
+	}
+	if rec := do("/items/3?page=2", `{"name":"a"}`); rec.Code != http.StatusOK {
+		t.Errorf("%d %s", rec.Code, rec.Body)
+	}
+	if rec := do("/items/3", `{"name":"a"}`); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Page") {
+		t.Errorf("missing page %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{ContentTypeJson, ContentTypeMsgPack}
+	tests := []struct {
+		accept, want string
+	}{
+		{"", ContentTypeJson},
+		{"*/*", ContentTypeJson},
+		{"application/msgpack", ContentTypeMsgPack},
+		{"application/*;q=0.2, application/msgpack;q=0.9", ContentTypeMsgPack},
+		{"application/json;q=0, */*", ContentTypeMsgPack},
+		{"text/html", ""},
+	}
+	for _, test := range tests {
+		if got := NegotiateContentType(test.accept, offers); got != test.want {
+			t.Errorf("NegotiateContentType(%q) = %q, want %q", test.accept, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package http
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+)
+
+// HARVersion is the version of the HTTP Archive format, http://www.softwareishard.com/blog/har-12-spec/.
+const HARVersion = "1.2"
+
+// HAR is an HTTP Archive.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+	Comment string     `json:"comment,omitempty"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	// Time is the total elapsed milliseconds
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARCookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	// Encoding is a non-standard field like in HARContent, "base64" for binary bodies
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	// Encoding is "base64" for binary bodies
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings are milliseconds, -1 means not applicable.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// NewHAR returns an empty archive.
+func NewHAR(creator string) *HAR {
+	return &HAR{Log: HARLog{Version: HARVersion, Creator: HARCreator{Name: creator, Version: HARVersion}, Entries: []HAREntry{}}}
+}
+
+// ReadHARFile reads an archive.
+func ReadHARFile(filename string) (*HAR, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	har := &HAR{}
+	if err = jsonx.Unmarshal(data, har); err != nil {
+		return nil, err
+	}
+	return har, nil
+}
+
+// WriteFile writes the archive atomically.
+func (h *HAR) WriteFile(filename string) error {
+	data, err := jsonx.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// HARHeaders returns the headers sorted by name.
+func HARHeaders(header http.Header) []HARNameValue {
+	nvs := make([]HARNameValue, 0, len(header))
+	for name, values := range header {
+		for _, v := range values {
+			nvs = append(nvs, HARNameValue{Name: name, Value: v})
+		}
+	}
+	slices.SortStableFunc(nvs, func(a, b HARNameValue) int { return cmp.Compare(a.Name, b.Name) })
+	return nvs
+}
+
+// HTTPHeader converts the name value pairs back to a header.
+func HTTPHeader(nvs []HARNameValue) http.Header {
+	header := make(http.Header, len(nvs))
+	for _, nv := range nvs {
+		header.Add(nv.Name, nv.Value)
+	}
+	return header
+}
+
+// harCookies converts cookies.
+func harCookies(cookies []*http.Cookie) []HARCookie {
+	hcs := make([]HARCookie, 0, len(cookies))
+	for _, c := range cookies {
+		hc := HARCookie{Name: c.Name, Value: c.Value, Path: c.Path, Domain: c.Domain, HTTPOnly: c.HttpOnly, Secure: c.Secure}
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			hc.Expires = &expires
+		}
+		hcs = append(hcs, hc)
+	}
+	return hcs
+}
+
+// harText returns the body as text, or base64 with the encoding when it is binary.
+func harText(contentType string, body []byte) (string, string) {
+	if recordableContentType(contentType) && utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// harBytes decodes a text of harText.
+func harBytes(text, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(text)
+	}
+	return []byte(text), nil
+}
+
+// NewHARRequest converts the request with its body.
+func NewHARRequest(r *http.Request, body []byte) HARRequest {
+	header := r.Header.Clone()
+	if r.Host != "" && header.Get(HeaderHost) == "" {
+		header.Set(HeaderHost, r.Host)
+	}
+	u := *r.URL
+	if u.Host == "" {
+		// 服务端收到的请求没有 scheme 和 host
+		u.Host = r.Host
+		u.Scheme = "http"
+		if r.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+	hr := HARRequest{
+		Method:      r.Method,
+		URL:         u.String(),
+		HTTPVersion: r.Proto,
+		Cookies:     harCookies(r.Cookies()),
+		Headers:     HARHeaders(header),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    int64(len(body)),
+	}
+	if hr.HTTPVersion == "" {
+		hr.HTTPVersion = "HTTP/1.1"
+	}
+	for k, vs := range r.URL.Query() {
+		for _, v := range vs {
+			hr.QueryString = append(hr.QueryString, HARNameValue{Name: k, Value: v})
+		}
+	}
+	slices.SortStableFunc(hr.QueryString, func(a, b HARNameValue) int { return cmp.Compare(a.Name, b.Name) })
+	if len(body) > 0 {
+		contentType := r.Header.Get(HeaderContentType)
+		text, encoding := harText(contentType, body)
+		hr.PostData = &HARPostData{MimeType: contentType, Text: text, Encoding: encoding}
+	}
+	return hr
+}
+
+// Body returns the decoded request body.
+func (r *HARRequest) Body() ([]byte, error) {
+	if r.PostData == nil {
+		return nil, nil
+	}
+	return harBytes(r.PostData.Text, r.PostData.Encoding)
+}
+
+// NewHARResponse converts a response with its body.
+func NewHARResponse(proto string, statusCode int, header http.Header, body []byte) HARResponse {
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	contentType := header.Get(HeaderContentType)
+	text, encoding := harText(contentType, body)
+	return HARResponse{
+		Status:      statusCode,
+		StatusText:  http.StatusText(statusCode),
+		HTTPVersion: proto,
+		Cookies:     harCookies((&http.Response{Header: header}).Cookies()),
+		Headers:     HARHeaders(header),
+		Content:     HARContent{Size: int64(len(body)), MimeType: contentType, Text: text, Encoding: encoding},
+		RedirectURL: header.Get(HeaderLocation),
+		HeadersSize: -1,
+		BodySize:    int64(len(body)),
+	}
+}
+
+// Body returns the decoded response body.
+func (r *HARResponse) Body() ([]byte, error) {
+	return harBytes(r.Content.Text, r.Content.Encoding)
+}
+
+// HAREntry returns the recorded exchange as an archive entry, started is when the request arrived.
+// The request body is the one recorded with RecordBody.
+func (rw *Recorder) HAREntry(r *http.Request, started time.Time) HAREntry {
+	reqBody := rw.RequestRecorder.Raw
+	if rw.RequestRecorder.Body != nil {
+		reqBody = rw.RequestRecorder.Body.Bytes()
+	}
+	respBody := rw.ResponseRecorder.Raw
+	if rw.ResponseRecorder.Body != nil {
+		respBody = rw.ResponseRecorder.Body.Bytes()
+	}
+	statusCode := rw.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	elapsed := float64(time.Since(started).Microseconds()) / 1000
+	return HAREntry{
+		StartedDateTime: started,
+		Time:            elapsed,
+		Request:         NewHARRequest(r, bytes.Clone(reqBody)),
+		Response:        NewHARResponse(r.Proto, statusCode, rw.Header(), bytes.Clone(respBody)),
+		Timings:         HARTimings{Blocked: -1, DNS: -1, Connect: -1, Send: 0, Wait: elapsed, Receive: 0, SSL: -1},
+	}
+}
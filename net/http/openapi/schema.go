@@ -124,6 +124,10 @@ func (api *API) createOpenAPI() (spec *openapi3.T, err error) {
 					})
 				op.AddResponse(status, resp)
 			}
+			// 未声明响应模型时补默认响应, responses 不能为空
+			if len(route.Models.Responses) == 0 {
+				op.AddResponse(0, openapi3.NewResponse().WithDescription(""))
+			}
 
 			// Handler tags.
 			op.Tags = append(op.Tags, route.Tags...)
@@ -0,0 +1,324 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package http
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hopeio/gox/container/cache"
+	"github.com/hopeio/gox/log"
+)
+
+// Rate limit headers, draft-ietf-httpapi-ratelimit-headers.
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+)
+
+// DefaultRateLimitStoreSize is the number of keys kept by the default memory store.
+var DefaultRateLimitStoreSize = 100000
+
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket refills Limit tokens every Window evenly, up to Burst tokens.
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindowLog allows at most Limit requests in any Window.
+	SlidingWindowLog
+)
+
+// String returns the string representation.
+func (a RateLimitAlgorithm) String() string {
+	switch a {
+	case TokenBucket:
+		return "token_bucket"
+	case SlidingWindowLog:
+		return "sliding_window_log"
+	}
+	return "RateLimitAlgorithm(" + strconv.Itoa(int(a)) + ")"
+}
+
+// RateLimitRate is the quota of a key.
+type RateLimitRate struct {
+	Algorithm RateLimitAlgorithm
+	Limit     int
+	Window    time.Duration
+	// Burst is the token bucket capacity, 0 means Limit
+	Burst int
+}
+
+// capacity returns the maximum number of requests allowed at once.
+func (r *RateLimitRate) capacity() int {
+	if r.Algorithm == TokenBucket && r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Limit
+}
+
+// interval returns the time a token bucket takes to refill a token.
+func (r *RateLimitRate) interval() time.Duration {
+	return r.Window / time.Duration(r.Limit)
+}
+
+// RateLimitResult is the outcome of RateLimitStore.Take.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the quota is fully restored
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, 0 when Allowed
+	RetryAfter time.Duration
+}
+
+// RateLimitStore takes a request from the quota of key, implementations must be safe for concurrent use.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, rate *RateLimitRate) (*RateLimitResult, error)
+}
+
+// RateLimitKeyFunc returns the key a request is limited by, "" skips the limit.
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RateLimitByIP limits by the client IP. trustedProxies is the number of proxies in front of the server
+// appending to X-Forwarded-For, the client IP is the address appended by the outermost one, i.e. the
+// trustedProxies-th from the right; the addresses left of it are sent by the client and ignored.
+// 0 uses RemoteAddr.
+func RateLimitByIP(trustedProxies int) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		if trustedProxies > 0 {
+			var hops []string
+			for _, line := range r.Header.Values(HeaderXForwardedFor) {
+				for hop := range strings.SplitSeq(line, ",") {
+					hops = append(hops, strings.TrimSpace(hop))
+				}
+			}
+			// 地址少于代理数时均由可信代理追加, 取最左侧的
+			if len(hops) > 0 {
+				return "ip:" + hops[max(len(hops)-trustedProxies, 0)]
+			}
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return "ip:" + host
+	}
+}
+
+// RateLimitByHeader limits by the value of the header, e.g. an API key.
+func RateLimitByHeader(name string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		if v := r.Header.Get(name); v != "" {
+			return "header:" + name + ":" + v
+		}
+		return ""
+	}
+}
+
+// RateLimitByRoute limits by the matched pattern, or method and path when no pattern matched, combined
+// with the key of by when it is not nil.
+func RateLimitByRoute(by RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		route := r.Pattern
+		if route == "" {
+			route = r.Method + " " + r.URL.Path
+		}
+		if by == nil {
+			return "route:" + route
+		}
+		key := by(r)
+		if key == "" {
+			return ""
+		}
+		return "route:" + route + ":" + key
+	}
+}
+
+type RateLimitOptions struct {
+	Rate RateLimitRate
+	// Store nil 时为 NewMemoryRateLimitStore(DefaultRateLimitStoreSize)
+	Store RateLimitStore
+	// Key nil 时为 RateLimitByIP(0)
+	Key RateLimitKeyFunc
+	// Name 作为 key 前缀区分共用 Store 的限流器
+	Name string
+	// FailClosed 为 true 时 Store 出错返回 503, 否则放行
+	FailClosed         bool
+	ExcludedPaths      ExcludedPaths
+	ExcludedPathsRegex ExcludedPathsRegex
+}
+
+// RateLimit returns a middleware limiting requests by options.Key, it sets the RateLimit-* headers
+// and responds 429 with Retry-After when the quota is exhausted.
+//
+//	httpx.RateLimit(&httpx.RateLimitOptions{Rate: httpx.RateLimitRate{Limit: 100, Window: time.Minute}})
+func RateLimit(options *RateLimitOptions) Middleware {
+	rate := options.Rate
+	if rate.Limit <= 0 || rate.Window <= 0 {
+		panic("ratelimit: limit and window must be positive")
+	}
+	store := options.Store
+	if store == nil {
+		store = NewMemoryRateLimitStore(DefaultRateLimitStoreSize)
+	}
+	keyFunc := options.Key
+	if keyFunc == nil {
+		keyFunc = RateLimitByIP(0)
+	}
+	prefix := ""
+	if options.Name != "" {
+		prefix = options.Name + ":"
+	}
+	policy := strconv.Itoa(rate.capacity()) + ";w=" + strconv.FormatInt(int64(math.Ceil(rate.Window.Seconds())), 10)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if options.ExcludedPaths.Contains(r.URL.Path) || options.ExcludedPathsRegex.Contains(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := keyFunc(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			result, err := store.Take(r.Context(), prefix+key, &rate)
+			if err != nil {
+				log.Errorf("ratelimit %s: %v", key, err)
+				if options.FailClosed {
+					WriteProblem(w, r, NewProblem(http.StatusServiceUnavailable, ""))
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			header := w.Header()
+			header.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+			header.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+			header.Set(HeaderRateLimitReset, ceilSeconds(result.Reset))
+			header.Set(HeaderRateLimitPolicy, policy)
+			if !result.Allowed {
+				header.Set(HeaderRetryAfter, ceilSeconds(result.RetryAfter))
+				WriteProblem(w, r, NewProblem(http.StatusTooManyRequests, "rate limit exceeded"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ceilSeconds formats d as whole seconds rounded up.
+func ceilSeconds(d time.Duration) string {
+	if d <= 0 {
+		return "0"
+	}
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
+
+// tokenBucket is the state of a TokenBucket key.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket up to now and takes a token.
+func (b *tokenBucket) take(rate *RateLimitRate, now time.Time) *RateLimitResult {
+	capacity := float64(rate.capacity())
+	perToken := rate.interval()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(capacity, b.tokens+float64(elapsed)/float64(perToken))
+		b.last = now
+	}
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return tokenBucketResult(rate, b.tokens, allowed)
+}
+
+// tokenBucketResult returns the result of a token bucket left with tokens.
+func tokenBucketResult(rate *RateLimitRate, tokens float64, allowed bool) *RateLimitResult {
+	capacity := rate.capacity()
+	perToken := float64(rate.interval())
+	result := &RateLimitResult{Allowed: allowed, Limit: capacity, Remaining: int(tokens)}
+	if !allowed {
+		result.RetryAfter = time.Duration(math.Ceil((1 - tokens) * perToken))
+	}
+	result.Reset = time.Duration(math.Ceil((float64(capacity) - tokens) * perToken))
+	return result
+}
+
+// windowLog is the state of a SlidingWindowLog key, the times are in ascending order.
+type windowLog struct {
+	times []time.Time
+}
+
+// take drops the times out of the window and logs now when the limit is not reached.
+func (l *windowLog) take(rate *RateLimitRate, now time.Time) *RateLimitResult {
+	start := now.Add(-rate.Window)
+	i := 0
+	for i < len(l.times) && !l.times[i].After(start) {
+		i++
+	}
+	l.times = l.times[i:]
+	result := &RateLimitResult{Limit: rate.Limit}
+	if len(l.times) < rate.Limit {
+		l.times = append(l.times, now)
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.times[len(l.times)-rate.Limit].Add(rate.Window).Sub(now)
+	}
+	result.Remaining = rate.Limit - len(l.times)
+	result.Reset = l.times[len(l.times)-1].Add(rate.Window).Sub(now)
+	return result
+}
+
+// MemoryRateLimitStore keeps the state in a container/cache LRU cache, keys expire once their quota is restored.
+type MemoryRateLimitStore struct {
+	mu    sync.Mutex
+	cache *cache.Cache
+	now   func() time.Time
+}
+
+// NewMemoryRateLimitStore creates and returns a new instance keeping at most size keys.
+func NewMemoryRateLimitStore(size int) *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{cache: cache.New(size).LRU(), now: time.Now}
+}
+
+// Take implements RateLimitStore.
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, rate *RateLimitRate) (*RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	v, _ := s.cache.Get(key)
+	var state any
+	var result *RateLimitResult
+	switch rate.Algorithm {
+	case SlidingWindowLog:
+		entries, ok := v.(*windowLog)
+		if !ok {
+			entries = &windowLog{}
+		}
+		result, state = entries.take(rate, now), entries
+	default:
+		bucket, ok := v.(*tokenBucket)
+		if !ok {
+			bucket = &tokenBucket{tokens: float64(rate.capacity()), last: now}
+		}
+		result, state = bucket.take(rate, now), bucket
+	}
+	// 配额恢复后状态与新建的相同, 可以过期
+	return result, s.cache.Set(key, state, max(result.Reset, time.Millisecond))
+}
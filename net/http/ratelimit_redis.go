@@ -0,0 +1,347 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tokenBucketScript refills and takes a token atomically, times are in microseconds.
+const tokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1]) or capacity
+local last = tonumber(state[2]) or now
+if now > last then
+	tokens = math.min(capacity, tokens + (now - last) / interval)
+	last = now
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'last', last)
+redis.call('PEXPIRE', KEYS[1], math.max(1, math.ceil((capacity - tokens) * interval / 1000)))
+return {allowed, string.format('%.6f', tokens)}
+`
+
+// slidingWindowLogScript drops the entries out of the window and logs the request atomically, times are
+// in microseconds.
+const slidingWindowLogScript = `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+local blocking = false
+if allowed == 0 then
+	blocking = redis.call('ZRANGE', KEYS[1], count - limit, count - limit, 'WITHSCORES')[2] or false
+end
+redis.call('PEXPIRE', KEYS[1], math.max(1, math.ceil(window / 1000)))
+return {allowed, count, newest[2] or false, blocking}
+`
+
+var (
+	tokenBucketScriptSHA      = scriptSHA(tokenBucketScript)
+	slidingWindowLogScriptSHA = scriptSHA(slidingWindowLogScript)
+
+	// aLongTimeAgo is a deadline in the past interrupting blocked I/O.
+	aLongTimeAgo = time.Unix(1, 0)
+)
+
+// scriptSHA returns the SHA1 digest EVALSHA refers to the script by.
+func scriptSHA(script string) string {
+	sum := sha1.Sum([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
+
+// RedisError is an error reply of the Redis server.
+type RedisError string
+
+// Error returns the error message.
+func (e RedisError) Error() string {
+	return string(e)
+}
+
+// RedisRateLimitStore keeps the state in Redis, or a server speaking the Redis protocol, with Lua scripts so
+// that the instances sharing the server share the quota. The clocks of the instances should be synchronized.
+type RedisRateLimitStore struct {
+	Addr     string
+	Username string
+	Password string
+	DB       int
+	// Prefix of the Redis keys
+	Prefix string
+	// Dial nil 时使用 net.Dialer
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	// Timeout of each command, 0 means only ctx is honored
+	Timeout time.Duration
+	idle    chan *redisConn
+	now     func() time.Time
+}
+
+// NewRedisRateLimitStore creates and returns a new instance keeping at most maxIdle idle connections.
+func NewRedisRateLimitStore(addr string, maxIdle int) *RedisRateLimitStore {
+	return &RedisRateLimitStore{
+		Addr:    addr,
+		Prefix:  "ratelimit:",
+		Timeout: time.Second,
+		idle:    make(chan *redisConn, max(maxIdle, 0)),
+		now:     time.Now,
+	}
+}
+
+// Take implements RateLimitStore.
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, rate *RateLimitRate) (*RateLimitResult, error) {
+	now := s.now()
+	nowMicro := now.UnixMicro()
+	key = s.Prefix + rate.Algorithm.String() + ":" + key
+	switch rate.Algorithm {
+	case TokenBucket:
+		reply, err := s.eval(ctx, tokenBucketScript, tokenBucketScriptSHA, key,
+			strconv.Itoa(rate.capacity()), strconv.FormatFloat(float64(rate.interval())/float64(time.Microsecond), 'f', -1, 64),
+			strconv.FormatInt(nowMicro, 10))
+		if err != nil {
+			return nil, err
+		}
+		if len(reply) != 2 {
+			return nil, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+		}
+		tokens, err := strconv.ParseFloat(replyString(reply[1]), 64)
+		if err != nil {
+			return nil, err
+		}
+		return tokenBucketResult(rate, tokens, reply[0] == int64(1)), nil
+	case SlidingWindowLog:
+		window := rate.Window.Microseconds()
+		reply, err := s.eval(ctx, slidingWindowLogScript, slidingWindowLogScriptSHA, key,
+			strconv.Itoa(rate.Limit), strconv.FormatInt(window, 10), strconv.FormatInt(nowMicro, 10),
+			strconv.FormatInt(nowMicro, 10)+"-"+strconv.FormatUint(rand.Uint64(), 36))
+		if err != nil {
+			return nil, err
+		}
+		if len(reply) != 4 {
+			return nil, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+		}
+		count, _ := reply[1].(int64)
+		result := &RateLimitResult{Allowed: reply[0] == int64(1), Limit: rate.Limit, Remaining: max(rate.Limit-int(count), 0)}
+		// 按分数计算到期时间, 分数为微秒
+		expireIn := func(v any) time.Duration {
+			score, err := strconv.ParseFloat(replyString(v), 64)
+			if err != nil {
+				return 0
+			}
+			return time.Duration(int64(score)+window-nowMicro) * time.Microsecond
+		}
+		result.Reset = expireIn(reply[2])
+		if !result.Allowed {
+			result.RetryAfter = expireIn(reply[3])
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("ratelimit: unsupported algorithm %v", rate.Algorithm)
+}
+
+// eval runs the script by its SHA1 digest, loading it by EVAL when the server does not have it.
+func (s *RedisRateLimitStore) eval(ctx context.Context, script, sha, key string, args ...string) ([]any, error) {
+	cmd := append([]string{"EVALSHA", sha, "1", key}, args...)
+	reply, err := s.Do(ctx, cmd...)
+	var redisErr RedisError
+	if errors.As(err, &redisErr) && strings.HasPrefix(string(redisErr), "NOSCRIPT") {
+		cmd[0], cmd[1] = "EVAL", script
+		reply, err = s.Do(ctx, cmd...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]any)
+	if !ok {
+		return nil, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+	}
+	return values, nil
+}
+
+// Do sends a command and returns the reply: string, int64, []any, nil or a RedisError.
+func (s *RedisRateLimitStore) Do(ctx context.Context, args ...string) (any, error) {
+	conn, err := s.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(ctx, s.Timeout, args...)
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case s.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+// Close closes the idle connections.
+func (s *RedisRateLimitStore) Close() error {
+	for {
+		select {
+		case conn := <-s.idle:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// conn returns an idle connection or dials a new one.
+func (s *RedisRateLimitStore) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-s.idle:
+		return conn, nil
+	default:
+	}
+	dial := s.Dial
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: s.Timeout}).DialContext
+	}
+	raw, err := dial(ctx, "tcp", s.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: raw, r: bufio.NewReader(raw), w: bufio.NewWriter(raw)}
+	if s.Password != "" {
+		auth := []string{"AUTH", s.Password}
+		if s.Username != "" {
+			auth = []string{"AUTH", s.Username, s.Password}
+		}
+		if _, err = conn.do(ctx, s.Timeout, auth...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if s.DB != 0 {
+		if _, err = conn.do(ctx, s.Timeout, "SELECT", strconv.Itoa(s.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// redisConn is a RESP2 connection.
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// do writes the command and reads the reply, honoring the deadline of ctx and timeout.
+func (c *redisConn) do(ctx context.Context, timeout time.Duration, args ...string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if timeout > 0 {
+		if d := time.Now().Add(timeout); !ok || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	c.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		c.SetDeadline(aLongTimeAgo)
+	})
+	defer stop()
+
+	c.w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		c.w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		c.w.WriteString(arg)
+		c.w.WriteString("\r\n")
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	reply, err := readRedisReply(c.r)
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return nil, ctxErr
+	}
+	return reply, err
+}
+
+// readRedisReply reads a RESP2 reply, error replies are returned as RedisError.
+func readRedisReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: invalid reply %q", line)
+	}
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		values := make([]any, n)
+		for i := range values {
+			values[i], err = readRedisReply(r)
+			var redisErr RedisError
+			if err != nil && !errors.As(err, &redisErr) {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("redis: invalid reply %q", line)
+}
+
+// replyString returns the string of a bulk or integer reply.
+func replyString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	}
+	return ""
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package http
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryRateLimitStore(100)
+	store.now = func() time.Time { return now }
+	take := func(key string, rate *RateLimitRate) *RateLimitResult {
+		t.Helper()
+		result, err := store.Take(t.Context(), key, rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	bucket := &RateLimitRate{Algorithm: TokenBucket, Limit: 2, Window: time.Second}
+	for i := range 2 {
+		if r := take("tb", bucket); !r.Allowed || r.Remaining != 1-i {
+			t.Fatalf("take %d %+v", i, r)
+		}
+	}
+	if r := take("tb", bucket); r.Allowed || r.RetryAfter != 500*time.Millisecond || r.Reset != time.Second {
+		t.Errorf("exhausted %+v", r)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if r := take("tb", bucket); !r.Allowed || r.Remaining != 0 {
+		t.Errorf("refilled %+v", r)
+	}
+
+	start := now
+	window := &RateLimitRate{Algorithm: SlidingWindowLog, Limit: 2, Window: time.Second}
+	take("swl", window)
+	now = start.Add(400 * time.Millisecond)
+	if r := take("swl", window); !r.Allowed || r.Remaining != 0 || r.Reset != time.Second {
+		t.Errorf("second %+v", r)
+	}
+	now = start.Add(500 * time.Millisecond)
+	if r := take("swl", window); r.Allowed || r.RetryAfter != 500*time.Millisecond {
+		t.Errorf("exhausted %+v", r)
+	}
+	now = start.Add(time.Second)
+	if r := take("swl", window); !r.Allowed || r.Remaining != 0 {
+		t.Errorf("slid %+v", r)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	handler := RateLimit(&RateLimitOptions{
+		Rate:          RateLimitRate{Algorithm: SlidingWindowLog, Limit: 1, Window: time.Minute},
+		Key:           RateLimitByRoute(RateLimitByHeader("X-Api-Key")),
+		ExcludedPaths: ExcludedPaths{"/health"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(path, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if key != "" {
+			r.Header.Set("X-Api-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := do("/a", "k1")
+	header := rec.Header()
+	if rec.Code != http.StatusOK || header.Get(HeaderRateLimitLimit) != "1" || header.Get(HeaderRateLimitRemaining) != "0" ||
+		header.Get(HeaderRateLimitReset) != "60" || header.Get(HeaderRateLimitPolicy) != "1;w=60" {
+		t.Errorf("first %d %v", rec.Code, header)
+	}
+	rec = do("/a", "k1")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get(HeaderRetryAfter) != "60" ||
+		rec.Header().Get(HeaderContentType) != ContentTypeJsonProblem {
+		t.Errorf("limited %d %v", rec.Code, rec.Header())
+	}
+	if rec = do("/a", "k2"); rec.Code != http.StatusOK {
+		t.Errorf("other key %d", rec.Code)
+	}
+	if rec = do("/b", "k1"); rec.Code != http.StatusOK {
+		t.Errorf("other route %d", rec.Code)
+	}
+	for range 2 {
+		if rec = do("/health", "k1"); rec.Code != http.StatusOK || rec.Header().Get(HeaderRateLimitLimit) != "" {
+			t.Errorf("excluded %d %v", rec.Code, rec.Header())
+		}
+		if rec = do("/a", ""); rec.Code != http.StatusOK {
+			t.Errorf("no key %d", rec.Code)
+		}
+	}
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	request := func(forwarded ...string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		for _, v := range forwarded {
+			r.Header.Add(HeaderXForwardedFor, v)
+		}
+		return r
+	}
+	tests := []struct {
+		proxies   int
+		forwarded []string
+		want      string
+	}{
+		{0, []string{"1.1.1.1"}, "ip:10.0.0.1"},
+		{1, nil, "ip:10.0.0.1"},
+		{1, []string{"1.1.1.1, 203.0.113.7"}, "ip:203.0.113.7"},
+		{2, []string{"1.1.1.1, 203.0.113.7", "10.0.0.2"}, "ip:203.0.113.7"},
+		{3, []string{"203.0.113.7"}, "ip:203.0.113.7"},
+	}
+	for _, tt := range tests {
+		if got := RateLimitByIP(tt.proxies)(request(tt.forwarded...)); got != tt.want {
+			t.Errorf("%d %v: got %s, want %s", tt.proxies, tt.forwarded, got, tt.want)
+		}
+	}
+
+	// 客户端伪造的地址在左侧, 每次更换也绕不过限流
+	handler := RateLimit(&RateLimitOptions{
+		Rate: RateLimitRate{Algorithm: SlidingWindowLog, Limit: 1, Window: time.Minute},
+		Key:  RateLimitByIP(1),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i, spoofed := range []string{"1.1.1.1", "2.2.2.2"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, request(spoofed+", 203.0.113.7"))
+		if want := []int{http.StatusOK, http.StatusTooManyRequests}[i]; rec.Code != want {
+			t.Errorf("spoofed %s: got %d, want %d", spoofed, rec.Code, want)
+		}
+	}
+}
+
+func TestRedisRateLimitStore_Protocol(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	commands := make(chan []string, 8)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			reply, err := readRedisReply(r)
+			if err != nil {
+				return
+			}
+			var cmd []string
+			for _, v := range reply.([]any) {
+				cmd = append(cmd, v.(string))
+			}
+			commands <- cmd
+			switch cmd[0] {
+			case "AUTH":
+				conn.Write([]byte("+OK\r\n"))
+			case "EVALSHA":
+				conn.Write([]byte("-NOSCRIPT No matching script. Please use EVAL.\r\n"))
+			case "EVAL":
+				conn.Write([]byte("*2\r\n:1\r\n$8\r\n3.500000\r\n"))
+			}
+		}
+	}()
+
+	store := NewRedisRateLimitStore(ln.Addr().String(), 1)
+	defer store.Close()
+	store.Password = "secret"
+	result, err := store.Take(t.Context(), "k", &RateLimitRate{Limit: 5, Window: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed || result.Limit != 5 || result.Remaining != 3 || result.Reset != 1500*time.Millisecond {
+		t.Errorf("got %+v", result)
+	}
+	want := []string{"AUTH secret", "EVALSHA " + tokenBucketScriptSHA + " 1 ratelimit:token_bucket:k", "EVAL " + tokenBucketScript}
+	for _, w := range want {
+		if cmd := strings.Join(<-commands, " "); !strings.HasPrefix(cmd, w) {
+			t.Errorf("got command %.80q, want %.80q", cmd, w)
+		}
+	}
+}
+
+func TestRedisRateLimitStore(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR not set")
+	}
+	store := NewRedisRateLimitStore(addr, 2)
+	defer store.Close()
+	store.Prefix = "ratelimit-test:" + time.Now().Format(time.RFC3339Nano) + ":"
+	for _, algorithm := range []RateLimitAlgorithm{TokenBucket, SlidingWindowLog} {
+		rate := &RateLimitRate{Algorithm: algorithm, Limit: 2, Window: time.Minute}
+		for i := range 3 {
+			result, err := store.Take(t.Context(), "k", rate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Allowed != (i < 2) || (i == 2 && result.RetryAfter <= 0) {
+				t.Errorf("%v take %d %+v", algorithm, i, result)
+			}
+		}
+	}
+}
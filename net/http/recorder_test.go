@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestResponseRecorder_RecordsTextLikeBody(t *testing.T) {
@@ -90,3 +91,29 @@ func TestResponseRecorder_FlushAndUnwrap(t *testing.T) {
 		t.Fatal("Hijack on non-hijackable writer should return an error")
 	}
 }
+
+func TestRecorder_HAREntry(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api?id=1", strings.NewReader(`{"a":1}`))
+	r.Header.Set(HeaderContentType, "application/json")
+	rec := NewRecorder(w, r)
+	rec.RequestRecorder.RecordBody([]byte(`{"a":1}`), nil)
+	rec.ResponseRecorder.Header().Set(HeaderContentType, "text/plain")
+	rec.ResponseRecorder.WriteHeader(201)
+	rec.ResponseRecorder.Write([]byte("created"))
+
+	entry := rec.HAREntry(r, time.Now())
+	if entry.Request.URL != "http://example.com/api?id=1" || entry.Request.PostData == nil || entry.Request.PostData.Text != `{"a":1}` {
+		t.Fatalf("request %+v", entry.Request)
+	}
+	if len(entry.Request.QueryString) != 1 || entry.Request.QueryString[0].Value != "1" {
+		t.Fatalf("query %+v", entry.Request.QueryString)
+	}
+	if entry.Response.Status != 201 || entry.Response.Content.Text != "created" || entry.Response.Content.Encoding != "" {
+		t.Fatalf("response %+v", entry.Response)
+	}
+	body, err := entry.Response.Body()
+	if err != nil || string(body) != "created" {
+		t.Fatalf("body %q, %v", body, err)
+	}
+}
@@ -0,0 +1,333 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+// Package router is an http.Handler routing by method and path on radixtree.Node.
+//
+// Patterns are httprouter style, e.g. /users/:id and /static/*filepath. Path parameters are set on
+// the request and read with r.PathValue("id"); the value of a catch-all parameter starts with "/".
+// Routes must be registered before serving, registration is not concurrency-safe.
+package router
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hopeio/gox/container/tree/radixtree"
+	httpx "github.com/hopeio/gox/net/http"
+	"github.com/hopeio/gox/net/http/openapi"
+)
+
+type route struct {
+	pattern string
+	handler http.Handler
+}
+
+type Router struct {
+	RouteGroup
+	trees map[string]*radixtree.Node[*route]
+
+	// RedirectTrailingSlash 重定向到多或少一个尾部斜杠的已注册路径, 默认开启
+	RedirectTrailingSlash bool
+	// RedirectFixedPath 清理路径(., .., 多余斜杠)并忽略大小写查找, 命中时重定向, 默认开启
+	RedirectFixedPath bool
+	// HandleMethodNotAllowed 路径存在但方法不匹配时返回 405 并带 Allow 头, 默认开启
+	HandleMethodNotAllowed bool
+	// HandleOPTIONS 自动回复 OPTIONS 请求, 默认开启
+	HandleOPTIONS bool
+
+	// NotFound default http.NotFound.
+	NotFound http.Handler
+	// MethodNotAllowed is called after the Allow header is set, default http.Error with 405.
+	MethodNotAllowed http.Handler
+	// GlobalOPTIONS is called for automatic OPTIONS responses after the Allow header is set.
+	GlobalOPTIONS http.Handler
+
+	// API 不为空时注册的路由同时登记到 openapi, 路径参数转为 {name}
+	API *openapi.API
+}
+
+// New creates and returns a new instance.
+func New() *Router {
+	r := &Router{
+		trees:                  make(map[string]*radixtree.Node[*route]),
+		RedirectTrailingSlash:  true,
+		RedirectFixedPath:      true,
+		HandleMethodNotAllowed: true,
+		HandleOPTIONS:          true,
+	}
+	r.RouteGroup.router = r
+	return r
+}
+
+// RouteGroup is a set of routes sharing a path prefix and middlewares.
+type RouteGroup struct {
+	router      *Router
+	prefix      string
+	middlewares []httpx.Middleware
+}
+
+// Group returns a subgroup, the middlewares of g run before its own.
+func (g *RouteGroup) Group(prefix string, middlewares ...httpx.Middleware) *RouteGroup {
+	return &RouteGroup{
+		router:      g.router,
+		prefix:      joinPath(g.prefix, prefix),
+		middlewares: append(slices.Clip(g.middlewares), middlewares...),
+	}
+}
+
+// Use adds middlewares to the routes registered afterwards, middlewares run in the order they are added.
+func (g *RouteGroup) Use(middlewares ...httpx.Middleware) {
+	g.middlewares = append(g.middlewares, middlewares...)
+}
+
+// Handle registers handler for method and the path relative to the group prefix.
+// It panics when the path conflicts with a registered route.
+func (g *RouteGroup) Handle(method, path string, handler http.Handler) {
+	if method == "" {
+		panic("router: method must not be empty")
+	}
+	if handler == nil {
+		panic("router: nil handler")
+	}
+	pattern := joinPath(g.prefix, path)
+	if pattern == "" || pattern[0] != '/' {
+		panic("router: path must begin with '/' in path '" + pattern + "'")
+	}
+	for i := len(g.middlewares) - 1; i >= 0; i-- {
+		handler = g.middlewares[i](handler)
+	}
+	r := g.router
+	tree := r.trees[method]
+	if tree == nil {
+		tree = new(radixtree.Node[*route])
+		r.trees[method] = tree
+	}
+	tree.Set(pattern, &route{pattern: pattern, handler: handler})
+	if r.API != nil {
+		apiRoute := r.API.Route(method, OpenAPIPattern(pattern))
+		for _, name := range paramNames(pattern) {
+			if _, ok := apiRoute.Params.Path[name]; !ok {
+				apiRoute.HasPathParameter(name, openapi.Parameter{Type: openapi3.TypeString, Required: true})
+			}
+		}
+	}
+}
+
+// HandleFunc registers the handler function for method and path.
+func (g *RouteGroup) HandleFunc(method, path string, handler http.HandlerFunc) {
+	g.Handle(method, path, handler)
+}
+
+// Get registers a GET route.
+func (g *RouteGroup) Get(path string, handler http.HandlerFunc) {
+	g.Handle(http.MethodGet, path, handler)
+}
+
+// Head registers a HEAD route.
+func (g *RouteGroup) Head(path string, handler http.HandlerFunc) {
+	g.Handle(http.MethodHead, path, handler)
+}
+
+// Post registers a POST route.
+func (g *RouteGroup) Post(path string, handler http.HandlerFunc) {
+	g.Handle(http.MethodPost, path, handler)
+}
+
+// Put registers a PUT route.
+func (g *RouteGroup) Put(path string, handler http.HandlerFunc) {
+	g.Handle(http.MethodPut, path, handler)
+}
+
+// Patch registers a PATCH route.
+func (g *RouteGroup) Patch(path string, handler http.HandlerFunc) {
+	g.Handle(http.MethodPatch, path, handler)
+}
+
+// Delete registers a DELETE route.
+func (g *RouteGroup) Delete(path string, handler http.HandlerFunc) {
+	g.Handle(http.MethodDelete, path, handler)
+}
+
+// Options registers an OPTIONS route, it takes precedence over the automatic OPTIONS response.
+func (g *RouteGroup) Options(path string, handler http.HandlerFunc) {
+	g.Handle(http.MethodOptions, path, handler)
+}
+
+// ServeFiles serves files from root under path, which must end with /*filepath.
+func (g *RouteGroup) ServeFiles(path string, root http.FileSystem) {
+	if !strings.HasSuffix(path, "/*filepath") {
+		panic("router: path must end with /*filepath in path '" + path + "'")
+	}
+	fileServer := http.FileServer(root)
+	g.Get(path, func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = r.PathValue("filepath")
+		fileServer.ServeHTTP(w, r)
+	})
+}
+
+// Lookup returns the handler and path parameters of method and path.
+func (r *Router) Lookup(method, path string) (http.Handler, radixtree.Params, bool) {
+	if tree := r.trees[method]; tree != nil {
+		if route, params, _ := tree.Get(path); route != nil {
+			return route.handler, params, true
+		}
+	}
+	return nil, nil, false
+}
+
+// ServeHTTP executes the operation.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := req.URL.Path
+	if tree := r.trees[req.Method]; tree != nil {
+		if route, params, _ := tree.Get(path); route != nil {
+			for _, param := range params {
+				req.SetPathValue(param.Key, param.Value)
+			}
+			req.Pattern = route.pattern
+			route.handler.ServeHTTP(w, req)
+			return
+		}
+		if req.Method != http.MethodConnect && path != "/" && r.redirect(w, req, tree) {
+			return
+		}
+	}
+
+	if req.Method == http.MethodOptions && r.HandleOPTIONS {
+		if allow := r.allowed(path, http.MethodOptions); allow != "" {
+			w.Header().Set(httpx.HeaderAllow, allow)
+			if r.GlobalOPTIONS != nil {
+				r.GlobalOPTIONS.ServeHTTP(w, req)
+			} else {
+				w.WriteHeader(http.StatusNoContent)
+			}
+			return
+		}
+	} else if r.HandleMethodNotAllowed {
+		if allow := r.allowed(path, req.Method); allow != "" {
+			w.Header().Set(httpx.HeaderAllow, allow)
+			if r.MethodNotAllowed != nil {
+				r.MethodNotAllowed.ServeHTTP(w, req)
+			} else {
+				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			}
+			return
+		}
+	}
+
+	if r.NotFound != nil {
+		r.NotFound.ServeHTTP(w, req)
+	} else {
+		http.NotFound(w, req)
+	}
+}
+
+// redirect redirects to the registered path with the trailing slash fixed, or the cleaned and case-corrected path.
+func (r *Router) redirect(w http.ResponseWriter, req *http.Request, tree *radixtree.Node[*route]) bool {
+	// GET 用 301, 其余方法用 308 保留方法和 body
+	code := http.StatusPermanentRedirect
+	if req.Method == http.MethodGet {
+		code = http.StatusMovedPermanently
+	}
+	path := req.URL.Path
+	if r.RedirectTrailingSlash {
+		fixed := path + "/"
+		if strings.HasSuffix(path, "/") {
+			fixed = path[:len(path)-1]
+		}
+		if route, _, _ := tree.Get(fixed); route != nil {
+			redirectTo(w, req, fixed, code)
+			return true
+		}
+	}
+	if r.RedirectFixedPath {
+		if fixed, ok := tree.FindCaseInsensitivePath(cleanPath(path), r.RedirectTrailingSlash); ok {
+			redirectTo(w, req, fixed, code)
+			return true
+		}
+	}
+	return false
+}
+
+// redirectTo redirects to path keeping the query.
+func redirectTo(w http.ResponseWriter, req *http.Request, path string, code int) {
+	u := *req.URL
+	u.Path, u.RawPath = path, ""
+	http.Redirect(w, req, u.String(), code)
+}
+
+// allowed returns the Allow header value of path, empty when no method other than except matches.
+func (r *Router) allowed(path, except string) string {
+	var methods []string
+	for method, tree := range r.trees {
+		if method == except || method == http.MethodOptions {
+			continue
+		}
+		// "*" 表示服务器整体
+		if path == "*" {
+			methods = append(methods, method)
+			continue
+		}
+		if route, _, _ := tree.Get(path); route != nil {
+			methods = append(methods, method)
+		}
+	}
+	if len(methods) == 0 {
+		return ""
+	}
+	if r.HandleOPTIONS || r.trees[http.MethodOptions] != nil {
+		methods = append(methods, http.MethodOptions)
+	}
+	slices.Sort(methods)
+	return strings.Join(methods, ", ")
+}
+
+// joinPath joins the group prefix and a relative path, keeping the trailing slash of path.
+func joinPath(prefix, relative string) string {
+	if prefix == "" {
+		return relative
+	}
+	if relative == "" {
+		return prefix
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(relative, "/")
+}
+
+// cleanPath is path.Clean keeping the trailing slash.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// paramNames returns the names of the path parameters of pattern.
+func paramNames(pattern string) []string {
+	var names []string
+	for _, segment := range strings.Split(pattern, "/") {
+		if len(segment) > 1 && (segment[0] == ':' || segment[0] == '*') {
+			names = append(names, segment[1:])
+		}
+	}
+	return names
+}
+
+// OpenAPIPattern converts /users/:id/*path into the OpenAPI path /users/{id}/{path}.
+func OpenAPIPattern(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if len(segment) > 1 && (segment[0] == ':' || segment[0] == '*') {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	httpx "github.com/hopeio/gox/net/http"
+	"github.com/hopeio/gox/net/http/openapi"
+)
+
+func serve(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestRouter(t *testing.T) {
+	r := New()
+	r.API = openapi.NewAPI("test")
+	var trace []string
+	mark := func(name string) httpx.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				trace = append(trace, name)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+	r.Get("/", func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "root")
+	})
+	api := r.Group("/api", mark("api"))
+	users := api.Group("/users", mark("users"))
+	users.Get("/:id", func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "user "+req.PathValue("id")+" "+req.Pattern)
+	})
+	users.Delete("/:id", func(w http.ResponseWriter, req *http.Request) {})
+	api.Get("/files/*path", func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, req.PathValue("path"))
+	})
+	api.Get("/list/", func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "list")
+	})
+
+	if rec := serve(r, http.MethodGet, "/api/users/42"); rec.Body.String() != "user 42 /api/users/:id" {
+		t.Errorf("got %q", rec.Body.String())
+	}
+	if strings.Join(trace, ",") != "api,users" {
+		t.Errorf("middleware order %v", trace)
+	}
+	if rec := serve(r, http.MethodGet, "/api/files/a/b.txt"); rec.Body.String() != "/a/b.txt" {
+		t.Errorf("catch-all %q", rec.Body.String())
+	}
+	if rec := serve(r, http.MethodGet, "/"); rec.Body.String() != "root" {
+		t.Errorf("root %q", rec.Body.String())
+	}
+
+	// 尾部斜杠与大小写修正
+	if rec := serve(r, http.MethodGet, "/api/list?x=1"); rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/api/list/?x=1" {
+		t.Errorf("trailing slash %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := serve(r, http.MethodDelete, "/API//users/7"); rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != "/api/users/7" {
+		t.Errorf("fixed path %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec := serve(r, http.MethodPost, "/api/users/42")
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get(httpx.HeaderAllow) != "DELETE, GET, OPTIONS" {
+		t.Errorf("405 %d %q", rec.Code, rec.Header().Get(httpx.HeaderAllow))
+	}
+	rec = serve(r, http.MethodOptions, "/api/users/42")
+	if rec.Code != http.StatusNoContent || rec.Header().Get(httpx.HeaderAllow) != "DELETE, GET, OPTIONS" {
+		t.Errorf("OPTIONS %d %q", rec.Code, rec.Header().Get(httpx.HeaderAllow))
+	}
+	if rec = serve(r, http.MethodGet, "/missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("404 %d", rec.Code)
+	}
+
+	route := r.API.Routes["/api/users/{id}"]["GET"]
+	if route == nil || !route.Params.Path["id"].Required {
+		t.Fatalf("openapi routes %v", r.API.Routes)
+	}
+	if _, err := r.API.Spec(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRouter_Conflict(t *testing.T) {
+	r := New()
+	r.Get("/users/:id", func(http.ResponseWriter, *http.Request) {})
+	defer func() {
+		if recover() == nil {
+			t.Error("conflicting wildcard should panic")
+		}
+	}()
+	r.Get("/users/:name", func(http.ResponseWriter, *http.Request) {})
+}
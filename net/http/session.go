@@ -0,0 +1,147 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+)
+
+var (
+	ErrInvalidSession = errors.New("invalid session")
+	ErrSessionExpired = errors.New("session expired")
+)
+
+// SessionCookie stores a session in a cookie signed with HMAC-SHA256, the session is readable by the client
+// and must not contain secrets. The value is base64url(JSON).expiry.signature.
+type SessionCookie struct {
+	Name string
+	// Keys 第一个用于签名, 全部用于验证, 轮换时把新密钥放在最前
+	Keys     [][]byte
+	MaxAge   time.Duration
+	Path     string
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// NewSessionCookie creates and returns a new instance.
+func NewSessionCookie(name string, keys ...[]byte) *SessionCookie {
+	return &SessionCookie{Name: name, Keys: keys, MaxAge: 24 * time.Hour, Path: "/", Secure: true, SameSite: http.SameSiteLaxMode}
+}
+
+// Encode returns the signed cookie value of v expiring at expires.
+func (s *SessionCookie) Encode(v any, expires time.Time) (string, error) {
+	if len(s.Keys) == 0 {
+		return "", errors.New("session: no keys")
+	}
+	data, err := jsonx.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	value := base64.RawURLEncoding.EncodeToString(data) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return value + "." + base64.RawURLEncoding.EncodeToString(s.sign(s.Keys[0], value)), nil
+}
+
+// Decode verifies value and decodes the session into v.
+func (s *SessionCookie) Decode(value string, v any) error {
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		return ErrInvalidSession
+	}
+	signed, sig := value[:i], value[i+1:]
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return ErrInvalidSession
+	}
+	valid := false
+	for _, key := range s.Keys {
+		if hmac.Equal(mac, s.sign(key, signed)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return ErrInvalidSession
+	}
+	payload, expires, ok := strings.Cut(signed, ".")
+	if !ok {
+		return ErrInvalidSession
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSession
+	}
+	if !time.Now().Before(time.Unix(unix, 0)) {
+		return ErrSessionExpired
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return ErrInvalidSession
+	}
+	return jsonx.Unmarshal(data, v)
+}
+
+// sign returns the MAC of value, the cookie name is signed as well so a value can not be moved to another cookie.
+func (s *SessionCookie) sign(key []byte, value string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(s.Name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// Save sets the cookie of the session v.
+func (s *SessionCookie) Save(w http.ResponseWriter, v any) error {
+	expires := time.Now().Add(s.MaxAge)
+	value, err := s.Encode(v, expires)
+	if err != nil {
+		return err
+	}
+	cookie := s.cookie(value)
+	cookie.Expires = expires
+	cookie.MaxAge = int(s.MaxAge / time.Second)
+	http.SetCookie(w, cookie)
+	return nil
+}
+
+// Load decodes the session of the request cookie into v, http.ErrNoCookie is returned when there is none.
+func (s *SessionCookie) Load(r *http.Request, v any) error {
+	cookie, err := r.Cookie(s.Name)
+	if err != nil {
+		return err
+	}
+	return s.Decode(cookie.Value, v)
+}
+
+// Clear deletes the cookie.
+func (s *SessionCookie) Clear(w http.ResponseWriter) {
+	cookie := s.cookie("")
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+}
+
+// cookie returns the cookie with the attributes of s.
+func (s *SessionCookie) cookie(value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     s.Name,
+		Value:    value,
+		Path:     s.Path,
+		Domain:   s.Domain,
+		Secure:   s.Secure,
+		HttpOnly: true,
+		SameSite: s.SameSite,
+	}
+}
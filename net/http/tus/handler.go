@@ -0,0 +1,257 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package tus
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hopeio/gox/log"
+	httpx "github.com/hopeio/gox/net/http"
+)
+
+// Handler serves tus uploads, POST to BasePath creates an upload at BasePath/{id}.
+type Handler struct {
+	Store Store
+	// BasePath the handler is mounted at, used to build the Location of new uploads
+	BasePath string
+	// MaxSize of uploads, 0 means no limit
+	MaxSize int64
+	// OnComplete is called once after the last byte of an upload was written
+	OnComplete func(info *Info)
+
+	locks sync.Map
+}
+
+// NewHandler returns a handler storing uploads in store.
+func NewHandler(store Store, basePath string) *Handler {
+	return &Handler{Store: store, BasePath: basePath}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := r.Method
+	if override := r.Header.Get(HeaderMethodOverride); override != "" && method == http.MethodPost {
+		method = override
+	}
+	header := w.Header()
+	header.Set(HeaderTusResumable, Version)
+
+	if method == http.MethodOptions {
+		header.Set(HeaderTusVersion, Version)
+		header.Set(HeaderTusExtension, Extensions)
+		header.Set(HeaderTusChecksumAlgorithm, strings.Join(ChecksumAlgorithms(), ","))
+		if h.MaxSize > 0 {
+			header.Set(HeaderTusMaxSize, strconv.FormatInt(h.MaxSize, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Header.Get(HeaderTusResumable) != Version {
+		header.Set(HeaderTusVersion, Version)
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	id := path.Base(r.URL.Path)
+	if strings.TrimSuffix(r.URL.Path, "/") == strings.TrimSuffix(h.BasePath, "/") {
+		id = ""
+	}
+	switch {
+	case method == http.MethodPost && id == "":
+		h.create(w, r)
+	case method == http.MethodHead && id != "":
+		h.head(w, r, id)
+	case method == http.MethodPatch && id != "":
+		h.patch(w, r, id)
+	case method == http.MethodDelete && id != "":
+		h.terminate(w, r, id)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// create handles the creation extension.
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	size, err := strconv.ParseInt(r.Header.Get(HeaderUploadLength), 10, 64)
+	if err != nil || size < 0 {
+		http.Error(w, "invalid "+HeaderUploadLength, http.StatusBadRequest)
+		return
+	}
+	if h.MaxSize > 0 && size > h.MaxSize {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	metadata, err := ParseMetadata(r.Header.Get(HeaderUploadMetadata))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	info := &Info{Size: size, Metadata: metadata}
+	if err = h.Store.Create(r.Context(), info); err != nil {
+		h.error(w, err)
+		return
+	}
+	w.Header().Set(httpx.HeaderLocation, strings.TrimSuffix(h.BasePath, "/")+"/"+info.ID)
+	w.WriteHeader(http.StatusCreated)
+	if size == 0 {
+		h.complete(info)
+	}
+}
+
+// head returns the offset of an upload.
+func (h *Handler) head(w http.ResponseWriter, r *http.Request, id string) {
+	info, err := h.Store.Get(r.Context(), id)
+	if err != nil {
+		h.error(w, err)
+		return
+	}
+	header := w.Header()
+	header.Set(httpx.HeaderCacheControl, httpx.CacheControlNoStore)
+	header.Set(HeaderUploadOffset, strconv.FormatInt(info.Offset, 10))
+	header.Set(HeaderUploadLength, strconv.FormatInt(info.Size, 10))
+	if len(info.Metadata) > 0 {
+		header.Set(HeaderUploadMetadata, EncodeMetadata(info.Metadata))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// patch appends the body at Upload-Offset.
+func (h *Handler) patch(w http.ResponseWriter, r *http.Request, id string) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get(httpx.HeaderContentType)); mediaType != ContentTypeOffsetOctetStream {
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get(HeaderUploadOffset), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "invalid "+HeaderUploadOffset, http.StatusBadRequest)
+		return
+	}
+	var verify *checksumReader
+	if checksum := r.Header.Get(HeaderUploadChecksum); checksum != "" {
+		if verify, err = newChecksumReader(checksum); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	// 同一上传同时只允许一个 PATCH
+	if _, locked := h.locks.LoadOrStore(id, struct{}{}); locked {
+		http.Error(w, http.StatusText(http.StatusLocked), http.StatusLocked)
+		return
+	}
+	defer h.locks.Delete(id)
+
+	info, err := h.Store.Get(r.Context(), id)
+	if err != nil {
+		h.error(w, err)
+		return
+	}
+	if offset != info.Offset {
+		http.Error(w, "offset mismatch", http.StatusConflict)
+		return
+	}
+	remaining := info.Size - offset
+	if r.ContentLength > remaining {
+		http.Error(w, "upload exceeds "+HeaderUploadLength, http.StatusRequestEntityTooLarge)
+		return
+	}
+	var body io.Reader = io.LimitReader(r.Body, remaining)
+	if verify != nil {
+		verify.r = body
+		body = verify
+	}
+	n, err := h.Store.Write(r.Context(), id, offset, body)
+	info.Offset = offset + n
+	if err != nil {
+		if errors.Is(err, ErrChecksumMismatch) {
+			http.Error(w, "checksum mismatch", StatusChecksumMismatch)
+			return
+		}
+		// 客户端中断时已写入的部分保留,可通过 HEAD 续传
+		h.error(w, err)
+		return
+	}
+	w.Header().Set(HeaderUploadOffset, strconv.FormatInt(info.Offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+	if n > 0 && info.Complete() {
+		h.complete(info)
+	}
+}
+
+// terminate handles the termination extension.
+func (h *Handler) terminate(w http.ResponseWriter, r *http.Request, id string) {
+	if err := h.Store.Terminate(r.Context(), id); err != nil {
+		h.error(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// complete calls OnComplete.
+func (h *Handler) complete(info *Info) {
+	if h.OnComplete != nil {
+		h.OnComplete(info)
+	}
+}
+
+// error writes the response of a store error.
+func (h *Handler) error(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case errors.Is(err, ErrOffsetMismatch):
+		http.Error(w, "offset mismatch", http.StatusConflict)
+	default:
+		log.Error(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// checksumReader hashes what is read and fails at EOF with ErrChecksumMismatch if the digest differs.
+type checksumReader struct {
+	r    io.Reader
+	hash hash.Hash
+	sum  []byte
+}
+
+// newChecksumReader parses an Upload-Checksum value.
+func newChecksumReader(checksum string) (*checksumReader, error) {
+	algorithm, encoded, _ := strings.Cut(checksum, " ")
+	newHash, ok := checksumAlgorithms[algorithm]
+	if !ok {
+		return nil, errors.New("tus: unsupported checksum algorithm " + algorithm)
+	}
+	sum, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("tus: invalid checksum")
+	}
+	return &checksumReader{hash: newHash(), sum: sum}, nil
+}
+
+// Read implements io.Reader.
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(c.hash.Sum(nil), c.sum) {
+		return n, ErrChecksumMismatch
+	}
+	if err != nil && err != io.EOF {
+		// 不完整的请求体无法校验,整体丢弃
+		return n, fmt.Errorf("%w: incomplete body: %w", ErrChecksumMismatch, err)
+	}
+	return n, err
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package tus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+)
+
+// Info describes an upload.
+type Info struct {
+	ID     string `json:"id"`
+	Size   int64  `json:"size"`
+	Offset int64  `json:"-"`
+	// Metadata of Upload-Metadata
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// Complete reports whether all bytes were received.
+func (i *Info) Complete() bool {
+	return i.Offset == i.Size
+}
+
+// Store persists uploads.
+type Store interface {
+	// Create assigns the ID and creates an empty upload.
+	Create(ctx context.Context, info *Info) error
+	// Get returns the upload with its current offset, ErrNotFound if it does not exist.
+	Get(ctx context.Context, id string) (*Info, error)
+	// Write appends r at offset and returns the bytes written. Bytes read before an error are kept so the
+	// upload can resume, unless the error is ErrChecksumMismatch. It returns ErrOffsetMismatch if offset is
+	// not the current offset.
+	Write(ctx context.Context, id string, offset int64, r io.Reader) (int64, error)
+	// Terminate deletes the upload.
+	Terminate(ctx context.Context, id string) error
+}
+
+// FileStore stores each upload as a data file and a JSON info file in Dir.
+type FileStore struct {
+	Dir string
+}
+
+// NewFileStore creates dir and returns a store in it.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+// Path returns the data file of the upload.
+func (s *FileStore) Path(id string) string {
+	return filepath.Join(s.Dir, id)
+}
+
+// infoPath returns the info file of the upload.
+func (s *FileStore) infoPath(id string) string {
+	return filepath.Join(s.Dir, id+".info")
+}
+
+// validID reports whether id was generated by Create, it must not escape Dir.
+func validID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// Create implements Store.
+func (s *FileStore) Create(ctx context.Context, info *Info) error {
+	var id [16]byte
+	rand.Read(id[:])
+	info.ID = hex.EncodeToString(id[:])
+	info.Offset = 0
+	if info.CreatedAt.IsZero() {
+		info.CreatedAt = time.Now()
+	}
+	data, err := jsonx.Marshal(info)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.Path(info.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.WriteFile(s.infoPath(info.ID), data, 0644)
+}
+
+// Get implements Store.
+func (s *FileStore) Get(ctx context.Context, id string) (*Info, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(s.infoPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	info := &Info{}
+	if err = jsonx.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+	// 偏移量即数据文件大小
+	stat, err := os.Stat(s.Path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	info.Offset = stat.Size()
+	return info, nil
+}
+
+// Write implements Store.
+func (s *FileStore) Write(ctx context.Context, id string, offset int64, r io.Reader) (int64, error) {
+	if !validID(id) {
+		return 0, ErrNotFound
+	}
+	f, err := os.OpenFile(s.Path(id), os.O_WRONLY, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if stat.Size() != offset {
+		return 0, ErrOffsetMismatch
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if errors.Is(err, ErrChecksumMismatch) {
+		if terr := f.Truncate(offset); terr != nil {
+			return n, terr
+		}
+		return 0, err
+	}
+	return n, err
+}
+
+// Terminate implements Store.
+func (s *FileStore) Terminate(ctx context.Context, id string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	err := os.Remove(s.infoPath(id))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(s.Path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
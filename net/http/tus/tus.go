@@ -0,0 +1,103 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+// Package tus implements the server side of the tus 1.0 resumable upload protocol, https://tus.io/protocols/resumable-upload,
+// with the creation, termination and checksum extensions.
+package tus
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const Version = "1.0.0"
+
+const (
+	HeaderTusResumable         = "Tus-Resumable"
+	HeaderTusVersion           = "Tus-Version"
+	HeaderTusExtension         = "Tus-Extension"
+	HeaderTusMaxSize           = "Tus-Max-Size"
+	HeaderTusChecksumAlgorithm = "Tus-Checksum-Algorithm"
+	HeaderUploadOffset         = "Upload-Offset"
+	HeaderUploadLength         = "Upload-Length"
+	HeaderUploadMetadata       = "Upload-Metadata"
+	HeaderUploadChecksum       = "Upload-Checksum"
+	HeaderMethodOverride       = "X-HTTP-Method-Override"
+)
+
+// ContentTypeOffsetOctetStream is the content type of PATCH requests.
+const ContentTypeOffsetOctetStream = "application/offset+octet-stream"
+
+// StatusChecksumMismatch is returned when the Upload-Checksum does not match the body.
+const StatusChecksumMismatch = 460
+
+const Extensions = "creation,termination,checksum"
+
+var (
+	ErrNotFound         = errors.New("tus: upload not found")
+	ErrOffsetMismatch   = errors.New("tus: offset mismatch")
+	ErrChecksumMismatch = errors.New("tus: checksum mismatch")
+)
+
+// checksumAlgorithms supported by the checksum extension.
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// ChecksumAlgorithms returns the names of the supported checksum algorithms.
+func ChecksumAlgorithms() []string {
+	return slices.Sorted(maps.Keys(checksumAlgorithms))
+}
+
+// FormatChecksum returns the Upload-Checksum value of a digest.
+func FormatChecksum(algorithm string, sum []byte) string {
+	return algorithm + " " + base64.StdEncoding.EncodeToString(sum)
+}
+
+// EncodeMetadata returns the Upload-Metadata value of metadata, values are base64 encoded.
+func EncodeMetadata(metadata map[string]string) string {
+	var b strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(metadata)) {
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		if v := metadata[k]; v != "" {
+			b.WriteByte(' ')
+			b.WriteString(base64.StdEncoding.EncodeToString([]byte(v)))
+		}
+	}
+	return b.String()
+}
+
+// ParseMetadata parses an Upload-Metadata value.
+func ParseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for pair := range strings.SplitSeq(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if k == "" {
+			return nil, errors.New("tus: empty metadata key")
+		}
+		value, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, errors.New("tus: invalid metadata value of " + k)
+		}
+		metadata[k] = string(value)
+	}
+	return metadata, nil
+}
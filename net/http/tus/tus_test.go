@@ -0,0 +1,131 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package tus
+
+import (
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T) (*FileStore, *httptest.Server, chan *Info) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	completed := make(chan *Info, 1)
+	h := NewHandler(store, "/files/")
+	h.MaxSize = 1 << 20
+	h.OnComplete = func(info *Info) { completed <- info }
+	mux := http.NewServeMux()
+	mux.Handle("/files/", h)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return store, srv, completed
+}
+
+func do(t *testing.T, method, url string, header map[string]string, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set(HeaderTusResumable, Version)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp
+}
+
+func TestProtocol(t *testing.T) {
+	store, srv, completed := newTestServer(t)
+
+	resp := do(t, http.MethodOptions, srv.URL+"/files/", nil, "")
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get(HeaderTusExtension) != Extensions || resp.Header.Get(HeaderTusChecksumAlgorithm) != "md5,sha1,sha256" {
+		t.Fatalf("options: %d %v", resp.StatusCode, resp.Header)
+	}
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/files/", nil)
+	if resp, _ = http.DefaultClient.Do(req); resp.StatusCode != http.StatusPreconditionFailed || resp.Header.Get(HeaderTusVersion) != Version {
+		t.Fatalf("missing Tus-Resumable: %d", resp.StatusCode)
+	}
+	if resp = do(t, http.MethodPost, srv.URL+"/files/", map[string]string{HeaderUploadLength: "2000000"}, ""); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("max size: %d", resp.StatusCode)
+	}
+
+	resp = do(t, http.MethodPost, srv.URL+"/files/", map[string]string{
+		HeaderUploadLength:   "11",
+		HeaderUploadMetadata: EncodeMetadata(map[string]string{"filename": "hello.txt", "empty": ""}),
+	}, "")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: %d", resp.StatusCode)
+	}
+	location := srv.URL + resp.Header.Get("Location")
+	patch := func(offset int, body string, checksum string) *http.Response {
+		header := map[string]string{"Content-Type": ContentTypeOffsetOctetStream, HeaderUploadOffset: strconv.Itoa(offset)}
+		if checksum != "" {
+			header[HeaderUploadChecksum] = checksum
+		}
+		return do(t, http.MethodPatch, location, header, body)
+	}
+
+	if resp = patch(0, "hello ", ""); resp.StatusCode != http.StatusNoContent || resp.Header.Get(HeaderUploadOffset) != "6" {
+		t.Fatalf("patch: %d %s", resp.StatusCode, resp.Header.Get(HeaderUploadOffset))
+	}
+	if resp = patch(0, "hello ", ""); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("stale offset: %d", resp.StatusCode)
+	}
+	if resp = patch(6, "world", FormatChecksum("md5", []byte("0123456789abcdef"))); resp.StatusCode != StatusChecksumMismatch {
+		t.Fatalf("bad checksum: %d", resp.StatusCode)
+	}
+	if resp = do(t, http.MethodHead, location, nil, ""); resp.Header.Get(HeaderUploadOffset) != "6" || resp.Header.Get(HeaderUploadLength) != "11" {
+		t.Fatalf("head after bad checksum: %v", resp.Header)
+	}
+	if resp = patch(6, "world!", ""); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("exceeding length: %d", resp.StatusCode)
+	}
+	sum := sha256.Sum256([]byte("world"))
+	if resp = patch(6, "world", FormatChecksum("sha256", sum[:])); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("patch with checksum: %d", resp.StatusCode)
+	}
+
+	info := <-completed
+	if info.Metadata["filename"] != "hello.txt" || info.Metadata["empty"] != "" || !info.Complete() {
+		t.Errorf("got %+v", info)
+	}
+	data, _ := os.ReadFile(store.Path(info.ID))
+	if string(data) != "hello world" {
+		t.Errorf("got %q", data)
+	}
+
+	if resp = do(t, http.MethodDelete, location, nil, ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("terminate: %d", resp.StatusCode)
+	}
+	if resp = do(t, http.MethodHead, location, nil, ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("head after terminate: %d", resp.StatusCode)
+	}
+	if resp = do(t, http.MethodHead, srv.URL+"/files/..%2f..%2fetc", nil, ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("invalid id: %d", resp.StatusCode)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	m, err := ParseMetadata("filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==, is_confidential")
+	if err != nil || m["filename"] != "world_domination_plan.pdf" || len(m) != 2 {
+		t.Fatalf("got %v %v", m, err)
+	}
+	if _, err = ParseMetadata("key !!"); err == nil {
+		t.Error("want error for invalid base64")
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+type DialOptions struct {
+	Header http.Header
+	// RequestOptions change the handshake request, e.g. authentication
+	RequestOptions []func(req *http.Request)
+	Subprotocols   []string
+	// EnableCompression offers permessage-deflate
+	EnableCompression bool
+	// HandshakeTimeout bounds the handshake, 0 means only the context
+	HandshakeTimeout time.Duration
+	// ReadLimit of messages, 0 means DefaultReadLimit and a negative limit means no limit
+	ReadLimit    int64
+	FragmentSize int
+}
+
+/*
+Dial opens a WebSocket connection to a ws, wss, http or https url through client, so its proxy and TLS
+settings apply; nil means http.DefaultClient. The client timeout is ignored, the handshake is bounded
+by ctx and HandshakeTimeout. On a failed handshake the response is returned with ErrBadHandshake.
+*/
+func Dial(ctx context.Context, client *http.Client, rawURL string, opts *DialOptions) (*Conn, *http.Response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if opts == nil {
+		opts = &DialOptions{}
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	case "http", "https":
+	default:
+		return nil, nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+	u.Fragment = ""
+
+	if opts.HandshakeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.HandshakeTimeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, vs := range opts.Header {
+		req.Header[k] = slices.Clone(vs)
+	}
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	// net/http 见到 websocket 升级请求会使用 HTTP/1.1
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if len(opts.Subprotocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(opts.Subprotocols, ", "))
+	}
+	if opts.EnableCompression {
+		req.Header.Set("Sec-WebSocket-Extensions", deflateParams)
+	}
+	for _, opt := range opts.RequestOptions {
+		opt(req)
+	}
+
+	hc := *client
+	hc.Timeout = 0
+	hc.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	bad := func(msg string) (*Conn, *http.Response, error) {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		resp.Body = io.NopCloser(strings.NewReader(string(body)))
+		return nil, resp, fmt.Errorf("%w: %s", ErrBadHandshake, msg)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return bad("status " + resp.Status)
+	}
+	if !headerContainsToken(resp.Header, "Connection", "upgrade") || !headerContainsToken(resp.Header, "Upgrade", "websocket") {
+		return bad("missing upgrade headers")
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return bad("mismatched Sec-WebSocket-Accept")
+	}
+	subprotocol := resp.Header.Get("Sec-WebSocket-Protocol")
+	if subprotocol != "" && !slices.Contains(opts.Subprotocols, subprotocol) {
+		return bad("unexpected subprotocol " + subprotocol)
+	}
+	var compress bool
+	for _, ext := range parseExtensions(resp.Header) {
+		if ext.name != extensionDeflate || !opts.EnableCompression || compress || !deflateAccepted(ext) {
+			return bad("unexpected extension " + ext.name)
+		}
+		compress = true
+	}
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return nil, resp, fmt.Errorf("%w: response body is not writable", ErrBadHandshake)
+	}
+
+	c := newConn(rwc, nil, false, subprotocol, compress)
+	c.readLimit = readLimitOf(opts.ReadLimit)
+	c.fragmentSize = opts.FragmentSize
+	return c, resp, nil
+}
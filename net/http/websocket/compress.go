@@ -0,0 +1,140 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultCompressionLevel = flate.BestSpeed
+	extensionDeflate        = "permessage-deflate"
+	// 只支持无上下文接管,每条消息独立压缩
+	deflateParams = extensionDeflate + "; server_no_context_takeover; client_no_context_takeover"
+)
+
+// deflateTail ends a message compressed with a sync flush, and a final empty block so the reader sees EOF
+const deflateTail = "\x00\x00\xff\xff\x01\x00\x00\xff\xff"
+
+type flateWriter struct {
+	*flate.Writer
+	level int
+}
+
+var (
+	flateWriterPools [flate.BestCompression - flate.HuffmanOnly + 1]sync.Pool
+	flateReaderPool  sync.Pool
+)
+
+// getFlateWriter returns a pooled writer of the level writing to w.
+func getFlateWriter(level int, w io.Writer) *flateWriter {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		level = defaultCompressionLevel
+	}
+	pool := &flateWriterPools[level-flate.HuffmanOnly]
+	if fw, ok := pool.Get().(*flateWriter); ok {
+		fw.Reset(w)
+		return fw
+	}
+	fw, _ := flate.NewWriter(w, level)
+	return &flateWriter{Writer: fw, level: level}
+}
+
+// putFlateWriter returns fw to the pool.
+func putFlateWriter(fw *flateWriter) {
+	fw.Reset(io.Discard)
+	flateWriterPools[fw.level-flate.HuffmanOnly].Put(fw)
+}
+
+// decompress inflates a message, limit 0 means no limit.
+func decompress(data []byte, limit int64) ([]byte, error) {
+	src := io.MultiReader(bytes.NewReader(data), strings.NewReader(deflateTail))
+	fr, ok := flateReaderPool.Get().(io.ReadCloser)
+	if ok {
+		fr.(flate.Resetter).Reset(src, nil)
+	} else {
+		fr = flate.NewReader(src)
+	}
+	defer flateReaderPool.Put(fr)
+
+	var r io.Reader = fr
+	if limit > 0 {
+		r = io.LimitReader(fr, limit+1)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(out)) > limit {
+		return nil, ErrReadLimit
+	}
+	return out, nil
+}
+
+// extension is an offer or a response of Sec-WebSocket-Extensions.
+type extension struct {
+	name   string
+	params map[string]string
+}
+
+// parseExtensions parses the Sec-WebSocket-Extensions headers, RFC 6455 9.1.
+func parseExtensions(header http.Header) []extension {
+	var extensions []extension
+	for _, line := range header.Values("Sec-WebSocket-Extensions") {
+		for item := range strings.SplitSeq(line, ",") {
+			parts := strings.Split(item, ";")
+			ext := extension{name: strings.ToLower(strings.TrimSpace(parts[0])), params: map[string]string{}}
+			if ext.name == "" {
+				continue
+			}
+			for _, param := range parts[1:] {
+				k, v, _ := strings.Cut(param, "=")
+				ext.params[strings.ToLower(strings.TrimSpace(k))] = strings.Trim(strings.TrimSpace(v), `"`)
+			}
+			extensions = append(extensions, ext)
+		}
+	}
+	return extensions
+}
+
+// acceptDeflate reports whether the server can accept a permessage-deflate offer, RFC 7692 7.1.
+func acceptDeflate(offer extension) bool {
+	for k, v := range offer.params {
+		switch k {
+		case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+		case "server_max_window_bits":
+			// flate 总是使用 32K 窗口
+			if v != "15" {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// deflateAccepted reports whether the server response of the client offer can be used by the client.
+func deflateAccepted(ext extension) bool {
+	// 客户端每条消息都用新的解压器,要求服务端不接管上下文
+	if _, ok := ext.params["server_no_context_takeover"]; !ok {
+		return false
+	}
+	for k := range ext.params {
+		switch k {
+		case "server_no_context_takeover", "client_no_context_takeover", "server_max_window_bits":
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,647 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+// Package websocket implements the WebSocket protocol of RFC 6455 with the permessage-deflate extension of RFC 7692.
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+type MessageType int
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+	CloseMessage  MessageType = 8
+	PingMessage   MessageType = 9
+	PongMessage   MessageType = 10
+)
+
+const continuationFrame = 0
+
+// close codes, RFC 6455 7.4.1
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+const maxControlPayload = 125
+
+// DefaultReadLimit is the read limit of Upgrader and Dial when ReadLimit is 0.
+const DefaultReadLimit = 32 << 20
+
+var (
+	ErrReadLimit    = errors.New("websocket: read limit exceeded")
+	ErrCloseSent    = errors.New("websocket: close sent")
+	ErrBadHandshake = errors.New("websocket: bad handshake")
+)
+
+// CloseError is returned by reads after the peer sent a close frame.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+// Error returns the error message.
+func (e *CloseError) Error() string {
+	return "websocket: close " + strconv.Itoa(e.Code) + " " + e.Text
+}
+
+// ProtocolError is returned when the peer violates the protocol, the connection is closed with CloseProtocolError.
+type ProtocolError struct {
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ProtocolError) Error() string {
+	return "websocket: protocol error: " + e.Msg
+}
+
+// Conn is a WebSocket connection.
+// One goroutine may read and another may write messages concurrently, control frames
+// (WriteControl, Close, keepalive pings) can be sent from any goroutine at any time.
+type Conn struct {
+	rwc         io.ReadWriteCloser
+	br          *bufio.Reader
+	isServer    bool
+	subprotocol string
+
+	compress         bool
+	compressionLevel int
+	readLimit        int64
+	fragmentSize     int
+
+	// wmu guards frames, mmu a data message that may be fragmented
+	wmu, mmu  sync.Mutex
+	closeSent atomic.Bool
+	closeOnce sync.Once
+	done      chan struct{}
+	lastRead  atomic.Int64
+
+	pingHandler func(data []byte) error
+	pongHandler func(data []byte) error
+}
+
+// newConn creates a new instance.
+func newConn(rwc io.ReadWriteCloser, br *bufio.Reader, isServer bool, subprotocol string, compress bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(rwc)
+	}
+	c := &Conn{
+		rwc:              rwc,
+		br:               br,
+		isServer:         isServer,
+		subprotocol:      subprotocol,
+		compress:         compress,
+		compressionLevel: defaultCompressionLevel,
+		done:             make(chan struct{}),
+	}
+	c.lastRead.Store(time.Now().UnixNano())
+	return c
+}
+
+// Subprotocol returns the negotiated subprotocol, empty if none.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Compressed reports whether permessage-deflate was negotiated.
+func (c *Conn) Compressed() bool {
+	return c.compress
+}
+
+// SetReadLimit limits the size of a message after decompression, 0 means no limit.
+// Connections of Upgrader and Dial start with DefaultReadLimit.
+// Larger messages fail with ErrReadLimit and close the connection with CloseMessageTooBig.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetFragmentSize splits written messages into frames of at most size bytes, 0 sends one frame per message.
+func (c *Conn) SetFragmentSize(size int) {
+	c.fragmentSize = size
+}
+
+// SetCompressionLevel sets the flate level of written messages when compression is negotiated.
+func (c *Conn) SetCompressionLevel(level int) {
+	c.compressionLevel = level
+}
+
+// SetPingHandler replaces the default handler that answers pings with pongs, it runs inside reads.
+func (c *Conn) SetPingHandler(handler func(data []byte) error) {
+	c.pingHandler = handler
+}
+
+// SetPongHandler sets the handler of pongs, it runs inside reads.
+func (c *Conn) SetPongHandler(handler func(data []byte) error) {
+	c.pongHandler = handler
+}
+
+// NetConn returns the underlying net.Conn, nil if the transport does not expose one.
+func (c *Conn) NetConn() net.Conn {
+	conn, _ := c.rwc.(net.Conn)
+	return conn
+}
+
+// SetReadDeadline sets the read deadline when the underlying connection supports it.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	if conn, ok := c.rwc.(interface{ SetReadDeadline(time.Time) error }); ok {
+		return conn.SetReadDeadline(t)
+	}
+	return errors.ErrUnsupported
+}
+
+// SetWriteDeadline sets the write deadline when the underlying connection supports it.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	if conn, ok := c.rwc.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		return conn.SetWriteDeadline(t)
+	}
+	return errors.ErrUnsupported
+}
+
+// KeepAlive pings the peer every interval and closes the connection when nothing was read for interval+timeout.
+// Pongs are only seen while a goroutine is reading. It stops when the connection is closed.
+func (c *Conn) KeepAlive(interval, timeout time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+				if time.Since(time.Unix(0, c.lastRead.Load())) > interval+timeout {
+					c.closeTransport()
+					return
+				}
+				if err := c.WriteControl(PingMessage, nil); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// WriteControl sends a ping, pong or close frame, data is at most 125 bytes.
+func (c *Conn) WriteControl(typ MessageType, data []byte) error {
+	if typ != PingMessage && typ != PongMessage && typ != CloseMessage {
+		return fmt.Errorf("websocket: %d is not a control message", typ)
+	}
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: control frame payload too large")
+	}
+	if typ == CloseMessage {
+		if !c.closeSent.CompareAndSwap(false, true) {
+			return ErrCloseSent
+		}
+	} else if c.closeSent.Load() {
+		return ErrCloseSent
+	}
+	return c.writeFrame(true, false, byte(typ), data)
+}
+
+// WriteClose sends a close frame with the code and reason.
+func (c *Conn) WriteClose(code int, text string) error {
+	return c.WriteControl(CloseMessage, formatClose(code, text))
+}
+
+// Close sends a normal close frame if none was sent and closes the connection.
+func (c *Conn) Close() error {
+	_ = c.WriteClose(CloseNormalClosure, "")
+	return c.closeTransport()
+}
+
+// closeTransport closes the underlying connection once.
+func (c *Conn) closeTransport() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.rwc.Close()
+	})
+	return err
+}
+
+// WriteMessage sends a data message, fragmented by the fragment size.
+func (c *Conn) WriteMessage(typ MessageType, data []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return c.WriteControl(typ, data)
+	}
+	w, err := c.NextWriter(typ)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// ReadMessage reads the next data message, answering pings and handling pongs and close frames in between.
+// After the peer closed it returns a *CloseError.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var typ MessageType
+	var data []byte
+	var compressed, started bool
+	for {
+		h, err := c.readFrameHeader()
+		if err != nil {
+			return 0, nil, err
+		}
+		if !h.control() && c.readLimit > 0 {
+			limit := c.readLimit
+			if compressed || h.rsv1 {
+				// 压缩后的大小在解压时检查,这里只防止过大的压缩数据
+				limit *= 2
+			}
+			if int64(len(data))+h.length > limit {
+				return 0, nil, c.fail(CloseMessageTooBig, ErrReadLimit)
+			}
+		}
+		payload, err := readPayload(c.br, h.length)
+		if err != nil {
+			return 0, nil, err
+		}
+		if h.masked {
+			maskBytes(h.mask, payload)
+		}
+		c.lastRead.Store(time.Now().UnixNano())
+
+		switch h.op {
+		case byte(PingMessage):
+			if err = c.handlePing(payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case byte(PongMessage):
+			if c.pongHandler != nil {
+				if err = c.pongHandler(payload); err != nil {
+					return 0, nil, err
+				}
+			}
+			continue
+		case byte(CloseMessage):
+			return 0, nil, c.handleClose(payload)
+		case continuationFrame:
+			if !started {
+				return 0, nil, c.fail(CloseProtocolError, &ProtocolError{"continuation frame without a message"})
+			}
+		default:
+			if started {
+				return 0, nil, c.fail(CloseProtocolError, &ProtocolError{"data frame inside a fragmented message"})
+			}
+			started, typ, compressed = true, MessageType(h.op), h.rsv1
+		}
+		data = append(data, payload...)
+		if h.fin {
+			break
+		}
+	}
+	if compressed {
+		var err error
+		if data, err = decompress(data, c.readLimit); err != nil {
+			if errors.Is(err, ErrReadLimit) {
+				return 0, nil, c.fail(CloseMessageTooBig, err)
+			}
+			return 0, nil, c.fail(CloseInvalidFramePayloadData, err)
+		}
+	}
+	if typ == TextMessage && !utf8.Valid(data) {
+		return 0, nil, c.fail(CloseInvalidFramePayloadData, &ProtocolError{"invalid utf-8 in text message"})
+	}
+	return typ, data, nil
+}
+
+// readPayload reads n bytes, the buffer grows as the payload arrives so a forged length allocates nothing up front.
+func readPayload(r io.Reader, n int64) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, min(n, 64<<10)))
+	if _, err := buf.ReadFrom(io.LimitReader(r, n)); err != nil {
+		return nil, err
+	}
+	if int64(buf.Len()) < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), nil
+}
+
+// readLimitOf returns the read limit of a connection for the ReadLimit option, 0 is DefaultReadLimit and
+// a negative limit means no limit.
+func readLimitOf(limit int64) int64 {
+	if limit == 0 {
+		return DefaultReadLimit
+	}
+	return max(limit, 0)
+}
+
+// handlePing answers a ping.
+func (c *Conn) handlePing(payload []byte) error {
+	if c.pingHandler != nil {
+		return c.pingHandler(payload)
+	}
+	err := c.WriteControl(PongMessage, payload)
+	if errors.Is(err, ErrCloseSent) {
+		return nil
+	}
+	return err
+}
+
+// handleClose answers a close frame and returns the CloseError.
+func (c *Conn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, &ProtocolError{"invalid close payload"})
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !validCloseCode(closeErr.Code) {
+			return c.fail(CloseProtocolError, &ProtocolError{"invalid close code " + strconv.Itoa(closeErr.Code)})
+		}
+		if !utf8.ValidString(closeErr.Text) {
+			return c.fail(CloseInvalidFramePayloadData, &ProtocolError{"invalid utf-8 in close reason"})
+		}
+	}
+	// 回应关闭帧,沿用对方的状态码
+	echo := closeErr.Code
+	if echo == CloseNoStatusReceived {
+		echo = CloseNormalClosure
+	}
+	_ = c.WriteClose(echo, "")
+	return closeErr
+}
+
+// fail sends a close frame with code and returns err.
+func (c *Conn) fail(code int, err error) error {
+	_ = c.WriteClose(code, "")
+	return err
+}
+
+// validCloseCode reports whether a received close code is allowed, RFC 6455 7.4.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code >= 1000 && code <= 1011:
+		return code != 1004 && code != CloseNoStatusReceived && code != CloseAbnormalClosure
+	}
+	return false
+}
+
+// formatClose returns the payload of a close frame.
+func formatClose(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return nil
+	}
+	payload := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	copy(payload[2:], text)
+	return payload
+}
+
+type frameHeader struct {
+	fin, rsv1, masked bool
+	op                byte
+	length            int64
+	mask              [4]byte
+}
+
+// control reports whether the frame is a control frame.
+func (h *frameHeader) control() bool {
+	return h.op >= byte(CloseMessage)
+}
+
+// readFrameHeader reads and validates a frame header, RFC 6455 5.2.
+func (c *Conn) readFrameHeader() (frameHeader, error) {
+	var h frameHeader
+	var b [8]byte
+	if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+		return h, err
+	}
+	h.fin = b[0]&0x80 != 0
+	h.rsv1 = b[0]&0x40 != 0
+	h.op = b[0] & 0x0f
+	h.masked = b[1]&0x80 != 0
+	length := int64(b[1] & 0x7f)
+
+	if b[0]&0x30 != 0 {
+		return h, c.fail(CloseProtocolError, &ProtocolError{"unexpected reserved bits"})
+	}
+	switch h.op {
+	case continuationFrame, byte(TextMessage), byte(BinaryMessage):
+		if h.rsv1 && (!c.compress || h.op == continuationFrame) {
+			return h, c.fail(CloseProtocolError, &ProtocolError{"unexpected rsv1 bit"})
+		}
+	case byte(CloseMessage), byte(PingMessage), byte(PongMessage):
+		if !h.fin || h.rsv1 || length > maxControlPayload {
+			return h, c.fail(CloseProtocolError, &ProtocolError{"invalid control frame"})
+		}
+	default:
+		return h, c.fail(CloseProtocolError, &ProtocolError{"unknown opcode " + strconv.Itoa(int(h.op))})
+	}
+	// 客户端发出的帧必须掩码,服务端发出的帧不能掩码
+	if h.masked != c.isServer {
+		return h, c.fail(CloseProtocolError, &ProtocolError{"bad frame masking"})
+	}
+
+	switch length {
+	case 126:
+		if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+			return h, err
+		}
+		length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.br, b[:8]); err != nil {
+			return h, err
+		}
+		if b[0]&0x80 != 0 {
+			return h, c.fail(CloseProtocolError, &ProtocolError{"invalid payload length"})
+		}
+		length = int64(binary.BigEndian.Uint64(b[:8]))
+	}
+	h.length = length
+	if h.masked {
+		if _, err := io.ReadFull(c.br, h.mask[:]); err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
+
+// writeFrame writes one frame, masked on the client side.
+func (c *Conn) writeFrame(fin, rsv1 bool, op byte, payload []byte) error {
+	buf := make([]byte, 0, 14+len(payload))
+	b0 := op
+	if fin {
+		b0 |= 0x80
+	}
+	if rsv1 {
+		b0 |= 0x40
+	}
+	var maskBit byte
+	if !c.isServer {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		buf = append(buf, b0, maskBit|byte(n))
+	case n <= 0xffff:
+		buf = append(buf, b0, maskBit|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, b0, maskBit|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	if c.isServer {
+		buf = append(buf, payload...)
+	} else {
+		var mask [4]byte
+		rand.Read(mask[:])
+		buf = append(buf, mask[:]...)
+		start := len(buf)
+		buf = append(buf, payload...)
+		maskBytes(mask, buf[start:])
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.rwc.Write(buf)
+	return err
+}
+
+// maskBytes applies the masking key, RFC 6455 5.3.
+func maskBytes(mask [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= mask[i&3]
+	}
+}
+
+// NextWriter returns a writer of a data message, the message is sent when the writer is closed and
+// split into frames of the fragment size while writing. Other data messages wait until it is closed.
+func (c *Conn) NextWriter(typ MessageType) (io.WriteCloser, error) {
+	if typ != TextMessage && typ != BinaryMessage {
+		return nil, fmt.Errorf("websocket: %d is not a data message", typ)
+	}
+	if c.closeSent.Load() {
+		return nil, ErrCloseSent
+	}
+	c.mmu.Lock()
+	w := &messageWriter{c: c, op: byte(typ), fragmentSize: c.fragmentSize}
+	if c.compress {
+		w.fw = getFlateWriter(c.compressionLevel, (*rawMessageWriter)(w))
+	}
+	return w, nil
+}
+
+type messageWriter struct {
+	c            *Conn
+	op           byte
+	fragmentSize int
+	buf          []byte
+	started      bool
+	closed       bool
+	err          error
+	fw           *flateWriter
+}
+
+// rawMessageWriter receives the compressed bytes of a messageWriter.
+type rawMessageWriter messageWriter
+
+// Write buffers p and flushes full fragments.
+func (w *rawMessageWriter) Write(p []byte) (int, error) {
+	mw := (*messageWriter)(w)
+	mw.buf = append(mw.buf, p...)
+	if mw.fragmentSize <= 0 {
+		return len(p), nil
+	}
+	// 压缩时保留末尾 4 字节,结束时去掉 00 00 ff ff
+	keep := 0
+	if mw.fw != nil {
+		keep = 4
+	}
+	for len(mw.buf)-keep > mw.fragmentSize {
+		if err := mw.writeFragment(mw.buf[:mw.fragmentSize], false); err != nil {
+			return 0, err
+		}
+		mw.buf = append(mw.buf[:0], mw.buf[mw.fragmentSize:]...)
+	}
+	return len(p), nil
+}
+
+// Write writes message data.
+func (w *messageWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("websocket: write to closed writer")
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	var n int
+	if w.fw != nil {
+		n, w.err = w.fw.Write(p)
+	} else {
+		n, w.err = (*rawMessageWriter)(w).Write(p)
+	}
+	return n, w.err
+}
+
+// Close sends the last frame of the message.
+func (w *messageWriter) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+	defer w.c.mmu.Unlock()
+	if w.fw != nil {
+		if w.err == nil {
+			w.err = w.fw.Flush()
+		}
+		putFlateWriter(w.fw)
+		if w.err == nil {
+			w.buf = w.buf[:len(w.buf)-4]
+		}
+	}
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.writeFragment(w.buf, true)
+	return w.err
+}
+
+// writeFragment sends a frame of the message.
+func (w *messageWriter) writeFragment(p []byte, fin bool) error {
+	if w.c.closeSent.Load() {
+		return ErrCloseSent
+	}
+	op := byte(continuationFrame)
+	if !w.started {
+		op = w.op
+	}
+	rsv1 := w.fw != nil && !w.started
+	w.started = true
+	return w.c.writeFrame(fin, rsv1, op, p)
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package websocket
+
+import (
+	"iter"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+)
+
+// WriteJSON sends v as a JSON text message.
+func (c *Conn) WriteJSON(v any) error {
+	data, err := jsonx.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, data)
+}
+
+// ReadJSON reads the next data message into v.
+func (c *Conn) ReadJSON(v any) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return jsonx.Unmarshal(data, v)
+}
+
+// Messages iterates the JSON messages of c decoded as T. It stops after the first error,
+// a *CloseError when the peer closed normally.
+func Messages[T any](c *Conn) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			var v T
+			if err := c.ReadJSON(&v); err != nil {
+				yield(v, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package websocket
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Upgrader upgrades HTTP requests to WebSocket connections.
+type Upgrader struct {
+	// Subprotocols supported in order of preference
+	Subprotocols []string
+	// CheckOrigin returns true if the Origin is allowed, nil allows requests without Origin or of the same host
+	CheckOrigin func(r *http.Request) bool
+	// EnableCompression negotiates permessage-deflate when offered
+	EnableCompression bool
+	// ReadLimit of messages, 0 means DefaultReadLimit and a negative limit means no limit
+	ReadLimit int64
+	// FragmentSize of written messages, 0 means no fragmentation
+	FragmentSize int
+	// HandshakeTimeout bounds writing the handshake response, 0 means none
+	HandshakeTimeout time.Duration
+}
+
+// acceptKey computes Sec-WebSocket-Accept, RFC 6455 4.2.2.
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// headerContainsToken reports whether the comma separated header contains token, case-insensitively.
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, line := range header.Values(name) {
+		for item := range strings.SplitSeq(line, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin allows requests without Origin or whose Origin host is the request host.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// Upgrade performs the server handshake, responseHeader is added to the 101 response.
+// On failure an HTTP error has been written to w.
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (*Conn, error) {
+	fail := func(status int, msg string) (*Conn, error) {
+		if status == http.StatusUpgradeRequired {
+			w.Header().Set("Sec-WebSocket-Version", "13")
+		}
+		http.Error(w, http.StatusText(status), status)
+		return nil, errors.New("websocket: " + msg)
+	}
+	if r.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "request method is not GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return fail(http.StatusBadRequest, "not a websocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return fail(http.StatusUpgradeRequired, "unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return fail(http.StatusForbidden, "origin not allowed")
+	}
+
+	var subprotocol string
+	if offered := r.Header.Values("Sec-WebSocket-Protocol"); len(offered) > 0 {
+		var protocols []string
+		for _, line := range offered {
+			for p := range strings.SplitSeq(line, ",") {
+				protocols = append(protocols, strings.TrimSpace(p))
+			}
+		}
+		for _, p := range u.Subprotocols {
+			if slices.Contains(protocols, p) {
+				subprotocol = p
+				break
+			}
+		}
+	}
+	var compress bool
+	if u.EnableCompression {
+		for _, ext := range parseExtensions(r.Header) {
+			if ext.name == extensionDeflate && acceptDeflate(ext) {
+				compress = true
+				break
+			}
+		}
+	}
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, "hijack: "+err.Error())
+	}
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	b.WriteString(acceptKey(key))
+	b.WriteString("\r\n")
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		b.WriteString("Sec-WebSocket-Extensions: " + deflateParams + "\r\n")
+	}
+	for k, vs := range responseHeader {
+		if k == "Sec-Websocket-Protocol" || k == "Sec-Websocket-Extensions" {
+			continue
+		}
+		for _, v := range vs {
+			b.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+	b.WriteString("\r\n")
+
+	if u.HandshakeTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(u.HandshakeTimeout))
+	}
+	if _, err = conn.Write([]byte(b.String())); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if u.HandshakeTimeout > 0 {
+		conn.SetWriteDeadline(time.Time{})
+	}
+	// 劫持前 http.Server 设置的读超时不再适用
+	conn.SetReadDeadline(time.Time{})
+
+	c := newConn(conn, brw.Reader, true, subprotocol, compress)
+	c.readLimit = readLimitOf(u.ReadLimit)
+	c.fragmentSize = u.FragmentSize
+	return c, nil
+}
+
+// Handler returns a handler that upgrades requests and serves the connection with handle, the connection
+// is closed when handle returns.
+func Handler(upgrader *Upgrader, handle func(conn *Conn, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(conn, r)
+	}
+}
@@ -0,0 +1,337 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func echoServer(t *testing.T, upgrader *Upgrader, tls bool) *httptest.Server {
+	t.Helper()
+	handler := Handler(upgrader, func(conn *Conn, r *http.Request) {
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err = conn.WriteMessage(typ, data); err != nil {
+				return
+			}
+		}
+	})
+	var srv *httptest.Server
+	if tls {
+		srv = httptest.NewTLSServer(handler)
+	} else {
+		srv = httptest.NewServer(handler)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestEcho(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		compress bool
+		fragment int
+		tls      bool
+	}{
+		{name: "plain"},
+		{name: "fragmented", fragment: 7},
+		{name: "compressed", compress: true},
+		{name: "compressed fragmented", compress: true, fragment: 5},
+		{name: "tls", tls: true, compress: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := echoServer(t, &Upgrader{EnableCompression: true, FragmentSize: tc.fragment}, tc.tls)
+			// 先取消握手用的 context,连接应不受影响
+			ctx, cancel := context.WithCancel(context.Background())
+			conn, resp, err := Dial(ctx, srv.Client(), wsURL(srv), &DialOptions{
+				EnableCompression: tc.compress,
+				FragmentSize:      tc.fragment,
+				HandshakeTimeout:  time.Second,
+			})
+			cancel()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if resp.StatusCode != http.StatusSwitchingProtocols || conn.Compressed() != tc.compress {
+				t.Fatalf("status %d, compressed %v", resp.StatusCode, conn.Compressed())
+			}
+
+			messages := []struct {
+				typ  MessageType
+				data []byte
+			}{
+				{TextMessage, []byte("hello")},
+				{BinaryMessage, bytes.Repeat([]byte{0, 1, 2, 3}, 20000)},
+				{TextMessage, []byte(strings.Repeat("数据", 100))},
+				{TextMessage, []byte{}},
+			}
+			for _, m := range messages {
+				if err = conn.WriteMessage(m.typ, m.data); err != nil {
+					t.Fatal(err)
+				}
+				typ, data, err := conn.ReadMessage()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if typ != m.typ || !bytes.Equal(data, m.data) {
+					t.Fatalf("got %d %d bytes, want %d %d bytes", typ, len(data), m.typ, len(m.data))
+				}
+			}
+		})
+	}
+}
+
+func TestNextWriterStream(t *testing.T) {
+	srv := echoServer(t, &Upgrader{}, false)
+	conn, _, err := Dial(context.Background(), nil, wsURL(srv), &DialOptions{FragmentSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	w, err := conn.NextWriter(TextMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{"stre", "amed ", "message"} {
+		w.Write([]byte(part))
+	}
+	// 分片之间可以插入控制帧
+	if err = conn.WriteControl(PingMessage, []byte("p")); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	_, data, err := conn.ReadMessage()
+	if err != nil || string(data) != "streamed message" {
+		t.Fatalf("got %q %v", data, err)
+	}
+}
+
+func TestCloseHandshake(t *testing.T) {
+	closed := make(chan error, 1)
+	srv := httptest.NewServer(Handler(&Upgrader{}, func(conn *Conn, r *http.Request) {
+		_, _, err := conn.ReadMessage()
+		closed <- err
+	}))
+	defer srv.Close()
+
+	conn, _, err := Dial(context.Background(), nil, wsURL(srv), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.WriteClose(CloseGoingAway, "bye"); err != nil {
+		t.Fatal(err)
+	}
+	var closeErr *CloseError
+	if err := <-closed; !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway || closeErr.Text != "bye" {
+		t.Fatalf("server got %v", err)
+	}
+	_, _, err = conn.ReadMessage()
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway {
+		t.Fatalf("client got %v", err)
+	}
+	if err = conn.WriteMessage(TextMessage, []byte("late")); !errors.Is(err, ErrCloseSent) {
+		t.Errorf("got %v, want ErrCloseSent", err)
+	}
+	conn.Close()
+}
+
+func TestReadLimit(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		result := make(chan error, 1)
+		srv := httptest.NewServer(Handler(&Upgrader{ReadLimit: 100, EnableCompression: true}, func(conn *Conn, r *http.Request) {
+			_, _, err := conn.ReadMessage()
+			result <- err
+		}))
+		conn, _, err := Dial(context.Background(), nil, wsURL(srv), &DialOptions{EnableCompression: compress})
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.WriteMessage(TextMessage, bytes.Repeat([]byte("a"), 101))
+		if err := <-result; !errors.Is(err, ErrReadLimit) {
+			t.Errorf("compress %v: got %v, want ErrReadLimit", compress, err)
+		}
+		var closeErr *CloseError
+		if _, _, err = conn.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != CloseMessageTooBig {
+			t.Errorf("compress %v: client got %v", compress, err)
+		}
+		conn.Close()
+		srv.Close()
+	}
+}
+
+func TestForgedLength(t *testing.T) {
+	// 2^62 字节的帧头, 载荷从未发送
+	header := []byte{0x82, 0x80 | 127, 0x40, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4}
+	for _, limit := range []int64{0, -1} {
+		result := make(chan error, 1)
+		srv := httptest.NewServer(Handler(&Upgrader{ReadLimit: limit}, func(conn *Conn, r *http.Request) {
+			_, _, err := conn.ReadMessage()
+			result <- err
+		}))
+		conn, _, err := Dial(context.Background(), nil, wsURL(srv), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = conn.rwc.Write(header); err != nil {
+			t.Fatal(err)
+		}
+		if limit < 0 {
+			// 无限制时只在载荷到达时分配, 连接关闭后读取失败
+			time.Sleep(50 * time.Millisecond)
+			conn.Close()
+		}
+		select {
+		case err = <-result:
+			if limit == 0 && !errors.Is(err, ErrReadLimit) {
+				t.Errorf("default limit: got %v, want ErrReadLimit", err)
+			}
+			if limit < 0 && err == nil {
+				t.Error("no limit: want an error after the connection closed")
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("limit %d: ReadMessage did not return", limit)
+		}
+		conn.Close()
+		srv.Close()
+	}
+}
+
+func TestPingPongKeepAlive(t *testing.T) {
+	pongs := make(chan []byte, 4)
+	srv := httptest.NewServer(Handler(&Upgrader{}, func(conn *Conn, r *http.Request) {
+		conn.SetPongHandler(func(data []byte) error {
+			pongs <- data
+			return nil
+		})
+		conn.WriteControl(PingMessage, []byte("hi"))
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+
+	conn, _, err := Dial(context.Background(), nil, wsURL(srv), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// 客户端读取时自动回复 pong
+	go conn.ReadMessage()
+	select {
+	case data := <-pongs:
+		if string(data) != "hi" {
+			t.Errorf("got pong %q", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no pong")
+	}
+
+	// 对端不再读取,keepalive 超时后关闭连接
+	silent := httptest.NewServer(Handler(&Upgrader{}, func(conn *Conn, r *http.Request) {
+		time.Sleep(time.Second)
+	}))
+	defer silent.Close()
+	idle, _, err := Dial(context.Background(), nil, wsURL(silent), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle.KeepAlive(20*time.Millisecond, 20*time.Millisecond)
+	select {
+	case <-idle.done:
+	case <-time.After(time.Second):
+		t.Fatal("keepalive did not close the idle connection")
+	}
+}
+
+func TestSubprotocolAndOrigin(t *testing.T) {
+	srv := echoServer(t, &Upgrader{Subprotocols: []string{"v2", "v1"}}, false)
+	conn, _, err := Dial(context.Background(), nil, wsURL(srv), &DialOptions{Subprotocols: []string{"v1", "v2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.Subprotocol() != "v2" {
+		t.Errorf("got subprotocol %q", conn.Subprotocol())
+	}
+	conn.Close()
+
+	_, resp, err := Dial(context.Background(), nil, wsURL(srv), &DialOptions{Header: http.Header{"Origin": {"http://evil.example"}}})
+	if !errors.Is(err, ErrBadHandshake) || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("got %v", err)
+	}
+	if _, err = http.Get(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMessagesJSON(t *testing.T) {
+	type msg struct {
+		N int `json:"n"`
+	}
+	srv := httptest.NewServer(Handler(&Upgrader{}, func(conn *Conn, r *http.Request) {
+		for i := range 3 {
+			conn.WriteJSON(msg{N: i + 1})
+		}
+	}))
+	defer srv.Close()
+
+	conn, _, err := Dial(context.Background(), nil, wsURL(srv), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var sum int
+	var closeErr *CloseError
+	for m, err := range Messages[msg](conn) {
+		if err != nil {
+			if !errors.As(err, &closeErr) || closeErr.Code != CloseNormalClosure {
+				t.Fatal(err)
+			}
+			break
+		}
+		sum += m.N
+	}
+	if sum != 6 {
+		t.Errorf("got sum %d", sum)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	result := make(chan error, 1)
+	srv := httptest.NewServer(Handler(&Upgrader{}, func(conn *Conn, r *http.Request) {
+		_, _, err := conn.ReadMessage()
+		result <- err
+	}))
+	defer srv.Close()
+	conn, _, err := Dial(context.Background(), nil, wsURL(srv), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// 无效 UTF-8 文本
+	conn.writeFrame(true, false, byte(TextMessage), []byte{0xff, 0xfe})
+	var protoErr *ProtocolError
+	if err := <-result; !errors.As(err, &protoErr) {
+		t.Errorf("got %v, want ProtocolError", err)
+	}
+	var closeErr *CloseError
+	if _, _, err = conn.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != CloseInvalidFramePayloadData {
+		t.Errorf("got %v", err)
+	}
+}
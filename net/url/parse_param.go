@@ -70,8 +70,19 @@ func parseParamByTag(param any, query stdurl.Values, tag string) {
 	if v.Kind() == reflect.Struct {
 		t := v.Type()
 		for i := range v.NumField() {
+			if t.Field(i).Tag.Get(tag) == "-" {
+				continue
+			}
 			filed := v.Field(i)
 			fieldKind := filed.Kind()
+			if (fieldKind == reflect.Interface || fieldKind == reflect.Ptr) && !t.Field(i).Anonymous {
+				// 可选参数用指针,nil 不编码
+				if filed.IsNil() {
+					continue
+				}
+				filed = filed.Elem()
+				fieldKind = filed.Kind()
+			}
 			if fieldKind == reflect.Interface || fieldKind == reflect.Ptr || fieldKind == reflect.Struct {
 				if t.Field(i).Anonymous {
 					parseParamByTag(filed.Interface(), query, tag)
@@ -95,7 +106,7 @@ func parseParamByTag(param any, query stdurl.Values, tag string) {
 
 			value := getFieldValue(filed)
 			if value != "" {
-				query.Set(t.Field(i).Tag.Get(tag), getFieldValue(v.Field(i)))
+				query.Set(t.Field(i).Tag.Get(tag), value)
 			}
 		}
 	}
@@ -122,7 +133,8 @@ func getFieldValue(v reflect.Value) string {
 	case reflect.Float32, reflect.Float64:
 		return math.FormatFloat(v.Float())
 	case reflect.String:
-		return stdurl.QueryEscape(v.String())
+		// 由 url.Values.Encode 转义,这里转义会重复编码
+		return v.String()
 	case reflect.Interface, reflect.Ptr, reflect.Struct:
 		panic("unsupported kind " + v.Kind().String())
 	default:
@@ -148,6 +160,9 @@ func AppendQueryParamByTag(url string, param interface{}, tag string) string {
 		url += sep + stringsx.FromBytes(paramt)
 	default:
 		params := QueryParamByTag(param, tag)
+		if params == "" {
+			return url
+		}
 		url += sep + params
 	}
 	return url
@@ -0,0 +1,23 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package url
+
+import "testing"
+
+func TestQueryParamByTag(t *testing.T) {
+	limit := 10
+	var param = struct {
+		Q      string   `query:"q"`
+		Limit  *int     `query:"limit"`
+		Offset *int     `query:"offset"`
+		Tags   []string `query:"tags"`
+		Token  string   `query:"-"`
+	}{Q: "a b&c", Limit: &limit, Tags: []string{"x", "y/z"}, Token: "t"}
+	if got, want := QueryParamByTag(&param, "query"), "limit=10&q=a+b%26c&tags=x&tags=y%2Fz"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
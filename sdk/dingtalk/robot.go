@@ -7,11 +7,10 @@
 package dingtalk
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -46,16 +45,34 @@ func RobotUrl(accessToken, secret string) (string, error) {
 	}
 	if secret != "" {
 		// Sign with the secret key
-		now := time.Now().UnixNano() / int64(time.Millisecond)
-		timestampStr := strconv.FormatInt(now, 10)
-		h := hmac.New(sha256.New, []byte(secret))
-		h.Write([]byte(timestampStr + "\n" + secret))
-		sum := h.Sum(nil)
-		return fmt.Sprintf("robot/send?access_token=%s&timestamp=%s&sign=%s", accessToken, timestampStr, url.QueryEscape(base64.StdEncoding.EncodeToString(sum))), nil
+		req, err := http.NewRequest(http.MethodPost, ROOT+"robot/send?access_token="+url.QueryEscape(accessToken), nil)
+		if err != nil {
+			return "", err
+		}
+		if err = RobotAuth(secret).Authenticate(req); err != nil {
+			return "", err
+		}
+		return strings.TrimPrefix(req.URL.String(), ROOT), nil
 	}
 	return fmt.Sprintf("robot/send?access_token=%s", accessToken), nil
 }
 
+// RobotAuth signs robot webhook requests with the secret, it adds the timestamp and sign query parameters.
+func RobotAuth(secret string) *client.HMACAuth {
+	return &client.HMACAuth{
+		Secret: []byte(secret),
+		StringToSign: func(req *http.Request, timestamp time.Time, body []byte) (string, error) {
+			return strconv.FormatInt(timestamp.UnixMilli(), 10) + "\n" + secret, nil
+		},
+		Apply: func(req *http.Request, timestamp time.Time, signature []byte) {
+			query := req.URL.Query()
+			query.Set("timestamp", strconv.FormatInt(timestamp.UnixMilli(), 10))
+			query.Set("sign", base64.StdEncoding.EncodeToString(signature))
+			req.URL.RawQuery = query.Encode()
+		},
+	}
+}
+
 // RobotSendTextMessage can send a text message to a group chat
 func RobotSendTextMessage(accessToken string, content string) error {
 	return RobotSendTextMessageWithSecret(accessToken, "", content)
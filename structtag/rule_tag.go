@@ -0,0 +1,67 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package structtag
+
+import (
+	"strings"
+)
+
+/*
+RuleTag is a comma separated list of rules, each rule may carry a parameter after "="
+
+	type example struct {
+		Name  string `validate:"required,min=1,max=100"`
+		Kind  string `validate:"oneof=a b c"`
+		Code  string `validate:"regexp=^[a-z]{1\\,3}$"`
+	}
+
+a literal comma inside a parameter is escaped as "\,", which is written as "\\," inside a struct tag
+*/
+type RuleTag string
+
+// Rule is a single entry of RuleTag
+type Rule struct {
+	Name  string
+	Param string
+}
+
+// ParseRuleTag parses the input.
+func ParseRuleTag(tag string) []Rule {
+	return RuleTag(tag).Rules()
+}
+
+// Rules parses the tag into rules, empty entries are skipped.
+func (tag RuleTag) Rules() []Rule {
+	if tag == "" || tag == "-" {
+		return nil
+	}
+	parts := strings.Split(string(tag), ",")
+	rules := make([]Rule, 0, len(parts))
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		// 以 \ 结尾表示逗号被转义，与下一段拼接
+		for len(part) > 0 && part[len(part)-1] == '\\' && i+1 < len(parts) {
+			i++
+			part = part[:len(part)-1] + "," + parts[i]
+		}
+		name, param, _ := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		rules = append(rules, Rule{Name: name, Param: param})
+	}
+	return rules
+}
+
+// String reassembles the rule into its tag representation
+func (r Rule) String() string {
+	if r.Param == "" {
+		return r.Name
+	}
+	return r.Name + "=" + strings.ReplaceAll(r.Param, ",", `\,`)
+}
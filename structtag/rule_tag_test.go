@@ -0,0 +1,42 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package structtag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRuleTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want []Rule
+	}{
+		{"", nil},
+		{"-", nil},
+		{"required", []Rule{{Name: "required"}}},
+		{"required,min=1,max=100", []Rule{{Name: "required"}, {Name: "min", Param: "1"}, {Name: "max", Param: "100"}}},
+		{"oneof=a b c", []Rule{{Name: "oneof", Param: "a b c"}}},
+		{`regexp=^[a-z]{1\,3}$,email`, []Rule{{Name: "regexp", Param: "^[a-z]{1,3}$"}, {Name: "email"}}},
+		{"required,,dive, min=1", []Rule{{Name: "required"}, {Name: "dive"}, {Name: "min", Param: "1"}}},
+	}
+	for _, tt := range tests {
+		if got := ParseRuleTag(tt.tag); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRuleTag(%q) = %#v, want %#v", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestRuleString(t *testing.T) {
+	r := Rule{Name: "regexp", Param: "^a{1,2}$"}
+	if got := r.String(); got != `regexp=^a{1\,2}$` {
+		t.Fatalf("String() = %q", got)
+	}
+	if got := ParseRuleTag(r.String()); !reflect.DeepEqual(got, []Rule{r}) {
+		t.Fatalf("round trip = %#v", got)
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package validate
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/hopeio/gox/structtag"
+)
+
+const (
+	LocaleEn = "en"
+	LocaleZh = "zh"
+)
+
+// FieldError stores information about a failed rule.
+type FieldError struct {
+	// Namespace is the path of the field from the root struct, e.g. "Items[0].Name"
+	Namespace string
+	Field     string
+	Rule      string
+	Param     string
+	Value     any
+	message   string
+	err       error
+}
+
+// Error returns the error message string.
+func (e *FieldError) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return e.message
+}
+
+// Unwrap returns the underlying error.
+func (e *FieldError) Unwrap() error {
+	return e.err
+}
+
+// Errors collects every FieldError of a validation.
+type Errors []*FieldError
+
+// Error returns the error message string.
+func (e Errors) Error() string {
+	var sb strings.Builder
+	for i, err := range e {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+// Unwrap returns the underlying errors.
+func (e Errors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// fieldError renders a FieldError with the message template of the current locale.
+func (v *Validator) fieldError(namespace, field string, rule structtag.Rule, fv reflect.Value) *FieldError {
+	fe := &FieldError{Namespace: namespace, Field: field, Rule: rule.Name, Param: rule.Param}
+	if fv.IsValid() && fv.CanInterface() {
+		fe.Value = fv.Interface()
+	}
+	name := namespace
+	if name == "" {
+		name = field
+	}
+	v.mu.RLock()
+	tmpl, ok := v.messages[v.locale][rule.Name]
+	if !ok {
+		tmpl, ok = v.messages[v.locale][""]
+	}
+	if !ok {
+		tmpl = builtinMessages[LocaleEn][""]
+	}
+	v.mu.RUnlock()
+	fe.message = strings.NewReplacer("{field}", name, "{param}", rule.Param, "{rule}", rule.Name).Replace(tmpl)
+	return fe
+}
+
+// builtinMessages are message templates of builtin rules, "" is the fallback of a locale.
+var builtinMessages = map[string]map[string]string{
+	LocaleEn: {
+		"":         "{field} failed on the '{rule}' rule",
+		"required": "{field} is required",
+		"len":      "{field} length must be {param}",
+		"min":      "{field} must be at least {param}",
+		"max":      "{field} must be at most {param}",
+		"eq":       "{field} must be equal to {param}",
+		"ne":       "{field} must not be equal to {param}",
+		"gt":       "{field} must be greater than {param}",
+		"gte":      "{field} must be greater than or equal to {param}",
+		"lt":       "{field} must be less than {param}",
+		"lte":      "{field} must be less than or equal to {param}",
+		"oneof":    "{field} must be one of [{param}]",
+		"email":    "{field} must be a valid email address",
+		"url":      "{field} must be a valid URL",
+		"regexp":   "{field} must match {param}",
+		"eqfield":  "{field} must be equal to {param}",
+		"nefield":  "{field} must not be equal to {param}",
+		"gtfield":  "{field} must be greater than {param}",
+		"gtefield": "{field} must be greater than or equal to {param}",
+		"ltfield":  "{field} must be less than {param}",
+		"ltefield": "{field} must be less than or equal to {param}",
+	},
+	LocaleZh: {
+		"":         "{field}未通过{rule}校验",
+		"required": "{field}为必填字段",
+		"len":      "{field}长度必须是{param}",
+		"min":      "{field}最小只能为{param}",
+		"max":      "{field}最大只能为{param}",
+		"eq":       "{field}必须等于{param}",
+		"ne":       "{field}不能等于{param}",
+		"gt":       "{field}必须大于{param}",
+		"gte":      "{field}必须大于或等于{param}",
+		"lt":       "{field}必须小于{param}",
+		"lte":      "{field}必须小于或等于{param}",
+		"oneof":    "{field}必须是[{param}]中的一个",
+		"email":    "{field}必须是一个有效的邮箱",
+		"url":      "{field}必须是一个有效的URL",
+		"regexp":   "{field}格式不正确",
+		"eqfield":  "{field}必须等于{param}",
+		"nefield":  "{field}不能等于{param}",
+		"gtfield":  "{field}必须大于{param}",
+		"gtefield": "{field}必须大于或等于{param}",
+		"ltfield":  "{field}必须小于{param}",
+		"ltefield": "{field}必须小于或等于{param}",
+	},
+}
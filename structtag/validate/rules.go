@@ -0,0 +1,206 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package validate
+
+import (
+	"cmp"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var builtinFuncs = map[string]Func{
+	ruleRequired: required,
+	"len":        func(f *Field) bool { return compareParam(f, func(c int) bool { return c == 0 }) },
+	"min":        func(f *Field) bool { return compareParam(f, func(c int) bool { return c >= 0 }) },
+	"max":        func(f *Field) bool { return compareParam(f, func(c int) bool { return c <= 0 }) },
+	"eq":         eq,
+	"ne":         func(f *Field) bool { return !eq(f) },
+	"gt":         func(f *Field) bool { return compareParam(f, func(c int) bool { return c > 0 }) },
+	"gte":        func(f *Field) bool { return compareParam(f, func(c int) bool { return c >= 0 }) },
+	"lt":         func(f *Field) bool { return compareParam(f, func(c int) bool { return c < 0 }) },
+	"lte":        func(f *Field) bool { return compareParam(f, func(c int) bool { return c <= 0 }) },
+	"oneof":      oneof,
+	"email":      email,
+	"url":        isURL,
+	"regexp":     matchRegexp,
+	"eqfield":    func(f *Field) bool { return compareField(f, func(c int) bool { return c == 0 }) },
+	"nefield":    func(f *Field) bool { return compareField(f, func(c int) bool { return c != 0 }) },
+	"gtfield":    func(f *Field) bool { return compareField(f, func(c int) bool { return c > 0 }) },
+	"gtefield":   func(f *Field) bool { return compareField(f, func(c int) bool { return c >= 0 }) },
+	"ltfield":    func(f *Field) bool { return compareField(f, func(c int) bool { return c < 0 }) },
+	"ltefield":   func(f *Field) bool { return compareField(f, func(c int) bool { return c <= 0 }) },
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// required reports whether the value is not empty.
+func required(f *Field) bool {
+	return !isEmpty(f.Value)
+}
+
+// eq compares the value with the param, strings are compared by content.
+func eq(f *Field) bool {
+	if f.Value.Kind() == reflect.String {
+		return f.Value.String() == f.Param
+	}
+	return compareParam(f, func(c int) bool { return c == 0 })
+}
+
+// compareParam compares numbers by value and strings, slices, maps by length.
+func compareParam(f *Field, ok func(int) bool) bool {
+	v := f.Value
+	switch v.Kind() {
+	case reflect.String:
+		n, err := strconv.Atoi(f.Param)
+		return err == nil && ok(cmp.Compare(utf8.RuneCountInString(v.String()), n))
+	case reflect.Slice, reflect.Map, reflect.Array:
+		n, err := strconv.Atoi(f.Param)
+		return err == nil && ok(cmp.Compare(v.Len(), n))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == durationType {
+			d, err := time.ParseDuration(f.Param)
+			return err == nil && ok(cmp.Compare(v.Int(), int64(d)))
+		}
+		n, err := strconv.ParseInt(f.Param, 10, 64)
+		return err == nil && ok(cmp.Compare(v.Int(), n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(f.Param, 10, 64)
+		return err == nil && ok(cmp.Compare(v.Uint(), n))
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(f.Param, 64)
+		return err == nil && ok(cmp.Compare(v.Float(), n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(f.Param)
+		return err == nil && ok(cmp.Compare(boolInt(v.Bool()), boolInt(b)))
+	}
+	return false
+}
+
+// oneof reports whether the value is one of the space separated params.
+func oneof(f *Field) bool {
+	var s string
+	switch f.Value.Kind() {
+	case reflect.String:
+		s = f.Value.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(f.Value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s = strconv.FormatUint(f.Value.Uint(), 10)
+	default:
+		return false
+	}
+	for _, p := range strings.Fields(f.Param) {
+		if p == s {
+			return true
+		}
+	}
+	return false
+}
+
+// email reports whether the value is a bare email address.
+func email(f *Field) bool {
+	if f.Value.Kind() != reflect.String {
+		return false
+	}
+	s := f.Value.String()
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// isURL reports whether the value is an absolute URL.
+func isURL(f *Field) bool {
+	if f.Value.Kind() != reflect.String {
+		return false
+	}
+	u, err := url.Parse(f.Value.String())
+	return err == nil && u.Scheme != "" && (u.Host != "" || u.Opaque != "")
+}
+
+// matchRegexp reports whether the value matches the expression in the param.
+func matchRegexp(f *Field) bool {
+	if f.Value.Kind() != reflect.String {
+		return false
+	}
+	re, err := f.v.regexp(f.Param)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(f.Value.String())
+}
+
+// compareField compares the value with the sibling field named by the param.
+func compareField(f *Field, ok func(int) bool) bool {
+	if !f.Parent.IsValid() || f.Parent.Kind() != reflect.Struct {
+		return false
+	}
+	other := f.Parent.FieldByName(f.Param)
+	for other.Kind() == reflect.Ptr || other.Kind() == reflect.Interface {
+		if other.IsNil() {
+			return false
+		}
+		other = other.Elem()
+	}
+	c, comparable := compareValues(f.Value, other)
+	return comparable && ok(c)
+}
+
+// compareValues compares two values of the same kind.
+func compareValues(a, b reflect.Value) (int, bool) {
+	if !a.IsValid() || !b.IsValid() {
+		return 0, false
+	}
+	if a.Type() == timeType && b.Type() == timeType {
+		return a.Interface().(time.Time).Compare(b.Interface().(time.Time)), true
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch b.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return cmp.Compare(a.Int(), b.Int()), true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch b.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return cmp.Compare(a.Uint(), b.Uint()), true
+		}
+	case reflect.Float32, reflect.Float64:
+		switch b.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return cmp.Compare(a.Float(), b.Float()), true
+		}
+	case reflect.String:
+		if b.Kind() == reflect.String {
+			return cmp.Compare(a.String(), b.String()), true
+		}
+	case reflect.Bool:
+		if b.Kind() == reflect.Bool {
+			return cmp.Compare(boolInt(a.Bool()), boolInt(b.Bool())), true
+		}
+	case reflect.Slice, reflect.Map, reflect.Array:
+		switch b.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Array:
+			return cmp.Compare(a.Len(), b.Len()), true
+		}
+	}
+	return 0, false
+}
+
+// boolInt returns the result.
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
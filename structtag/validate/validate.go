@@ -0,0 +1,349 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sync"
+
+	"github.com/hopeio/gox/structtag"
+)
+
+const (
+	DefaultTag = "validate"
+
+	ruleOmitempty = "omitempty"
+	ruleRequired  = "required"
+	ruleDive      = "dive"
+)
+
+var ErrInvalidValue = errors.New("validate: value must be a struct or a pointer to struct")
+
+// Field is the context passed to a rule function
+type Field struct {
+	// Value is the field value, pointers are already dereferenced
+	Value reflect.Value
+	// Parent is the struct that contains the field, used by cross-field rules
+	Parent reflect.Value
+	// StructField is the field definition, zero for dive elements
+	StructField reflect.StructField
+	// Param is the text after "=" in the rule
+	Param string
+
+	v *Validator
+}
+
+// Func reports whether the field satisfies the rule.
+type Func func(f *Field) bool
+
+// Validator validates structs by the rules declared in struct tags
+type Validator struct {
+	tag      string
+	locale   string
+	mu       sync.RWMutex
+	funcs    map[string]Func
+	messages map[string]map[string]string
+	regexps  sync.Map // string -> *regexp.Regexp
+	cache    sync.Map // reflect.Type -> *structRules
+}
+
+// New returns a new Validator with defaults.
+func New() *Validator {
+	v := &Validator{
+		tag:      DefaultTag,
+		locale:   LocaleEn,
+		funcs:    make(map[string]Func, len(builtinFuncs)),
+		messages: make(map[string]map[string]string, len(builtinMessages)),
+	}
+	for name, fn := range builtinFuncs {
+		v.funcs[name] = fn
+	}
+	for locale, msgs := range builtinMessages {
+		m := make(map[string]string, len(msgs))
+		for rule, msg := range msgs {
+			m[rule] = msg
+		}
+		v.messages[locale] = m
+	}
+	return v
+}
+
+// SetTag changes the tag used to locate rules.
+// The default tag is "validate".
+func (v *Validator) SetTag(tag string) {
+	v.mu.Lock()
+	v.tag = tag
+	v.cache.Clear()
+	v.mu.Unlock()
+}
+
+// SetLocale changes the locale used to render error messages.
+func (v *Validator) SetLocale(locale string) {
+	v.mu.Lock()
+	v.locale = locale
+	v.mu.Unlock()
+}
+
+// Register registers a rule function, an existing rule with the same name is replaced.
+func (v *Validator) Register(name string, fn Func) {
+	v.mu.Lock()
+	v.funcs[name] = fn
+	v.mu.Unlock()
+}
+
+// RegisterMessage registers the message template of a rule for a locale.
+// {field} and {param} in the template are replaced by the field name and the rule parameter.
+func (v *Validator) RegisterMessage(locale, rule, message string) {
+	v.mu.Lock()
+	if v.messages[locale] == nil {
+		v.messages[locale] = make(map[string]string)
+	}
+	v.messages[locale][rule] = message
+	v.mu.Unlock()
+}
+
+// Validate validates a struct, it implements kvstruct.Validator.
+func (v *Validator) Validate(s any) error {
+	return v.Struct(s)
+}
+
+// Struct validates a struct or a pointer to struct, the returned error is of type Errors.
+func (v *Validator) Struct(s any) error {
+	rv := reflect.ValueOf(s)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return ErrInvalidValue
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return ErrInvalidValue
+	}
+	var errs Errors
+	v.validateStruct(rv, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Var validates a single value against a rule tag, e.g. Var(email, "required,email").
+func (v *Validator) Var(value any, tag string) error {
+	var errs Errors
+	rv := reflect.ValueOf(value)
+	v.validateValue(rv, reflect.Value{}, reflect.StructField{}, "", structtag.ParseRuleTag(tag), &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+type structRules struct {
+	fields []fieldRules
+}
+
+type fieldRules struct {
+	index int
+	field reflect.StructField
+	rules []structtag.Rule
+}
+
+// rules returns a cached structRules, creating it if necessary.
+func (v *Validator) rules(t reflect.Type) *structRules {
+	if info, ok := v.cache.Load(t); ok {
+		return info.(*structRules)
+	}
+	v.mu.RLock()
+	tagName := v.tag
+	v.mu.RUnlock()
+	info := &structRules{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() && !sf.Anonymous {
+			continue
+		}
+		tag := sf.Tag.Get(tagName)
+		if tag == "-" {
+			continue
+		}
+		info.fields = append(info.fields, fieldRules{index: i, field: sf, rules: structtag.ParseRuleTag(tag)})
+	}
+	actual, _ := v.cache.LoadOrStore(t, info)
+	return actual.(*structRules)
+}
+
+// validateStruct performs the operation.
+func (v *Validator) validateStruct(rv reflect.Value, namespace string, errs *Errors) {
+	for _, fr := range v.rules(rv.Type()).fields {
+		fv := rv.Field(fr.index)
+		ns := fr.field.Name
+		if fr.field.Anonymous {
+			// 嵌入字段的规则挂在外层命名空间上
+			ns = ""
+		}
+		if namespace != "" {
+			if ns == "" {
+				ns = namespace
+			} else {
+				ns = namespace + "." + ns
+			}
+		}
+		v.validateValue(fv, rv, fr.field, ns, fr.rules, errs)
+	}
+}
+
+// validateValue applies rules to the value, then descends into structs, slices and maps.
+func (v *Validator) validateValue(fv, parent reflect.Value, sf reflect.StructField, namespace string, rules []structtag.Rule, errs *Errors) {
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			break
+		}
+		fv = fv.Elem()
+	}
+
+	diveAt := -1
+	for i, rule := range rules {
+		if rule.Name == ruleDive {
+			diveAt = i
+			break
+		}
+		if rule.Name == ruleOmitempty {
+			if isEmpty(fv) {
+				return
+			}
+			continue
+		}
+		if fv.Kind() == reflect.Ptr && rule.Name != ruleRequired {
+			// 未赋值的指针只校验 required
+			continue
+		}
+		fn := v.fn(rule.Name)
+		if fn == nil {
+			*errs = append(*errs, &FieldError{Namespace: namespace, Field: sf.Name, Rule: rule.Name, Param: rule.Param, err: fmt.Errorf("validate: unknown rule %q", rule.Name)})
+			continue
+		}
+		f := &Field{Value: fv, Parent: parent, StructField: sf, Param: rule.Param, v: v}
+		if !fn(f) {
+			*errs = append(*errs, v.fieldError(namespace, sf.Name, rule, fv))
+		}
+	}
+
+	if !fv.IsValid() || (fv.Kind() == reflect.Ptr && fv.IsNil()) {
+		return
+	}
+
+	var elemRules []structtag.Rule
+	if diveAt >= 0 {
+		elemRules = rules[diveAt+1:]
+	}
+	switch fv.Kind() {
+	case reflect.Struct:
+		if fv.NumField() > 0 && fv.CanInterface() {
+			v.validateStruct(fv, namespace, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			ev := fv.Index(i)
+			if elemRules == nil && !hasStruct(ev.Type()) {
+				break
+			}
+			v.validateValue(ev, parent, reflect.StructField{Name: sf.Name}, fmt.Sprintf("%s[%d]", namespace, i), elemRules, errs)
+		}
+	case reflect.Map:
+		if elemRules == nil && !hasStruct(fv.Type().Elem()) {
+			return
+		}
+		iter := fv.MapRange()
+		for iter.Next() {
+			v.validateValue(iter.Value(), parent, reflect.StructField{Name: sf.Name}, fmt.Sprintf("%s[%v]", namespace, iter.Key().Interface()), elemRules, errs)
+		}
+	}
+}
+
+// fn returns the registered rule function.
+func (v *Validator) fn(name string) Func {
+	v.mu.RLock()
+	fn := v.funcs[name]
+	v.mu.RUnlock()
+	return fn
+}
+
+// regexp returns a cached compiled expression.
+func (v *Validator) regexp(expr string) (*regexp.Regexp, error) {
+	if re, ok := v.regexps.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	v.regexps.Store(expr, re)
+	return re, nil
+}
+
+// hasStruct reports whether values of t may contain nested rules.
+func hasStruct(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Interface:
+		return true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return hasStruct(t.Elem())
+	}
+	return false
+}
+
+// isEmpty reports whether the value is the zero value, nil or has no elements.
+func isEmpty(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+var defaultValidator = New()
+
+// Default returns the package level Validator.
+func Default() *Validator {
+	return defaultValidator
+}
+
+// Struct validates a struct with the package level Validator.
+func Struct(s any) error {
+	return defaultValidator.Struct(s)
+}
+
+// Var validates a single value with the package level Validator.
+func Var(value any, tag string) error {
+	return defaultValidator.Var(value, tag)
+}
+
+// Register registers a rule function on the package level Validator.
+func Register(name string, fn Func) {
+	defaultValidator.Register(name, fn)
+}
+
+// RegisterMessage registers a message template on the package level Validator.
+func RegisterMessage(locale, rule, message string) {
+	defaultValidator.RegisterMessage(locale, rule, message)
+}
+
+// SetLocale changes the locale of the package level Validator.
+func SetLocale(locale string) {
+	defaultValidator.SetLocale(locale)
+}
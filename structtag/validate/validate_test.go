@@ -0,0 +1,152 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hopeio/gox/kvstruct"
+)
+
+type address struct {
+	City string `validate:"required"`
+	Zip  string `validate:"omitempty,len=6"`
+}
+
+type user struct {
+	Name    string            `json:"name" validate:"required,min=2,max=8"`
+	Email   string            `json:"email" validate:"omitempty,email"`
+	Age     int               `json:"age" validate:"gte=0,lte=150"`
+	Kind    string            `json:"kind" validate:"oneof=admin guest"`
+	Code    string            `json:"code" validate:"omitempty,regexp=^[a-z]{2\\,3}$"`
+	Start   int               `json:"start"`
+	End     int               `json:"end" validate:"gtfield=Start"`
+	Tags    []string          `json:"tags" validate:"max=3,dive,required"`
+	Addr    *address          `json:"-"`
+	History []address         `json:"-"`
+	Extra   map[string]string `json:"-" validate:"dive,min=1"`
+}
+
+func validUser() *user {
+	return &user{Name: "alice", Kind: "admin", Start: 1, End: 2}
+}
+
+func rulesOf(err error) []string {
+	var errs Errors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	var rules []string
+	for _, e := range errs {
+		rules = append(rules, e.Namespace+":"+e.Rule)
+	}
+	return rules
+}
+
+func TestStruct(t *testing.T) {
+	if err := Struct(validUser()); err != nil {
+		t.Fatalf("valid user: %v", err)
+	}
+	tests := []struct {
+		name   string
+		modify func(u *user)
+		want   []string
+	}{
+		{"required", func(u *user) { u.Name = "" }, []string{"Name:required", "Name:min"}},
+		{"max", func(u *user) { u.Name = "abcdefghi" }, []string{"Name:max"}},
+		{"email", func(u *user) { u.Email = "nope" }, []string{"Email:email"}},
+		{"lte", func(u *user) { u.Age = 200 }, []string{"Age:lte"}},
+		{"oneof", func(u *user) { u.Kind = "root" }, []string{"Kind:oneof"}},
+		{"regexp", func(u *user) { u.Code = "abcd" }, []string{"Code:regexp"}},
+		{"gtfield", func(u *user) { u.End = 1 }, []string{"End:gtfield"}},
+		{"dive", func(u *user) { u.Tags = []string{"a", ""} }, []string{"Tags[1]:required"}},
+		{"slice len", func(u *user) { u.Tags = []string{"a", "b", "c", "d"} }, []string{"Tags:max"}},
+		{"nested ptr", func(u *user) { u.Addr = &address{Zip: "1"} }, []string{"Addr.City:required", "Addr.Zip:len"}},
+		{"nested slice", func(u *user) { u.History = []address{{City: "x"}, {}} }, []string{"History[1].City:required"}},
+		{"map", func(u *user) { u.Extra = map[string]string{"k": ""} }, []string{"Extra[k]:min"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := validUser()
+			tt.modify(u)
+			if got := rulesOf(Struct(u)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterAndLocale(t *testing.T) {
+	v := New()
+	v.Register("even", func(f *Field) bool { return f.Value.Int()%2 == 0 })
+	v.RegisterMessage(LocaleEn, "even", "{field} must be even")
+	var s struct {
+		N int `validate:"even"`
+	}
+	s.N = 3
+	err := v.Struct(&s)
+	if err == nil || err.Error() != "N must be even" {
+		t.Fatalf("got %v", err)
+	}
+	v.SetLocale(LocaleZh)
+	if err = v.Var("", "required"); err == nil || err.Error() != "为必填字段" {
+		t.Fatalf("got %v", err)
+	}
+	if err = v.Var(1, "unknown"); err == nil || !strings.Contains(err.Error(), "unknown rule") {
+		t.Fatalf("got %v", err)
+	}
+	if err = v.Struct(1); !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestConcurrentSetters(t *testing.T) {
+	v := New()
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Go(func() {
+			for range 50 {
+				if i%2 == 0 {
+					v.SetLocale(LocaleZh)
+					v.SetTag("validate")
+				} else {
+					v.Struct(&user{})
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func TestKvstructDecode(t *testing.T) {
+	d := kvstruct.NewDecoder("json")
+	d.IgnoreUnknownKeys(true)
+	d.SetValidator(New())
+	var u user
+	err := d.Decode(&u, map[string][]string{"name": {"a"}, "kind": {"guest"}})
+	if got := rulesOf(err); !reflect.DeepEqual(got, []string{"Name:min", "End:gtfield"}) {
+		t.Fatalf("got %v", err)
+	}
+	u = user{}
+	if err = d.Decode(&u, map[string][]string{"name": {"bob"}, "kind": {"guest"}, "end": {"1"}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKvstructMapping(t *testing.T) {
+	kvstruct.SetValidator(Default())
+	defer kvstruct.SetValidator(nil)
+	var u user
+	err := kvstruct.Mapping(&u, kvstruct.KVSource{"name": "bob", "kind": "other", "end": "1"}, "json")
+	if got := rulesOf(err); !reflect.DeepEqual(got, []string{"Kind:oneof"}) {
+		t.Fatalf("got %v", err)
+	}
+}
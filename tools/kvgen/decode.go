@@ -0,0 +1,348 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// cacheField mirrors the fieldInfo of the kvstruct cache.
+type cacheField struct {
+	// access is the selector from the struct, promoted fields include the embedded field
+	access    string
+	alias     string
+	canonical string
+	typ       types.Type
+	anonymous bool
+	required  bool
+	// settable is false when the path passes an unexported field, the Decoder skips those silently
+	settable bool
+}
+
+// cacheInfo mirrors cache.create, promoted fields of embedded structs are appended after the direct fields.
+func (g *generator) cacheInfo(st *types.Struct, parentAlias string) []*cacheField {
+	var fields []*cacheField
+	var anonymous [][]*cacheField
+	for i := 0; i < st.NumFields(); i++ {
+		sf := st.Field(i)
+		ft := g.parseFieldTag(st, i)
+		if ft.alias == "-" || !supported(sf.Type()) {
+			continue
+		}
+		canonical := ft.alias
+		if parentAlias != "" {
+			canonical = parentAlias + "." + ft.alias
+		}
+		f := &cacheField{
+			access:    sf.Name(),
+			alias:     ft.alias,
+			canonical: canonical,
+			typ:       sf.Type(),
+			anonymous: sf.Anonymous(),
+			required:  ft.opts != nil && ft.opts.Required,
+			settable:  sf.Exported() || sf.Anonymous(),
+		}
+		fields = append(fields, f)
+		if fst, ok := structOf(sf.Type()); ok && sf.Anonymous() {
+			promoted := g.cacheInfo(fst, canonical)
+			for _, p := range promoted {
+				p.access = sf.Name() + "." + p.access
+				p.settable = p.settable && f.settable
+			}
+			anonymous = append(anonymous, promoted)
+		}
+	}
+	direct := fields
+	for i, a := range anonymous {
+		for _, f := range a {
+			if containsAlias(direct, f.alias) {
+				continue
+			}
+			conflict := false
+			for j, other := range anonymous {
+				if j != i && containsAlias(other, f.alias) {
+					conflict = true
+					break
+				}
+			}
+			if !conflict {
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields
+}
+
+// containsAlias reports whether the condition holds.
+func containsAlias(fields []*cacheField, alias string) bool {
+	for _, f := range fields {
+		if strings.EqualFold(f.alias, alias) {
+			return true
+		}
+	}
+	return false
+}
+
+// paths mirrors fieldInfo.paths.
+func (f *cacheField) paths(prefix string) []string {
+	if f.alias == f.canonical {
+		return []string{prefix + f.alias}
+	}
+	return []string{prefix + f.alias, prefix + f.canonical}
+}
+
+type decodeCase struct {
+	path   string
+	allocs []string
+	target string
+	typ    types.Type
+	// structKey marks the path of a struct itself, which has no converter
+	structKey bool
+	noop      bool
+}
+
+// decodeCases walks the struct the same way parsePath resolves keys.
+func (g *generator) decodeCases(st *types.Struct, prefix, access string, allocs []string, settable bool, visiting map[*types.Struct]bool, seen map[string]bool, cases *[]decodeCase) error {
+	if visiting[st] {
+		return fmt.Errorf("recursive struct types are not supported")
+	}
+	visiting[st] = true
+	defer delete(visiting, st)
+
+	for _, f := range g.cacheInfo(st, "") {
+		path := prefix + strings.ToLower(f.alias)
+		target := access + "." + f.access
+		fieldSettable := settable && f.settable
+		elem, isPtr := deref(f.typ)
+		if fst, ok := elem.Underlying().(*types.Struct); ok && !isTextUnmarshaler(elem) {
+			if f.anonymous && isPtr {
+				return fmt.Errorf("field %s: embedded struct pointers are not supported", f.access)
+			}
+			fieldAllocs := allocs
+			if isPtr && fieldSettable {
+				fieldAllocs = append(append([]string(nil), allocs...), target)
+			}
+			if !seen[path] {
+				seen[path] = true
+				*cases = append(*cases, decodeCase{path: path, allocs: fieldAllocs, target: target, typ: elem, structKey: true, noop: !fieldSettable})
+			}
+			if err := g.decodeCases(fst, path+".", target, fieldAllocs, fieldSettable, visiting, seen, cases); err != nil {
+				return err
+			}
+			continue
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		if !fieldSettable {
+			*cases = append(*cases, decodeCase{path: path, noop: true})
+			continue
+		}
+		if err := g.checkDecodeType(f.typ); err != nil {
+			return fmt.Errorf("field %s: %w", f.access, err)
+		}
+		*cases = append(*cases, decodeCase{path: path, allocs: allocs, target: target, typ: f.typ})
+	}
+	return nil
+}
+
+// checkDecodeType reports types the generator doesn't handle.
+func (g *generator) checkDecodeType(t types.Type) error {
+	t, _ = deref(t)
+	if isTextUnmarshaler(t) {
+		return nil
+	}
+	if hasMethod(t, "UnmarshalText") {
+		return fmt.Errorf("%s: value receiver UnmarshalText is not supported", t)
+	}
+	if _, ok := basic(t); ok {
+		return nil
+	}
+	if s, ok := t.Underlying().(*types.Slice); ok {
+		if _, ok := s.Elem().Underlying().(*types.Pointer); ok {
+			return fmt.Errorf("%s: slices of pointers are not supported", t)
+		}
+		if isTextUnmarshaler(s.Elem()) || hasMethod(s.Elem(), "UnmarshalText") {
+			return fmt.Errorf("%s: slices of TextUnmarshaler are not supported", t)
+		}
+		if _, ok := basic(s.Elem()); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: type is not supported", t)
+}
+
+type requiredField struct {
+	paths  []string
+	scalar bool
+}
+
+// requiredFields mirrors Decoder.findRequiredFields.
+func (g *generator) requiredFields(st *types.Struct, canonicalPrefix, searchPrefix string, m map[string][]requiredField) {
+	for _, f := range g.cacheInfo(st, "") {
+		if fst, ok := f.typ.Underlying().(*types.Struct); ok {
+			fcprefix := canonicalPrefix + f.canonical + "."
+			for _, fspath := range f.paths(searchPrefix) {
+				g.requiredFields(fst, fcprefix, fspath+".", m)
+			}
+		}
+		if f.required {
+			key := canonicalPrefix + f.canonical
+			m[key] = append(m[key], requiredField{paths: f.paths(searchPrefix), scalar: isScalarKind(f.typ)})
+		}
+	}
+}
+
+// decodeKV writes the DecodeKV method.
+func (g *generator) decodeKV(name string, st *types.Struct) error {
+	var cases []decodeCase
+	if err := g.decodeCases(st, "", "x", nil, true, map[*types.Struct]bool{}, map[string]bool{}, &cases); err != nil {
+		return err
+	}
+	required := map[string][]requiredField{}
+	g.requiredFields(st, "", "", required)
+
+	kvstruct := g.use("github.com/hopeio/gox/kvstruct")
+	multierr := g.use("go.uber.org/multierr")
+
+	g.printf("\n// DecodeKV implements kvstruct.KVDecoder.\n")
+	g.printf("func (x *%s) DecodeKV(src map[string][]string) error {\n", name)
+	g.printf("var errs error\n")
+	usesValues := false
+	for _, c := range cases {
+		usesValues = usesValues || !c.noop
+	}
+	if usesValues {
+		g.printf("for key, values := range src {\n")
+	} else {
+		g.printf("for key := range src {\n")
+	}
+	g.printf("switch %s.ToLower(key) {\n", g.use("strings"))
+	for _, c := range cases {
+		g.printf("case %q:\n", c.path)
+		if c.noop {
+			continue
+		}
+		for _, alloc := range c.allocs {
+			g.printf("if %s == nil {\n%s = new(%s)\n}\n", alloc, alloc, g.typeString(mustElem(g.typeOfSelector(alloc, st))))
+		}
+		if c.structKey {
+			g.printf("if %s.LastValue(values) != \"\" {\n", kvstruct)
+			g.printf("errs = %s.Append(errs, %s.Errorf(\"schema: converter not found for %%v\", %s))\n", multierr, g.use("fmt"), g.typeFor(c.typ))
+			g.printf("}\n")
+			continue
+		}
+		g.decodeLeaf(c.target, c.typ)
+	}
+	g.printf("default:\n")
+	g.printf("errs = %s.Append(errs, %s.UnknownKeyError{Key: key})\n", multierr, kvstruct)
+	g.printf("}\n}\n")
+
+	keys := make([]string, 0, len(required))
+	for key := range required {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var conds []string
+		for _, f := range required[key] {
+			conds = append(conds, fmt.Sprintf("!%s.HasValue(src, %t, %s)", kvstruct, f.scalar, quoteAll(f.paths)))
+		}
+		g.printf("if %s {\n", strings.Join(conds, " && "))
+		g.printf("errs = %s.Append(errs, %s.EmptyFieldError{Key: %q})\n", multierr, kvstruct, key)
+		g.printf("}\n")
+	}
+	g.printf("return errs\n}\n")
+	return nil
+}
+
+// decodeLeaf writes the conversion of a single field, mirroring Decoder.decode.
+func (g *generator) decodeLeaf(target string, t types.Type) {
+	kvstruct := g.use("github.com/hopeio/gox/kvstruct")
+	multierr := g.use("go.uber.org/multierr")
+	if elem, isPtr := deref(t); isPtr {
+		g.printf("if %s == nil {\n%s = new(%s)\n}\n", target, target, g.typeString(elem))
+		target = "*" + target
+		t = elem
+	}
+
+	if isTextUnmarshaler(t) {
+		g.printf("var v %s\n", g.typeString(t))
+		g.printf("if err := v.UnmarshalText([]byte(%s.LastValue(values))); err != nil {\n", kvstruct)
+		g.printf("errs = %s.Append(errs, %s.ConversionError{Key: key, Type: %s, Index: -1, Err: err})\n", multierr, kvstruct, g.typeFor(t))
+		g.printf("} else {\n%s = v\n}\n", target)
+		return
+	}
+
+	if b, ok := basic(t); ok {
+		g.printf("if val := %s.LastValue(values); val != \"\" {\n", kvstruct)
+		if b.Info()&types.IsString != 0 {
+			g.printf("%s = %s(val)\n", target, g.typeString(t))
+		} else {
+			g.printf("if v, err := %s; err == nil {\n", g.parseCall(b, "val"))
+			g.printf("%s = %s(v)\n", target, g.typeString(t))
+			g.printf("} else {\n")
+			g.printf("errs = %s.Append(errs, %s.ConversionError{Key: key, Type: %s, Index: -1})\n", multierr, kvstruct, g.typeFor(t))
+			g.printf("}\n")
+		}
+		g.printf("}\n")
+		return
+	}
+
+	s := t.Underlying().(*types.Slice)
+	elem := s.Elem()
+	b, _ := basic(elem)
+	elemType := g.typeString(elem)
+	g.printf("items := make(%s, 0, len(values))\n", g.typeString(t))
+	g.printf("var err error\n")
+	if b.Info()&types.IsString != 0 {
+		g.printf("for _, value := range values {\n")
+		g.printf("if value == \"\" {\ncontinue\n}\n")
+		g.printf("items = append(items, %s(value))\n", elemType)
+	} else {
+		g.printf("for i, value := range values {\n")
+		g.printf("if value == \"\" {\ncontinue\n}\n")
+		g.printf("if v, e := %s; e == nil {\n", g.parseCall(b, "value"))
+		g.printf("items = append(items, %s(v))\ncontinue\n}\n", elemType)
+		g.printf("if !%s.Contains(value, \",\") {\n", g.use("strings"))
+		g.printf("err = %s.ConversionError{Key: key, Type: %s, Index: i}\nbreak\n}\n", kvstruct, g.typeFor(elem))
+		g.printf("for _, value := range %s.Split(value, \",\") {\n", g.use("strings"))
+		g.printf("if value == \"\" {\ncontinue\n}\n")
+		g.printf("v, e := %s\n", g.parseCall(b, "value"))
+		g.printf("if e != nil {\n")
+		g.printf("err = %s.ConversionError{Key: key, Type: %s, Index: i}\nbreak\n}\n", kvstruct, g.typeFor(elem))
+		g.printf("items = append(items, %s(v))\n}\n", elemType)
+		g.printf("if err != nil {\nbreak\n}\n")
+	}
+	g.printf("}\n")
+	g.printf("if err != nil {\nerrs = %s.Append(errs, err)\n} else {\n%s = items\n}\n", multierr, target)
+}
+
+// typeOfSelector resolves the type of a selector like "x.A.B" from the root struct.
+func (g *generator) typeOfSelector(sel string, root *types.Struct) types.Type {
+	var t types.Type = root
+	for _, name := range strings.Split(sel, ".")[1:] {
+		st, _ := structOf(t)
+		for i := 0; i < st.NumFields(); i++ {
+			if st.Field(i).Name() == name {
+				t = st.Field(i).Type()
+				break
+			}
+		}
+	}
+	return t
+}
+
+// mustElem returns the element of a pointer type.
+func mustElem(t types.Type) types.Type {
+	elem, _ := deref(t)
+	return elem
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"strconv"
+)
+
+// encodeKV writes the EncodeKV method.
+func (g *generator) encodeKV(name string, st *types.Struct) error {
+	g.printf("\n// EncodeKV implements kvstruct.KVEncoder.\n")
+	g.printf("func (x %s) EncodeKV(dst map[string][]string) error {\n", name)
+	if err := g.encodeStruct(st, "", "x", map[*types.Struct]bool{}); err != nil {
+		return err
+	}
+	g.printf("return nil\n}\n")
+	return nil
+}
+
+// encodeStruct mirrors Encoder.encode.
+func (g *generator) encodeStruct(st *types.Struct, prefix, access string, visiting map[*types.Struct]bool) error {
+	if visiting[st] {
+		return fmt.Errorf("recursive struct types are not supported")
+	}
+	visiting[st] = true
+	defer delete(visiting, st)
+
+	for i := 0; i < st.NumFields(); i++ {
+		sf := st.Field(i)
+		if !sf.Exported() && !sf.Anonymous() {
+			continue
+		}
+		ft := g.parseFieldTag(st, i)
+		if ft.alias == "-" {
+			continue
+		}
+		omitempty := ft.opts != nil && ft.opts.Omitempty
+		target := access + "." + sf.Name()
+
+		if elem, isPtr := deref(sf.Type()); sf.Anonymous() && !g.isScalar(elem) {
+			if fst, ok := elem.Underlying().(*types.Struct); ok {
+				if isPtr {
+					g.printf("if %s != nil {\n", target)
+				}
+				if err := g.encodeStruct(fst, prefix, target, visiting); err != nil {
+					return err
+				}
+				if isPtr {
+					g.printf("}\n")
+				}
+				continue
+			}
+		}
+		if err := g.encodeValue(sf.Type(), prefix+ft.alias, omitempty, target, visiting); err != nil {
+			return fmt.Errorf("field %s: %w", sf.Name(), err)
+		}
+	}
+	return nil
+}
+
+// encodeValue mirrors Encoder.encodeValue.
+func (g *generator) encodeValue(t types.Type, key string, omitempty bool, expr string, visiting map[*types.Struct]bool) error {
+	elem, isPtr := deref(t)
+	if g.isScalar(t) {
+		closing := 0
+		if isPtr && !isTextMarshaler(t) {
+			// nil pointers are omitted, which also covers omitempty
+			g.printf("if %s != nil {\n", expr)
+			closing++
+			t, expr = elem, "(*"+expr+")"
+		} else if omitempty {
+			zero, err := g.isZeroExpr(t, expr)
+			if err != nil {
+				return err
+			}
+			g.printf("if !(%s) {\n", zero)
+			closing++
+		}
+		g.encodeScalar(t, strconv.Quote(key), expr)
+		for ; closing > 0; closing-- {
+			g.printf("}\n")
+		}
+		return nil
+	}
+
+	if isPtr {
+		g.printf("if %s != nil {\n", expr)
+		if _, ok := elem.Underlying().(*types.Struct); !ok {
+			expr = "(*" + expr + ")"
+		}
+		if err := g.encodeValue(elem, key, omitempty, expr, visiting); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		return g.encodeStruct(u, key+".", expr, visiting)
+	case *types.Slice, *types.Array:
+		var elemType types.Type
+		if s, ok := u.(*types.Slice); ok {
+			elemType = s.Elem()
+		} else {
+			elemType = u.(*types.Array).Elem()
+		}
+		if !g.hasTypeEncoder(elemType) {
+			return fmt.Errorf("%s: slices of structs are not supported", t)
+		}
+		if omitempty {
+			g.printf("if len(%s) != 0 {\n", expr)
+		}
+		g.printf("dst[%q] = []string{}\n", key)
+		g.printf("for _, e := range %s {\n", expr)
+		g.encodeScalar(elemType, strconv.Quote(key), "e")
+		g.printf("}\n")
+		if omitempty {
+			g.printf("}\n")
+		}
+		return nil
+	}
+	return fmt.Errorf("%s: type is not supported", t)
+}
+
+// isScalar mirrors Encoder.isScalar without registered encoders.
+func (g *generator) isScalar(t types.Type) bool {
+	if isTextMarshaler(t) {
+		return true
+	}
+	elem, _ := deref(t)
+	if isTextMarshaler(elem) {
+		return true
+	}
+	switch elem.Underlying().(type) {
+	case *types.Struct, *types.Slice, *types.Array, *types.Map:
+		return false
+	}
+	return g.hasTypeEncoder(t)
+}
+
+// hasTypeEncoder mirrors typeEncoder(t) != nil.
+func (g *generator) hasTypeEncoder(t types.Type) bool {
+	if isTextMarshaler(t) {
+		return true
+	}
+	if elem, isPtr := deref(t); isPtr {
+		return g.hasTypeEncoder(elem)
+	}
+	_, ok := basic(t)
+	return ok
+}
+
+// encodeScalar appends the encoded value of expr to dst[key], mirroring typeEncoder.
+func (g *generator) encodeScalar(t types.Type, key, expr string) {
+	if isTextMarshaler(t) {
+		if _, isPtr := deref(t); isPtr {
+			g.printf("if %s == nil {\ndst[%s] = append(dst[%s], \"null\")\n} else {\n", expr, key, key)
+			g.printf("dst[%s] = append(dst[%s], %s.TextString(%s))\n}\n", key, key, g.use("github.com/hopeio/gox/kvstruct"), expr)
+			return
+		}
+		g.printf("dst[%s] = append(dst[%s], %s.TextString(%s))\n", key, key, g.use("github.com/hopeio/gox/kvstruct"), expr)
+		return
+	}
+	if elem, isPtr := deref(t); isPtr {
+		g.printf("if %s == nil {\ndst[%s] = append(dst[%s], \"null\")\n} else {\n", expr, key, key)
+		g.encodeScalar(elem, key, "(*"+expr+")")
+		g.printf("}\n")
+		return
+	}
+	b, _ := basic(t)
+	strconvPkg := g.use("strconv")
+	var value string
+	switch {
+	case b.Info()&types.IsString != 0:
+		value = fmt.Sprintf("string(%s)", expr)
+	case b.Info()&types.IsBoolean != 0:
+		value = fmt.Sprintf("%s.FormatBool(bool(%s))", strconvPkg, expr)
+	case b.Info()&types.IsUnsigned != 0:
+		value = fmt.Sprintf("%s.FormatUint(uint64(%s), 10)", strconvPkg, expr)
+	case b.Info()&types.IsInteger != 0:
+		value = fmt.Sprintf("%s.FormatInt(int64(%s), 10)", strconvPkg, expr)
+	case b.Kind() == types.Float32:
+		value = fmt.Sprintf("%s.FormatFloat(float64(%s), 'f', -1, 32)", strconvPkg, expr)
+	default:
+		value = fmt.Sprintf("%s.FormatFloat(float64(%s), 'f', -1, 64)", strconvPkg, expr)
+	}
+	g.printf("dst[%s] = append(dst[%s], %s)\n", key, key, value)
+}
+
+// isZeroExpr mirrors the isZero check of omitempty.
+func (g *generator) isZeroExpr(t types.Type, expr string) (string, error) {
+	if _, isPtr := deref(t); isPtr {
+		return expr + " == nil", nil
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
+			return expr + ` == ""`, nil
+		case u.Info()&types.IsBoolean != 0:
+			return "!" + expr, nil
+		}
+		return expr + " == 0", nil
+	case *types.Slice, *types.Map:
+		return "len(" + expr + ") == 0", nil
+	case *types.Struct:
+		if hasMethod(t, "IsZero") {
+			return expr + ".IsZero()", nil
+		}
+	}
+	if types.Comparable(t) {
+		return fmt.Sprintf("%s == (%s{})", expr, g.typeString(t)), nil
+	}
+	return "", fmt.Errorf("%s: omitempty is not supported", t)
+}
@@ -0,0 +1,325 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hopeio/gox/kvstruct"
+	"golang.org/x/tools/go/packages"
+)
+
+// imports used by generated code, unused ones are dropped after generation.
+var knownImports = [][2]string{
+	{"", "fmt"},
+	{"", "reflect"},
+	{"", "strconv"},
+	{"", "strings"},
+	{"", "time"},
+	{"", "github.com/hopeio/gox/kvstruct"},
+	{"stringsx", "github.com/hopeio/gox/strings"},
+	{"", "go.uber.org/multierr"},
+}
+
+type generator struct {
+	pkg     *types.Package
+	tag     string
+	buf     bytes.Buffer
+	imports map[string]string // path -> name
+	used    map[string]bool
+}
+
+// Generate loads the package in dir and returns the binder source of the named types.
+func Generate(dir, tag string, typeNames ...string) ([]byte, error) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.NeedName | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax,
+		Dir:  dir,
+		// 跳过已生成的文件,过期的生成代码不影响重新生成
+		BuildFlags: []string{"-tags=kvgen"},
+	}, ".")
+	if err != nil {
+		return nil, fmt.Errorf("kvgen: load package: %w", err)
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("kvgen: expected one package in %s, got %d", dir, len(pkgs))
+	}
+	if len(pkgs[0].Errors) > 0 {
+		return nil, fmt.Errorf("kvgen: %v", pkgs[0].Errors[0])
+	}
+	g := &generator{
+		pkg:     pkgs[0].Types,
+		tag:     tag,
+		imports: map[string]string{},
+		used:    map[string]bool{},
+	}
+	for _, name := range typeNames {
+		obj := g.pkg.Scope().Lookup(strings.TrimSpace(name))
+		if obj == nil {
+			return nil, fmt.Errorf("kvgen: type %s not found", name)
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok {
+			return nil, fmt.Errorf("kvgen: %s is not a named type", name)
+		}
+		if _, ok := named.Underlying().(*types.Struct); !ok {
+			return nil, fmt.Errorf("kvgen: %s is not a struct", name)
+		}
+		if err = g.generate(named); err != nil {
+			return nil, fmt.Errorf("kvgen: %s: %w", name, err)
+		}
+	}
+	return g.source()
+}
+
+// source assembles the file and formats it.
+func (g *generator) source() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString("// Code generated by kvgen. DO NOT EDIT.\n\n//go:build !kvgen\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", g.pkg.Name())
+	var std, third []string
+	add := func(name, path string) {
+		spec := fmt.Sprintf("\t%s %q\n", name, path)
+		if strings.Contains(path, ".") {
+			third = append(third, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+	for _, imp := range knownImports {
+		if g.used[imp[1]] {
+			add(imp[0], imp[1])
+		}
+	}
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		add(g.imports[path], path)
+	}
+	out.WriteString("import (\n")
+	out.WriteString(strings.Join(std, ""))
+	if len(std) > 0 && len(third) > 0 {
+		out.WriteString("\n")
+	}
+	out.WriteString(strings.Join(third, ""))
+	out.WriteString(")\n")
+	out.Write(g.buf.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return out.Bytes(), fmt.Errorf("kvgen: format source: %w", err)
+	}
+	return src, nil
+}
+
+// use marks an import as used and returns its name.
+func (g *generator) use(path string) string {
+	g.used[path] = true
+	for _, imp := range knownImports {
+		if imp[1] == path && imp[0] != "" {
+			return imp[0]
+		}
+	}
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// qualifier records imports of types from other packages.
+func (g *generator) qualifier(p *types.Package) string {
+	if p == g.pkg {
+		return ""
+	}
+	for _, imp := range knownImports {
+		if imp[1] == p.Path() {
+			return g.use(p.Path())
+		}
+	}
+	name, ok := g.imports[p.Path()]
+	if !ok {
+		name = p.Name()
+		g.imports[p.Path()] = name
+	}
+	return name
+}
+
+// typeString returns the source representation of t.
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, g.qualifier)
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// generate writes every method of a type.
+func (g *generator) generate(named *types.Named) error {
+	name := named.Obj().Name()
+	st := named.Underlying().(*types.Struct)
+
+	g.printf("\n// KVTag implements kvstruct.KVTagger.\n")
+	g.printf("func (%s) KVTag() string {\n\treturn %q\n}\n", name, g.tag)
+
+	if err := g.decodeKV(name, st); err != nil {
+		return err
+	}
+	if err := g.encodeKV(name, st); err != nil {
+		return err
+	}
+	return g.mappingKV(name, st)
+}
+
+// ----------------------------------------------------------------------------
+// type helpers
+
+type fieldTag struct {
+	alias string
+	opts  *kvstruct.Options
+}
+
+// parseFieldTag mirrors the alias and options resolution of kvstruct.
+func (g *generator) parseFieldTag(st *types.Struct, i int) fieldTag {
+	f := st.Field(i)
+	tag := reflect.StructTag(st.Tag(i)).Get(g.tag)
+	var ft fieldTag
+	if tag != "" {
+		ft.alias, ft.opts = kvstruct.ParseTag(tag)
+	}
+	if ft.alias == "" {
+		ft.alias = f.Name()
+	}
+	return ft
+}
+
+// basic returns the basic underlying type of t.
+func basic(t types.Type) (*types.Basic, bool) {
+	b, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return nil, false
+	}
+	switch {
+	case b.Info()&types.IsString != 0, b.Info()&types.IsBoolean != 0:
+		return b, true
+	case b.Info()&(types.IsInteger|types.IsFloat) != 0 && b.Kind() != types.Uintptr && b.Info()&types.IsUntyped == 0:
+		return b, true
+	}
+	return nil, false
+}
+
+// hasMethod reports whether the method set of t has the named method.
+func hasMethod(t types.Type, name string) bool {
+	sel := types.NewMethodSet(t).Lookup(nil, name)
+	return sel != nil
+}
+
+// isTextUnmarshaler reports whether *t implements encoding.TextUnmarshaler through a pointer receiver.
+func isTextUnmarshaler(t types.Type) bool {
+	if _, ok := t.Underlying().(*types.Pointer); ok {
+		return false
+	}
+	return hasMethod(types.NewPointer(t), "UnmarshalText")
+}
+
+// isTextMarshaler reports whether t implements encoding.TextMarshaler.
+func isTextMarshaler(t types.Type) bool {
+	return hasMethod(t, "MarshalText")
+}
+
+// deref returns the element type of pointers.
+func deref(t types.Type) (types.Type, bool) {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		return p.Elem(), true
+	}
+	return t, false
+}
+
+// structOf returns the struct of t when t is a struct or a pointer to struct.
+func structOf(t types.Type) (*types.Struct, bool) {
+	t, _ = deref(t)
+	st, ok := t.Underlying().(*types.Struct)
+	return st, ok
+}
+
+// supported mirrors the type check of the kvstruct cache, unsupported fields are not decoded.
+func supported(t types.Type) bool {
+	t, _ = deref(t)
+	switch u := t.Underlying().(type) {
+	case *types.Slice:
+		t, _ = deref(u.Elem())
+	case *types.Array:
+		t, _ = deref(u.Elem())
+	case *types.Map:
+		return true
+	}
+	if _, ok := t.Underlying().(*types.Struct); ok {
+		return true
+	}
+	_, ok := basic(t)
+	return ok
+}
+
+// isScalarKind reports whether the declared field type is checked as a single value for required.
+func isScalarKind(t types.Type) bool {
+	b, ok := t.(*types.Basic)
+	if !ok {
+		b, ok = t.Underlying().(*types.Basic)
+	}
+	return ok && b.Info()&(types.IsString|types.IsBoolean|types.IsInteger|types.IsFloat) != 0 && b.Kind() != types.Uintptr
+}
+
+// parseCall returns the expression converting arg the same way as the kvstruct StringConverter of the kind.
+func (g *generator) parseCall(b *types.Basic, arg string) string {
+	switch b.Kind() {
+	case types.Bool:
+		return fmt.Sprintf("%s.ParseBool(%s)", g.use("strconv"), arg)
+	case types.Int:
+		return fmt.Sprintf("%s.Int(%s)", g.use("github.com/hopeio/gox/strings"), arg)
+	case types.Int8:
+		return fmt.Sprintf("%s.Int8(%s)", g.use("github.com/hopeio/gox/strings"), arg)
+	case types.Int16:
+		return fmt.Sprintf("%s.Int16(%s)", g.use("github.com/hopeio/gox/strings"), arg)
+	case types.Int32:
+		return fmt.Sprintf("%s.Int32(%s)", g.use("github.com/hopeio/gox/strings"), arg)
+	case types.Int64:
+		return fmt.Sprintf("%s.ParseInt(%s, 10, 64)", g.use("strconv"), arg)
+	case types.Uint:
+		return fmt.Sprintf("%s.Uint(%s)", g.use("github.com/hopeio/gox/strings"), arg)
+	case types.Uint8:
+		return fmt.Sprintf("%s.Uint8(%s)", g.use("github.com/hopeio/gox/strings"), arg)
+	case types.Uint16:
+		return fmt.Sprintf("%s.Uint16(%s)", g.use("github.com/hopeio/gox/strings"), arg)
+	case types.Uint32:
+		return fmt.Sprintf("%s.Uint32(%s)", g.use("github.com/hopeio/gox/strings"), arg)
+	case types.Uint64:
+		return fmt.Sprintf("%s.ParseUint(%s, 10, 64)", g.use("strconv"), arg)
+	case types.Float32:
+		return fmt.Sprintf("%s.Float32(%s)", g.use("github.com/hopeio/gox/strings"), arg)
+	case types.Float64:
+		return fmt.Sprintf("%s.ParseFloat(%s, 64)", g.use("strconv"), arg)
+	}
+	return ""
+}
+
+// typeFor returns a reflect.TypeFor expression, used only to build errors.
+func (g *generator) typeFor(t types.Type) string {
+	return fmt.Sprintf("%s.TypeFor[%s]()", g.use("reflect"), g.typeString(t))
+}
+
+// quoteAll quotes every string.
+func quoteAll(ss []string) string {
+	q := make([]string, len(ss))
+	for i, s := range ss {
+		q[i] = strconv.Quote(s)
+	}
+	return strings.Join(q, ", ")
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+// kvgen generates reflection-free binders for kvstruct.
+//
+//	//go:generate go run github.com/hopeio/gox/tools/kvgen --type=Query --tag=json
+//
+// The generated DecodeKV, EncodeKV and MappingKV methods follow the rules of
+// kvstruct.Decoder, kvstruct.Encoder and kvstruct.Mapping, which detect and use them.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	typeNames string
+	tag       string
+	output    string
+)
+
+var command = &cobra.Command{
+	Use:   "kvgen [dir]",
+	Short: "generate reflection-free kvstruct binders",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		if typeNames == "" {
+			return fmt.Errorf("kvgen: --type is required")
+		}
+		types := strings.Split(typeNames, ",")
+		src, err := Generate(dir, tag, types...)
+		if err != nil {
+			return err
+		}
+		if output == "" {
+			output = strings.ToLower(types[0]) + "_kv.go"
+		}
+		if !filepath.IsAbs(output) {
+			output = filepath.Join(dir, output)
+		}
+		return os.WriteFile(output, src, 0644)
+	},
+}
+
+func init() {
+	command.Flags().StringVar(&typeNames, "type", "", "comma separated list of struct type names")
+	command.Flags().StringVar(&tag, "tag", "json", "tag used to locate field aliases")
+	command.Flags().StringVarP(&output, "output", "o", "", "output file name; default <type>_kv.go")
+}
+
+// main is the program entry point.
+func main() {
+	if err := command.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"reflect"
+)
+
+// mappingKV writes the MappingKV method.
+func (g *generator) mappingKV(name string, st *types.Struct) error {
+	g.printf("\n// MappingKV implements kvstruct.KVMapper.\n")
+	g.printf("func (x *%s) MappingKV(getter %s.ValuesGetter) error {\n", name, g.use("github.com/hopeio/gox/kvstruct"))
+	var n int
+	if err := g.mappingStruct(st, "x", "", &n, map[*types.Struct]bool{}); err != nil {
+		return err
+	}
+	g.printf("return nil\n}\n")
+	return nil
+}
+
+// mappingStruct mirrors mapping for struct values, setVar is assigned when any field is set.
+func (g *generator) mappingStruct(st *types.Struct, access, setVar string, n *int, visiting map[*types.Struct]bool) error {
+	if visiting[st] {
+		return fmt.Errorf("recursive struct types are not supported")
+	}
+	visiting[st] = true
+	defer delete(visiting, st)
+
+	for i := 0; i < st.NumFields(); i++ {
+		sf := st.Field(i)
+		if !sf.Exported() && !sf.Anonymous() {
+			continue
+		}
+		tagValue := reflect.StructTag(st.Tag(i)).Get(g.tag)
+		if tagValue == "-" {
+			continue
+		}
+		if !sf.Exported() && sf.Pkg() != g.pkg {
+			if _, ok := structOf(sf.Type()); ok {
+				return fmt.Errorf("field %s: unexported embedded struct of another package", sf.Name())
+			}
+			continue
+		}
+		if err := g.mappingValue(sf.Type(), access+"."+sf.Name(), st, i, sf.Anonymous(), setVar, n, visiting); err != nil {
+			return fmt.Errorf("field %s: %w", sf.Name(), err)
+		}
+	}
+	return nil
+}
+
+// mappingValue mirrors mapping for a field value.
+func (g *generator) mappingValue(t types.Type, target string, st *types.Struct, i int, anonymous bool, setVar string, n *int, visiting map[*types.Struct]bool) error {
+	if elem, isPtr := deref(t); isPtr {
+		*n++
+		p, set := fmt.Sprintf("p%d", *n), fmt.Sprintf("set%d", *n)
+		g.printf("{\n%s := %s\n", p, target)
+		g.printf("isNew := %s == nil\n", p)
+		g.printf("if isNew {\n%s = new(%s)\n}\n", p, g.typeString(elem))
+		g.printf("var %s bool\n", set)
+		if err := g.mappingValue(elem, "(*"+p+")", st, i, anonymous, set, n, visiting); err != nil {
+			return err
+		}
+		g.printf("if isNew && %s {\n%s = %s\n}\n", set, target, p)
+		if setVar != "" {
+			g.printf("if %s {\n%s = true\n}\n", set, setVar)
+		}
+		g.printf("}\n")
+		return nil
+	}
+
+	if fst, ok := t.Underlying().(*types.Struct); ok {
+		return g.mappingStruct(fst, target, setVar, n, visiting)
+	}
+	if anonymous {
+		return nil
+	}
+
+	ft := g.parseFieldTag(st, i)
+	g.printf("{\nvals, _ := getter.Get(%q)\n", ft.alias)
+	if ft.opts != nil && ft.opts.Default != "" {
+		g.printf("if len(vals) == 0 {\nvals = %s.Split(%q, \",\")\n}\n", g.use("strings"), ft.opts.Default)
+	}
+	g.printf("if len(vals) > 0 {\n")
+	switch u := t.Underlying().(type) {
+	case *types.Slice:
+		if err := g.checkMappingScalar(u.Elem()); err != nil {
+			return err
+		}
+		g.printf("s := make(%s, len(vals))\n", g.typeString(t))
+		g.printf("for i, val := range vals {\n")
+		g.mappingScalar(u.Elem(), "s[i]", "val")
+		g.printf("}\n")
+		g.printf("%s = s\n", target)
+	default:
+		if err := g.checkMappingScalar(t); err != nil {
+			return err
+		}
+		g.printf("val := vals[0]\n")
+		g.mappingScalar(t, target, "val")
+	}
+	if setVar != "" {
+		g.printf("%s = true\n", setVar)
+	}
+	g.printf("}\n}\n")
+	return nil
+}
+
+// checkMappingScalar reports types the generator doesn't handle.
+func (g *generator) checkMappingScalar(t types.Type) error {
+	if isTextUnmarshaler(t) {
+		return nil
+	}
+	if _, ok := basic(t); ok {
+		return nil
+	}
+	return fmt.Errorf("%s: type is not supported", t)
+}
+
+// mappingScalar mirrors ParseStringSetReflectValue.
+func (g *generator) mappingScalar(t types.Type, target, val string) {
+	g.printf("if %s != \"\" {\n", val)
+	defer g.printf("}\n")
+	if isTextUnmarshaler(t) {
+		g.printf("if err := %s.UnmarshalText([]byte(%s)); err != nil {\nreturn err\n}\n", target, val)
+		return
+	}
+	b, _ := basic(t)
+	strconvPkg := g.use("strconv")
+	var parse string
+	switch {
+	case b.Info()&types.IsString != 0:
+		g.printf("%s = %s(%s)\n", target, g.typeString(t), val)
+		return
+	case b.Info()&types.IsBoolean != 0:
+		parse = fmt.Sprintf("%s.ParseBool(%s)", strconvPkg, val)
+	case b.Kind() == types.Int64 && types.TypeString(t, nil) == "time.Duration":
+		parse = fmt.Sprintf("%s.ParseDuration(%s)", g.use("time"), val)
+	case b.Info()&types.IsUnsigned != 0:
+		parse = fmt.Sprintf("%s.ParseUint(%s, 10, %d)", strconvPkg, val, bitSize(b))
+	case b.Info()&types.IsInteger != 0:
+		parse = fmt.Sprintf("%s.ParseInt(%s, 10, %d)", strconvPkg, val, bitSize(b))
+	default:
+		parse = fmt.Sprintf("%s.ParseFloat(%s, %d)", strconvPkg, val, bitSize(b))
+	}
+	g.printf("v, err := %s\n", parse)
+	g.printf("if err != nil {\nreturn err\n}\n")
+	g.printf("%s = %s(v)\n", target, g.typeString(t))
+}
+
+// bitSize returns the bit size passed to strconv by the kvstruct setters, 0 for int and uint.
+func bitSize(b *types.Basic) int {
+	switch b.Kind() {
+	case types.Int8, types.Uint8:
+		return 8
+	case types.Int16, types.Uint16:
+		return 16
+	case types.Int32, types.Uint32, types.Float32:
+		return 32
+	case types.Int64, types.Uint64, types.Float64:
+		return 64
+	}
+	return 0
+}
@@ -0,0 +1,762 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// imports used by generated code, unused ones are dropped after generation.
+var knownImports = [][2]string{
+	{"", "context"},
+	{"", "encoding/json"},
+	{"", "errors"},
+	{"", "fmt"},
+	{"", "io"},
+	{"", "net/url"},
+	{"", "time"},
+	{"", "github.com/hopeio/gox/net/http/client"},
+	{"urlx", "github.com/hopeio/gox/net/url"},
+}
+
+var methodOrder = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodOptions, http.MethodTrace}
+
+type model struct {
+	name   string
+	schema *openapi3.Schema
+}
+
+type generator struct {
+	doc *openapi3.T
+	pkg string
+	// models and ops are written separately, operations register the inline models they use
+	models bytes.Buffer
+	ops    bytes.Buffer
+	used   map[string]bool
+	// typeNames holds the type and constant names, methodNames the Client method names
+	typeNames   names
+	methodNames names
+	named       map[*openapi3.Schema]string
+	queue       []model
+	errorModels map[string]bool
+	decodeError bool
+}
+
+// Generate returns the source of the models and the client of the document.
+func Generate(doc *openapi3.T, pkg string) ([]byte, error) {
+	g := &generator{
+		doc:         doc,
+		pkg:         pkg,
+		used:        map[string]bool{},
+		typeNames:   names{"Client": true, "NewClient": true, "DefaultBaseUrl": true},
+		methodNames: names{"Client": true},
+		named:       map[*openapi3.Schema]string{},
+		errorModels: map[string]bool{},
+	}
+	var aliases []string
+	if doc.Components != nil {
+		// 组件优先占用名字
+		for _, key := range sortedKeys(doc.Components.Schemas) {
+			ref := doc.Components.Schemas[key]
+			if ref.Ref != "" {
+				aliases = append(aliases, key)
+				continue
+			}
+			g.namedType(ref.Value, key)
+		}
+		for _, alias := range aliases {
+			ref := doc.Components.Schemas[alias]
+			name := g.typeNames.unique(exportedName(alias))
+			fmt.Fprintf(&g.models, "type %s = %s\n\n", name, g.goType(ref, name))
+		}
+	}
+	if err := g.operations(); err != nil {
+		return nil, err
+	}
+	// 生成模型时可能继续登记内联类型
+	for i := 0; i < len(g.queue); i++ {
+		g.model(g.queue[i])
+	}
+	return g.source()
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// importName returns the name of an import in generated code.
+func (g *generator) importName(imp [2]string) string {
+	if imp[0] != "" {
+		return imp[0]
+	}
+	return imp[1][strings.LastIndex(imp[1], "/")+1:]
+}
+
+// use marks an import as used and returns its name.
+func (g *generator) use(path string) string {
+	g.used[path] = true
+	for _, imp := range knownImports {
+		if imp[1] == path {
+			return g.importName(imp)
+		}
+	}
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// source assembles the file and formats it.
+func (g *generator) source() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString("// Code generated by openapigen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", g.pkg)
+	var std, third []string
+	for _, imp := range knownImports {
+		if !g.used[imp[1]] {
+			continue
+		}
+		spec := fmt.Sprintf("\t%s %q\n", imp[0], imp[1])
+		if strings.Contains(imp[1], ".") {
+			third = append(third, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+	out.WriteString("import (\n")
+	out.WriteString(strings.Join(std, ""))
+	if len(std) > 0 && len(third) > 0 {
+		out.WriteString("\n")
+	}
+	out.WriteString(strings.Join(third, ""))
+	out.WriteString(")\n\n")
+	out.Write(g.models.Bytes())
+	out.Write(g.ops.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return out.Bytes(), fmt.Errorf("openapigen: format source: %w", err)
+	}
+	return src, nil
+}
+
+// comment writes the text as a comment, the first line starts with prefix.
+func comment(buf *bytes.Buffer, indent, prefix, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		if prefix != "" {
+			fmt.Fprintf(buf, "%s// %s\n", indent, strings.TrimSpace(prefix))
+		}
+		return
+	}
+	for i, line := range strings.Split(text, "\n") {
+		if i == 0 {
+			line = prefix + line
+		}
+		fmt.Fprintf(buf, "%s// %s\n", indent, strings.TrimRight(line, " \t\r"))
+	}
+}
+
+// schemaType returns the JSON type of the schema, ignoring "null".
+func schemaType(s *openapi3.Schema) string {
+	if s.Type == nil {
+		if len(s.Properties) > 0 {
+			return openapi3.TypeObject
+		}
+		return ""
+	}
+	for _, t := range s.Type.Slice() {
+		if t != openapi3.TypeNull {
+			return t
+		}
+	}
+	return ""
+}
+
+// nullable reports whether the schema allows null.
+func nullable(s *openapi3.Schema) bool {
+	return s.Nullable || s.Type != nil && s.Type.IncludesNull()
+}
+
+// isStruct reports whether the schema is generated as a struct.
+func isStruct(s *openapi3.Schema) bool {
+	return len(s.Enum) == 0 && (len(s.Properties) > 0 || len(s.AllOf) > 1 || len(s.AllOf) == 1 && len(s.Properties) > 0)
+}
+
+// namedType returns the name of the schema, registering it to be generated with a name from hint.
+func (g *generator) namedType(s *openapi3.Schema, hint string) string {
+	if name, ok := g.named[s]; ok {
+		return name
+	}
+	name := g.typeNames.unique(exportedName(hint))
+	g.named[s] = name
+	g.queue = append(g.queue, model{name: name, schema: s})
+	return name
+}
+
+// goType returns the Go type of the schema, inline enums and objects get a type named after hint.
+func (g *generator) goType(ref *openapi3.SchemaRef, hint string) string {
+	if ref == nil || ref.Value == nil {
+		return "any"
+	}
+	s := ref.Value
+	if name, ok := g.named[s]; ok {
+		return name
+	}
+	if len(s.AllOf) == 1 && len(s.Properties) == 0 {
+		return g.goType(s.AllOf[0], hint)
+	}
+	if len(s.OneOf) > 0 || len(s.AnyOf) > 0 {
+		return g.use("encoding/json") + ".RawMessage"
+	}
+	if len(s.Enum) > 0 || isStruct(s) {
+		return g.namedType(s, hint)
+	}
+	return g.plainType(s, hint)
+}
+
+// plainType returns the Go type of a schema which is not an enum or a struct.
+func (g *generator) plainType(s *openapi3.Schema, hint string) string {
+	switch schemaType(s) {
+	case openapi3.TypeString:
+		switch s.Format {
+		case "date-time":
+			return g.use("time") + ".Time"
+		case "byte", "binary":
+			return "[]byte"
+		}
+		return "string"
+	case openapi3.TypeInteger:
+		switch s.Format {
+		case "int32":
+			return "int32"
+		case "int64":
+			return "int64"
+		}
+		return "int"
+	case openapi3.TypeNumber:
+		if s.Format == "float" {
+			return "float32"
+		}
+		return "float64"
+	case openapi3.TypeBoolean:
+		return "bool"
+	case openapi3.TypeArray:
+		return "[]" + g.goType(s.Items, hint+"Item")
+	case openapi3.TypeObject:
+		if ap := s.AdditionalProperties.Schema; ap != nil {
+			return "map[string]" + g.goType(ap, hint+"Value")
+		}
+		return "map[string]any"
+	}
+	return "any"
+}
+
+// pointable reports whether an optional value of the type is a pointer.
+func pointable(typ string) bool {
+	return !strings.HasPrefix(typ, "[]") && !strings.HasPrefix(typ, "map[") && typ != "any" && !strings.HasSuffix(typ, ".RawMessage")
+}
+
+// model writes the type of a named schema.
+func (g *generator) model(m model) {
+	s := m.schema
+	title := s.Description
+	if title == "" {
+		title = s.Title
+	}
+	if title != "" {
+		comment(&g.models, "", m.name+" ", title)
+	}
+	switch {
+	case len(s.Enum) > 0:
+		g.enum(m.name, s)
+	case isStruct(s):
+		g.structType(m.name, s)
+	default:
+		fmt.Fprintf(&g.models, "type %s %s\n\n", m.name, g.plainType(s, m.name))
+	}
+	if g.errorModels[m.name] {
+		fmt.Fprintf(&g.models, "// Error implements error.\nfunc (e *%s) Error() string {\n\tdata, _ := %s.Marshal(e)\n\treturn string(data)\n}\n\n",
+			m.name, g.use("encoding/json"))
+	}
+}
+
+// enum writes a named type with a constant of each value.
+func (g *generator) enum(name string, s *openapi3.Schema) {
+	base := "string"
+	switch schemaType(s) {
+	case openapi3.TypeInteger:
+		base = "int"
+	case openapi3.TypeNumber:
+		base = "float64"
+	case openapi3.TypeBoolean:
+		base = "bool"
+	}
+	fmt.Fprintf(&g.models, "type %s %s\n\nconst (\n", name, base)
+	for _, v := range s.Enum {
+		if v == nil {
+			continue
+		}
+		literal := fmt.Sprint(v)
+		if base == "string" {
+			literal = strconv.Quote(literal)
+		}
+		fmt.Fprintf(&g.models, "\t%s %s = %s\n", g.typeNames.unique(name+exportedName(fmt.Sprint(v))), name, literal)
+	}
+	g.models.WriteString(")\n\n")
+}
+
+// structType writes a struct, the named parts of allOf are embedded and the inline ones merged.
+func (g *generator) structType(name string, s *openapi3.Schema) {
+	var embedded []string
+	properties := openapi3.Schemas{}
+	required := map[string]bool{}
+	var merge func(s *openapi3.Schema)
+	merge = func(s *openapi3.Schema) {
+		for _, part := range s.AllOf {
+			if part.Value == nil {
+				continue
+			}
+			if n, ok := g.named[part.Value]; ok {
+				embedded = append(embedded, n)
+				continue
+			}
+			if part.Ref != "" && isStruct(part.Value) {
+				embedded = append(embedded, g.namedType(part.Value, part.Ref[strings.LastIndexByte(part.Ref, '/')+1:]))
+				continue
+			}
+			merge(part.Value)
+		}
+		for k, v := range s.Properties {
+			properties[k] = v
+		}
+		for _, k := range s.Required {
+			required[k] = true
+		}
+	}
+	merge(s)
+
+	fields := names{}
+	var body bytes.Buffer
+	for _, e := range embedded {
+		fields[e] = true
+		fmt.Fprintf(&body, "\t%s\n", e)
+	}
+	for _, key := range sortedKeys(properties) {
+		prop := properties[key]
+		field := fields.unique(exportedName(key))
+		typ := g.goType(prop, name+field)
+		tag := key
+		if !required[key] {
+			tag += ",omitempty"
+		}
+		if (!required[key] || prop.Value != nil && nullable(prop.Value)) && pointable(typ) {
+			typ = "*" + typ
+		}
+		if prop.Value != nil {
+			comment(&body, "\t", "", prop.Value.Description)
+		}
+		fmt.Fprintf(&body, "\t%s %s `json:%q`\n", field, typ, tag)
+	}
+	fmt.Fprintf(&g.models, "type %s struct {\n%s}\n\n", name, body.String())
+	if fields["Error"] {
+		// 字段名与 Error 方法冲突
+		delete(g.errorModels, name)
+	}
+}
+
+// operations writes the client and a method of each operation.
+func (g *generator) operations() error {
+	baseUrl := ""
+	if len(g.doc.Servers) > 0 {
+		server := g.doc.Servers[0]
+		baseUrl = server.URL
+		for k, v := range server.Variables {
+			baseUrl = strings.ReplaceAll(baseUrl, "{"+k+"}", v.Default)
+		}
+		baseUrl = strings.TrimSuffix(baseUrl, "/")
+	}
+	title := ""
+	if g.doc.Info != nil {
+		title = strings.TrimSpace(g.doc.Info.Title + " " + g.doc.Info.Version)
+	}
+	clientPkg := g.use("github.com/hopeio/gox/net/http/client")
+	fmt.Fprintf(&g.ops, "// DefaultBaseUrl is the first server of the document.\nconst DefaultBaseUrl = %q\n\n", baseUrl)
+	fmt.Fprintf(&g.ops, "// Client is the client of %s.\ntype Client struct {\n\t*%s.Client\n}\n\n", title, clientPkg)
+	fmt.Fprintf(&g.ops, "// NewClient creates and returns a new instance, baseUrl default DefaultBaseUrl.\n"+
+		"func NewClient(baseUrl string) *Client {\n\tif baseUrl == \"\" {\n\t\tbaseUrl = DefaultBaseUrl\n\t}\n"+
+		"\treturn &Client{Client: %s.New().BaseUrl(baseUrl)}\n}\n\n", clientPkg)
+
+	if g.doc.Paths == nil {
+		return nil
+	}
+	paths := g.doc.Paths.Map()
+	for _, path := range sortedKeys(paths) {
+		item := paths[path]
+		operations := item.Operations()
+		for _, method := range methodOrder {
+			if op := operations[method]; op != nil {
+				if err := g.operation(path, method, item, op); err != nil {
+					return fmt.Errorf("openapigen: %s %s: %w", method, path, err)
+				}
+			}
+		}
+	}
+	if g.decodeError {
+		fmt.Fprintf(&g.ops, `// decodeError decodes the body of a client.HTTPError into the error model of its status.
+func decodeError(err error, model func(status int) any) error {
+	var httpErr *%s.HTTPError
+	if !%s.As(err, &httpErr) || len(httpErr.Body) == 0 {
+		return err
+	}
+	if m := model(httpErr.StatusCode); m != nil && %s.Unmarshal(httpErr.Body, m) == nil {
+		httpErr.Model = m
+	}
+	return err
+}
+`, clientPkg, g.use("errors"), g.use("encoding/json"))
+	}
+	return nil
+}
+
+// isJSON reports whether the media type is JSON.
+func isJSON(mime string) bool {
+	mime, _, _ = strings.Cut(mime, ";")
+	return mime == "application/json" || strings.HasSuffix(mime, "+json")
+}
+
+// pickContent returns the preferred media type, JSON first.
+func pickContent(content openapi3.Content) (string, *openapi3.MediaType) {
+	keys := sortedKeys(content)
+	for _, k := range keys {
+		if isJSON(k) {
+			return k, content[k]
+		}
+	}
+	for _, k := range keys {
+		if strings.HasPrefix(k, "application/x-www-form-urlencoded") {
+			return k, content[k]
+		}
+	}
+	if len(keys) > 0 {
+		return keys[0], content[keys[0]]
+	}
+	return "", nil
+}
+
+// statusMatch returns the condition of a response code like 404, 4XX or default.
+func statusMatch(code string) string {
+	if len(code) == 3 && strings.HasSuffix(strings.ToUpper(code), "XX") {
+		low := int(code[0]-'0') * 100
+		return fmt.Sprintf("status >= %d && status < %d", low, low+100)
+	}
+	return "status == " + code
+}
+
+// isSuccess reports whether a response code is 2xx.
+func isSuccess(code string) bool {
+	return len(code) == 3 && code[0] == '2'
+}
+
+// operation writes the method of an operation.
+func (g *generator) operation(path, method string, item *openapi3.PathItem, op *openapi3.Operation) error {
+	name := op.OperationID
+	if name == "" {
+		name = strings.ToLower(method) + " " + path
+	}
+	name = g.methodNames.unique(exportedName(name))
+
+	// 操作级参数覆盖路径级同名参数
+	var params []*openapi3.Parameter
+	index := map[string]int{}
+	for _, refs := range []openapi3.Parameters{item.Parameters, op.Parameters} {
+		for _, ref := range refs {
+			p := ref.Value
+			if p == nil {
+				continue
+			}
+			if i, ok := index[p.In+":"+p.Name]; ok {
+				params[i] = p
+				continue
+			}
+			index[p.In+":"+p.Name] = len(params)
+			params = append(params, p)
+		}
+	}
+
+	args := names{"c": true, "ctx": true, "params": true, "body": true, "param": true, "u": true, "req": true, "resp": true, "err": true}
+	// 参数不能遮蔽生成代码用到的包
+	for _, imp := range knownImports {
+		args[g.importName(imp)] = true
+	}
+	signature := []string{"ctx " + g.use("context") + ".Context"}
+	pathArgs := map[string]string{}
+	var pathTypes = map[string]string{}
+	var queryParams, headerParams []*openapi3.Parameter
+	for _, p := range params {
+		switch p.In {
+		case openapi3.ParameterInPath:
+			arg := args.unique(unexportedName(p.Name))
+			typ := g.goType(p.Schema, name+exportedName(p.Name))
+			pathArgs[p.Name], pathTypes[p.Name] = arg, typ
+			signature = append(signature, arg+" "+typ)
+		case openapi3.ParameterInQuery:
+			queryParams = append(queryParams, p)
+		case openapi3.ParameterInHeader:
+			headerParams = append(headerParams, p)
+		}
+	}
+
+	// query 和 header 参数
+	var paramsType string
+	headerFields := map[string]string{}
+	if len(queryParams)+len(headerParams) > 0 {
+		paramsType = g.typeNames.unique(name + "Params")
+		fields := names{}
+		var body bytes.Buffer
+		for _, p := range append(queryParams, headerParams...) {
+			field := fields.unique(exportedName(p.Name))
+			typ := g.goType(p.Schema, name+field)
+			if !p.Required && pointable(typ) {
+				typ = "*" + typ
+			}
+			comment(&body, "\t", "", p.Description)
+			if p.In == openapi3.ParameterInQuery {
+				fmt.Fprintf(&body, "\t%s %s `query:%q`\n", field, typ, p.Name)
+			} else {
+				headerFields[p.Name] = field
+				fmt.Fprintf(&body, "\t%s %s `query:\"-\" header:%q`\n", field, typ, p.Name)
+			}
+		}
+		fmt.Fprintf(&g.ops, "// %s are the parameters of Client.%s.\ntype %s struct {\n%s}\n\n", paramsType, name, paramsType, body.String())
+		signature = append(signature, "params *"+paramsType)
+	}
+
+	// 请求体
+	var bodyMime, bodyType string
+	if op.RequestBody != nil && op.RequestBody.Value != nil {
+		mime, media := pickContent(op.RequestBody.Value.Content)
+		switch {
+		case media == nil:
+		case isJSON(mime) || strings.HasPrefix(mime, "application/x-www-form-urlencoded"):
+			bodyMime = mime
+			bodyType = g.goType(media.Schema, name+"Request")
+			if pointable(bodyType) {
+				bodyType = "*" + bodyType
+			}
+		default:
+			bodyMime = mime
+			bodyType = g.use("io") + ".Reader"
+		}
+		if bodyType != "" {
+			signature = append(signature, "body "+bodyType)
+		}
+	}
+
+	// 响应和错误模型
+	var resultType string
+	raw := false
+	responses := map[string]*openapi3.ResponseRef{}
+	if op.Responses != nil {
+		responses = op.Responses.Map()
+	}
+	codes := sortedKeys(responses)
+	for _, code := range codes {
+		resp := responses[code].Value
+		if !isSuccess(code) || resp == nil {
+			continue
+		}
+		if mime, media := pickContent(resp.Content); media != nil {
+			if isJSON(mime) {
+				resultType = g.goType(media.Schema, name+"Response")
+			} else {
+				raw = true
+			}
+		}
+		break
+	}
+	// 具体状态码优先于 4XX,最后是 default
+	slices.SortStableFunc(codes, func(a, b string) int {
+		rank := func(code string) int {
+			switch {
+			case code == "default":
+				return 2
+			case strings.HasSuffix(strings.ToUpper(code), "XX"):
+				return 1
+			}
+			return 0
+		}
+		return rank(a) - rank(b)
+	})
+	var errorCases bytes.Buffer
+	fallback := ""
+	for _, code := range codes {
+		resp := responses[code].Value
+		if isSuccess(code) || resp == nil {
+			continue
+		}
+		mime, media := pickContent(resp.Content)
+		if media == nil || !isJSON(mime) {
+			continue
+		}
+		typ := g.goType(media.Schema, name+code+"Error")
+		if !token.IsIdentifier(typ) || !g.isNamed(typ) {
+			continue
+		}
+		g.errorModels[typ] = true
+		if code == "default" {
+			fallback = typ
+			continue
+		}
+		fmt.Fprintf(&errorCases, "\t\tcase %s:\n\t\t\treturn new(%s)\n", statusMatch(code), typ)
+	}
+	errorReturn := "err"
+	if errorCases.Len() > 0 || fallback != "" {
+		g.decodeError = true
+		var fn bytes.Buffer
+		fn.WriteString("decodeError(err, func(status int) any {\n")
+		if errorCases.Len() > 0 {
+			fmt.Fprintf(&fn, "\t\tswitch {\n%s\t\t}\n", errorCases.String())
+		}
+		if fallback != "" {
+			fmt.Fprintf(&fn, "\t\treturn new(%s)\n", fallback)
+		} else {
+			fn.WriteString("\t\treturn nil\n")
+		}
+		fn.WriteString("\t})")
+		errorReturn = fn.String()
+	}
+
+	// 切片和 map 直接返回值, 不必再取指针
+	byValue := strings.HasPrefix(resultType, "[]") || strings.HasPrefix(resultType, "map[")
+	results := "error"
+	switch {
+	case byValue:
+		results = "(" + resultType + ", error)"
+	case resultType != "":
+		results = "(*" + resultType + ", error)"
+	case raw:
+		results = "([]byte, error)"
+	}
+
+	// 方法
+	if op.Summary != "" {
+		comment(&g.ops, "", name+" ", op.Summary)
+		g.ops.WriteString("//\n")
+	}
+	fmt.Fprintf(&g.ops, "// %s calls %s %s.\n", name, method, path)
+	if op.Description != "" && op.Description != op.Summary {
+		g.ops.WriteString("//\n")
+		comment(&g.ops, "", "", op.Description)
+	}
+	if op.Deprecated {
+		g.ops.WriteString("//\n// Deprecated: the operation is deprecated.\n")
+	}
+	fmt.Fprintf(&g.ops, "func (c *Client) %s(%s) %s {\n", name, strings.Join(signature, ", "), results)
+	fmt.Fprintf(&g.ops, "\tu := %s\n", g.pathExpr(path, pathArgs, pathTypes))
+	if len(queryParams) > 0 {
+		fmt.Fprintf(&g.ops, "\tu = %s.AppendQueryParamByTag(u, params, \"query\")\n", g.use("github.com/hopeio/gox/net/url"))
+	}
+	fmt.Fprintf(&g.ops, "\treq := c.Client.Request(%q, u).Context(ctx)\n", method)
+	if len(headerParams) > 0 {
+		g.ops.WriteString("\tif params != nil {\n")
+		for _, p := range headerParams {
+			field := headerFields[p.Name]
+			typ := g.goType(p.Schema, name+field)
+			value := "params." + field
+			if !p.Required && pointable(typ) {
+				fmt.Fprintf(&g.ops, "\t\tif %s != nil {\n\t\t\treq.AddHeader(%q, %s.Sprint(*%s))\n\t\t}\n", value, p.Name, g.use("fmt"), value)
+			} else {
+				fmt.Fprintf(&g.ops, "\t\treq.AddHeader(%q, %s.Sprint(%s))\n", p.Name, g.use("fmt"), value)
+			}
+		}
+		g.ops.WriteString("\t}\n")
+	}
+	param := "nil"
+	if bodyType != "" {
+		param = "body"
+		switch {
+		case strings.HasPrefix(bodyMime, "application/x-www-form-urlencoded"):
+			fmt.Fprintf(&g.ops, "\treq.ContentType(%s.ContentTypeForm)\n", g.use("github.com/hopeio/gox/net/http/client"))
+		case !isJSON(bodyMime):
+			fmt.Fprintf(&g.ops, "\treq.AddHeader(\"Content-Type\", %q)\n", bodyMime)
+		}
+		if strings.HasPrefix(bodyType, "*") {
+			// nil 指针不能发送 "null"
+			g.ops.WriteString("\tvar param any\n\tif body != nil {\n\t\tparam = body\n\t}\n")
+			param = "param"
+		}
+	}
+	switch {
+	case byValue:
+		fmt.Fprintf(&g.ops, "\tresp, err := req.DoAs[%s](%s)\n\tif err != nil {\n\t\treturn nil, %s\n\t}\n\treturn *resp, nil\n}\n\n",
+			resultType, param, errorReturn)
+	case resultType != "":
+		fmt.Fprintf(&g.ops, "\tresp, err := req.DoAs[%s](%s)\n\tif err != nil {\n\t\treturn nil, %s\n\t}\n\treturn resp, nil\n}\n\n",
+			resultType, param, errorReturn)
+	case raw:
+		fmt.Fprintf(&g.ops, "\tresp, err := req.DoRaw(%s)\n\tif err != nil {\n\t\treturn nil, %s\n\t}\n\treturn resp, nil\n}\n\n",
+			param, errorReturn)
+	default:
+		fmt.Fprintf(&g.ops, "\tif err := req.Do(%s, nil); err != nil {\n\t\treturn %s\n\t}\n\treturn nil\n}\n\n", param, errorReturn)
+	}
+	return nil
+}
+
+// isNamed reports whether typ is a generated model.
+func (g *generator) isNamed(typ string) bool {
+	for _, name := range g.named {
+		if name == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// pathExpr returns the expression of the path with the escaped arguments.
+func (g *generator) pathExpr(path string, args, types map[string]string) string {
+	var parts []string
+	for path != "" {
+		start := strings.IndexByte(path, '{')
+		end := strings.IndexByte(path, '}')
+		if start < 0 || end < start {
+			parts = append(parts, strconv.Quote(path))
+			break
+		}
+		if start > 0 {
+			parts = append(parts, strconv.Quote(path[:start]))
+		}
+		param := path[start+1 : end]
+		path = path[end+1:]
+		arg, ok := args[param]
+		if !ok {
+			parts = append(parts, strconv.Quote("{"+param+"}"))
+			continue
+		}
+		value := arg
+		if types[param] != "string" {
+			value = g.use("fmt") + ".Sprint(" + arg + ")"
+		}
+		parts = append(parts, g.use("net/url")+".PathEscape("+value+")")
+	}
+	if len(parts) == 0 {
+		return `""`
+	}
+	return strings.Join(parts, " + ")
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+func TestGenerate(t *testing.T) {
+	doc, err := openapi3.NewLoader().LoadFromFile("testdata/petstore.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = doc.Validate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	src, err := Generate(doc, "petstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := format.Source(src)
+	if err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, src)
+	}
+	if !bytes.Equal(formatted, src) {
+		t.Error("generated code is not gofmt formatted")
+	}
+	for _, want := range []string{
+		"func (c *Client) ListPets(ctx context.Context, params *ListPetsParams) ([]Pet, error) {",
+		"func (c *Client) CreatePet(ctx context.Context, body *Pet) (*Pet, error) {",
+		"func (c *Client) GetPet(ctx context.Context, petID int64) (*Pet, error) {",
+		"func (c *Client) CountTags(ctx context.Context) (map[string]int, error) {",
+		"type PetStatus string",
+	} {
+		if !bytes.Contains(src, []byte(want)) {
+			t.Errorf("missing %q", want)
+		}
+	}
+
+	if testing.Short() {
+		t.Skip("compiling the generated code needs the go tool")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	// 目录在模块内, 生成代码才能引用本模块的包; 以 _ 开头的目录不参与 ./...
+	dir, err := os.MkdirTemp(".", "_generated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err = os.WriteFile(filepath.Join(dir, "petstore_client.go"), src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.CommandContext(t.Context(), goTool, "vet", ".")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("generated code does not compile: %v\n%s\n%s", err, out, numbered(src))
+	}
+}
+
+// numbered returns src with line numbers for compile errors.
+func numbered(src []byte) string {
+	var b strings.Builder
+	for i, line := range strings.Split(string(src), "\n") {
+		fmt.Fprintf(&b, "%4d  %s\n", i+1, line)
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+// openapigen generates Go models and a typed client.Client from an OpenAPI 3 document.
+//
+//	//go:generate go run github.com/hopeio/gox/tools/openapigen --package=petstore petstore.yaml
+//
+// Components and inline objects become structs, enums become named types with constants.
+// Every operation becomes a method of Client: path parameters are arguments, query and header
+// parameters are fields of a <Operation>Params struct bound by its `query` tags, the JSON body is
+// an argument and the first 2xx JSON response is the result. Error responses are decoded into their
+// models as client.HTTPError.Model, reachable with errors.As. Cookie parameters are not supported.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pkgName string
+	output  string
+)
+
+var command = &cobra.Command{
+	Use:   "openapigen <spec>",
+	Short: "generate Go models and a typed client from an OpenAPI 3 document",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loader := openapi3.NewLoader()
+		loader.IsExternalRefsAllowed = true
+		doc, err := loader.LoadFromFile(args[0])
+		if err != nil {
+			return fmt.Errorf("openapigen: load %s: %w", args[0], err)
+		}
+		if err = doc.Validate(context.Background()); err != nil {
+			return fmt.Errorf("openapigen: invalid document: %w", err)
+		}
+		if pkgName == "" {
+			pkgName = "api"
+		}
+		src, err := Generate(doc, pkgName)
+		if err != nil {
+			return err
+		}
+		if output == "" {
+			name := filepath.Base(args[0])
+			output = strings.TrimSuffix(name, filepath.Ext(name)) + "_client.go"
+		}
+		return os.WriteFile(output, src, 0644)
+	},
+}
+
+func init() {
+	command.Flags().StringVar(&pkgName, "package", "", "package name of the generated file; default api")
+	command.Flags().StringVarP(&output, "output", "o", "", "output file name; default <spec>_client.go")
+}
+
+// main is the program entry point.
+func main() {
+	if err := command.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package main
+
+import (
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// commonInitialisms are written in upper case, like golint.
+var commonInitialisms = map[string]bool{
+	"API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "EOF": true, "GUID": true, "HTML": true,
+	"HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true, "JWT": true, "OS": true, "SQL": true,
+	"SSH": true, "TCP": true, "TLS": true, "TTL": true, "UDP": true, "UI": true, "UID": true, "URI": true,
+	"URL": true, "UTF8": true, "UUID": true, "XML": true,
+}
+
+// words splits an identifier of any style at non alphanumeric characters and lower to upper case changes.
+func words(s string) []string {
+	var ws []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			ws = append(ws, string(cur))
+			cur = cur[:0]
+		}
+	}
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if i > 0 && unicode.IsUpper(r) && len(cur) > 0 {
+			prev := runes[i-1]
+			// 小写转大写,或 "HTTPServer" 中 S 前断开
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+				flush()
+			}
+		}
+		cur = append(cur, r)
+	}
+	flush()
+	return ws
+}
+
+// exportedName returns the exported Go identifier of s.
+func exportedName(s string) string {
+	var b strings.Builder
+	for _, w := range words(s) {
+		if upper := strings.ToUpper(w); commonInitialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(w)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+// unexportedName returns the unexported Go identifier of s, it is never a keyword.
+func unexportedName(s string) string {
+	ws := words(s)
+	if len(ws) == 0 {
+		return "x"
+	}
+	name := exportedName(s)
+	first := exportedName(ws[0])
+	if strings.HasPrefix(name, first) {
+		name = strings.ToLower(first) + name[len(first):]
+	}
+	if token.IsKeyword(name) || unicode.IsDigit([]rune(name)[0]) {
+		name += "_"
+	}
+	return name
+}
+
+// names assigns unique identifiers.
+type names map[string]bool
+
+// unique returns name, or name with the smallest number suffix not taken yet.
+func (n names) unique(name string) string {
+	candidate := name
+	for i := 2; n[candidate]; i++ {
+		candidate = name + strconv.Itoa(i)
+	}
+	n[candidate] = true
+	return candidate
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	caCertFile = "ca.pem"
+	caKeyFile  = "ca-key.pem"
+	// leafValidity 叶子证书有效期, 过期前一天重新签发
+	leafValidity = 30 * 24 * time.Hour
+)
+
+// CA is the root certificate authority signing the leaf certificates of intercepted hosts.
+type CA struct {
+	Cert    *x509.Certificate
+	CertPEM []byte
+	key     crypto.Signer
+	// leafKey 所有叶子证书共用一个密钥, 避免每个主机生成密钥
+	leafKey *ecdsa.PrivateKey
+	mu      sync.Mutex
+	leaves  map[string]*tls.Certificate
+}
+
+// LoadOrCreateCA loads ca.pem and ca-key.pem of dir, a new CA is generated and saved when they do not exist.
+func LoadOrCreateCA(dir string) (ca *CA, created bool, err error) {
+	certPEM, err := os.ReadFile(filepath.Join(dir, caCertFile))
+	if errors.Is(err, os.ErrNotExist) {
+		ca, err = createCA(dir)
+		return ca, err == nil, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(dir, caKeyFile))
+	if err != nil {
+		return nil, false, err
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, false, fmt.Errorf("load ca: %w", err)
+	}
+	signer, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok || !pair.Leaf.IsCA {
+		return nil, false, fmt.Errorf("load ca: %s is not a certificate authority", filepath.Join(dir, caCertFile))
+	}
+	ca, err = newCA(pair.Leaf, certPEM, signer)
+	return ca, false, err
+}
+
+// createCA generates a CA and saves it to dir, the key is only readable by the owner.
+func createCA(dir string) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber(),
+		Subject:               pkix.Name{Organization: []string{"gox debug proxy"}, CommonName: "gox debug proxy CA " + hostname},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(filepath.Join(dir, caKeyFile), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(filepath.Join(dir, caCertFile), certPEM, 0644); err != nil {
+		return nil, err
+	}
+	return newCA(cert, certPEM, key)
+}
+
+// newCA creates and returns a new instance.
+func newCA(cert *x509.Certificate, certPEM []byte, key crypto.Signer) (*CA, error) {
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, CertPEM: certPEM, key: key, leafKey: leafKey, leaves: make(map[string]*tls.Certificate)}, nil
+}
+
+// Leaf returns the cached certificate of host, a new one is signed when there is none or it expires soon.
+func (ca *CA) Leaf(host string) (*tls.Certificate, error) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if leaf, ok := ca.leaves[host]; ok && time.Until(leaf.Leaf.NotAfter) > 24*time.Hour {
+		return leaf, nil
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serialNumber(),
+		Subject:      pkix.Name{Organization: []string{"gox debug proxy"}, CommonName: host},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(leafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, ca.leafKey.Public(), ca.key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	leaf := &tls.Certificate{Certificate: [][]byte{der, ca.Cert.Raw}, PrivateKey: ca.leafKey, Leaf: cert}
+	ca.leaves[host] = leaf
+	return leaf, nil
+}
+
+// TLSConfig returns the server config of an intercepted connection, host is used when the client sends no SNI.
+func (ca *CA) TLSConfig(host string) *tls.Config {
+	return &tls.Config{
+		// 只协商 HTTP/1.1, 解密后的连接由 http.Server 逐个请求处理
+		NextProtos: []string{"http/1.1"},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName != "" {
+				return ca.Leaf(hello.ServerName)
+			}
+			return ca.Leaf(host)
+		},
+	}
+}
+
+// serialNumber returns a random 128 bit serial number.
+func serialNumber() *big.Int {
+	serial, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	return serial
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+// proxy is a debugging HTTP/HTTPS forward proxy for inspecting the traffic of our own services during development.
+//
+//	go run github.com/hopeio/gox/tools/proxy --intercept='*.example.com' --rules=rules.json --har=session.har
+//	HTTPS_PROXY=http://localhost:8080 curl --cacert ~/.config/gox-proxy/ca.pem https://api.example.com/users
+//
+// Every exchange is logged as it completes. CONNECT requests are tunneled, except for the --intercept hosts
+// whose TLS is terminated with leaf certificates minted on demand by a local root CA, generated into --ca-dir
+// on the first run. Trust the CA only on development machines, it is also served at http://<addr>/ca.pem.
+// The recorded traffic is served to loopback clients at http://<addr>/har and written to --har on exit, the
+// recording is kept in memory. The proxy listens on 127.0.0.1 by default, anyone who can reach --addr can
+// use it. The rules of --rules (see Rule) mock, redirect and rewrite requests and responses, the file is
+// reloaded when it changes.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/hopeio/gox/log"
+	"github.com/hopeio/gox/net/http/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	addr      string
+	caDir     string
+	intercept []string
+	harFile   string
+	rulesFile string
+	insecure  bool
+	redact    bool
+)
+
+var command = &cobra.Command{
+	Use:   "proxy",
+	Short: "debugging HTTP/HTTPS forward proxy with TLS interception, HAR export and rewrite rules",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		// 不再经过环境变量中的代理, 避免指向自身
+		transport.Proxy = nil
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecure}
+		proxy := &Proxy{Intercept: intercept, Recorder: client.NewHARRecorder(transport), Transport: transport}
+		proxy.Recorder.Redact = logEntry
+		if !redact {
+			proxy.Recorder.RedactHeaders = []string{}
+		}
+		var err error
+		if len(intercept) > 0 {
+			var created bool
+			if proxy.CA, created, err = LoadOrCreateCA(caDir); err != nil {
+				return err
+			}
+			if created {
+				log.Infof("created root CA %s, trust it to intercept HTTPS", filepath.Join(caDir, caCertFile))
+			}
+		}
+		if rulesFile != "" {
+			if proxy.Rules, err = LoadRules(rulesFile); err != nil {
+				return err
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		server := &http.Server{Addr: addr, Handler: proxy}
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- server.ListenAndServe()
+		}()
+		log.Infof("proxy listening on %s", addr)
+		select {
+		case err = <-errCh:
+			return err
+		case <-ctx.Done():
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err = server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			log.Errorf("shutdown: %v", err)
+		}
+		if harFile != "" {
+			if err = proxy.Recorder.Save(harFile); err != nil {
+				return fmt.Errorf("save har: %w", err)
+			}
+			log.Infof("saved %d entries to %s", len(proxy.Recorder.HAR().Log.Entries), harFile)
+		}
+		return nil
+	},
+}
+
+// init registers the command line flags.
+func init() {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		configDir = "."
+	}
+	flags := command.Flags()
+	flags.StringVar(&addr, "addr", "127.0.0.1:8080", "listen address, listening on other interfaces opens the proxy and the recording to the network")
+	flags.StringVar(&caDir, "ca-dir", filepath.Join(configDir, "gox-proxy"), "directory of the root CA ca.pem and ca-key.pem, generated when missing")
+	flags.StringSliceVar(&intercept, "intercept", nil, "host globs whose HTTPS traffic is decrypted, '*' for every host")
+	flags.StringVar(&harFile, "har", "", "file the recorded traffic is written to on exit")
+	flags.StringVar(&rulesFile, "rules", "", "JSON file of rewrite rules")
+	flags.BoolVar(&insecure, "insecure", false, "skip verifying the certificates of upstreams")
+	flags.BoolVar(&redact, "redact", true, "mask Authorization and cookies in the recording")
+}
+
+// main is the program entry point.
+func main() {
+	if err := command.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
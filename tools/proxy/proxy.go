@@ -1,10 +1,298 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
 package main
 
 import (
-	"github.com/hopeio/gox/net/http"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+	"github.com/hopeio/gox/log"
+	httpx "github.com/hopeio/gox/net/http"
+	"github.com/hopeio/gox/net/http/client"
 )
 
-// main is the program entry point.
-func main() {
-	http.DirectorServer(":8080")
+// Proxy is an HTTP forward proxy, CONNECT requests of the intercepted hosts are decrypted with leaf
+// certificates signed by CA, the others are tunneled.
+type Proxy struct {
+	// CA nil 时不解密任何连接
+	CA *CA
+	// Intercept are the host globs of path.Match whose HTTPS traffic is decrypted, "*" decrypts everything
+	Intercept []string
+	Rules     *Rules
+	// Recorder records the exchanges with the upstreams, it is the HAR export
+	Recorder *client.HARRecorder
+	// Transport sends the upgraded requests, e.g. WebSocket, which are passed through without recording
+	Transport http.RoundTripper
+	once      sync.Once
+	proxy     *httputil.ReverseProxy
+}
+
+type rulesKey struct{}
+
+// ServeHTTP executes the operation.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodConnect:
+		if p.intercepts(r.Host) {
+			p.intercept(w, r)
+			return
+		}
+		log.Infof("TUNNEL %s", r.Host)
+		httpx.Tunneling(w, r)
+	case r.URL.IsAbs():
+		p.forward(w, r)
+	default:
+		p.serveLocal(w, r)
+	}
+}
+
+// intercepts reports whether the HTTPS traffic of the CONNECT authority is decrypted.
+func (p *Proxy) intercepts(authority string) bool {
+	if p.CA == nil {
+		return false
+	}
+	host, _, err := net.SplitHostPort(authority)
+	if err != nil {
+		host = authority
+	}
+	host = strings.ToLower(host)
+	for _, pattern := range p.Intercept {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// intercept answers the CONNECT request and serves the decrypted requests of the connection.
+func (p *Proxy) intercept(w http.ResponseWriter, r *http.Request) {
+	authority := r.Host
+	if _, _, err := net.SplitHostPort(authority); err != nil {
+		authority = net.JoinHostPort(authority, "443")
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err = io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		conn.Close()
+		return
+	}
+	if rw.Reader.Buffered() > 0 {
+		conn = &bufferedConn{Conn: conn, r: rw.Reader}
+	}
+	host, _, _ := net.SplitHostPort(authority)
+	log.Infof("INTERCEPT %s", authority)
+	tlsConn := tls.Server(conn, p.CA.TLSConfig(host))
+	listener := newConnListener(tlsConn)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.URL.Scheme, r.URL.Host = "https", authority
+			p.forward(w, r)
+		}),
+		// 连接关闭后 Accept 返回 net.ErrClosed, Serve 随之退出
+		ConnState: func(_ net.Conn, state http.ConnState) {
+			if state == http.StateClosed || state == http.StateHijacked {
+				listener.Close()
+			}
+		},
+	}
+	server.Serve(listener)
+}
+
+// forward sends the request with the absolute URL to the upstream, or answers it by a mock rule.
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request) {
+	rules := p.Rules.Match(r)
+	for _, rule := range rules {
+		if rule.Status != 0 {
+			rule.ResponseHeaders.Apply(w.Header())
+			w.Header().Set(httpx.HeaderContentLength, strconv.Itoa(len(rule.Body)))
+			w.WriteHeader(rule.Status)
+			io.WriteString(w, rule.Body)
+			log.Infof("MOCK %s %s %d %dB", r.Method, r.URL, rule.Status, len(rule.Body))
+			return
+		}
+	}
+	p.once.Do(p.init)
+	p.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), rulesKey{}, rules)))
+}
+
+// init creates the reverse proxy.
+func (p *Proxy) init() {
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			rules, _ := pr.In.Context().Value(rulesKey{}).([]*Rule)
+			for _, rule := range rules {
+				if rule.upstream != nil {
+					pr.Out.URL.Scheme, pr.Out.URL.Host, pr.Out.Host = rule.upstream.Scheme, rule.upstream.Host, ""
+				}
+				rule.RequestHeaders.Apply(pr.Out.Header)
+				if rule.rewritesBody() {
+					// 由 Transport 协商并解压, 规则处理的是明文
+					pr.Out.Header.Del(httpx.HeaderAcceptEncoding)
+				}
+			}
+		},
+		Transport:      p,
+		ModifyResponse: p.modifyResponse,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if r.Context().Err() != nil {
+				return
+			}
+			log.Errorf("ERROR %s %s: %v", r.Method, r.URL, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		},
+	}
+}
+
+// RoundTrip implements http.RoundTripper, upgraded requests bypass the recorder whose body can not be written.
+func (p *Proxy) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Upgrade") == "" {
+		return p.Recorder.RoundTrip(req)
+	}
+	start := time.Now()
+	resp, err := p.Transport.RoundTrip(req)
+	if err == nil {
+		log.Infof("UPGRADE %s %s %d %s", req.Method, req.URL, resp.StatusCode, time.Since(start).Round(time.Millisecond))
+	}
+	return resp, err
+}
+
+// modifyResponse applies the response rules.
+func (p *Proxy) modifyResponse(resp *http.Response) error {
+	rules, _ := resp.Request.Context().Value(rulesKey{}).([]*Rule)
+	var body []byte
+	for _, rule := range rules {
+		rule.ResponseHeaders.Apply(resp.Header)
+		if !rule.rewritesBody() {
+			continue
+		}
+		if body == nil {
+			data, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return err
+			}
+			body = data
+		}
+		var err error
+		if body, err = rule.rewriteBody(resp.Request.Context(), resp, body); err != nil {
+			return err
+		}
+	}
+	if body != nil {
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		resp.ContentLength = int64(len(body))
+		resp.Header.Set(httpx.HeaderContentLength, strconv.Itoa(len(body)))
+	}
+	return nil
+}
+
+// serveLocal answers the requests addressed to the proxy itself.
+func (p *Proxy) serveLocal(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/ca.pem":
+		if p.CA == nil {
+			http.Error(w, "interception is disabled", http.StatusNotFound)
+			return
+		}
+		w.Header().Set(httpx.HeaderContentType, "application/x-pem-file")
+		w.Write(p.CA.CertPEM)
+	case "/har":
+		// 录制的流量含请求体和凭证, 只提供给本机
+		if !isLoopback(r.RemoteAddr) {
+			http.Error(w, "the recording is only served to loopback clients", http.StatusForbidden)
+			return
+		}
+		data, err := jsonx.Marshal(p.Recorder.HAR())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(httpx.HeaderContentType, "application/json")
+		w.Header().Set(httpx.HeaderContentDisposition, `attachment; filename="proxy.har"`)
+		w.Write(data)
+	default:
+		http.Error(w, "this is a proxy, GET /ca.pem for the root certificate, GET /har for the recorded traffic", http.StatusNotFound)
+	}
+}
+
+// isLoopback reports whether the remote address is a loopback address.
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// logEntry prints a recorded exchange.
+func logEntry(entry *httpx.HAREntry) {
+	log.Infof("%s %s %d %s %dB", entry.Request.Method, entry.Request.URL, entry.Response.Status,
+		time.Duration(entry.Time*float64(time.Millisecond)).Round(time.Millisecond), entry.Response.Content.Size)
+}
+
+// bufferedConn reads the bytes buffered before hijacking first.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Read reads data.
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// connListener is a net.Listener accepting a single connection.
+type connListener struct {
+	conn   net.Conn
+	accept chan net.Conn
+	once   sync.Once
+	done   chan struct{}
+}
+
+// newConnListener creates and returns a new instance.
+func newConnListener(conn net.Conn) *connListener {
+	l := &connListener{conn: conn, accept: make(chan net.Conn, 1), done: make(chan struct{})}
+	l.accept <- conn
+	return l
+}
+
+// Accept returns the connection once, then blocks until the listener is closed.
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.accept:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes the listener, the accepted connection is closed by the server.
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+// Addr returns the local address of the connection.
+func (l *connListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
 }
@@ -0,0 +1,199 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsonx "github.com/hopeio/gox/encoding/json"
+	"github.com/hopeio/gox/log"
+	"github.com/hopeio/gox/net/http/gateway"
+)
+
+// Rule rewrites the matched requests and responses, the empty match fields match everything.
+//
+//	[
+//	  {"host": "*.example.com", "path": "^/api/", "upstream": "http://localhost:8080"},
+//	  {"host": "api.example.com", "method": "GET", "path": "^/flags$", "status": 200, "body": "{\"beta\":true}",
+//	   "responseHeaders": {"set": {"Content-Type": "application/json"}}},
+//	  {"path": "\\.js$", "replace": [{"pattern": "console\\.debug", "with": "console.log"}]},
+//	  {"host": "api.example.com", "exec": ["jq", ".items |= .[:3]"]}
+//	]
+type Rule struct {
+	// Host glob of path.Match, 比较时忽略端口
+	Host   string `json:"host"`
+	Method string `json:"method"`
+	// Path regular expression of the request path
+	Path string `json:"path"`
+	// Upstream sends the request to this scheme://host instead
+	Upstream        string               `json:"upstream"`
+	RequestHeaders  *gateway.HeaderRules `json:"requestHeaders"`
+	ResponseHeaders *gateway.HeaderRules `json:"responseHeaders"`
+	// Status 不为 0 时直接以 Status 和 Body 响应, 不请求上游
+	Status  int       `json:"status"`
+	Body    string    `json:"body"`
+	Replace []Replace `json:"replace"`
+	// Exec pipes the response body through the command, PROXY_URL and PROXY_STATUS are set in its environment
+	Exec []string `json:"exec"`
+
+	path     *regexp.Regexp
+	upstream *url.URL
+}
+
+// Replace replaces the matches of Pattern in the response body, With may refer to groups as $1.
+type Replace struct {
+	Pattern string `json:"pattern"`
+	With    string `json:"with"`
+	pattern *regexp.Regexp
+}
+
+// compile validates the rule.
+func (rule *Rule) compile() error {
+	var err error
+	if rule.Path != "" {
+		if rule.path, err = regexp.Compile(rule.Path); err != nil {
+			return err
+		}
+	}
+	if rule.Upstream != "" {
+		if rule.upstream, err = url.Parse(rule.Upstream); err != nil {
+			return err
+		}
+		if rule.upstream.Scheme == "" || rule.upstream.Host == "" {
+			return fmt.Errorf("upstream %q: missing scheme or host", rule.Upstream)
+		}
+	}
+	for i := range rule.Replace {
+		if rule.Replace[i].pattern, err = regexp.Compile(rule.Replace[i].Pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match reports whether the rule matches r, r.URL is absolute.
+func (rule *Rule) Match(r *http.Request) bool {
+	if rule.Method != "" && !strings.EqualFold(rule.Method, r.Method) {
+		return false
+	}
+	if rule.Host != "" {
+		host, _, err := net.SplitHostPort(r.URL.Host)
+		if err != nil {
+			host = r.URL.Host
+		}
+		if ok, _ := path.Match(strings.ToLower(rule.Host), strings.ToLower(host)); !ok {
+			return false
+		}
+	}
+	return rule.path == nil || rule.path.MatchString(r.URL.Path)
+}
+
+// rewritesBody reports whether the rule edits the response body.
+func (rule *Rule) rewritesBody() bool {
+	return len(rule.Replace) > 0 || len(rule.Exec) > 0
+}
+
+// rewriteBody returns the edited response body.
+func (rule *Rule) rewriteBody(ctx context.Context, resp *http.Response, body []byte) ([]byte, error) {
+	for _, replace := range rule.Replace {
+		body = replace.pattern.ReplaceAll(body, []byte(replace.With))
+	}
+	if len(rule.Exec) > 0 {
+		cmd := exec.CommandContext(ctx, rule.Exec[0], rule.Exec[1:]...)
+		cmd.Stdin = bytes.NewReader(body)
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(), "PROXY_URL="+resp.Request.URL.String(), "PROXY_STATUS="+strconv.Itoa(resp.StatusCode))
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("exec %s: %w", rule.Exec[0], err)
+		}
+		body = out
+	}
+	return body, nil
+}
+
+// Rules are the rules of a JSON file, the file is reloaded when it changes.
+type Rules struct {
+	file    string
+	mu      sync.Mutex
+	rules   []*Rule
+	modTime time.Time
+	checked time.Time
+}
+
+// LoadRules creates and returns a new instance.
+func LoadRules(file string) (*Rules, error) {
+	rules := &Rules{file: file}
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if err = rules.load(info.ModTime()); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// load reads the file.
+func (rs *Rules) load(modTime time.Time) error {
+	data, err := os.ReadFile(rs.file)
+	if err != nil {
+		return err
+	}
+	var rules []*Rule
+	if err = jsonx.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("%s: %w", rs.file, err)
+	}
+	for i, rule := range rules {
+		if err = rule.compile(); err != nil {
+			return fmt.Errorf("%s: rule %d: %w", rs.file, i, err)
+		}
+	}
+	rs.rules, rs.modTime = rules, modTime
+	return nil
+}
+
+// Match returns the rules matching r in file order, r.URL is absolute.
+func (rs *Rules) Match(r *http.Request) []*Rule {
+	if rs == nil {
+		return nil
+	}
+	rs.mu.Lock()
+	// 最多每秒检查一次文件修改, 解析失败时保留旧规则
+	if now := time.Now(); now.Sub(rs.checked) > time.Second {
+		rs.checked = now
+		if info, err := os.Stat(rs.file); err == nil && !info.ModTime().Equal(rs.modTime) {
+			if err = rs.load(info.ModTime()); err != nil {
+				log.Errorf("reload rules: %v", err)
+			} else {
+				log.Infof("reloaded %d rules from %s", len(rs.rules), rs.file)
+			}
+		}
+	}
+	all := rs.rules
+	rs.mu.Unlock()
+	var matched []*Rule
+	for _, rule := range all {
+		if rule.Match(r) {
+			matched = append(matched, rule)
+		}
+	}
+	return matched
+}